@@ -0,0 +1,383 @@
+package dolphin
+
+import (
+	"errors"
+	"io/ioutil"
+	"sync"
+
+	"github.com/DataDrake/waterlog"
+	"gitlab.com/EbonJaeger/dolphin/command"
+	"gitlab.com/EbonJaeger/dolphin/config"
+	"gitlab.com/EbonJaeger/dolphin/rcon"
+	"gitlab.com/EbonJaeger/dolphin/storage"
+)
+
+// Bridge connects a Minecraft server and a Discord channel. It owns the
+// config, logger, database, Discord bot, and command parser of one bridge,
+// so Dolphin can be used as a library and several bridges can run in the
+// same process.
+type Bridge struct {
+	bot  *DiscordBot
+	conf *config.RootConfig
+	// configMu makes changes to the config one at a time
+	configMu          sync.Mutex
+	db                *storage.DB
+	dial              rcon.Dialer
+	discordMiddleware []DiscordMiddleware
+	events            *EventBus
+	// file is the config file the config was loaded from, if any
+	file         *config.File
+	log          *waterlog.WaterLog
+	mcMiddleware []MinecraftMiddleware
+	// mu guards the config, the sinks, and the channels the bot uses
+	mu sync.RWMutex
+	// ownsDB is true if the bridge opened the database itself, and should
+	// close it when the bridge is closed
+	ownsDB   bool
+	parser   *command.Parser
+	reload   bool
+	reloader *ConfigReloader
+	// sinks are where messages can be sent, by name
+	sinks map[string]Sink
+	// sources are where messages come from, other than Minecraft and Discord
+	sources []Source
+}
+
+// Option changes how a Bridge is created.
+type Option func(*Bridge)
+
+// WithConfig sets the config of the bridge. Lists that aren't set are
+// given their default values, but settings that are false or zero are kept,
+// so a config should start from config.MergeDefaults(config.RootConfig{})
+// if it isn't loaded from a file.
+func WithConfig(conf config.RootConfig) Option {
+	return func(b *Bridge) {
+		c := config.FillDefaults(conf)
+		b.conf = &c
+	}
+}
+
+// WithConfigFile sets the file the config of the bridge was loaded from.
+// Changes made with the config command are saved to it, and the database is
+// kept next to it. Without one, changes are lost when the bridge closes.
+func WithConfigFile(file *config.File) Option {
+	return func(b *Bridge) {
+		b.file = file
+	}
+}
+
+// WithLogger sets the logger of the bridge. By default, nothing is logged.
+func WithLogger(log *waterlog.WaterLog) Option {
+	return func(b *Bridge) {
+		b.log = log
+	}
+}
+
+// WithDatabase sets the database the bridge keeps its data in. By default,
+// the database at the configured path is opened, and closed with the bridge.
+func WithDatabase(db *storage.DB) Option {
+	return func(b *Bridge) {
+		b.db = db
+	}
+}
+
+// WithRconDialer sets the function used to connect to the Minecraft server
+// over RCON. By default, rcon.Connect is used.
+func WithRconDialer(dial rcon.Dialer) Option {
+	return func(b *Bridge) {
+		b.dial = dial
+	}
+}
+
+// WithMinecraftMiddleware adds middleware that can change or drop messages
+// from Minecraft before they're sent to Discord. Middleware is run in the
+// order it was added.
+func WithMinecraftMiddleware(middleware ...MinecraftMiddleware) Option {
+	return func(b *Bridge) {
+		b.mcMiddleware = append(b.mcMiddleware, middleware...)
+	}
+}
+
+// WithDiscordMiddleware adds middleware that can change or drop messages
+// from Discord before they're sent to Minecraft. Middleware is run in the
+// order it was added.
+func WithDiscordMiddleware(middleware ...DiscordMiddleware) Option {
+	return func(b *Bridge) {
+		b.discordMiddleware = append(b.discordMiddleware, middleware...)
+	}
+}
+
+// WithSource adds somewhere messages come from, such as another chat
+// platform. Where its messages are sent is set by its name in the Routes
+// section of the config.
+func WithSource(source Source) Option {
+	return func(b *Bridge) {
+		b.sources = append(b.sources, source)
+	}
+}
+
+// WithSink adds somewhere messages can be sent, such as another chat
+// platform. Sources send messages to it by listing its name in the Routes
+// section of the config.
+func WithSink(sink Sink) Option {
+	return func(b *Bridge) {
+		b.sinks[sink.Name()] = sink
+	}
+}
+
+// WithConfigReloading makes the bridge reload the config file when it
+// changes. The file must be set with WithConfigFile.
+func WithConfigReloading() Option {
+	return func(b *Bridge) {
+		b.reload = true
+	}
+}
+
+// NewBridge creates a new bridge with the given options. The config is
+// checked for problems, but nothing is connected until Start is called.
+func NewBridge(options ...Option) (*Bridge, error) {
+	b := &Bridge{
+		dial:  rcon.Connect,
+		log:   waterlog.New(ioutil.Discard, "", 0),
+		sinks: make(map[string]Sink),
+	}
+	for _, option := range options {
+		option(b)
+	}
+	b.events = NewEventBus(b.log)
+
+	// Check that we have a usable config
+	if b.conf == nil {
+		return nil, errors.New("no config given")
+	}
+	if err := config.Validate(*b.conf); err != nil {
+		return nil, err
+	}
+	if b.reload && b.file == nil {
+		return nil, errors.New("config reloading needs a config file")
+	}
+
+	// Bridge IRC if it's enabled
+	if b.conf.IRC.Enabled {
+		irc := NewIRCClient(b.conf.IRC, b.log)
+		b.sources = append(b.sources, irc)
+		b.sinks[irc.Name()] = irc
+	}
+
+	// Routes can only use the sources and sinks we have
+	if err := b.checkRoutes(b.conf.Routes); err != nil {
+		return nil, err
+	}
+
+	// Open our database if we weren't given one
+	if b.db == nil {
+		path := b.databasePath()
+		b.log.Infof("Opening database at '%s'\n", path)
+		db, err := storage.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		b.db = db
+		b.ownsDB = true
+	}
+
+	b.parser = command.NewParser(b.conf, b.log, b.db,
+		command.WithConfigStore(configStore{bridge: b}),
+		command.WithConfigFile(b.file),
+		command.WithRconDialer(b.dial),
+		command.WithCommandHook(b.commandRan),
+	)
+	return b, nil
+}
+
+// Start connects to Discord and starts bridging messages.
+func (b *Bridge) Start() error {
+	b.log.Infoln("Creating Discord session")
+	bot, err := NewDiscordBot(b)
+	if err != nil {
+		return err
+	}
+	b.bot = bot
+
+	// Messages can be sent to Minecraft and Discord
+	b.mu.Lock()
+	b.sinks[minecraftName] = &minecraftSink{bridge: b}
+	b.sinks[discordName] = &discordSink{bot: bot}
+	b.mu.Unlock()
+
+	// Sessions left open when we last ran end when the players were last
+	// seen, and start again when we see them online
+	b.parser.CloseOpenSessions()
+
+	// Start watching Minecraft and the other sources for messages
+	go bot.WaitForMessages()
+	for _, source := range b.sources {
+		go b.listen(source)
+	}
+
+	// Reload the config when it changes
+	if b.reload {
+		if b.reloader, err = NewConfigReloader(bot); err != nil {
+			b.log.Errorf("Unable to watch the config file for changes: %s\n", err)
+		} else {
+			go b.reloader.Run()
+		}
+	}
+
+	return nil
+}
+
+// Close disconnects from Discord and stops everything the bridge started.
+func (b *Bridge) Close() error {
+	var closeErr error
+
+	if b.reloader != nil {
+		b.reloader.Close()
+	}
+	for _, source := range b.sources {
+		if err := source.Close(); err != nil {
+			closeErr = err
+		}
+	}
+	if b.bot != nil {
+		if err := b.bot.Close(); err != nil {
+			closeErr = err
+		}
+	}
+	b.events.Close()
+	if b.ownsDB {
+		if err := b.db.Close(); err != nil {
+			closeErr = err
+		}
+	}
+
+	return closeErr
+}
+
+// Config gets a copy of the current config of the bridge.
+func (b *Bridge) Config() config.RootConfig {
+	return *b.config()
+}
+
+// Events gets the event bus that everything happening in Minecraft and
+// Discord is published on.
+func (b *Bridge) Events() *EventBus {
+	return b.events
+}
+
+// config gets the live config of the bridge. It must not be changed in
+// place; changes are swapped in with updateConfig.
+func (b *Bridge) config() *config.RootConfig {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.conf
+}
+
+// updateConfig calls change with a copy of the config, and swaps the copy
+// in if change doesn't return an error. Changes are made one at a time, so
+// none of them are lost. If the Discord bot is running, it's changed along
+// with the config, so it never uses an old channel or console.
+func (b *Bridge) updateConfig(change func(c *config.RootConfig) error) error {
+	b.configMu.Lock()
+	defer b.configMu.Unlock()
+
+	old := *b.config()
+	updated := old
+	if err := change(&updated); err != nil {
+		return err
+	}
+
+	if b.bot != nil {
+		return b.bot.applyConfig(old, updated)
+	}
+
+	b.mu.Lock()
+	b.conf = &updated
+	b.mu.Unlock()
+	return nil
+}
+
+// configStore shares the live config of a bridge with its commands.
+type configStore struct {
+	bridge *Bridge
+}
+
+// Config gets the live config of the bridge.
+func (s configStore) Config() *config.RootConfig {
+	return s.bridge.config()
+}
+
+// UpdateConfig changes the config of the bridge.
+func (s configStore) UpdateConfig(change func(c *config.RootConfig) error) error {
+	return s.bridge.updateConfig(change)
+}
+
+// databasePath gets the path of the database to open. Unless another path
+// is configured, it's kept next to the config file, or in the working
+// directory if there is no config file.
+func (b *Bridge) databasePath() string {
+	if b.file == nil {
+		if b.conf.Storage.Path != "" {
+			return b.conf.Storage.Path
+		}
+		return "dolphin.db"
+	}
+	return b.file.DatabasePath(*b.conf)
+}
+
+// serverProperties gets the server.properties settings that were read when
+// the config was last loaded. If there is no config file or no server
+// directory is set, false is returned.
+func (b *Bridge) serverProperties() (config.ServerProperties, bool) {
+	if b.file == nil {
+		return config.ServerProperties{}, false
+	}
+	return b.file.ServerProperties()
+}
+
+// connect opens an RCON connection to the Minecraft server.
+func (b *Bridge) connect() (*rcon.Client, error) {
+	c := b.config()
+	return b.dial(c.Minecraft.RconIP, c.Minecraft.RconPort, c.Minecraft.RconPassword)
+}
+
+// publishMinecraft publishes an event for a message from Minecraft or
+// another source. The subscribers get a copy, so they can't change the
+// message being sent.
+func (b *Bridge) publishMinecraft(source string, m *MinecraftMessage) {
+	msg := *m
+	b.events.Publish(Event{Type: msg.EventType(), Timestamp: msg.Timestamp, Source: source, Minecraft: &msg})
+}
+
+// publishDiscord publishes an event for a message from Discord.
+func (b *Bridge) publishDiscord(m *DiscordMessage) {
+	msg := *m
+	b.events.Publish(Event{Type: DiscordMessageEvent, Timestamp: msg.Timestamp, Source: discordName, Discord: &msg})
+}
+
+// commandRan publishes an event for a command that was run from Discord.
+func (b *Bridge) commandRan(cmd command.DiscordCommand, err error) {
+	b.events.Publish(Event{Type: CommandEvent, Command: &cmd, Err: err})
+}
+
+// filterMinecraft runs a message from Minecraft or another source through
+// the middleware, and returns false if it should be dropped.
+func (b *Bridge) filterMinecraft(m *MinecraftMessage) bool {
+	for _, middleware := range b.mcMiddleware {
+		if !middleware(m) {
+			return false
+		}
+	}
+	return true
+}
+
+// filterDiscord runs a message from Discord through the middleware, and
+// returns false if it should be dropped.
+func (b *Bridge) filterDiscord(m *DiscordMessage) bool {
+	for _, middleware := range b.discordMiddleware {
+		if !middleware(m) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,160 @@
+package dolphin
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"gitlab.com/EbonJaeger/dolphin/config"
+	"gitlab.com/EbonJaeger/dolphin/rcon"
+)
+
+// setupBridge creates a config file with some contents in a temporary
+// directory, and a bridge that uses it. The returned function closes the
+// bridge and removes the directory.
+func setupBridge(t *testing.T, contents string) (*Bridge, string, func()) {
+	dir, err := ioutil.TempDir("", "dolphin")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %s", err)
+	}
+	path := filepath.Join(dir, "dolphin.conf")
+	if err := ioutil.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatalf("Failed to write config file: %s", err)
+	}
+
+	file, err := config.NewFile(path)
+	if err != nil {
+		t.Fatalf("Failed to create config file: %s", err)
+	}
+	c, err := file.Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %s", err)
+	}
+	b, err := NewBridge(WithConfig(c), WithConfigFile(file))
+	if err != nil {
+		t.Fatalf("Failed to create bridge: %s", err)
+	}
+
+	return b, path, func() {
+		b.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestNewBridgeNeedsConfig(t *testing.T) {
+	// When
+	_, err := NewBridge()
+
+	// Then
+	if err == nil {
+		t.Errorf("Creating a bridge without a config should fail")
+	}
+}
+
+func TestNewBridgeChecksRoutes(t *testing.T) {
+	// Given
+	c := config.MergeDefaults(config.RootConfig{})
+	c.Discord.ChannelID = "1234"
+	c.Minecraft.UseLogFile = false
+	c.Routes = config.RouteConfig{minecraftName: {"slack"}}
+
+	// When
+	_, err := NewBridge(WithConfig(c))
+
+	// Then
+	if err == nil || !strings.Contains(err.Error(), "Routes.minecraft[0]") {
+		t.Errorf("Routes to sinks the bridge doesn't have should fail, got: %v", err)
+	}
+}
+
+func TestBridgeUsesRconDialer(t *testing.T) {
+	// Given
+	c := config.MergeDefaults(config.RootConfig{})
+	c.Minecraft.RconIP = "mc.example.com"
+	c.Minecraft.RconPort = 25580
+	var dialed string
+	b := &Bridge{conf: &c}
+	WithRconDialer(func(host string, port int, password string) (*rcon.Client, error) {
+		dialed = host
+		return nil, errors.New("not connected")
+	})(b)
+
+	// When
+	_, err := b.connect()
+
+	// Then
+	if err == nil || dialed != "mc.example.com" {
+		t.Errorf("Bridge did not use the given RCON dialer, got host: %s", dialed)
+	}
+}
+
+func TestWithConfigKeepsFalseSettings(t *testing.T) {
+	// Given
+	c := config.MergeDefaults(config.RootConfig{})
+	c.Discord.MessageOptions = config.MessageConfig{}
+	c.Discord.Status.UpdatePresence = false
+	c.Minecraft.CustomDeathKeywords = nil
+	b := &Bridge{}
+
+	// When
+	WithConfig(c)(b)
+
+	// Then
+	if b.conf.Discord.MessageOptions != (config.MessageConfig{}) || b.conf.Discord.Status.UpdatePresence {
+		t.Errorf("Settings that are false should be kept, got: %+v", b.conf.Discord)
+	}
+	if b.conf.Minecraft.CustomDeathKeywords == nil {
+		t.Errorf("Lists that aren't set should get their defaults")
+	}
+}
+
+func TestBridgesUseTheirOwnConfigFiles(t *testing.T) {
+	// Given
+	serverDir, err := ioutil.TempDir("", "dolphin")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(serverDir)
+	ioutil.WriteFile(filepath.Join(serverDir, "server.properties"), []byte("enable-rcon=true\nrcon.port=25580\n"), 0600)
+
+	first, firstPath, cleanup := setupBridge(t, "[Discord]\nChannelID = \"1000\"\n[Minecraft]\nUseLogFile = false\nServerDir = "+strconv.Quote(serverDir)+"\n")
+	defer cleanup()
+	second, secondPath, cleanup := setupBridge(t, "[Discord]\nChannelID = \"2000\"\n[Minecraft]\nUseLogFile = false\nRconPort = 25590\n")
+	defer cleanup()
+
+	// When
+	err = first.updateConfig(func(c *config.RootConfig) error {
+		c.Discord.ChannelID = "1234"
+		return first.file.Save(c)
+	})
+
+	// Then
+	if err != nil {
+		t.Fatalf("Failed to change the config: %s", err)
+	}
+	if first.Config().Minecraft.RconPort != 25580 || second.Config().Minecraft.RconPort != 25590 {
+		t.Errorf("Bridges should have their own RCON ports, got: %d and %d", first.Config().Minecraft.RconPort, second.Config().Minecraft.RconPort)
+	}
+	if _, ok := first.file.Overridden("Minecraft.RconPort"); !ok {
+		t.Errorf("First bridge should read its RCON port from server.properties")
+	}
+	if _, ok := second.file.Overridden("Minecraft.RconPort"); ok {
+		t.Errorf("Second bridge should not share the first bridge's overrides")
+	}
+	if _, ok := second.serverProperties(); ok {
+		t.Errorf("Second bridge should not share the first bridge's server.properties")
+	}
+	if second.Config().Discord.ChannelID == "1234" {
+		t.Errorf("Second bridge's config should not change")
+	}
+	if saved, _ := ioutil.ReadFile(firstPath); !strings.Contains(string(saved), `"1234"`) {
+		t.Errorf("Change was not saved to the first bridge's file:\n%s", saved)
+	}
+	if saved, _ := ioutil.ReadFile(secondPath); strings.Contains(string(saved), `"1234"`) {
+		t.Errorf("Change was saved to the second bridge's file:\n%s", saved)
+	}
+}
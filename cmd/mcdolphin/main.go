@@ -1,19 +1,112 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
 	"github.com/jessevdk/go-flags"
 	"gitlab.com/EbonJaeger/dolphin"
+	"gitlab.com/EbonJaeger/dolphin/config"
+	"gitlab.com/EbonJaeger/dolphin/setup"
 )
 
 // Version is the version string of the program, set in the Makefile.
 var Version string
 
+// configCommand holds the config subcommands.
+type configCommand struct {
+	Check configCheckCommand `command:"check" description:"Check a config file for problems, and exit non-zero if there are any"`
+}
+
+// configCheckCommand checks a config file for problems.
+type configCheckCommand struct {
+	Args struct {
+		Path string `positional-arg-name:"path" description:"The config file or its directory. Defaults to the default config location."`
+	} `positional-args:"yes"`
+}
+
+// Execute checks the config file and prints any problems.
+func (c *configCheckCommand) Execute(args []string) error {
+	path, err := config.CheckFile(c.Args.Path)
+
+	var validationErr *config.ValidationError
+	if errors.As(err, &validationErr) {
+		fmt.Fprintf(os.Stderr, "%s has %d problem(s):\n", path, len(validationErr.Problems))
+		for _, p := range validationErr.Problems {
+			fmt.Fprintf(os.Stderr, "  %s\n", p)
+		}
+		os.Exit(1)
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to check '%s': %s\n", path, err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("%s is valid\n", path)
+	return nil
+}
+
+// initCommand sets up a config file by asking for each setting.
+type initCommand struct {
+	Args struct {
+		Path string `positional-arg-name:"path" description:"The config file or its directory. Defaults to the default config location."`
+	} `positional-args:"yes"`
+}
+
+// Execute runs the setup wizard and saves the config file.
+func (c *initCommand) Execute(args []string) error {
+	path := c.Args.Path
+	if path == "" {
+		var err error
+		if path, err = config.GetDefaultConfDir(); err != nil {
+			return err
+		}
+	}
+
+	// Start from the existing config, if there is one
+	file, err := config.NewFile(path)
+	if err != nil {
+		return err
+	}
+	conf, err := file.Load()
+	if err != nil {
+		return err
+	}
+
+	wizard := setup.NewWizard(file, os.Stdin, os.Stdout, setup.DefaultChecks())
+	conf, err = wizard.Run(conf)
+
+	var validationErr *config.ValidationError
+	if errors.As(err, &validationErr) {
+		fmt.Fprintf(os.Stderr, "\nThe config has %d problem(s), so it wasn't saved:\n", len(validationErr.Problems))
+		for _, p := range validationErr.Problems {
+			fmt.Fprintf(os.Stderr, "  %s\n", p)
+		}
+		os.Exit(1)
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "\nUnable to set up Dolphin: %s\n", err)
+		os.Exit(1)
+	}
+
+	if err := file.Save(conf); err != nil {
+		return err
+	}
+
+	fmt.Printf("\nSaved the config to %s. Run mcdolphin to start the bridge!\n", file.Path())
+	return nil
+}
+
 func main() {
 	var opts dolphin.Flags
 	parser := flags.NewParser(&opts, flags.Default)
+	parser.SubcommandsOptional = true
+	if _, err := parser.AddCommand("config", "Manage the config file", "Commands for working with the config file", &configCommand{}); err != nil {
+		panic(err)
+	}
+	if _, err := parser.AddCommand("init", "Set up Dolphin", "Create or update the config file by answering questions, checking that each setting works", &initCommand{}); err != nil {
+		panic(err)
+	}
+
 	if _, err := parser.Parse(); err != nil {
 		if flagsErr, ok := err.(*flags.Error); ok && flagsErr.Type == flags.ErrHelp {
 			os.Exit(0)
@@ -22,6 +115,11 @@ func main() {
 		}
 	}
 
+	// A subcommand was run instead of the bot
+	if parser.Active != nil {
+		os.Exit(0)
+	}
+
 	if opts.Version {
 		fmt.Printf("mcdolphin version %s\n", Version)
 		os.Exit(0)
@@ -0,0 +1,271 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/diamondburned/arikawa/discord"
+)
+
+var durationRegex = regexp.MustCompile(`^(\d+)([wdhms])`)
+
+// ArgError is returned when the arguments given to a command are invalid.
+type ArgError struct {
+	Message string
+}
+
+func (e *ArgError) Error() string {
+	return e.Message
+}
+
+// token is a single argument in a command, and where it starts in the
+// command text.
+type token struct {
+	text  string
+	start int
+}
+
+// tokenize splits command text into arguments. Arguments are separated by
+// spaces, unless they are in double quotes. A backslash escapes the next
+// character.
+func tokenize(raw string) ([]token, error) {
+	tokens := make([]token, 0)
+
+	var (
+		b       strings.Builder
+		inQuote bool
+		escaped bool
+		started bool
+		start   int
+	)
+
+	for i, r := range raw {
+		switch {
+		case escaped:
+			b.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '"':
+			inQuote = !inQuote
+		case r == ' ' && !inQuote:
+			if started {
+				tokens = append(tokens, token{b.String(), start})
+				b.Reset()
+				started = false
+			}
+			continue
+		default:
+			b.WriteRune(r)
+		}
+
+		if !started {
+			started = true
+			start = i
+		}
+	}
+
+	if inQuote {
+		return tokens, &ArgError{"A quoted argument is missing its closing quote."}
+	}
+
+	if started {
+		tokens = append(tokens, token{b.String(), start})
+	}
+
+	return tokens, nil
+}
+
+// bindArgs checks the given arguments against the parameters of a command,
+// and parses them into their values.
+func bindArgs(params []Param, args []token, raw string) (map[string]interface{}, error) {
+	values := make(map[string]interface{})
+
+	for i, param := range params {
+		// Make sure we have all of the arguments we need
+		if i >= len(args) {
+			if param.Optional {
+				break
+			}
+			return nil, &ArgError{fmt.Sprintf("Missing a value for `%s`.", param.Name)}
+		}
+
+		// The rest of the text is the value. If that's only one argument,
+		// use it as it was parsed, so any quotes are removed.
+		if param.Type == RestParam {
+			if i == len(args)-1 {
+				values[param.Name] = args[i].text
+			} else {
+				values[param.Name] = raw[args[i].start:]
+			}
+			return values, nil
+		}
+
+		value, err := parseValue(param, args[i].text)
+		if err != nil {
+			return nil, err
+		}
+		values[param.Name] = value
+	}
+
+	// Make sure we weren't given too much
+	if len(args) > len(params) {
+		return nil, &ArgError{"Too many arguments."}
+	}
+
+	return values, nil
+}
+
+// parseValue parses an argument into the type of the parameter.
+func parseValue(param Param, arg string) (interface{}, error) {
+	switch param.Type {
+	case IntParam:
+		value, err := strconv.Atoi(arg)
+		if err != nil {
+			return nil, &ArgError{fmt.Sprintf("`%s` must be a whole number.", param.Name)}
+		}
+		return value, nil
+	case BoolParam:
+		value, err := strconv.ParseBool(arg)
+		if err != nil {
+			return nil, &ArgError{fmt.Sprintf("`%s` only accepts `true` or `false`.", param.Name)}
+		}
+		return value, nil
+	case UserParam:
+		snowflake, err := parseMention(arg, "<@!", "<@")
+		if err != nil {
+			return nil, &ArgError{fmt.Sprintf("`%s` must be a user mention or ID.", param.Name)}
+		}
+		return discord.UserID(snowflake), nil
+	case ChannelParam:
+		snowflake, err := parseMention(arg, "<#")
+		if err != nil {
+			return nil, &ArgError{fmt.Sprintf("`%s` does not seem to be an actual channel or channel ID.", arg)}
+		}
+		return discord.ChannelID(snowflake), nil
+	case RoleParam:
+		snowflake, err := parseMention(arg, "<@&")
+		if err != nil {
+			return nil, &ArgError{fmt.Sprintf("`%s` must be a role mention or ID.", param.Name)}
+		}
+		return discord.RoleID(snowflake), nil
+	case DurationParam:
+		value, err := ParseDuration(arg)
+		if err != nil {
+			return nil, &ArgError{fmt.Sprintf("`%s` must be a duration, such as `30m` or `1d12h`.", param.Name)}
+		}
+		return value, nil
+	default:
+		return arg, nil
+	}
+}
+
+// parseMention parses a Discord mention with one of the given prefixes, or a
+// plain ID, into a Snowflake.
+func parseMention(arg string, prefixes ...string) (discord.Snowflake, error) {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(arg, prefix) && strings.HasSuffix(arg, ">") {
+			arg = arg[len(prefix) : len(arg)-1]
+			break
+		}
+	}
+
+	return discord.ParseSnowflake(arg)
+}
+
+// maxDuration is the longest duration ParseDuration accepts.
+const maxDuration = time.Duration(math.MaxInt64)
+
+// ParseDuration parses a duration such as 30m, 12h, or 1w2d. In addition to
+// hours, minutes, and seconds, days and weeks are supported. Durations too
+// long to represent, about 292 years, are an error.
+func ParseDuration(text string) (time.Duration, error) {
+	if text == "" {
+		return 0, errors.New("empty duration")
+	}
+
+	units := map[string]time.Duration{
+		"w": 7 * 24 * time.Hour,
+		"d": 24 * time.Hour,
+		"h": time.Hour,
+		"m": time.Minute,
+		"s": time.Second,
+	}
+
+	var total time.Duration
+	rest := strings.ToLower(text)
+	for rest != "" {
+		match := durationRegex.FindStringSubmatch(rest)
+		if match == nil {
+			return 0, fmt.Errorf("invalid duration: %s", text)
+		}
+
+		n, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("duration is too long: %s", text)
+		}
+
+		// Check that the duration fits before adding it up
+		unit := units[match[2]]
+		if n > int64((maxDuration-total)/unit) {
+			return 0, fmt.Errorf("duration is too long: %s", text)
+		}
+		total += time.Duration(n) * unit
+		rest = rest[len(match[0]):]
+	}
+
+	return total, nil
+}
+
+// HasArg checks if a value was given for a parameter.
+func (cmd DiscordCommand) HasArg(name string) bool {
+	_, ok := cmd.Values[name]
+	return ok
+}
+
+// StringArg gets the value of a string or rest-of-line parameter.
+func (cmd DiscordCommand) StringArg(name string) string {
+	value, _ := cmd.Values[name].(string)
+	return value
+}
+
+// IntArg gets the value of a number parameter.
+func (cmd DiscordCommand) IntArg(name string) int {
+	value, _ := cmd.Values[name].(int)
+	return value
+}
+
+// BoolArg gets the value of a true/false parameter.
+func (cmd DiscordCommand) BoolArg(name string) bool {
+	value, _ := cmd.Values[name].(bool)
+	return value
+}
+
+// UserArg gets the value of a user parameter.
+func (cmd DiscordCommand) UserArg(name string) discord.UserID {
+	value, _ := cmd.Values[name].(discord.UserID)
+	return value
+}
+
+// ChannelArg gets the value of a channel parameter.
+func (cmd DiscordCommand) ChannelArg(name string) discord.ChannelID {
+	value, _ := cmd.Values[name].(discord.ChannelID)
+	return value
+}
+
+// RoleArg gets the value of a role parameter.
+func (cmd DiscordCommand) RoleArg(name string) discord.RoleID {
+	value, _ := cmd.Values[name].(discord.RoleID)
+	return value
+}
+
+// DurationArg gets the value of a duration parameter.
+func (cmd DiscordCommand) DurationArg(name string) time.Duration {
+	value, _ := cmd.Values[name].(time.Duration)
+	return value
+}
@@ -0,0 +1,168 @@
+package command
+
+import (
+	"testing"
+	"time"
+
+	"github.com/diamondburned/arikawa/discord"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestTokenizeQuotedArgs(t *testing.T) {
+	// given
+	raw := `kick "Some Player" being \"rude\"`
+	expected := []string{"kick", "Some Player", "being", `"rude"`}
+
+	// when
+	tokens, err := tokenize(raw)
+
+	// then
+	if err != nil {
+		t.Fatalf("Failed to tokenize command: %s", err)
+	}
+	if actual := tokenText(tokens); !cmp.Equal(actual, expected) {
+		t.Errorf("Tokenized command is incorrect: Diff: %s", cmp.Diff(actual, expected))
+	}
+}
+
+func TestTokenizeUnclosedQuote(t *testing.T) {
+	// when
+	_, err := tokenize(`say "hello`)
+
+	// then
+	if err == nil {
+		t.Errorf("Tokenizing an unclosed quote should return an error")
+	}
+}
+
+func TestBindArgs(t *testing.T) {
+	// given
+	raw := `tempban <@!1234> 1d12h griefing  the spawn`
+	params := []Param{
+		{Name: "user", Type: UserParam},
+		{Name: "duration", Type: DurationParam},
+		{Name: "reason", Type: RestParam},
+	}
+	tokens, _ := tokenize(raw)
+
+	// when
+	values, err := bindArgs(params, tokens[1:], raw)
+
+	// then
+	if err != nil {
+		t.Fatalf("Failed to bind args: %s", err)
+	}
+	expected := map[string]interface{}{
+		"user":     discord.UserID(1234),
+		"duration": 36 * time.Hour,
+		"reason":   "griefing  the spawn",
+	}
+	if !cmp.Equal(values, expected) {
+		t.Errorf("Bound args are incorrect: Diff: %s", cmp.Diff(values, expected))
+	}
+}
+
+func TestBindArgsMissingRequired(t *testing.T) {
+	// given
+	params := []Param{
+		{Name: "option", Type: StringParam},
+		{Name: "value", Type: BoolParam},
+	}
+	tokens, _ := tokenize("showdeaths")
+
+	// when
+	_, err := bindArgs(params, tokens, "showdeaths")
+
+	// then
+	if err == nil {
+		t.Errorf("Binding args without a required value should return an error")
+	}
+}
+
+func TestBindArgsInvalidBool(t *testing.T) {
+	// given
+	params := []Param{
+		{Name: "value", Type: BoolParam},
+	}
+	tokens, _ := tokenize("maybe")
+
+	// when
+	_, err := bindArgs(params, tokens, "maybe")
+
+	// then
+	if _, ok := err.(*ArgError); !ok {
+		t.Errorf("Binding an invalid bool should return an ArgError, got: %v", err)
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	// when
+	actual, err := ParseDuration("1w2d30m")
+
+	// then
+	if err != nil {
+		t.Fatalf("Failed to parse duration: %s", err)
+	}
+	expected := 9*24*time.Hour + 30*time.Minute
+	if actual != expected {
+		t.Errorf("Parsed duration is incorrect, got: %s, expected: %s", actual, expected)
+	}
+}
+
+func TestParseDurationTooLong(t *testing.T) {
+	for _, text := range []string{"20000w", "106752d", "99999999999999999999s", "15250w2d"} {
+		// when
+		actual, err := ParseDuration(text)
+
+		// then
+		if err == nil {
+			t.Errorf("Parsing %s should fail because it overflows, got: %s", text, actual)
+		}
+	}
+}
+
+func TestUsage(t *testing.T) {
+	// given
+	handler := Handler{
+		Name: "tempban",
+		Params: []Param{
+			{Name: "player", Type: StringParam},
+			{Name: "duration", Type: DurationParam},
+			{Name: "reason", Type: RestParam, Optional: true},
+		},
+	}
+
+	// when
+	actual := Usage("!", "tempban", handler)
+
+	// then
+	expected := "!tempban <player> <duration> [reason...]"
+	if actual != expected {
+		t.Errorf("Usage is incorrect, got: %s, expected: %s", actual, expected)
+	}
+}
+
+func TestCommandOptionsWithSubcommands(t *testing.T) {
+	// given
+	handler := Handler{
+		Name: "config",
+		Subcommands: []Handler{
+			{
+				Name:   "set",
+				Desc:   "Set an option",
+				Params: setConfigParams,
+			},
+		},
+	}
+
+	// when
+	options := commandOptions(handler)
+
+	// then
+	if len(options) != 1 || options[0].Type != OptionTypeSubcommand {
+		t.Fatalf("Slash command options should only have the subcommand, got: %+v", options)
+	}
+	if len(options[0].Options) != 2 || !options[0].Options[1].Required {
+		t.Errorf("Subcommand options are incorrect, got: %+v", options[0].Options)
+	}
+}
@@ -2,7 +2,7 @@ package command
 
 import (
 	"fmt"
-	"strconv"
+	"reflect"
 	"strings"
 
 	"github.com/diamondburned/arikawa/discord"
@@ -10,186 +10,213 @@ import (
 	"gitlab.com/EbonJaeger/dolphin/config"
 )
 
-// ParseConfigCommand checks if the config option specified is valid, and
+// maxListValue is how long a value can be in the list of config options
+// before it is cut off.
+const maxListValue = 60
+
+// setConfigParams are the parameters for setting a config option.
+var setConfigParams = []Param{
+	{Name: "option", Desc: "The option to change", Type: StringParam},
+	{Name: "value", Desc: "The new value of the option", Type: RestParam},
+}
+
+// getConfigParams are the parameters for showing a config option.
+var getConfigParams = []Param{
+	{Name: "option", Desc: "The option to show", Type: StringParam},
+}
+
+// SetConfigOption checks if the config option specified is valid, and
 // updates the config accordingly.
-func ParseConfigCommand(state *state.State, cmd DiscordCommand) error {
-	// Check if the sender has permission
-	if !hasAdminPermisison(state, cmd) {
-		log.Warnf("A user tried to update the config without the administrator permissions: '%s'\n", cmd.Sender.Username)
-		return SendMissingPermsEmbed(state, cmd.ChannelID, cmd.MessageID)
+func (p *Parser) SetConfigOption(state *state.State, cmd DiscordCommand) error {
+	setting, ok := findSetting(cmd.StringArg("option"))
+	if !ok || setting.Secret {
+		return sendUnknownOption(state, cmd)
 	}
 
-	// Check the number of args for the command
-	if len(cmd.Args) != 2 {
-		embed := CreateEmbed(WarnColor, "Incorrect Usage", ":warning: Configuration usage: `!config <option> <value>`", "")
+	// Hosts and paths can't be changed from Discord, because they could be
+	// pointed at someone else's server or any file the bot can write to
+	if setting.ReadOnly {
+		embed := CreateEmbed(WarnColor, "Option Read-Only", fmt.Sprintf(":warning: `%s` can only be changed in the config file.", setting.Name), "")
 		return SendCommandEmbed(state, cmd, embed)
 	}
 
-	// Handle the command and update the config
-	confOption := strings.ToLower(cmd.Args[0])
-	switch confOption {
-	case "allowmentions":
-		{
-			value, err := strconv.ParseBool(cmd.Args[1])
-			if err != nil {
-				embed := CreateEmbed(WarnColor, "Invalid Value", ":warning: This option only accepts `true` or `false`.", "")
-				return SendCommandEmbed(state, cmd, embed)
-			}
+	// Options set by the environment can't be changed, because they wouldn't be saved
+	if o, ok := p.overridden(setting.Key); ok && o.Env != "" {
+		embed := CreateEmbed(WarnColor, "Option Overridden", fmt.Sprintf(":warning: `%s` is set by the `%s` environment variable, and can't be changed here.", setting.Name, o.Env), "")
+		return SendCommandEmbed(state, cmd, embed)
+	}
 
-			log.Debugf("Updating config property '%s' to '%s'\n", cmd.Args[0], cmd.Args[1])
-			conf.Discord.AllowMentions = value
-			if err := config.SaveConfig(conf); err != nil {
-				return err
-			}
+	// Parse the value for the type of option
+	value, err := parseSettingValue(setting, cmd.StringArg("value"))
+	if err != nil {
+		embed := CreateEmbed(WarnColor, "Invalid Value", fmt.Sprintf(":warning: %s", err), "")
+		return SendCommandEmbed(state, cmd, embed)
+	}
 
-			// Let the user know the command was successful
-			return sendSuccessEmbed(state, cmd)
+	// Make sure channels are in this server
+	if setting.Channel && value != "" {
+		id, _ := discord.ParseSnowflake(value.(string))
+		channel, err := state.Channel(discord.ChannelID(id))
+		if err != nil || channel.GuildID != cmd.GuildID {
+			embed := CreateEmbed(WarnColor, "Channel Doesn't Exist", fmt.Sprintf(":warning: No channel with ID `%s` found in this server!", value), fmt.Sprintf("See `%shelp config` for configuration options", commandPrefix(cmd)))
+			return SendCommandEmbed(state, cmd, embed)
 		}
-	case "channel":
-		{
-			chanStr := cmd.Args[1]
-
-			// Check if we were given a channel mention or just an ID
-			if strings.HasPrefix(chanStr, "<#") && strings.HasSuffix(chanStr, ">") {
-				endIndex := len(chanStr) - 1
-				chanStr = chanStr[2:endIndex]
-			}
-
-			// Get the channel
-			snowflake, err := discord.ParseSnowflake(chanStr)
-			if err != nil {
-				embed := CreateEmbed(WarnColor, "Unknown Channel", fmt.Sprintf(":warning: `%s` does not seem to be an actual channel or channel ID.", chanStr), "")
-				return SendCommandEmbed(state, cmd, embed)
-			}
+	}
 
-			// Update the config
-			return updateChannel(state, cmd, snowflake, chanStr)
+	// Change a copy of the config, and only use it if it's valid and saved.
+	// Changes are made one at a time, so changes made at the same time
+	// aren't lost.
+	var invalid error
+	err = p.store.UpdateConfig(func(c *config.RootConfig) error {
+		if err := setting.Set(c, value); err != nil {
+			return err
 		}
-	case "showadvancements":
-		{
-			value, err := strconv.ParseBool(cmd.Args[1])
-			if err != nil {
-				embed := CreateEmbed(WarnColor, "Invalid Value", ":warning: This option only accepts `true` or `false`.", "")
-				return SendCommandEmbed(state, cmd, embed)
-			}
-
-			log.Debugf("Updating config property '%s' to '%s'\n", cmd.Args[0], cmd.Args[1])
-			conf.Discord.MessageOptions.ShowAdvancements = value
-			if err := config.SaveConfig(conf); err != nil {
-				return err
-			}
-
-			// Let the user know the command was successful
-			return sendSuccessEmbed(state, cmd)
+		if invalid = config.Validate(*c); invalid != nil {
+			return invalid
 		}
-	case "showdeaths":
-		{
-			value, err := strconv.ParseBool(cmd.Args[1])
-			if err != nil {
-				embed := CreateEmbed(WarnColor, "Invalid Value", ":warning: This option only accepts `true` or `false`.", "")
-				return SendCommandEmbed(state, cmd, embed)
-			}
-
-			log.Debugf("Updating config property '%s' to '%s'\n", cmd.Args[0], cmd.Args[1])
-			conf.Discord.MessageOptions.ShowDeaths = value
-			if err := config.SaveConfig(conf); err != nil {
-				return err
-			}
 
-			// Let the user know the command was successful
-			return sendSuccessEmbed(state, cmd)
+		p.log.Debugf("Updating config property '%s' to '%s'\n", setting.Key, cmd.StringArg("value"))
+		if p.file == nil {
+			return nil
 		}
-	case "showjoinleave":
-		{
-			value, err := strconv.ParseBool(cmd.Args[1])
-			if err != nil {
-				embed := CreateEmbed(WarnColor, "Invalid Value", ":warning: This option only accepts `true` or `false`.", "")
-				return SendCommandEmbed(state, cmd, embed)
-			}
-
-			log.Debugf("Updating config property '%s' to '%s'\n", cmd.Args[0], cmd.Args[1])
-			conf.Discord.MessageOptions.ShowJoinsLeaves = value
-			if err := config.SaveConfig(conf); err != nil {
-				return err
-			}
+		return p.file.Save(c)
+	})
+	if invalid != nil {
+		embed := CreateEmbed(WarnColor, "Invalid Value", fmt.Sprintf(":warning: %s", invalid), "")
+		return SendCommandEmbed(state, cmd, embed)
+	}
+	if err != nil {
+		return err
+	}
 
-			// Let the user know the command was successful
-			return sendSuccessEmbed(state, cmd)
-		}
-	case "usemembernicks":
-		{
-			value, err := strconv.ParseBool(cmd.Args[1])
-			if err != nil {
-				embed := CreateEmbed(WarnColor, "Invalid Value", ":warning: This option only accepts `true` or `false`.", "")
-				return SendCommandEmbed(state, cmd, embed)
-			}
+	// Let the user know the command was successful
+	embed := CreateEmbed(SuccessColor, "Config Updated", fmt.Sprintf(":white_check_mark: `%s` is now `%s`.", setting.Name, setting.Display(*p.config())), "")
+	return SendCommandEmbed(state, cmd, embed)
+}
 
-			log.Debugf("Updating config property '%s' to '%s'\n", cmd.Args[0], cmd.Args[1])
-			conf.Discord.UseMemberNicks = value
-			if err := config.SaveConfig(conf); err != nil {
-				return err
-			}
+// GetConfigOption shows the current value of a config option.
+func (p *Parser) GetConfigOption(state *state.State, cmd DiscordCommand) error {
+	setting, ok := findSetting(cmd.StringArg("option"))
+	if !ok {
+		return sendUnknownOption(state, cmd)
+	}
 
-			// Let the user know the command was successful
-			return sendSuccessEmbed(state, cmd)
-		}
-	default:
-		{
-			embed := CreateEmbed(WarnColor, "Unknown Option", fmt.Sprintf(":warning: Unknown configuration option `%s`.", confOption), "See `!help config` for configuration options")
-			return SendCommandEmbed(state, cmd, embed)
-		}
+	b := strings.Builder{}
+	b.WriteString(fmt.Sprintf("`%s`\n", setting.Display(*p.config())))
+	if setting.Desc != "" {
+		b.WriteString("\n" + setting.Desc + "\n")
+	}
+	if o, ok := p.overridden(setting.Key); ok {
+		b.WriteString(fmt.Sprintf("\nSet by %s.\n", o.Source()))
 	}
+
+	embed := CreateEmbed(InfoColor, setting.Name, b.String(), fmt.Sprintf("%s in the config file", setting.Key))
+	return SendCommandEmbed(state, cmd, embed)
 }
 
-func hasAdminPermisison(state *state.State, cmd DiscordCommand) bool {
-	user, err := state.Member(cmd.GuildID, cmd.Sender.ID)
-	if err != nil {
-		return false
-	}
+// ListConfigOptions shows every config option and its current value.
+func (p *Parser) ListConfigOptions(state *state.State, cmd DiscordCommand) error {
+	return SendPrivateEmbed(state, cmd, createConfigListEmbed(*p.config(), commandPrefix(cmd)))
+}
 
-	for _, roleID := range user.RoleIDs {
-		role, err := state.Role(cmd.GuildID, roleID)
-		if err != nil {
-			continue
+// createConfigListEmbed creates an embed with every config option and its
+// value, grouped by the section of the config file they are in.
+func createConfigListEmbed(c config.RootConfig, prefix string) discord.Embed {
+	embed := CreateEmbed(InfoColor, "Configuration", "", fmt.Sprintf("Use %sconfig get <option> for more information about an option", prefix))
+
+	for _, setting := range config.Settings() {
+		section := setting.Key[:strings.LastIndex(setting.Key, ".")]
+		value := setting.Display(c)
+		if len(value) > maxListValue {
+			value = value[:maxListValue] + "…"
 		}
+		line := fmt.Sprintf("`%s` — %s\n", setting.Name, value)
 
-		if role.Permissions.Has(discord.PermissionAdministrator) {
-			return true
+		// Start a new field for each section
+		if n := len(embed.Fields); n == 0 || embed.Fields[n-1].Name != section {
+			embed.Fields = append(embed.Fields, discord.EmbedField{Name: section})
 		}
+		embed.Fields[len(embed.Fields)-1].Value += line
+	}
+
+	return embed
+}
+
+// overridden checks if a config option was set by an environment variable
+// or the server.properties file when the config file was last loaded.
+func (p *Parser) overridden(key string) (config.Override, bool) {
+	if p.file == nil {
+		return config.Override{}, false
 	}
+	return p.file.Overridden(key)
+}
 
-	return false
+// findSetting finds a config option by its name or alias.
+func findSetting(name string) (config.Setting, bool) {
+	return config.FindSetting(strings.TrimSpace(name))
 }
 
-func sendSuccessEmbed(state *state.State, cmd DiscordCommand) error {
-	embed := CreateEmbed(SuccessColor, "Config Updated", ":white_check_mark: Configuration updated successfully!", "")
+// sendUnknownOption lets the user know that a config option doesn't exist.
+func sendUnknownOption(state *state.State, cmd DiscordCommand) error {
+	embed := CreateEmbed(WarnColor, "Unknown Option", fmt.Sprintf(":warning: Unknown configuration option `%s`.", cmd.StringArg("option")), fmt.Sprintf("See `%shelp config` for configuration options", commandPrefix(cmd)))
 	return SendCommandEmbed(state, cmd, embed)
 }
 
-func updateChannel(state *state.State, cmd DiscordCommand, snowflake discord.Snowflake, newChannel string) error {
-	// Get the channel
-	channelID := discord.ChannelID(snowflake)
-	channel, err := state.Channel(channelID)
-	if err != nil {
-		embed := CreateEmbed(WarnColor, "Unknown Channel", fmt.Sprintf(":warning: '%s' does not seem to be an actual channel.", newChannel), "")
-		return SendCommandEmbed(state, cmd, embed)
+// settingParamType gets the type of parameter a config option takes.
+func settingParamType(setting config.Setting) ParamType {
+	switch {
+	case setting.Channel:
+		return ChannelParam
+	case setting.Type.Kind() == reflect.Bool:
+		return BoolParam
+	case setting.Type.Kind() == reflect.Int:
+		return IntParam
+	default:
+		return RestParam
 	}
+}
 
-	// Get the Guild to check if the Channel is actually in this Guild
-	t, _ := state.Message(cmd.ChannelID, cmd.MessageID)
-	guild, _ := state.Guild(t.GuildID)
-	if channel.GuildID != guild.ID {
-		embed := CreateEmbed(WarnColor, "Channel Doesn't Exist", fmt.Sprintf(":warning: No channel with ID `%s` found in this server!", newChannel), "See `!help config` for configuration options")
-		return SendCommandEmbed(state, cmd, embed)
+// settingHint describes the values a config option accepts.
+func settingHint(setting config.Setting) string {
+	switch {
+	case setting.Channel:
+		return paramHint(ChannelParam) + ", or none"
+	case setting.Type.Kind() == reflect.Slice:
+		return "comma-separated list, or none"
+	default:
+		return paramHint(settingParamType(setting))
 	}
+}
 
-	// Update the config
-	log.Debugf("Updating config property '%s' to '%s'\n", cmd.Args[0], newChannel)
-	conf.Discord.ChannelID = newChannel
-	if err := config.SaveConfig(conf); err != nil {
-		return err
+// parseSettingValue parses the value of a config option. Channels are kept
+// as their ID, and lists are separated by commas. Channels and lists can be
+// cleared with none.
+func parseSettingValue(setting config.Setting, arg string) (interface{}, error) {
+	arg = strings.TrimSpace(arg)
+	none := strings.EqualFold(arg, "none")
+
+	switch {
+	case setting.Channel:
+		if none {
+			return "", nil
+		}
+		value, err := parseValue(Param{Name: "value", Type: ChannelParam}, arg)
+		if err != nil {
+			return nil, err
+		}
+		return value.(discord.ChannelID).String(), nil
+	case setting.Type.Kind() == reflect.Slice:
+		list := make([]string, 0)
+		if none {
+			return list, nil
+		}
+		for _, item := range strings.Split(arg, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		return list, nil
+	default:
+		return parseValue(Param{Name: "value", Type: settingParamType(setting)}, arg)
 	}
-
-	// Let the user know the command was successful
-	return sendSuccessEmbed(state, cmd)
 }
@@ -0,0 +1,76 @@
+package command
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"gitlab.com/EbonJaeger/dolphin/config"
+)
+
+func TestParseSettingValue(t *testing.T) {
+	// given
+	tests := []struct {
+		option   string
+		arg      string
+		expected interface{}
+	}{
+		{"showdeaths", "false", false},
+		{"status.poll_interval", "30", 30},
+		{"channel", "<#1234>", "1234"},
+		{"commands.audit_channel_id", "none", ""},
+		{"console.levels", "INFO, WARN,", []string{"INFO", "WARN"}},
+		{"minecraft.tellrawtemplate", `[{"text": "%message%"}]`, `[{"text": "%message%"}]`},
+	}
+
+	for _, test := range tests {
+		setting, ok := findSetting(test.option)
+		if !ok {
+			t.Fatalf("Option '%s' was not found", test.option)
+		}
+
+		// when
+		actual, err := parseSettingValue(setting, test.arg)
+
+		// then
+		if err != nil {
+			t.Errorf("Failed to parse '%s' for %s: %s", test.arg, test.option, err)
+		}
+		if !cmp.Equal(actual, test.expected) {
+			t.Errorf("Value for %s is incorrect: Diff: %s", test.option, cmp.Diff(actual, test.expected))
+		}
+	}
+}
+
+func TestConfigListEmbed(t *testing.T) {
+	// given
+	c := config.MergeDefaults(config.RootConfig{})
+	c.Discord.BotToken = "super-secret-token"
+	c.Minecraft.RconPassword = "hunter2"
+
+	// when
+	embed := createConfigListEmbed(c, "!")
+
+	// then
+	total := len(embed.Title) + len(embed.Footer.Text)
+	for _, field := range embed.Fields {
+		if len(field.Value) > 1024 {
+			t.Errorf("Field '%s' is too long for Discord: %d", field.Name, len(field.Value))
+		}
+		if field.Value == "" {
+			t.Errorf("Field '%s' is empty", field.Name)
+		}
+		total += len(field.Name) + len(field.Value)
+	}
+	if len(embed.Fields) > 25 || total > 6000 {
+		t.Errorf("Embed is too big for Discord: %d fields, %d characters", len(embed.Fields), total)
+	}
+
+	for _, field := range embed.Fields {
+		for _, secret := range []string{"super-secret-token", "hunter2"} {
+			if strings.Contains(field.Value, secret) {
+				t.Errorf("Secret was not redacted in '%s'", field.Name)
+			}
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package command
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/diamondburned/arikawa/discord"
+	"github.com/diamondburned/arikawa/state"
+	"gitlab.com/EbonJaeger/dolphin/storage"
+)
+
+const (
+	// digestCheckInterval is how often we check if the digest should be
+	// posted.
+	digestCheckInterval = 10 * time.Minute
+	// digestGracePeriod is how late the digest can be posted, such as when
+	// Dolphin wasn't running at the scheduled time.
+	digestGracePeriod = 24 * time.Hour
+	// digestKey is the key of the time the last digest was posted in the
+	// state bucket.
+	digestKey = "last_digest"
+)
+
+// weekdays are the days of the week by name.
+var weekdays = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+// digestStats are the statistics summarized in a digest.
+type digestStats struct {
+	From         time.Time
+	To           time.Time
+	Playtime     map[string]time.Duration
+	Deaths       []DeathEvent
+	Advancements []AdvancementEvent
+}
+
+// DigestScheduler posts a weekly summary of player statistics to the bridge
+// channel.
+type DigestScheduler struct {
+	done   chan struct{}
+	parser *Parser
+	repo   storage.Repository
+	state  *state.State
+}
+
+// NewDigestScheduler creates a new scheduler to post the weekly digest with
+// the statistics kept by a command parser.
+func NewDigestScheduler(parser *Parser, state *state.State, repo storage.Repository) *DigestScheduler {
+	return &DigestScheduler{
+		done:   make(chan struct{}),
+		parser: parser,
+		repo:   repo,
+		state:  state,
+	}
+}
+
+// Run checks if the digest should be posted until the scheduler is closed.
+func (s *DigestScheduler) Run() {
+	ticker := time.NewTicker(digestCheckInterval)
+	defer ticker.Stop()
+
+	s.check(time.Now())
+	for {
+		select {
+		case <-ticker.C:
+			s.check(time.Now())
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// Close stops posting the digest.
+func (s *DigestScheduler) Close() {
+	close(s.done)
+}
+
+// check posts the digest if it is due and hasn't been posted yet.
+func (s *DigestScheduler) check(now time.Time) {
+	p := s.parser
+	if !p.config().Discord.Digest.Enabled {
+		return
+	}
+
+	day, ok := weekdays[strings.ToLower(strings.TrimSpace(p.config().Discord.Digest.Day))]
+	if !ok {
+		p.log.Warnf("Invalid weekly digest day: %s\n", p.config().Discord.Digest.Day)
+		return
+	}
+
+	due := lastDigestTime(now, day, p.config().Discord.Digest.Hour)
+	var last time.Time
+	if _, err := s.repo.Get(storage.StateBucket, digestKey, &last); err != nil {
+		p.log.Errorf("Error reading when the last digest was posted: %s\n", err)
+		return
+	}
+	if !last.Before(due) || now.Sub(due) > digestGracePeriod {
+		return
+	}
+
+	stats, err := p.collectDigestStats(due.AddDate(0, 0, -7), due)
+	if err != nil {
+		p.log.Errorf("Error collecting weekly digest statistics: %s\n", err)
+		return
+	}
+
+	snowflake, err := discord.ParseSnowflake(p.config().Discord.ChannelID)
+	if err != nil {
+		p.log.Warnf("Unable to post the weekly digest: invalid channel ID: %s\n", err)
+		return
+	}
+	if _, err := s.state.Client.SendEmbed(discord.ChannelID(snowflake), createDigestEmbed(stats)); err != nil {
+		p.log.Errorf("Error posting the weekly digest: %s\n", err)
+		return
+	}
+
+	p.log.Infoln("Posted the weekly digest")
+	if err := s.repo.Put(storage.StateBucket, digestKey, now); err != nil {
+		p.log.Errorf("Error saving when the digest was posted: %s\n", err)
+	}
+}
+
+// lastDigestTime gets the most recent time, at or before now, that the
+// digest is scheduled for.
+func lastDigestTime(now time.Time, day time.Weekday, hour int) time.Time {
+	due := time.Date(now.Year(), now.Month(), now.Day(), hour, 0, 0, 0, now.Location())
+	due = due.AddDate(0, 0, -int((now.Weekday()-day+7)%7))
+	if due.After(now) {
+		due = due.AddDate(0, 0, -7)
+	}
+	return due
+}
+
+// collectDigestStats gets the statistics of a time range for the digest.
+func (p *Parser) collectDigestStats(from time.Time, to time.Time) (digestStats, error) {
+	stats := digestStats{From: from, To: to, Playtime: make(map[string]time.Duration)}
+
+	// Add up the sessions in the range, including the ones still going
+	finished, err := p.sessions.Sessions(from, time.Now().Add(time.Second))
+	if err != nil {
+		return stats, err
+	}
+	for _, session := range finished {
+		stats.Playtime[session.Player] += overlap(session.Start, session.End, from, to)
+	}
+	all, err := p.sessions.All()
+	if err != nil {
+		return stats, err
+	}
+	for _, player := range all {
+		if player.Online() {
+			stats.Playtime[player.Name] += overlap(player.SessionStart, time.Now(), from, to)
+		}
+	}
+
+	if stats.Deaths, err = p.events.Deaths(from, to); err != nil {
+		return stats, err
+	}
+	if stats.Advancements, err = p.events.Advancements("", from, to); err != nil {
+		return stats, err
+	}
+
+	return stats, nil
+}
+
+// overlap gets how much of a time range is within another time range.
+func overlap(start time.Time, end time.Time, from time.Time, to time.Time) time.Duration {
+	if start.Before(from) {
+		start = from
+	}
+	if end.After(to) {
+		end = to
+	}
+	if !end.After(start) {
+		return 0
+	}
+	return end.Sub(start)
+}
+
+// createDigestEmbed creates the weekly digest embed.
+func createDigestEmbed(stats digestStats) discord.Embed {
+	embed := CreateEmbed(InfoColor, "Weekly Digest", fmt.Sprintf("Here's what happened on the server from %s to %s.", stats.From.Format("Jan 2"), stats.To.Format("Jan 2")), "")
+
+	// Most active players
+	players := make([]string, 0, len(stats.Playtime))
+	for player, d := range stats.Playtime {
+		if d >= time.Minute {
+			players = append(players, player)
+		}
+	}
+	sort.Slice(players, func(i, j int) bool {
+		if stats.Playtime[players[i]] != stats.Playtime[players[j]] {
+			return stats.Playtime[players[i]] > stats.Playtime[players[j]]
+		}
+		return players[i] < players[j]
+	})
+	active := strings.Builder{}
+	for i, player := range players {
+		if i == 5 {
+			break
+		}
+		active.WriteString(fmt.Sprintf("**%d.** %s — %s\n", i+1, player, formatDuration(stats.Playtime[player])))
+	}
+	if active.Len() == 0 {
+		active.WriteString("Nobody played this week.")
+	}
+
+	// Deaths by cause
+	causes := make(map[string]int)
+	for _, death := range stats.Deaths {
+		causes[death.Cause]++
+	}
+	deaths := fmt.Sprintf("%d deaths\n%s", len(stats.Deaths), rankCounts(causes, 3))
+
+	// New advancements
+	advs := strings.Builder{}
+	for i, adv := range stats.Advancements {
+		if i == 10 {
+			advs.WriteString(fmt.Sprintf("*and %d more*", len(stats.Advancements)-10))
+			break
+		}
+		advs.WriteString(fmt.Sprintf("%s — **%s**\n", adv.Player, adv.Title))
+	}
+	if advs.Len() == 0 {
+		advs.WriteString("No new advancements this week.")
+	}
+
+	embed.Fields = []discord.EmbedField{
+		{Name: "Most Active Players", Value: active.String()},
+		{Name: "Most Common Deaths", Value: deaths},
+		{Name: "New Advancements", Value: advs.String()},
+	}
+	return embed
+}
@@ -1,6 +1,7 @@
 package command
 
 import (
+	"strings"
 	"time"
 
 	"github.com/diamondburned/arikawa/discord"
@@ -42,9 +43,14 @@ func RemoveEmbed(state *state.State, channelID discord.ChannelID, commandID, emb
 }
 
 // SendCommandEmbed sends the given embed to the specified Discord channel,
-// and removes it after 30 seconds. If there was an error sending or removing
-// the embed, an error is returned.
+// and removes it after 30 seconds. Slash commands are instead replied to
+// with an embed only the user can see. If there was an error sending or
+// removing the embed, an error is returned.
 func SendCommandEmbed(state *state.State, cmd DiscordCommand, embed discord.Embed) error {
+	if cmd.Interaction != nil {
+		return SendInteractionEmbed(state, cmd.Interaction, embed)
+	}
+
 	message, err := state.Client.SendEmbed(cmd.ChannelID, embed)
 	if err != nil {
 		return err
@@ -56,12 +62,44 @@ func SendCommandEmbed(state *state.State, cmd DiscordCommand, embed discord.Embe
 // SendMissingPermsEmbed creates a new embed for a player missing a permission.
 // This embed is then sent to the channel, and the command and embed are removed
 // after 30 seconds.
-func SendMissingPermsEmbed(state *state.State, channelID discord.ChannelID, messageID discord.MessageID) error {
+func SendMissingPermsEmbed(state *state.State, cmd DiscordCommand) error {
 	embed := CreateEmbed(ErrorColor, "Insufficient Permissions", ":no_entry: You don't have permission to run that command!", "Please contact a server administrator for help.")
-	message, err := state.Client.SendEmbed(channelID, embed)
+	return SendCommandEmbed(state, cmd, embed)
+}
+
+// SendPrivateEmbed sends an embed to the user that sent a command in a DM.
+// If the user doesn't allow DMs, it is sent to the channel instead. Replies
+// to slash commands are already private, so they are sent as normal.
+func SendPrivateEmbed(state *state.State, cmd DiscordCommand, embed discord.Embed) error {
+	if cmd.Interaction != nil {
+		return SendInteractionEmbed(state, cmd.Interaction, embed)
+	}
+
+	// Create a DM channel with the sender
+	dm, err := state.Client.CreatePrivateChannel(cmd.Sender.ID)
 	if err != nil {
 		return err
 	}
 
-	return RemoveEmbed(state, channelID, message.ID, messageID)
+	// Attempt to send the embed to the DM
+	if _, err := state.Client.SendEmbed(dm.ID, embed); err != nil {
+		// An error happened; Probably the sender doesn't allow DM's from randos.
+		// So, send it to the channel instead, and remove after 30 seconds.
+		return SendCommandEmbed(state, cmd, embed)
+	}
+
+	return nil
+}
+
+// commandPrefix gets the prefix to show in help messages for a command.
+func commandPrefix(cmd DiscordCommand) string {
+	if cmd.Interaction != nil {
+		return "/"
+	}
+	return cmd.Prefix
+}
+
+// EscapeCodeBlock keeps text from ending the code block it is shown in.
+func EscapeCodeBlock(text string) string {
+	return strings.ReplaceAll(text, "```", "`\u200b``")
 }
@@ -0,0 +1,141 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"gitlab.com/EbonJaeger/dolphin/storage"
+)
+
+// eventTimeFormat is the time format used in the keys of events, so that
+// they are sorted by time.
+const eventTimeFormat = "20060102T150405.000000000"
+
+// DeathEvent is a player dying in game.
+type DeathEvent struct {
+	Player string `json:"player"`
+	// Cause is what kind of death it was, such as "Fall" or "Explosion".
+	Cause string `json:"cause"`
+	// Killer is the mob or player that killed the player, if any.
+	Killer  string    `json:"killer,omitempty"`
+	Message string    `json:"message"`
+	At      time.Time `json:"at"`
+}
+
+// AdvancementEvent is a player getting an advancement for the first time.
+type AdvancementEvent struct {
+	Player string    `json:"player"`
+	Title  string    `json:"title"`
+	At     time.Time `json:"at"`
+}
+
+// EventLog records the deaths and advancements of players.
+type EventLog struct {
+	repo storage.Repository
+}
+
+// NewEventLog creates an event log stored in a repository.
+func NewEventLog(repo storage.Repository) *EventLog {
+	return &EventLog{repo: repo}
+}
+
+// AddDeath records a player dying.
+func (l *EventLog) AddDeath(death DeathEvent) error {
+	return l.repo.Put(storage.DeathsBucket, eventKey(death.At, death.Player), death)
+}
+
+// AddAdvancement records a player getting an advancement. Only the first
+// time a player gets each advancement is kept.
+func (l *EventLog) AddAdvancement(adv AdvancementEvent) error {
+	key := strings.ToLower(adv.Player) + "/" + adv.Title
+	var existing AdvancementEvent
+	if ok, err := l.repo.Get(storage.AdvancementsBucket, key, &existing); err != nil || ok {
+		return err
+	}
+
+	return l.repo.Put(storage.AdvancementsBucket, key, adv)
+}
+
+// Deaths gets the deaths that happened in a time range.
+func (l *EventLog) Deaths(from time.Time, to time.Time) ([]DeathEvent, error) {
+	list := make([]DeathEvent, 0)
+	err := forEachEvent(l.repo, storage.DeathsBucket, from, to, func(data []byte) error {
+		var death DeathEvent
+		if err := json.Unmarshal(data, &death); err != nil {
+			return err
+		}
+		list = append(list, death)
+		return nil
+	})
+	return list, err
+}
+
+// Advancements gets the advancements that were made in a time range, oldest
+// first. If player isn't empty, only that player's advancements are given.
+func (l *EventLog) Advancements(player string, from time.Time, to time.Time) ([]AdvancementEvent, error) {
+	list := make([]AdvancementEvent, 0)
+	err := l.repo.ForEach(storage.AdvancementsBucket, func(key string, data []byte) error {
+		var adv AdvancementEvent
+		if err := json.Unmarshal(data, &adv); err != nil {
+			return fmt.Errorf("invalid advancement '%s': %s", key, err)
+		}
+
+		if (player == "" || strings.EqualFold(adv.Player, player)) && !adv.At.Before(from) && adv.At.Before(to) {
+			list = append(list, adv)
+		}
+		return nil
+	})
+
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].At.Before(list[j].At)
+	})
+	return list, err
+}
+
+// eventKey creates the key of an event that happened at a time, so that
+// events are sorted by time.
+func eventKey(at time.Time, player string) string {
+	return at.UTC().Format(eventTimeFormat) + "/" + strings.ToLower(player)
+}
+
+// forEachEvent calls fn with the data of every event in a bucket that
+// happened in a time range.
+func forEachEvent(repo storage.Repository, bucket string, from time.Time, to time.Time, fn func(data []byte) error) error {
+	start := from.UTC().Format(eventTimeFormat)
+	end := to.UTC().Format(eventTimeFormat)
+	return repo.ForEach(bucket, func(key string, data []byte) error {
+		if key < start || key >= end {
+			return nil
+		}
+
+		if err := fn(data); err != nil {
+			return fmt.Errorf("invalid event '%s': %s", key, err)
+		}
+		return nil
+	})
+}
+
+// RecordDeath records a player dying in game.
+func (p *Parser) RecordDeath(death DeathEvent) {
+	if p.events == nil {
+		return
+	}
+
+	if err := p.events.AddDeath(death); err != nil {
+		p.log.Errorf("Error recording the death of '%s': %s\n", death.Player, err)
+	}
+}
+
+// RecordAdvancement records a player getting an advancement.
+func (p *Parser) RecordAdvancement(adv AdvancementEvent) {
+	if p.events == nil {
+		return
+	}
+
+	if err := p.events.AddAdvancement(adv); err != nil {
+		p.log.Errorf("Error recording the advancement of '%s': %s\n", adv.Player, err)
+	}
+}
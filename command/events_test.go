@@ -0,0 +1,109 @@
+package command
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDeathsInRange(t *testing.T) {
+	// given
+	_, db, cleanup := openTestDB(t)
+	defer cleanup()
+	eventLog := NewEventLog(db)
+	start := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+	eventLog.AddDeath(DeathEvent{Player: "Steve", Cause: "Fall", At: start})
+	eventLog.AddDeath(DeathEvent{Player: "Alex", Cause: "Shot", Killer: "Skeleton", At: start.Add(time.Hour)})
+	eventLog.AddDeath(DeathEvent{Player: "Steve", Cause: "Lava", At: start.Add(48 * time.Hour)})
+
+	// when
+	deaths, err := eventLog.Deaths(start, start.Add(24*time.Hour))
+
+	// then
+	if err != nil {
+		t.Fatalf("Failed to get deaths: %s", err)
+	}
+	if len(deaths) != 2 || deaths[0].Player != "Steve" || deaths[1].Killer != "Skeleton" {
+		t.Errorf("Deaths in range are incorrect: %+v", deaths)
+	}
+}
+
+func TestAdvancementsOnlyFirstKept(t *testing.T) {
+	// given
+	_, db, cleanup := openTestDB(t)
+	defer cleanup()
+	eventLog := NewEventLog(db)
+	start := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+	eventLog.AddAdvancement(AdvancementEvent{Player: "Steve", Title: "Stone Age", At: start})
+	eventLog.AddAdvancement(AdvancementEvent{Player: "Alex", Title: "Stone Age", At: start.Add(time.Minute)})
+
+	// when
+	eventLog.AddAdvancement(AdvancementEvent{Player: "Steve", Title: "Stone Age", At: start.Add(time.Hour)})
+	advs, err := eventLog.Advancements("steve", time.Time{}, start.Add(24*time.Hour))
+
+	// then
+	if err != nil {
+		t.Fatalf("Failed to get advancements: %s", err)
+	}
+	if len(advs) != 1 || !advs[0].At.Equal(start) {
+		t.Errorf("Only the first advancement should be kept: %+v", advs)
+	}
+}
+
+func TestDeathsLeaderboard(t *testing.T) {
+	// given
+	deaths := []DeathEvent{
+		{Player: "Steve"},
+		{Player: "Alex"},
+		{Player: "steve"},
+		{Player: "Notch"},
+	}
+	expected := "**1.** steve — 2\n**2.** Alex — 1\n**3.** Notch — 1\n"
+
+	// when
+	actual := deathsLeaderboard(deaths)
+
+	// then
+	if actual != expected {
+		t.Errorf("Leaderboard is incorrect, expected: %q, got: %q", expected, actual)
+	}
+}
+
+func TestLastDigestTime(t *testing.T) {
+	tests := []struct {
+		now      time.Time
+		expected time.Time
+	}{
+		// Thursday, so the last Sunday
+		{time.Date(2020, 10, 8, 12, 0, 0, 0, time.UTC), time.Date(2020, 10, 4, 18, 0, 0, 0, time.UTC)},
+		// Sunday before the hour, so the Sunday before
+		{time.Date(2020, 10, 11, 17, 0, 0, 0, time.UTC), time.Date(2020, 10, 4, 18, 0, 0, 0, time.UTC)},
+		// Sunday after the hour
+		{time.Date(2020, 10, 11, 19, 0, 0, 0, time.UTC), time.Date(2020, 10, 11, 18, 0, 0, 0, time.UTC)},
+	}
+
+	for _, test := range tests {
+		// when
+		actual := lastDigestTime(test.now, time.Sunday, 18)
+
+		// then
+		if !actual.Equal(test.expected) {
+			t.Errorf("Digest time for %s is incorrect, expected: %s, got: %s", test.now, test.expected, actual)
+		}
+	}
+}
+
+func TestOverlap(t *testing.T) {
+	// given
+	from := time.Date(2020, 10, 4, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 7)
+
+	// when
+	before := overlap(from.Add(-time.Hour), from.Add(time.Hour), from, to)
+	inside := overlap(from.Add(time.Hour), from.Add(3*time.Hour), from, to)
+	outside := overlap(to.Add(time.Hour), to.Add(2*time.Hour), from, to)
+
+	// then
+	if before != time.Hour || inside != 2*time.Hour || outside != 0 {
+		t.Errorf("Overlaps are incorrect: %s, %s, %s", before, inside, outside)
+	}
+}
@@ -6,79 +6,164 @@ import (
 
 	"github.com/diamondburned/arikawa/discord"
 	"github.com/diamondburned/arikawa/state"
+	"gitlab.com/EbonJaeger/dolphin/config"
 )
 
 // ShowHelp creates and sends an embed listing all of the commands that
 // can be used on Discord.
-func ShowHelp(state *state.State, cmd DiscordCommand) error {
-	if len(cmd.Args) == 1 {
-		topic := strings.ToLower(cmd.Args[0])
+func (p *Parser) ShowHelp(state *state.State, cmd DiscordCommand) error {
+	if cmd.HasArg("topic") {
+		topic := strings.ToLower(cmd.StringArg("topic"))
 
 		// Show a more detailed help page if one if available
-		switch topic {
-		case "config":
-			return showConfigHelp(state, cmd)
-		default:
-			{
-				embed := CreateEmbed(WarnColor, "Unknown Help Topic", fmt.Sprintf("There is no help page for `%s`. Use `!help` for commands.", topic), "")
-				return SendCommandEmbed(state, cmd, embed)
-			}
+		if topic == "config" {
+			return p.showConfigHelp(state, cmd)
+		}
+		if handler := findHandler(p.handlers, topic); handler != nil {
+			return showCommandHelp(state, cmd, *handler)
+		}
+
+		embed := CreateEmbed(WarnColor, "Unknown Help Topic", fmt.Sprintf("There is no help page for `%s`. Use `%shelp` for commands.", topic, commandPrefix(cmd)), "")
+		return SendCommandEmbed(state, cmd, embed)
+	}
+
+	// Show the default help page
+	return p.showDefaultHelp(state, cmd)
+}
+
+// Usage creates the usage text for a command, e.g. `!config set <option> <value...>`.
+// Optional parameters are shown in square brackets.
+func Usage(prefix string, path string, handler Handler) string {
+	b := strings.Builder{}
+	b.WriteString(prefix)
+	b.WriteString(path)
+
+	if len(handler.Subcommands) > 0 && handler.Run == nil {
+		names := make([]string, 0, len(handler.Subcommands))
+		for _, sub := range handler.Subcommands {
+			names = append(names, sub.Name)
+		}
+		b.WriteString(fmt.Sprintf(" <%s>", strings.Join(names, "|")))
+	}
+
+	for _, param := range handler.Params {
+		name := param.Name
+		if param.Type == RestParam {
+			name += "..."
+		}
+
+		if param.Optional {
+			b.WriteString(fmt.Sprintf(" [%s]", name))
+		} else {
+			b.WriteString(fmt.Sprintf(" <%s>", name))
 		}
-	} else {
-		// Show the default help page
-		return showDefaultHelp(state, cmd)
 	}
 
+	return b.String()
 }
 
-func showConfigHelp(state *state.State, cmd DiscordCommand) error {
+// paramHint describes the values a type of parameter accepts.
+func paramHint(t ParamType) string {
+	switch t {
+	case IntParam:
+		return "number"
+	case BoolParam:
+		return "true/false"
+	case UserParam:
+		return "user mention or id"
+	case ChannelParam:
+		return "channel id or channel mention"
+	case RoleParam:
+		return "role mention or id"
+	case DurationParam:
+		return "duration, e.g. 30m or 1d12h"
+	default:
+		return "text"
+	}
+}
+
+func showCommandHelp(state *state.State, cmd DiscordCommand, handler Handler) error {
+	prefix := commandPrefix(cmd)
+
 	// Create our help embed
 	embed := discord.Embed{
-		Title: "Config Command Help",
+		Title: fmt.Sprintf("%s%s Command Help", prefix, handler.Name),
 		Type:  discord.NormalEmbed,
 		Color: InfoColor,
 	}
 
-	// Create our description text with all of the commands
 	b := strings.Builder{}
-	b.WriteString("Here is a list of all configuration options that can be set via the `!config` command.\n")
-	b.WriteString("The configuration can be updated by entering `!config <option> <value>`.\n")
+	b.WriteString(handler.Desc + "\n")
 	b.WriteString("\n")
-	b.WriteString("**Name — Value**\n")
+	b.WriteString(fmt.Sprintf("**Usage:** `%s`\n", Usage(prefix, handler.Name, handler)))
+	if len(handler.Aliases) > 0 {
+		b.WriteString(fmt.Sprintf("**Aliases:** `%s`\n", strings.Join(handler.Aliases, "`, `")))
+	}
 
-	b.WriteString("`allowmentions` ** — ** true/false\n")
-	b.WriteString("`channel` ** — ** channel id or channel mention\n")
-	b.WriteString("`showadvancements` ** — ** true/false\n")
-	b.WriteString("`showdeaths` ** — ** true/false\n")
-	b.WriteString("`showjoinleave` ** — ** true/false\n")
-	b.WriteString("`usemembernicks` ** — ** true/false\n")
+	// Describe each parameter
+	if len(handler.Params) > 0 {
+		b.WriteString("\n")
+		for _, param := range handler.Params {
+			b.WriteString(fmt.Sprintf("`%s` ** — ** %s (%s)\n", param.Name, param.Desc, paramHint(param.Type)))
+		}
+	}
+
+	// List each subcommand
+	if len(handler.Subcommands) > 0 {
+		b.WriteString("\n**Subcommands**\n")
+		for _, sub := range handler.Subcommands {
+			b.WriteString(fmt.Sprintf("`%s`  **—**  %s\n", Usage(prefix, handler.Name+" "+sub.Name, sub), sub.Desc))
+		}
+	}
 
 	embed.Description = b.String()
 
-	// Create a DM channel with the sender
-	dm, err := state.Client.CreatePrivateChannel(cmd.Sender.ID)
-	if err != nil {
-		return err
+	return SendPrivateEmbed(state, cmd, embed)
+}
+
+func (p *Parser) showConfigHelp(state *state.State, cmd DiscordCommand) error {
+	// Create our help embed
+	embed := discord.Embed{
+		Title: "Config Command Help",
+		Type:  discord.NormalEmbed,
+		Color: InfoColor,
 	}
 
-	// Attempt to send the help embed to the DM
-	if _, err := state.Client.SendEmbed(dm.ID, embed); err != nil {
-		// An error happened; Probably the sender doesn't allow DM's from randos.
-		// So, send it to the channel instead, and remove after 30 seconds.
-		return SendCommandEmbed(state, cmd, embed)
+	// Create our description text with all of the commands
+	b := strings.Builder{}
+	prefix := commandPrefix(cmd)
+	b.WriteString(fmt.Sprintf("Here is a list of all configuration options that can be set via the `%sconfig` command.\n", prefix))
+	b.WriteString(fmt.Sprintf("The configuration can be updated by entering `%sconfig set <option> <value>`.\n", prefix))
+	b.WriteString(fmt.Sprintf("Use `%sconfig list` to see the current values.\n", prefix))
+	b.WriteString("\n")
+	b.WriteString("**Name — Value**\n")
+
+	for _, setting := range config.Settings() {
+		if setting.Secret || setting.ReadOnly {
+			continue
+		}
+		name := setting.Name
+		if setting.Alias != "" {
+			name = setting.Alias
+		}
+		b.WriteString(fmt.Sprintf("`%s` ** — ** %s\n", name, settingHint(setting)))
 	}
 
-	return nil
+	embed.Description = b.String()
+
+	return SendPrivateEmbed(state, cmd, embed)
 }
 
-func showDefaultHelp(state *state.State, cmd DiscordCommand) error {
+func (p *Parser) showDefaultHelp(state *state.State, cmd DiscordCommand) error {
+	prefix := commandPrefix(cmd)
+
 	// Create our help embed
 	embed := discord.Embed{
 		Title: "Bot Command Help",
 		Type:  discord.NormalEmbed,
 		Color: InfoColor,
 		Footer: &discord.EmbedFooter{
-			Text: "Use !help config to view possible options",
+			Text: fmt.Sprintf("Use %shelp <command> for more information about a command", prefix),
 		},
 	}
 
@@ -86,23 +171,10 @@ func showDefaultHelp(state *state.State, cmd DiscordCommand) error {
 	b := strings.Builder{}
 	b.WriteString("Here is a list of all available commands:\n")
 	b.WriteString("\n")
-	for _, handler := range handlers {
-		b.WriteString(fmt.Sprintf("`!%s`  **—**  %s\n", handler.Name, handler.Desc))
+	for _, handler := range p.handlers {
+		b.WriteString(fmt.Sprintf("`%s`  **—**  %s\n", Usage(prefix, handler.Name, handler), handler.Desc))
 	}
 	embed.Description = b.String()
 
-	// Create a DM channel with the sender
-	dm, err := state.Client.CreatePrivateChannel(cmd.Sender.ID)
-	if err != nil {
-		return err
-	}
-
-	// Attempt to send the help embed to the DM
-	if _, err := state.Client.SendEmbed(dm.ID, embed); err != nil {
-		// An error happened; Probably the sender doesn't allow DM's from randos.
-		// So, send it to the channel instead, and remove after 30 seconds.
-		return SendCommandEmbed(state, cmd, embed)
-	}
-
-	return nil
+	return SendPrivateEmbed(state, cmd, embed)
 }
@@ -0,0 +1,278 @@
+package command
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/diamondburned/arikawa/discord"
+	"github.com/diamondburned/arikawa/gateway"
+	"github.com/diamondburned/arikawa/state"
+	"github.com/diamondburned/arikawa/utils/httputil"
+)
+
+// interactionEndpoint is the Discord API that application commands and
+// interactions are available in.
+const interactionEndpoint = "https://discord.com/api/v8/"
+
+// Interaction and response types that we use.
+const (
+	interactionTypeCommand         = 2
+	interactionTypeComponent       = 3
+	responseTypeMessage            = 4
+	responseTypeDeferredWithSource = 5
+	responseTypeUpdateMessage      = 7
+	messageFlagEphemeral           = 64
+)
+
+// Message component types and button styles.
+const (
+	ComponentTypeActionRow = 1
+	ComponentTypeButton    = 2
+
+	ButtonStylePrimary   = 1
+	ButtonStyleSecondary = 2
+	ButtonStyleSuccess   = 3
+	ButtonStyleDanger    = 4
+)
+
+// Application command option types.
+const (
+	OptionTypeSubcommand = 1
+	OptionTypeString     = 3
+	OptionTypeInteger    = 4
+	OptionTypeBoolean    = 5
+	OptionTypeUser       = 6
+	OptionTypeChannel    = 7
+	OptionTypeRole       = 8
+)
+
+// Our version of arikawa doesn't know about interactions, so tell the
+// gateway how to decode them.
+func init() {
+	gateway.EventCreator["INTERACTION_CREATE"] = func() gateway.Event {
+		return new(InteractionCreateEvent)
+	}
+}
+
+// InteractionCreateEvent is sent by Discord when a user uses one of our
+// slash commands.
+type InteractionCreateEvent struct {
+	ID            discord.Snowflake `json:"id"`
+	ApplicationID discord.Snowflake `json:"application_id"`
+	Type          int               `json:"type"`
+	Data          *InteractionData  `json:"data,omitempty"`
+	GuildID       discord.GuildID   `json:"guild_id,omitempty"`
+	ChannelID     discord.ChannelID `json:"channel_id"`
+	Member        *discord.Member   `json:"member,omitempty"`
+	User          *discord.User     `json:"user,omitempty"`
+	Token         string            `json:"token"`
+	// Message is the message with the component that was used, for
+	// component interactions.
+	Message *discord.Message `json:"message,omitempty"`
+}
+
+// InteractionData is the slash command that was used, and its options, or
+// the message component that was used.
+type InteractionData struct {
+	ID            discord.Snowflake   `json:"id"`
+	Name          string              `json:"name"`
+	Options       []InteractionOption `json:"options,omitempty"`
+	CustomID      string              `json:"custom_id,omitempty"`
+	ComponentType int                 `json:"component_type,omitempty"`
+}
+
+// InteractionOption is a value the user gave for a slash command option.
+type InteractionOption struct {
+	Name    string              `json:"name"`
+	Type    int                 `json:"type"`
+	Value   json.RawMessage     `json:"value,omitempty"`
+	Options []InteractionOption `json:"options,omitempty"`
+}
+
+// String gets the option value as a string, no matter the option type.
+func (o InteractionOption) String() string {
+	if s, err := strconv.Unquote(string(o.Value)); err == nil {
+		return s
+	}
+	return string(o.Value)
+}
+
+// ApplicationCommand is a slash command to register with Discord.
+type ApplicationCommand struct {
+	Name        string                     `json:"name"`
+	Description string                     `json:"description"`
+	Options     []ApplicationCommandOption `json:"options,omitempty"`
+}
+
+// ApplicationCommandOption is an option for a slash command.
+type ApplicationCommandOption struct {
+	Type        int                        `json:"type"`
+	Name        string                     `json:"name"`
+	Description string                     `json:"description"`
+	Required    bool                       `json:"required,omitempty"`
+	Options     []ApplicationCommandOption `json:"options,omitempty"`
+}
+
+// Component is an interactive part of a message, such as a button. Buttons
+// must be in an action row.
+type Component struct {
+	Type       int         `json:"type"`
+	Style      int         `json:"style,omitempty"`
+	Label      string      `json:"label,omitempty"`
+	CustomID   string      `json:"custom_id,omitempty"`
+	Disabled   bool        `json:"disabled,omitempty"`
+	Components []Component `json:"components,omitempty"`
+}
+
+// Interaction holds what we need to reply to a slash command.
+type Interaction struct {
+	ApplicationID discord.Snowflake
+	Token         string
+}
+
+type interactionResponse struct {
+	Type int                      `json:"type"`
+	Data *interactionResponseData `json:"data,omitempty"`
+}
+
+type interactionResponseData struct {
+	Content    string          `json:"content,omitempty"`
+	Embeds     []discord.Embed `json:"embeds,omitempty"`
+	Flags      int             `json:"flags,omitempty"`
+	Components *[]Component    `json:"components,omitempty"`
+}
+
+type componentMessageData struct {
+	Embed      discord.Embed `json:"embed"`
+	Components []Component   `json:"components"`
+}
+
+// RegisterCommands registers all of our command handlers as slash commands
+// in a Guild, replacing any that were registered before.
+func (p *Parser) RegisterCommands(state *state.State, appID discord.Snowflake, guildID discord.GuildID) error {
+	commands := make([]ApplicationCommand, 0, len(p.handlers))
+	for _, handler := range p.handlers {
+		commands = append(commands, ApplicationCommand{
+			Name:        handler.Name,
+			Description: handler.Desc,
+			Options:     commandOptions(handler),
+		})
+	}
+
+	url := interactionEndpoint + "applications/" + appID.String() + "/guilds/" + guildID.String() + "/commands"
+	return state.Client.FastRequest("PUT", url, httputil.WithJSONBody(commands))
+}
+
+// commandOptions creates the slash command options for a command from its
+// parameters, or its subcommands if it has any.
+func commandOptions(handler Handler) []ApplicationCommandOption {
+	options := make([]ApplicationCommandOption, 0)
+
+	// Slash commands can't have both subcommands and options
+	if len(handler.Subcommands) > 0 {
+		for _, sub := range handler.Subcommands {
+			options = append(options, ApplicationCommandOption{
+				Type:        OptionTypeSubcommand,
+				Name:        sub.Name,
+				Description: sub.Desc,
+				Options:     commandOptions(sub),
+			})
+		}
+		return options
+	}
+
+	for _, param := range handler.Params {
+		options = append(options, ApplicationCommandOption{
+			Type:        optionType(param.Type),
+			Name:        param.Name,
+			Description: param.Desc,
+			Required:    !param.Optional,
+		})
+	}
+
+	return options
+}
+
+// optionType gets the slash command option type for a parameter type.
+func optionType(t ParamType) int {
+	switch t {
+	case IntParam:
+		return OptionTypeInteger
+	case BoolParam:
+		return OptionTypeBoolean
+	case UserParam:
+		return OptionTypeUser
+	case ChannelParam:
+		return OptionTypeChannel
+	case RoleParam:
+		return OptionTypeRole
+	default:
+		return OptionTypeString
+	}
+}
+
+// deferInteraction tells Discord that we received a slash command and will
+// reply to it later. The reply will only be visible to the user that used
+// the command.
+func deferInteraction(state *state.State, e *InteractionCreateEvent) error {
+	resp := interactionResponse{
+		Type: responseTypeDeferredWithSource,
+		Data: &interactionResponseData{
+			Flags: messageFlagEphemeral,
+		},
+	}
+
+	return respondInteraction(state, e, resp)
+}
+
+// respondInteraction sends the initial response to an interaction.
+func respondInteraction(state *state.State, e *InteractionCreateEvent, resp interactionResponse) error {
+	url := interactionEndpoint + "interactions/" + e.ID.String() + "/" + e.Token + "/callback"
+	return state.Client.FastRequest("POST", url, httputil.WithJSONBody(resp))
+}
+
+// respondInteractionEmbed responds to an interaction with an embed that is
+// only visible to the user that used it.
+func respondInteractionEmbed(state *state.State, e *InteractionCreateEvent, embed discord.Embed) error {
+	resp := interactionResponse{
+		Type: responseTypeMessage,
+		Data: &interactionResponseData{Embeds: []discord.Embed{embed}, Flags: messageFlagEphemeral},
+	}
+
+	return respondInteraction(state, e, resp)
+}
+
+// SendComponentEmbed sends an embed with message components, such as
+// buttons, to a channel.
+func SendComponentEmbed(state *state.State, channelID discord.ChannelID, embed discord.Embed, components []Component) error {
+	data := componentMessageData{
+		Embed:      embed,
+		Components: components,
+	}
+
+	url := interactionEndpoint + "channels/" + channelID.String() + "/messages"
+	return state.Client.FastRequest("POST", url, httputil.WithJSONBody(data))
+}
+
+// SendInteractionEmbed replies to a slash command with an embed.
+func SendInteractionEmbed(state *state.State, interaction *Interaction, embed discord.Embed) error {
+	data := interactionResponseData{
+		Embeds: []discord.Embed{embed},
+	}
+
+	url := interactionEndpoint + "webhooks/" + interaction.ApplicationID.String() + "/" + interaction.Token + "/messages/@original"
+	return state.Client.FastRequest("PATCH", url, httputil.WithJSONBody(data))
+}
+
+// SendInteractionFollowup sends another embed in reply to a slash command,
+// after the first reply. Like the first reply, it is only visible to the user
+// that used the command.
+func SendInteractionFollowup(state *state.State, interaction *Interaction, embed discord.Embed) error {
+	data := interactionResponseData{
+		Embeds: []discord.Embed{embed},
+		Flags:  messageFlagEphemeral,
+	}
+
+	url := interactionEndpoint + "webhooks/" + interaction.ApplicationID.String() + "/" + interaction.Token
+	return state.Client.FastRequest("POST", url, httputil.WithJSONBody(data))
+}
@@ -0,0 +1,56 @@
+package command
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDecodeInteraction(t *testing.T) {
+	// given
+	raw := `{
+		"id": "786008729715212338",
+		"application_id": "775799577604587520",
+		"type": 2,
+		"data": {
+			"id": "771825006014889984",
+			"name": "config",
+			"options": [
+				{"name": "value", "type": 3, "value": "true"},
+				{"name": "option", "type": 3, "value": "showdeaths"}
+			]
+		},
+		"guild_id": "765957742184005632",
+		"channel_id": "772908445358620702",
+		"token": "A_UNIQUE_TOKEN"
+	}`
+
+	// when
+	var e InteractionCreateEvent
+	if err := json.Unmarshal([]byte(raw), &e); err != nil {
+		t.Fatalf("Failed to decode interaction: %s", err)
+	}
+
+	// then
+	if e.Data == nil || e.Data.Name != "config" {
+		t.Fatalf("Decoded interaction has incorrect command: %+v", e.Data)
+	}
+	if actual := e.Data.Options[1].String(); actual != "showdeaths" {
+		t.Errorf("Decoded option has incorrect value, got: %s, expected: %s", actual, "showdeaths")
+	}
+	if e.ApplicationID.String() != "775799577604587520" {
+		t.Errorf("Decoded interaction has incorrect application ID, got: %s", e.ApplicationID)
+	}
+}
+
+func TestNumberOptionString(t *testing.T) {
+	// given
+	option := InteractionOption{Name: "count", Type: 4, Value: json.RawMessage("42")}
+
+	// when
+	actual := option.String()
+
+	// then
+	if actual != "42" {
+		t.Errorf("Number option has incorrect value, got: %s, expected: %s", actual, "42")
+	}
+}
@@ -0,0 +1,65 @@
+package command
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/diamondburned/arikawa/state"
+)
+
+// LinkAccount sends the user a code in a DM to type in Minecraft, to link
+// their Minecraft and Discord accounts.
+func (p *Parser) LinkAccount(state *state.State, cmd DiscordCommand) error {
+	if !p.config().Discord.Linking.Enabled {
+		embed := CreateEmbed(WarnColor, "Linking Disabled", ":warning: Account linking isn't enabled on this server.", "")
+		return SendCommandEmbed(state, cmd, embed)
+	}
+
+	code, err := p.links.NewCode(cmd.Sender.ID, time.Now())
+	if err != nil {
+		return err
+	}
+
+	text := fmt.Sprintf("To link your Minecraft account, type this in Minecraft chat within %d minutes:\n\n`%slink %s`", int(linkCodeExpiry.Minutes()), p.config().Discord.Commands.Prefix, code)
+	if player, ok, _ := p.links.ByUser(cmd.Sender.ID); ok {
+		text += fmt.Sprintf("\n\nYou are currently linked to **%s**. Linking another account will replace it.", player.Player)
+	}
+
+	embed := CreateEmbed(InfoColor, "Link Your Account", text, "Don't share this code with anyone!")
+
+	// Slash command replies are already private
+	if cmd.Interaction != nil {
+		return SendInteractionEmbed(state, cmd.Interaction, embed)
+	}
+
+	// Never show the code in the channel if the user doesn't allow DMs
+	dm, err := state.Client.CreatePrivateChannel(cmd.Sender.ID)
+	if err == nil {
+		_, err = state.Client.SendEmbed(dm.ID, embed)
+	}
+	if err != nil {
+		embed := CreateEmbed(WarnColor, "Can't Send Code", ":warning: I couldn't DM you your link code. Please allow DMs from server members and try again.", "")
+		return SendCommandEmbed(state, cmd, embed)
+	}
+
+	return nil
+}
+
+// UnlinkAccount removes the link between the user's Minecraft and Discord
+// accounts.
+func (p *Parser) UnlinkAccount(state *state.State, cmd DiscordCommand) error {
+	player, ok, err := p.links.Unlink(cmd.Sender.ID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		embed := CreateEmbed(WarnColor, "Not Linked", ":warning: Your Discord account isn't linked to a Minecraft account.", "")
+		return SendCommandEmbed(state, cmd, embed)
+	}
+
+	p.log.Infof("User '%s' unlinked their account from '%s'\n", cmd.Sender.Username, player)
+	p.UnsyncRoles(player)
+
+	embed := CreateEmbed(SuccessColor, "Account Unlinked", fmt.Sprintf(":white_check_mark: Your Discord account is no longer linked to **%s**.", player), "")
+	return SendCommandEmbed(state, cmd, embed)
+}
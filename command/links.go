@@ -0,0 +1,338 @@
+package command
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/diamondburned/arikawa/discord"
+	"github.com/diamondburned/arikawa/state"
+	"gitlab.com/EbonJaeger/dolphin/config"
+	"gitlab.com/EbonJaeger/dolphin/storage"
+)
+
+const (
+	// linkCodeChars are the characters used in link codes. Characters that
+	// look alike, such as 0 and O, aren't used.
+	linkCodeChars = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	// linkCodeLength is how many characters are in a link code.
+	linkCodeLength = 6
+	// linkCodeExpiry is how long a link code can be used for.
+	linkCodeExpiry = 10 * time.Minute
+)
+
+// linkCodeRegex matches a link code typed in Minecraft chat, after the
+// command prefix.
+var linkCodeRegex = regexp.MustCompile(`(?i)^link\s+([A-Z0-9]{6})$`)
+
+// AccountLink is a Minecraft player linked to a Discord user.
+type AccountLink struct {
+	Player   string         `json:"player"`
+	UserID   discord.UserID `json:"user_id"`
+	LinkedAt time.Time      `json:"linked_at"`
+}
+
+// pendingLink is a link code that a Discord user was sent, but hasn't typed
+// in game yet.
+type pendingLink struct {
+	UserID    discord.UserID
+	ExpiresAt time.Time
+}
+
+// LinkList keeps track of linked accounts and link codes. Links are kept in
+// the database so they survive restarts, keyed by Discord user ID.
+type LinkList struct {
+	mu      sync.Mutex
+	pending map[string]pendingLink
+	repo    storage.Repository
+}
+
+// NewLinkList creates a list of linked accounts stored in a repository.
+func NewLinkList(repo storage.Repository) *LinkList {
+	return &LinkList{
+		pending: make(map[string]pendingLink),
+		repo:    repo,
+	}
+}
+
+// NewCode creates a new link code for a Discord user. Any code the user had
+// before can no longer be used.
+func (l *LinkList) NewCode(userID discord.UserID, now time.Time) (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for code, p := range l.pending {
+		if p.UserID == userID || now.After(p.ExpiresAt) {
+			delete(l.pending, code)
+		}
+	}
+
+	code, err := randomCode()
+	if err != nil {
+		return "", err
+	}
+
+	l.pending[code] = pendingLink{UserID: userID, ExpiresAt: now.Add(linkCodeExpiry)}
+	return code, nil
+}
+
+// Verify links a player to the Discord user that was sent a link code. The
+// code can only be used once. Any other links of the player or user are
+// replaced.
+func (l *LinkList) Verify(player string, code string, now time.Time) (AccountLink, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	code = strings.ToUpper(code)
+	p, ok := l.pending[code]
+	if !ok || now.After(p.ExpiresAt) {
+		delete(l.pending, code)
+		return AccountLink{}, errors.New("invalid or expired link code")
+	}
+	delete(l.pending, code)
+
+	// Remove the player's link to anyone else
+	if existing, ok, err := l.byPlayer(player); err != nil {
+		return AccountLink{}, err
+	} else if ok {
+		if err := l.repo.Delete(storage.LinksBucket, existing.UserID.String()); err != nil {
+			return AccountLink{}, err
+		}
+	}
+
+	link := AccountLink{Player: player, UserID: p.UserID, LinkedAt: now}
+	return link, l.repo.Put(storage.LinksBucket, p.UserID.String(), link)
+}
+
+// Unlink removes the link of a Discord user, and returns the player they
+// were linked to.
+func (l *LinkList) Unlink(userID discord.UserID) (string, bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var link AccountLink
+	ok, err := l.repo.Get(storage.LinksBucket, userID.String(), &link)
+	if err != nil || !ok {
+		return "", false, err
+	}
+
+	return link.Player, true, l.repo.Delete(storage.LinksBucket, userID.String())
+}
+
+// ByPlayer gets the link of a Minecraft player, case-insensitive.
+func (l *LinkList) ByPlayer(player string) (AccountLink, bool, error) {
+	return l.byPlayer(player)
+}
+
+// ByUser gets the link of a Discord user.
+func (l *LinkList) ByUser(userID discord.UserID) (AccountLink, bool, error) {
+	var link AccountLink
+	ok, err := l.repo.Get(storage.LinksBucket, userID.String(), &link)
+	if err != nil {
+		return AccountLink{}, false, err
+	}
+	return link, ok, nil
+}
+
+// byPlayer looks through every link for the one of a Minecraft player.
+func (l *LinkList) byPlayer(player string) (AccountLink, bool, error) {
+	found := AccountLink{}
+	err := l.repo.ForEach(storage.LinksBucket, func(key string, data []byte) error {
+		var link AccountLink
+		if err := json.Unmarshal(data, &link); err != nil {
+			return fmt.Errorf("invalid linked account '%s': %s", key, err)
+		}
+
+		if strings.EqualFold(link.Player, player) {
+			found = link
+		}
+		return nil
+	})
+
+	return found, found.Player != "", err
+}
+
+// randomCode creates a random link code.
+func randomCode() (string, error) {
+	b := strings.Builder{}
+	max := big.NewInt(int64(len(linkCodeChars)))
+	for i := 0; i < linkCodeLength; i++ {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b.WriteByte(linkCodeChars[n.Int64()])
+	}
+	return b.String(), nil
+}
+
+// LinkedUser gets the Discord user linked to a Minecraft player.
+func (p *Parser) LinkedUser(player string) (discord.UserID, bool) {
+	if p.links == nil || !p.config().Discord.Linking.Enabled {
+		return 0, false
+	}
+
+	link, ok, err := p.links.ByPlayer(player)
+	if err != nil {
+		p.log.Errorf("Error reading linked accounts: %s\n", err)
+	}
+	return link.UserID, ok
+}
+
+// LinkedPlayer gets the Minecraft player linked to a Discord user.
+func (p *Parser) LinkedPlayer(userID discord.UserID) (string, bool) {
+	if p.links == nil || !p.config().Discord.Linking.Enabled {
+		return "", false
+	}
+
+	link, ok, err := p.links.ByUser(userID)
+	if err != nil {
+		p.log.Errorf("Error reading linked accounts: %s\n", err)
+	}
+	return link.Player, ok
+}
+
+// ParseLinkCode checks if a Minecraft chat message is a link code, such as
+// "!link ABC123", and returns the code.
+func (p *Parser) ParseLinkCode(message string) (string, bool) {
+	if !p.config().Discord.Linking.Enabled || !strings.HasPrefix(message, p.config().Discord.Commands.Prefix) {
+		return "", false
+	}
+
+	match := linkCodeRegex.FindStringSubmatch(strings.TrimSpace(strings.TrimPrefix(message, p.config().Discord.Commands.Prefix)))
+	if match == nil {
+		return "", false
+	}
+	return match[1], true
+}
+
+// CompleteLink links a Minecraft player to the Discord user that was sent a
+// link code, and lets both of them know.
+func (p *Parser) CompleteLink(state *state.State, guildID discord.GuildID, player string, code string) {
+	link, err := p.links.Verify(player, code, time.Now())
+	if err != nil {
+		p.log.Infof("Player '%s' tried to link their account: %s\n", player, err)
+		p.tellPlayer(player, "That link code is invalid or has expired.", "red")
+		return
+	}
+
+	p.log.Infof("Player '%s' linked their account to Discord user '%s'\n", player, link.UserID)
+	p.tellPlayer(player, "Your account has been linked to Discord!", "green")
+
+	// Let the Discord user know
+	if dm, err := state.Client.CreatePrivateChannel(link.UserID); err == nil {
+		embed := CreateEmbed(SuccessColor, "Account Linked", fmt.Sprintf(":white_check_mark: Your Discord account is now linked to **%s**.", player), "")
+		if _, err := state.Client.SendEmbed(dm.ID, embed); err != nil {
+			p.log.Warnf("Unable to tell the user about their linked account: %s\n", err)
+		}
+	}
+
+	p.SyncRoles(state, guildID, link.UserID)
+}
+
+// SyncRoles runs the configured role sync commands for a linked Discord user,
+// so their Minecraft player matches their Discord roles.
+func (p *Parser) SyncRoles(state *state.State, guildID discord.GuildID, userID discord.UserID) {
+	if _, ok := p.LinkedPlayer(userID); !ok {
+		return
+	}
+
+	member, err := state.Member(guildID, userID)
+	if err != nil {
+		p.log.Warnf("Unable to get the roles of user '%s' to sync: %s\n", userID, err)
+		return
+	}
+
+	p.SyncMemberRoles(userID, member.RoleIDs)
+}
+
+// SyncMemberRoles runs the configured role sync commands for a linked Discord
+// user with the given roles.
+func (p *Parser) SyncMemberRoles(userID discord.UserID, roles []discord.RoleID) {
+	player, ok := p.LinkedPlayer(userID)
+	if !ok || p.config().Discord.Linking.RoleSync == nil {
+		return
+	}
+
+	roleIDs := make([]string, 0, len(roles))
+	for _, roleID := range roles {
+		roleIDs = append(roleIDs, roleID.String())
+	}
+
+	p.runRoleSyncCommands(roleSyncCommands(*p.config().Discord.Linking.RoleSync, roleIDs, player))
+}
+
+// UnsyncRoles runs the configured commands to remove the roles of a player
+// in Minecraft, such as when they unlink their account.
+func (p *Parser) UnsyncRoles(player string) {
+	if p.config().Discord.Linking.RoleSync == nil {
+		return
+	}
+
+	p.runRoleSyncCommands(roleSyncCommands(*p.config().Discord.Linking.RoleSync, []string{}, player))
+}
+
+// roleSyncCommands gets the server commands to run to sync the roles of a
+// player.
+func roleSyncCommands(rules []config.RoleSyncConfig, roleIDs []string, player string) []string {
+	commands := make([]string, 0, len(rules))
+	for _, rule := range rules {
+		command := rule.RemoveCommand
+		if containsID(roleIDs, rule.RoleID) {
+			command = rule.AddCommand
+		}
+
+		if strings.TrimSpace(command) != "" {
+			commands = append(commands, strings.ReplaceAll(command, "%player%", player))
+		}
+	}
+	return commands
+}
+
+// runRoleSyncCommands sends role sync commands to the server.
+func (p *Parser) runRoleSyncCommands(commands []string) {
+	if len(commands) == 0 {
+		return
+	}
+
+	// Create RCON connection
+	conn, err := p.connect()
+	if err != nil {
+		p.log.Warnf("Unable to sync roles: %s\n", err)
+		return
+	}
+	defer conn.Close()
+
+	for _, command := range commands {
+		p.log.Debugf("Syncing roles with command: %s\n", command)
+		if _, err := conn.SendCommand(command); err != nil {
+			p.log.Errorf("Error running role sync command '%s': %s\n", command, err)
+		}
+	}
+}
+
+// tellPlayer sends a private message to a player in Minecraft.
+func (p *Parser) tellPlayer(player string, text string, color string) {
+	data, err := json.Marshal(map[string]string{"text": text, "color": color})
+	if err != nil {
+		return
+	}
+
+	// Create RCON connection
+	conn, err := p.connect()
+	if err != nil {
+		p.log.Warnf("Unable to send a message to '%s': %s\n", player, err)
+		return
+	}
+	defer conn.Close()
+
+	if _, err := conn.SendCommand(fmt.Sprintf("tellraw %s %s", player, data)); err != nil {
+		p.log.Errorf("Error sending a message to '%s': %s\n", player, err)
+	}
+}
@@ -0,0 +1,122 @@
+package command
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"gitlab.com/EbonJaeger/dolphin/config"
+	"gitlab.com/EbonJaeger/dolphin/storage"
+)
+
+func TestLinkAccount(t *testing.T) {
+	// given
+	path, db, cleanup := openTestDB(t)
+	defer cleanup()
+
+	list := NewLinkList(db)
+	now := time.Now()
+	code, err := list.NewCode(1234, now)
+	if err != nil {
+		t.Fatalf("Failed to create link code: %s", err)
+	}
+
+	// when
+	_, err = list.Verify("Steve", code, now.Add(time.Minute))
+
+	// then
+	if err != nil {
+		t.Fatalf("Failed to verify link code: %s", err)
+	}
+	if _, err := list.Verify("Alex", code, now.Add(time.Minute)); err == nil {
+		t.Errorf("Link codes should only work once")
+	}
+
+	db.Close()
+	reopened, err := storage.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen database: %s", err)
+	}
+	defer reopened.Close()
+	if link, ok, _ := NewLinkList(reopened).ByPlayer("steve"); !ok || link.UserID != 1234 {
+		t.Errorf("Linked account was not saved: %+v", link)
+	}
+}
+
+func TestLinkCodeExpires(t *testing.T) {
+	// given
+	_, db, cleanup := openTestDB(t)
+	defer cleanup()
+
+	list := NewLinkList(db)
+	now := time.Now()
+	code, _ := list.NewCode(1234, now)
+
+	// when
+	_, err := list.Verify("Steve", code, now.Add(linkCodeExpiry+time.Second))
+
+	// then
+	if err == nil {
+		t.Errorf("Expired link codes should not work")
+	}
+}
+
+func TestRelinkReplacesLink(t *testing.T) {
+	// given
+	_, db, cleanup := openTestDB(t)
+	defer cleanup()
+
+	list := NewLinkList(db)
+	now := time.Now()
+	code, _ := list.NewCode(1234, now)
+	list.Verify("Steve", code, now)
+
+	// when
+	code, _ = list.NewCode(1234, now)
+	list.Verify("Alex", code, now)
+
+	// then
+	if _, ok, _ := list.ByPlayer("Steve"); ok {
+		t.Errorf("Old link should be replaced")
+	}
+	if link, ok, _ := list.ByUser(1234); !ok || link.Player != "Alex" {
+		t.Errorf("New link is incorrect: %+v", link)
+	}
+
+	player, ok, err := list.Unlink(1234)
+	if err != nil || !ok || player != "Alex" {
+		t.Errorf("Unlinking is incorrect, got: %s %t %v", player, ok, err)
+	}
+}
+
+func TestParseLinkCode(t *testing.T) {
+	// given
+	p := testParser(&config.RootConfig{})
+	p.config().Discord.Commands.Prefix = "!"
+	p.config().Discord.Linking.Enabled = true
+
+	// then
+	if code, ok := p.ParseLinkCode("!link abc234"); !ok || code != "abc234" {
+		t.Errorf("Link code was not parsed, got: %s %t", code, ok)
+	}
+	if _, ok := p.ParseLinkCode("I typed link ABC234"); ok {
+		t.Errorf("Chat messages should not be parsed as link codes")
+	}
+}
+
+func TestRoleSyncCommands(t *testing.T) {
+	// given
+	rules := []config.RoleSyncConfig{
+		{RoleID: "100", AddCommand: "team join mods %player%", RemoveCommand: "team leave %player%"},
+		{RoleID: "200", AddCommand: "lp user %player% parent add vip"},
+	}
+	expected := []string{"team join mods Steve"}
+
+	// when
+	actual := roleSyncCommands(rules, []string{"100"}, "Steve")
+
+	// then
+	if !cmp.Equal(actual, expected) {
+		t.Errorf("Role sync commands are incorrect: Diff: %s", cmp.Diff(actual, expected))
+	}
+}
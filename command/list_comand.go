@@ -2,7 +2,6 @@ package command
 
 import (
 	"fmt"
-	"strconv"
 	"strings"
 
 	"github.com/diamondburned/arikawa/discord"
@@ -11,69 +10,37 @@ import (
 )
 
 // ListPlayers sends an RCON command to the Minecraft server to list all online players.
-func ListPlayers(state *state.State, cmd DiscordCommand) error {
+func (p *Parser) ListPlayers(state *state.State, cmd DiscordCommand) error {
 	// Create RCON connection
-	conn, err := rcon.Dial(conf.Minecraft.RconIP, conf.Minecraft.RconPort, conf.Minecraft.RconPassword)
+	conn, err := p.connect()
 	if err != nil {
 		return err
 	}
 	defer conn.Close()
 
-	// Authenticate to RCON
-	if err = conn.Authenticate(); err != nil {
-		return err
-	}
-
 	// Send the command to Minecraft
-	resp, err := conn.SendCommand("minecraft:list")
+	list, err := conn.ListPlayers()
 	if err != nil {
 		return err
 	}
 
-	// Vanilla servers dont support the 'minecraft:' command prefix
-	if strings.HasPrefix(resp, "Unknown or incomplete command") {
-		resp, err = conn.SendCommand("list")
-		if err != nil {
-			return err
-		}
-	}
-
-	embed := createListEmbed(strings.Split(resp, ":"))
+	embed := createListEmbed(list)
 	return SendCommandEmbed(state, cmd, embed)
 }
 
-func createListEmbed(resp []string) discord.Embed {
-	online, max := getPlayerCount(resp[0])
-
+func createListEmbed(list rcon.PlayerList) discord.Embed {
 	embed := discord.Embed{
 		Color:       InfoColor,
-		Description: fmt.Sprintf("There are **%d** out of **%d** players online.", online, max),
+		Description: fmt.Sprintf("There are **%d** out of **%d** players online.", list.Online, list.Max),
 		Title:       "Online Players",
 		Type:        discord.NormalEmbed,
 	}
 
-	if len(resp) > 1 {
+	if len(list.Players) > 0 {
 		embed.Footer = &discord.EmbedFooter{
-			Text: strings.TrimSpace(resp[1]),
+			Text: strings.Join(list.Players, ", "),
 		}
 	}
 
 	return embed
 }
-
-func getPlayerCount(text string) (online, max int) {
-	parts := strings.Split(text, " ")
-	gotOnline := false
-	for _, part := range parts {
-		if num, err := strconv.Atoi(part); err == nil {
-			if gotOnline {
-				max = num
-			} else {
-				online = num
-				gotOnline = true
-			}
-		}
-	}
-
-	return
-}
@@ -0,0 +1,323 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/diamondburned/arikawa/discord"
+	"github.com/diamondburned/arikawa/state"
+	"gitlab.com/EbonJaeger/dolphin/rcon"
+)
+
+// banListPageSize is how many bans are shown on each page of the ban list.
+const banListPageSize = 10
+
+// moderationPlayerParam is the player parameter of the moderation commands.
+var moderationPlayerParam = Param{Name: "player", Desc: "The Minecraft name of the player", Type: StringParam}
+
+// moderationReasonParam is the reason parameter of the moderation commands.
+var moderationReasonParam = Param{Name: "reason", Desc: "Why this is being done", Type: RestParam, Optional: true}
+
+// BanListEntry is a player in the server's banned-players.json file.
+type BanListEntry struct {
+	UUID    string `json:"uuid"`
+	Name    string `json:"name"`
+	Created string `json:"created"`
+	Source  string `json:"source"`
+	Expires string `json:"expires"`
+	Reason  string `json:"reason"`
+}
+
+// moderationAction is something a moderator did, to be posted in the
+// moderation log.
+type moderationAction struct {
+	Title     string
+	Color     discord.Color
+	Player    string
+	Moderator *discord.User
+	Reason    string
+	Duration  time.Duration
+}
+
+// KickPlayer kicks a player from the server.
+func (p *Parser) KickPlayer(state *state.State, cmd DiscordCommand) error {
+	player := cmd.StringArg("player")
+	if !playerNameRegex.MatchString(player) {
+		return sendInvalidName(state, cmd, player)
+	}
+	reason := cmd.StringArg("reason")
+
+	resp, err := p.sendModerationCommand(withReason("kick "+player, reason))
+	if err != nil {
+		return err
+	}
+
+	p.sendModerationLog(state, moderationAction{Title: "Player Kicked", Color: WarnColor, Player: player, Moderator: &cmd.Sender, Reason: reason})
+	return sendModerationResult(state, cmd, resp)
+}
+
+// BanPlayer permanently bans a player from the server.
+func (p *Parser) BanPlayer(state *state.State, cmd DiscordCommand) error {
+	player := cmd.StringArg("player")
+	if !playerNameRegex.MatchString(player) {
+		return sendInvalidName(state, cmd, player)
+	}
+	reason := cmd.StringArg("reason")
+
+	resp, err := p.sendModerationCommand(withReason("ban "+player, reason))
+	if err != nil {
+		return err
+	}
+
+	// The ban is no longer temporary
+	if err := p.tempBans.Remove(player); err != nil {
+		p.log.Errorf("Error saving temporary bans: %s\n", err)
+	}
+
+	p.sendModerationLog(state, moderationAction{Title: "Player Banned", Color: ErrorColor, Player: player, Moderator: &cmd.Sender, Reason: reason})
+	return sendModerationResult(state, cmd, resp)
+}
+
+// TempBanPlayer bans a player from the server for a while. The ban is lifted
+// by the ban scheduler when it expires.
+func (p *Parser) TempBanPlayer(state *state.State, cmd DiscordCommand) error {
+	player := cmd.StringArg("player")
+	if !playerNameRegex.MatchString(player) {
+		return sendInvalidName(state, cmd, player)
+	}
+	duration := cmd.DurationArg("duration")
+	if duration <= 0 {
+		embed := CreateEmbed(WarnColor, "Invalid Duration", ":warning: The ban must last longer than that.", "")
+		return SendCommandEmbed(state, cmd, embed)
+	}
+	reason := cmd.StringArg("reason")
+	expires := time.Now().Add(duration)
+
+	// Let the player know when they can come back
+	serverReason := fmt.Sprintf("Banned until %s", expires.UTC().Format("2006-01-02 15:04 MST"))
+	if reason != "" {
+		serverReason = fmt.Sprintf("%s (%s)", reason, serverReason)
+	}
+
+	resp, err := p.sendModerationCommand(withReason("ban "+player, serverReason))
+	if err != nil {
+		return err
+	}
+
+	ban := TempBan{
+		Player:    player,
+		Reason:    reason,
+		BannedBy:  cmd.Sender.Username,
+		ExpiresAt: expires,
+	}
+	if err := p.tempBans.Add(ban); err != nil {
+		return fmt.Errorf("the player was banned, but the ban couldn't be saved and won't expire: %s", err)
+	}
+
+	p.sendModerationLog(state, moderationAction{Title: "Player Temporarily Banned", Color: ErrorColor, Player: player, Moderator: &cmd.Sender, Reason: reason, Duration: duration})
+	return sendModerationResult(state, cmd, resp)
+}
+
+// PardonPlayer lifts the ban of a player.
+func (p *Parser) PardonPlayer(state *state.State, cmd DiscordCommand) error {
+	player := cmd.StringArg("player")
+	if !playerNameRegex.MatchString(player) {
+		return sendInvalidName(state, cmd, player)
+	}
+
+	resp, err := p.sendModerationCommand("pardon " + player)
+	if err != nil {
+		return err
+	}
+
+	if err := p.tempBans.Remove(player); err != nil {
+		p.log.Errorf("Error saving temporary bans: %s\n", err)
+	}
+
+	p.sendModerationLog(state, moderationAction{Title: "Player Pardoned", Color: SuccessColor, Player: player, Moderator: &cmd.Sender})
+	return sendModerationResult(state, cmd, resp)
+}
+
+// ShowBanList shows a page of the players in the server's banned-players.json
+// file.
+func (p *Parser) ShowBanList(state *state.State, cmd DiscordCommand) error {
+	data, err := ioutil.ReadFile(p.config().Discord.Moderation.BanListPath)
+	if err != nil {
+		return err
+	}
+
+	entries := make([]BanListEntry, 0)
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("invalid ban list file: %s", err)
+	}
+
+	page := 1
+	if cmd.HasArg("page") {
+		page = cmd.IntArg("page")
+	}
+
+	embed := p.createBanListEmbed(entries, page, commandPrefix(cmd))
+	return SendCommandEmbed(state, cmd, embed)
+}
+
+// createBanListEmbed creates an embed showing a page of banned players.
+// Temporary bans show when they expire.
+func (p *Parser) createBanListEmbed(entries []BanListEntry, page int, prefix string) discord.Embed {
+	pages := (len(entries) + banListPageSize - 1) / banListPageSize
+	if pages == 0 {
+		pages = 1
+	}
+	if page < 1 {
+		page = 1
+	}
+	if page > pages {
+		page = pages
+	}
+
+	b := strings.Builder{}
+	if len(entries) == 0 {
+		b.WriteString("There are no banned players.")
+	}
+
+	start := (page - 1) * banListPageSize
+	end := start + banListPageSize
+	if end > len(entries) {
+		end = len(entries)
+	}
+	for _, entry := range entries[start:end] {
+		b.WriteString(fmt.Sprintf("**%s** — banned by %s: %s", entry.Name, entry.Source, entry.Reason))
+		if p.tempBans != nil {
+			ban, ok, err := p.tempBans.Get(entry.Name)
+			if err != nil {
+				p.log.Errorf("Error reading the temporary ban of '%s': %s\n", entry.Name, err)
+			}
+			if ok {
+				b.WriteString(fmt.Sprintf(" (expires in %s)", formatDuration(time.Until(ban.ExpiresAt))))
+			}
+		}
+		b.WriteString("\n")
+	}
+
+	footer := fmt.Sprintf("%d bans | Page %d/%d", len(entries), page, pages)
+	if page < pages {
+		footer += fmt.Sprintf(" | Use %sbanlist %d for the next page", prefix, page+1)
+	}
+
+	return CreateEmbed(InfoColor, "Banned Players", b.String(), footer)
+}
+
+// withReason adds a reason to the end of a server command, if there is one.
+func withReason(command string, reason string) string {
+	// Keep the reason on one line so it can't become another command
+	reason = strings.Join(strings.Fields(reason), " ")
+	if reason == "" {
+		return command
+	}
+	return command + " " + reason
+}
+
+// sendModerationCommand sends a moderation command to the server, and
+// returns the response.
+func (p *Parser) sendModerationCommand(command string) (string, error) {
+	// Create RCON connection
+	conn, err := p.connect()
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	// Send the command to Minecraft
+	resp, err := conn.SendCommand(command)
+	if err != nil {
+		return "", err
+	}
+
+	return rcon.StripFormatting(resp), nil
+}
+
+// sendModerationResult shows the server's response to a moderation command.
+func sendModerationResult(state *state.State, cmd DiscordCommand, resp string) error {
+	if resp == "" {
+		resp = "Done!"
+	}
+	embed := CreateEmbed(SuccessColor, "Moderation", fmt.Sprintf(":white_check_mark: %s", resp), "")
+	return SendCommandEmbed(state, cmd, embed)
+}
+
+// sendModerationLog posts a moderation action to the moderation log
+// channel, or the audit channel if there isn't one.
+func (p *Parser) sendModerationLog(state *state.State, action moderationAction) {
+	embed := createModerationEmbed(action)
+
+	channelID := p.config().Discord.Moderation.LogChannelID
+	if channelID == "" {
+		p.SendAuditEmbed(state, embed)
+		return
+	}
+
+	snowflake, err := discord.ParseSnowflake(channelID)
+	if err != nil {
+		p.log.Warnf("Invalid moderation log channel ID: %s\n", err)
+		return
+	}
+
+	if _, err := state.Client.SendEmbed(discord.ChannelID(snowflake), embed); err != nil {
+		p.log.Errorf("Error sending to the moderation log channel: %s\n", err)
+	}
+}
+
+// createModerationEmbed creates the moderation log embed for an action.
+func createModerationEmbed(action moderationAction) discord.Embed {
+	embed := discord.Embed{
+		Title:     action.Title,
+		Type:      discord.NormalEmbed,
+		Color:     action.Color,
+		Timestamp: discord.NewTimestamp(time.Now()),
+		Fields: []discord.EmbedField{
+			{Name: "Player", Value: action.Player, Inline: true},
+		},
+	}
+
+	if action.Moderator != nil {
+		embed.Fields = append(embed.Fields, discord.EmbedField{Name: "Moderator", Value: action.Moderator.Mention(), Inline: true})
+	}
+	if action.Duration > 0 {
+		embed.Fields = append(embed.Fields, discord.EmbedField{Name: "Duration", Value: formatDuration(action.Duration), Inline: true})
+	}
+
+	reason := action.Reason
+	if reason == "" {
+		reason = "No reason given"
+	}
+	embed.Fields = append(embed.Fields, discord.EmbedField{Name: "Reason", Value: reason})
+
+	return embed
+}
+
+// formatDuration formats a duration in days, hours, and minutes, such as
+// "1d 12h 30m".
+func formatDuration(d time.Duration) string {
+	if d < time.Minute {
+		return "less than a minute"
+	}
+
+	days := int(d / (24 * time.Hour))
+	hours := int(d % (24 * time.Hour) / time.Hour)
+	minutes := int(d % time.Hour / time.Minute)
+
+	parts := make([]string, 0, 3)
+	if days > 0 {
+		parts = append(parts, fmt.Sprintf("%dd", days))
+	}
+	if hours > 0 {
+		parts = append(parts, fmt.Sprintf("%dh", hours))
+	}
+	if minutes > 0 {
+		parts = append(parts, fmt.Sprintf("%dm", minutes))
+	}
+
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,48 @@
+package command
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWithReason(t *testing.T) {
+	// when
+	actual := withReason("kick Steve", "being\nrude   again")
+
+	// then
+	if actual != "kick Steve being rude again" {
+		t.Errorf("Command with reason is incorrect, got: %s", actual)
+	}
+	if actual := withReason("kick Steve", " "); actual != "kick Steve" {
+		t.Errorf("Command without reason is incorrect, got: %s", actual)
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	// given
+	d := 36*time.Hour + 30*time.Minute
+
+	// when
+	actual := formatDuration(d)
+
+	// then
+	if actual != "1d 12h 30m" {
+		t.Errorf("Formatted duration is incorrect, got: %s, expected: %s", actual, "1d 12h 30m")
+	}
+}
+
+func TestCreateModerationEmbed(t *testing.T) {
+	// given
+	action := moderationAction{Title: "Player Temporarily Banned", Player: "Steve", Duration: 2 * time.Hour}
+
+	// when
+	embed := createModerationEmbed(action)
+
+	// then
+	if len(embed.Fields) != 3 {
+		t.Fatalf("Embed has the wrong number of fields: %+v", embed.Fields)
+	}
+	if embed.Fields[1].Value != "2h" || embed.Fields[2].Value != "No reason given" {
+		t.Errorf("Embed fields are incorrect: %+v", embed.Fields)
+	}
+}
@@ -8,82 +8,587 @@ import (
 	"github.com/diamondburned/arikawa/discord"
 	"github.com/diamondburned/arikawa/state"
 	"gitlab.com/EbonJaeger/dolphin/config"
+	"gitlab.com/EbonJaeger/dolphin/rcon"
+	"gitlab.com/EbonJaeger/dolphin/storage"
 )
 
-var (
-	conf     *config.RootConfig
-	handlers []Handler
-	log      *waterlog.WaterLog
-)
+// componentHandler handles a message component, such as a button, being
+// used. The args are the parts of the component's custom ID after its name.
+type componentHandler func(state *state.State, e *InteractionCreateEvent, args []string) error
+
+// ParserOption changes how a command parser is created.
+type ParserOption func(p *Parser)
+
+// WithRconDialer sets how commands connect to the server's RCON. By
+// default, rcon.Connect is used.
+func WithRconDialer(dial rcon.Dialer) ParserOption {
+	return func(p *Parser) {
+		p.dial = dial
+	}
+}
+
+// WithCommandHook sets a function that is called after every command that
+// is run from Discord, with the error the command returned, if any. It isn't
+// called for commands the user isn't allowed to use.
+func WithCommandHook(hook func(cmd DiscordCommand, err error)) ParserOption {
+	return func(p *Parser) {
+		p.hook = hook
+	}
+}
 
-// NewParser creates a new command parser with our commands registered.
-func NewParser(configuration *config.RootConfig, logger *waterlog.WaterLog) *Parser {
-	conf = configuration
-	log = logger
+// WithConfigStore makes commands use the live config in a store, so it can
+// be shared with the rest of the bot. By default, commands keep the config
+// given to NewParser themselves.
+func WithConfigStore(store ConfigStore) ParserOption {
+	return func(p *Parser) {
+		p.store = store
+	}
+}
+
+// WithConfigFile makes the config command save its changes to a config
+// file, and show which settings are overridden. By default, changes are only
+// kept in memory.
+func WithConfigFile(file *config.File) ParserOption {
+	return func(p *Parser) {
+		p.file = file
+	}
+}
+
+// NewParser creates a new command parser with our commands registered. Data
+// that commands keep, such as linked accounts, is stored in repo.
+func NewParser(configuration *config.RootConfig, logger *waterlog.WaterLog, repo storage.Repository, options ...ParserOption) *Parser {
+	p := &Parser{
+		dial:  rcon.Connect,
+		log:   logger,
+		store: newConfigHolder(configuration),
+	}
+	for _, option := range options {
+		option(p)
+	}
 
 	// Register our commands
-	handlers = append(handlers, Handler{
-		Name: "config",
-		Desc: "Configure certain bot settings",
-		Run:  ParseConfigCommand,
-	})
+	p.handlers = []Handler{
+		{
+			Name:              "config",
+			Desc:              "Configure certain bot settings",
+			DefaultPermission: discord.PermissionAdministrator,
+			// Setting an option is the default if no subcommand is given
+			Params: setConfigParams,
+			Run:    p.SetConfigOption,
+			Subcommands: []Handler{
+				{
+					Name:              "set",
+					Desc:              "Change the value of a configuration option",
+					DefaultPermission: discord.PermissionAdministrator,
+					Params:            setConfigParams,
+					Run:               p.SetConfigOption,
+				},
+				{
+					Name:              "get",
+					Desc:              "Show the value of a configuration option",
+					DefaultPermission: discord.PermissionAdministrator,
+					Params:            getConfigParams,
+					Run:               p.GetConfigOption,
+				},
+				{
+					Name:              "list",
+					Desc:              "Show every configuration option and its value",
+					DefaultPermission: discord.PermissionAdministrator,
+					Run:               p.ListConfigOptions,
+				},
+			},
+		},
+		{
+			Name:    "help",
+			Desc:    "Show all available bot commands",
+			Aliases: []string{"commands"},
+			Params: []Param{
+				{Name: "topic", Desc: "A command to show detailed help for", Type: StringParam, Optional: true},
+			},
+			Run: p.ShowHelp,
+		},
+		{
+			Name:    "list",
+			Desc:    "List all online players",
+			Aliases: []string{"online", "players"},
+			Run:     p.ListPlayers,
+		},
+		{
+			Name:              "rcon",
+			Desc:              "Run a command on the Minecraft server",
+			DefaultPermission: discord.PermissionAdministrator,
+			Params: []Param{
+				{Name: "command", Desc: "The server command to run", Type: RestParam},
+			},
+			Run: p.RunRconCommand,
+		},
+		{
+			Name: "whitelist",
+			Desc: "Manage the server whitelist",
+			Subcommands: []Handler{
+				{
+					Name:              "add",
+					Desc:              "Add a player to the whitelist",
+					DefaultPermission: discord.PermissionAdministrator,
+					Params:            []Param{whitelistNameParam},
+					Run:               p.AddToWhitelist,
+				},
+				{
+					Name:              "remove",
+					Desc:              "Remove a player from the whitelist",
+					DefaultPermission: discord.PermissionAdministrator,
+					Params:            []Param{whitelistNameParam},
+					Run:               p.RemoveFromWhitelist,
+				},
+				{
+					Name:              "list",
+					Desc:              "List all whitelisted players",
+					DefaultPermission: discord.PermissionAdministrator,
+					Params: []Param{
+						{Name: "page", Desc: "The page of the list to show", Type: IntParam, Optional: true},
+					},
+					Run: p.ListWhitelist,
+				},
+				{
+					Name:   "check",
+					Desc:   "Check if a player is whitelisted",
+					Params: []Param{whitelistNameParam},
+					Run:    p.CheckWhitelist,
+				},
+				{
+					Name:   "request",
+					Desc:   "Ask the moderators to whitelist a player",
+					Params: []Param{whitelistNameParam},
+					Run:    p.RequestWhitelist,
+				},
+			},
+		},
+		{
+			Name:              "kick",
+			Desc:              "Kick a player from the server",
+			DefaultPermission: discord.PermissionKickMembers,
+			Params:            []Param{moderationPlayerParam, moderationReasonParam},
+			Run:               p.KickPlayer,
+		},
+		{
+			Name:              "ban",
+			Desc:              "Ban a player from the server",
+			DefaultPermission: discord.PermissionBanMembers,
+			Params:            []Param{moderationPlayerParam, moderationReasonParam},
+			Run:               p.BanPlayer,
+		},
+		{
+			Name:              "tempban",
+			Desc:              "Ban a player from the server for a while",
+			DefaultPermission: discord.PermissionBanMembers,
+			Params: []Param{
+				moderationPlayerParam,
+				{Name: "duration", Desc: "How long to ban the player for, e.g. 1d12h", Type: DurationParam},
+				moderationReasonParam,
+			},
+			Run: p.TempBanPlayer,
+		},
+		{
+			Name:              "pardon",
+			Desc:              "Lift the ban of a player",
+			Aliases:           []string{"unban"},
+			DefaultPermission: discord.PermissionBanMembers,
+			Params:            []Param{moderationPlayerParam},
+			Run:               p.PardonPlayer,
+		},
+		{
+			Name:              "banlist",
+			Desc:              "List all banned players",
+			DefaultPermission: discord.PermissionBanMembers,
+			Params: []Param{
+				{Name: "page", Desc: "The page of the list to show", Type: IntParam, Optional: true},
+			},
+			Run: p.ShowBanList,
+		},
+		{
+			Name: "link",
+			Desc: "Link your Minecraft account to Discord",
+			Run:  p.LinkAccount,
+		},
+		{
+			Name: "unlink",
+			Desc: "Unlink your Minecraft account from Discord",
+			Run:  p.UnlinkAccount,
+		},
+		{
+			Name: "seen",
+			Desc: "Show when a player was last online",
+			Params: []Param{
+				{Name: "player", Desc: "The Minecraft name of the player", Type: StringParam},
+			},
+			Run: p.SeenPlayer,
+		},
+		{
+			Name:   "playtime",
+			Desc:   "Show how long a player has played",
+			Params: []Param{statsPlayerParam},
+			Run:    p.ShowPlaytime,
+		},
+		{
+			Name:   "deaths",
+			Desc:   "Show how many times a player has died, and how",
+			Params: []Param{statsPlayerParam},
+			Run:    p.ShowDeaths,
+		},
+		{
+			Name: "advancements",
+			Desc: "Show the advancements a player has made",
+			Params: []Param{
+				{Name: "player", Desc: "The Minecraft name of the player", Type: StringParam},
+			},
+			Run: p.ShowAdvancements,
+		},
+		{
+			Name:    "top",
+			Desc:    "Show the server leaderboards",
+			Aliases: []string{"leaderboard"},
+			Subcommands: []Handler{
+				{
+					Name: "playtime",
+					Desc: "Show the players that have played the longest",
+					Run:  p.TopPlaytime,
+				},
+				{
+					Name: "deaths",
+					Desc: "Show the players that have died the most",
+					Run:  p.TopDeaths,
+				},
+			},
+		},
+	}
+
+	// Subcommands need the same permission as their command unless they
+	// say otherwise
+	inheritPermissions(p.handlers, 0)
+
+	// Keep temporary bans, linked accounts, and player statistics in the
+	// database
+	p.tempBans = NewTempBanList(repo)
+	p.links = NewLinkList(repo)
+	p.sessions = NewSessionTracker(repo)
+	p.events = NewEventLog(repo)
+
+	// Register handlers for buttons in our messages
+	p.componentHandlers = map[string]componentHandler{
+		"whitelist": p.handleWhitelistButton,
+	}
 
-	handlers = append(handlers, Handler{
-		Name: "help",
-		Desc: "Show all available bot commands",
-		Run:  ShowHelp,
-	})
+	return p
+}
 
-	handlers = append(handlers, Handler{
-		Name: "list",
-		Desc: "List all online players",
-		Run:  ListPlayers,
-	})
+// config gets the config used by commands.
+func (p *Parser) config() *config.RootConfig {
+	return p.store.Config()
+}
 
-	return &Parser{}
+// connect connects to the server's RCON using the current config.
+func (p *Parser) connect() (*rcon.Client, error) {
+	c := p.config()
+	return p.dial(c.Minecraft.RconIP, c.Minecraft.RconPort, c.Minecraft.RconPassword)
 }
 
 // Parse will turn a Discord message into a DiscordCommand to be
-// passed on to a command handler.
+// passed on to a command handler. Whether or not the command was
+// found is sent through the resp channel.
 func (p *Parser) Parse(message discord.Message, state *state.State, resp chan bool) {
 	// Forget about the command prefix
-	raw := message.Content[1:]
-	parts := strings.Split(raw, " ")
+	prefix := p.config().Discord.Commands.Prefix
+	raw := strings.TrimPrefix(message.Content, prefix)
+	tokens, tokenErr := tokenize(raw)
 
-	log.Debugf("Parsing command from Discord: %s\n", parts)
+	p.log.Debugf("Parsing command from Discord: %s\n", raw)
 
-	// Split out any command args
-	var args []string
-	if len(parts) > 1 {
-		args = parts[1:]
-	} else {
-		args = make([]string, 0)
+	// Find the handler for the command
+	if len(tokens) == 0 {
+		resp <- false
+		return
+	}
+	handler := findHandler(p.handlers, tokens[0].text)
+	if handler == nil {
+		resp <- false
+		return
+	}
+	resp <- true
+
+	// Find the subcommand being used, if any
+	path := []string{handler.Name}
+	args := tokens[1:]
+	for len(args) > 0 {
+		sub := findHandler(handler.Subcommands, args[0].text)
+		if sub == nil {
+			break
+		}
+		handler = sub
+		path = append(path, sub.Name)
+		args = args[1:]
 	}
 
 	cmd := DiscordCommand{
-		message.Author,
-		parts[0],
-		args,
-		message.GuildID,
-		message.ChannelID,
-		message.ID,
-	}
-
-	// Send the command to all handlers
-	for _, handler := range handlers {
-		// Only send to the correct handler
-		if handler.Name == cmd.Command {
-			log.Debugf("Running Discord bot command: %s\n", handler.Name)
-			resp <- true
-
-			if err := handler.Run(state, cmd); err != nil {
-				handleCommandError(state, cmd, err)
+		Sender:    message.Author,
+		Command:   strings.Join(path, " "),
+		Prefix:    prefix,
+		Args:      tokenText(args),
+		GuildID:   message.GuildID,
+		ChannelID: message.ChannelID,
+		MessageID: message.ID,
+	}
+
+	// Check the arguments that were given
+	if tokenErr != nil {
+		p.sendUsageError(state, cmd, *handler, tokenErr)
+		return
+	}
+	if handler.Run == nil {
+		p.sendUsageError(state, cmd, *handler, &ArgError{"Please choose a subcommand."})
+		return
+	}
+	values, err := bindArgs(handler.Params, args, raw)
+	if err != nil {
+		p.sendUsageError(state, cmd, *handler, err)
+		return
+	}
+	cmd.Values = values
+
+	p.runHandler(state, *handler, cmd)
+}
+
+// ParseInteraction will turn a slash command into a DiscordCommand to be
+// passed on to a command handler. Replies to slash commands are only
+// visible to the user that used the command.
+func (p *Parser) ParseInteraction(e *InteractionCreateEvent, state *state.State) {
+	if e.Data == nil {
+		return
+	}
+	if e.Type == interactionTypeComponent {
+		p.parseComponent(e, state)
+		return
+	}
+	if e.Type != interactionTypeCommand {
+		return
+	}
+
+	handler := findHandler(p.handlers, e.Data.Name)
+	if handler == nil {
+		p.log.Warnf("Received unknown slash command: %s\n", e.Data.Name)
+
+		// Answer anyway, or Discord shows the interaction as failed
+		embed := CreateEmbed(WarnColor, "Unknown Command", fmt.Sprintf(":warning: `/%s` isn't a command this bot knows.", e.Data.Name), "Use /help to see the available commands")
+		if err := respondInteractionEmbed(state, e, embed); err != nil {
+			p.log.Errorf("Error responding to a slash command: %s\n", err)
+		}
+		return
+	}
+
+	// Let Discord know we're working on it
+	if err := deferInteraction(state, e); err != nil {
+		p.log.Errorf("Error responding to a slash command: %s\n", err)
+		return
+	}
+
+	// Find the subcommand being used, if any
+	path := []string{handler.Name}
+	options := e.Data.Options
+	for len(options) == 1 && options[0].Type == OptionTypeSubcommand {
+		sub := findHandler(handler.Subcommands, options[0].Name)
+		if sub == nil {
+			break
+		}
+		handler = sub
+		path = append(path, sub.Name)
+		options = options[0].Options
+	}
+
+	cmd := DiscordCommand{
+		Sender:    interactionSender(e),
+		Command:   strings.Join(path, " "),
+		Args:      make([]string, 0, len(options)),
+		Values:    make(map[string]interface{}),
+		GuildID:   e.GuildID,
+		ChannelID: e.ChannelID,
+		Interaction: &Interaction{
+			ApplicationID: e.ApplicationID,
+			Token:         e.Token,
+		},
+	}
+
+	p.log.Debugf("Parsing slash command from Discord: %s\n", cmd.Command)
+
+	// Parse the options in the order the handler declares them
+	for _, param := range handler.Params {
+		option, ok := findOption(options, param.Name)
+		if !ok {
+			if !param.Optional {
+				p.sendUsageError(state, cmd, *handler, &ArgError{fmt.Sprintf("Missing a value for `%s`.", param.Name)})
+				return
+			}
+			continue
+		}
+
+		value, err := parseValue(param, option.String())
+		if err != nil {
+			p.sendUsageError(state, cmd, *handler, err)
+			return
+		}
+		cmd.Args = append(cmd.Args, option.String())
+		cmd.Values[param.Name] = value
+	}
+
+	p.runHandler(state, *handler, cmd)
+}
+
+// parseComponent finds the handler for a message component that was used,
+// by the first part of its custom ID, and runs it.
+func (p *Parser) parseComponent(e *InteractionCreateEvent, state *state.State) {
+	parts := strings.Split(e.Data.CustomID, ":")
+	handler, ok := p.componentHandlers[parts[0]]
+	if !ok {
+		p.log.Warnf("Received unknown message component: %s\n", e.Data.CustomID)
+		return
+	}
+
+	p.log.Debugf("Handling message component from Discord: %s\n", e.Data.CustomID)
+	if err := handler(state, e, parts[1:]); err != nil {
+		p.log.Errorf("Error handling the '%s' component: %s\n", e.Data.CustomID, err)
+
+		// Let the user know something went wrong
+		embed := CreateEmbed(ErrorColor, "Error", ":no_entry: An error occurred while handling that.", fmt.Sprintf("err: %s", err))
+		if err := respondInteractionEmbed(state, e, embed); err != nil {
+			p.log.Errorf("Error while trying to display another error: %s\n", err)
+		}
+	}
+}
+
+// interactionSender gets the user that used an interaction. Interactions in
+// a Guild have a member, and in DMs a user.
+func interactionSender(e *InteractionCreateEvent) discord.User {
+	if e.Member != nil {
+		return e.Member.User
+	}
+	if e.User != nil {
+		return *e.User
+	}
+	return discord.User{}
+}
+
+// RunServerCommand runs a message from the console channel as a server
+// command. It is handled like the rcon command, so the same permissions and
+// rules apply.
+func (p *Parser) RunServerCommand(message discord.Message, state *state.State) {
+	handler := findHandler(p.handlers, "rcon")
+	if handler == nil || strings.TrimSpace(message.Content) == "" {
+		return
+	}
+
+	cmd := DiscordCommand{
+		Sender:    message.Author,
+		Command:   handler.Name,
+		Prefix:    p.config().Discord.Commands.Prefix,
+		Args:      []string{message.Content},
+		Values:    map[string]interface{}{"command": message.Content},
+		GuildID:   message.GuildID,
+		ChannelID: message.ChannelID,
+		MessageID: message.ID,
+	}
+
+	p.log.Debugf("Running server command from the console channel: %s\n", message.Content)
+	p.runHandler(state, *handler, cmd)
+}
+
+// findHandler gets the handler for a command by its name or one of its
+// aliases, or nil if there is no such command.
+func findHandler(list []Handler, name string) *Handler {
+	name = strings.ToLower(name)
+	for i, handler := range list {
+		if handler.Name == name {
+			return &list[i]
+		}
+		for _, alias := range handler.Aliases {
+			if alias == name {
+				return &list[i]
 			}
 		}
 	}
+	return nil
 }
 
-func handleCommandError(state *state.State, cmd DiscordCommand, err error) {
+// inheritPermissions gives every handler without a default permission the
+// default permission of its parent command.
+func inheritPermissions(handlers []Handler, parent discord.Permissions) {
+	for i := range handlers {
+		if handlers[i].DefaultPermission == 0 {
+			handlers[i].DefaultPermission = parent
+		}
+		inheritPermissions(handlers[i].Subcommands, handlers[i].DefaultPermission)
+	}
+}
+
+// findSubcommand gets the handler for a subcommand of a command, or nil if
+// there is no such subcommand.
+func (p *Parser) findSubcommand(name string, sub string) *Handler {
+	handler := findHandler(p.handlers, name)
+	if handler == nil {
+		return nil
+	}
+	return findHandler(handler.Subcommands, sub)
+}
+
+// findOption gets the slash command option with the given name.
+func findOption(options []InteractionOption, name string) (InteractionOption, bool) {
+	for _, option := range options {
+		if option.Name == name {
+			return option, true
+		}
+	}
+	return InteractionOption{}, false
+}
+
+// tokenText gets the text of each token.
+func tokenText(tokens []token) []string {
+	text := make([]string, 0, len(tokens))
+	for _, t := range tokens {
+		text = append(text, t.text)
+	}
+	return text
+}
+
+// runHandler runs a command handler if the sender is allowed to use it, and
+// shows any error in Discord.
+func (p *Parser) runHandler(state *state.State, handler Handler, cmd DiscordCommand) {
+	// Check if the sender has permission
+	if ok, reason := p.canUseCommand(state, handler, cmd); !ok {
+		p.auditDenied(state, cmd, reason)
+		if err := SendMissingPermsEmbed(state, cmd); err != nil {
+			p.log.Errorf("Error sending missing permissions embed: %s\n", err)
+		}
+		return
+	}
+
+	p.log.Debugf("Running Discord bot command: %s\n", cmd.Command)
+	err := handler.Run(state, cmd)
+	if err != nil {
+		p.handleCommandError(state, cmd, err)
+	}
+	if p.hook != nil {
+		p.hook(cmd, err)
+	}
+}
+
+// sendUsageError tells the user that the arguments they gave to a command
+// are invalid, and how to use the command.
+func (p *Parser) sendUsageError(state *state.State, cmd DiscordCommand, handler Handler, err error) {
+	text := fmt.Sprintf(":warning: %s\nUsage: `%s`", err, Usage(commandPrefix(cmd), cmd.Command, handler))
+	embed := CreateEmbed(WarnColor, "Incorrect Usage", text, fmt.Sprintf("See %shelp %s for more information", commandPrefix(cmd), strings.Split(cmd.Command, " ")[0]))
+	if err := SendCommandEmbed(state, cmd, embed); err != nil {
+		p.log.Errorf("Error sending usage embed: %s\n", err)
+	}
+}
+
+func (p *Parser) handleCommandError(state *state.State, cmd DiscordCommand, err error) {
 	// Sanitize error from RCON
 	errorMessage := err.Error()
 	if strings.HasPrefix(errorMessage, "dial tcp") {
@@ -96,17 +601,27 @@ func handleCommandError(state *state.State, cmd DiscordCommand, err error) {
 
 	// Embed an error and log it
 	embed := CreateEmbed(ErrorColor, "Error", fmt.Sprintf(":no_entry: An error occurred while running the `%s` command.", cmd.Command), fmt.Sprintf("err: %s", errorMessage))
-	snowflake, _ := discord.ParseSnowflake(conf.Discord.ChannelID)
+
+	// Only show the error to the user of a slash command
+	if cmd.Interaction != nil {
+		p.log.Errorf("Error running the '%s' command: %s\n", cmd.Command, err)
+		if sendError := SendInteractionEmbed(state, cmd.Interaction, embed); sendError != nil {
+			p.log.Errorf("Error while trying to display another error: %s\n", sendError)
+		}
+		return
+	}
+
+	snowflake, _ := discord.ParseSnowflake(p.config().Discord.ChannelID)
 	channel := discord.ChannelID(snowflake)
 	message, sendError := state.Client.SendEmbed(channel, embed)
 	if sendError != nil {
-		log.Errorf("Error while trying to display another error: %s\n", sendError)
-		log.Errorf("The previous error was: %s\n", err)
+		p.log.Errorf("Error while trying to display another error: %s\n", sendError)
+		p.log.Errorf("The previous error was: %s\n", err)
 		return
 	}
 
-	log.Errorf("Error running the '%s' command: %s\n", cmd.Command, err)
+	p.log.Errorf("Error running the '%s' command: %s\n", cmd.Command, err)
 	if err := RemoveEmbed(state, channel, cmd.MessageID, message.ID); err != nil {
-		log.Errorf("Error trying to remove an error embed: %s\n", err)
+		p.log.Errorf("Error trying to remove an error embed: %s\n", err)
 	}
 }
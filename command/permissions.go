@@ -0,0 +1,189 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/diamondburned/arikawa/discord"
+	"github.com/diamondburned/arikawa/state"
+	"gitlab.com/EbonJaeger/dolphin/config"
+)
+
+// permissionNames maps the names of Discord permissions that can be used in
+// command permission rules to their permission bits.
+var permissionNames = map[string]discord.Permissions{
+	"administrator":   discord.PermissionAdministrator,
+	"banmembers":      discord.PermissionBanMembers,
+	"kickmembers":     discord.PermissionKickMembers,
+	"managechannels":  discord.PermissionManageChannels,
+	"manageguild":     discord.PermissionManageGuild,
+	"manageserver":    discord.PermissionManageGuild,
+	"managemessages":  discord.PermissionManageMessages,
+	"managenicknames": discord.PermissionManageNicknames,
+	"manageroles":     discord.PermissionManageRoles,
+	"managewebhooks":  discord.PermissionManageWebhooks,
+	"mutemembers":     discord.PermissionMuteMembers,
+	"viewauditlog":    discord.PermissionViewAuditLog,
+}
+
+// ParsePermission gets the Discord permission bit with the given name,
+// case-insensitive. The returned bool is false if there is no such permission.
+func ParsePermission(name string) (discord.Permissions, bool) {
+	perm, ok := permissionNames[strings.ToLower(name)]
+	return perm, ok
+}
+
+// findPermissionRule gets the configured permission rule for a command. Rules
+// for a subcommand take precedence over rules for the whole command.
+func (p *Parser) findPermissionRule(command string) *config.PermissionConfig {
+	if p.config().Discord.Commands.Permissions == nil {
+		return nil
+	}
+
+	rules := *p.config().Discord.Commands.Permissions
+	for {
+		for i, rule := range rules {
+			if strings.EqualFold(strings.TrimSpace(rule.Command), command) {
+				return &rules[i]
+			}
+		}
+
+		// Try the parent command
+		i := strings.LastIndex(command, " ")
+		if i == -1 {
+			return nil
+		}
+		command = command[:i]
+	}
+}
+
+// sender is who used a command and where, for checking permission rules.
+type sender struct {
+	UserID      string
+	ChannelID   string
+	RoleIDs     []string
+	Permissions discord.Permissions
+}
+
+// canUseCommand checks if the sender of a command is allowed to use it. The
+// returned string is the reason if they aren't.
+func (p *Parser) canUseCommand(state *state.State, handler Handler, cmd DiscordCommand) (bool, string) {
+	return p.checkRule(p.findPermissionRule(cmd.Command), handler.DefaultPermission, p.getSender(state, cmd))
+}
+
+// getSender gets who sent a command, with their roles and permissions if the
+// command was used in a Guild.
+func (p *Parser) getSender(state *state.State, cmd DiscordCommand) sender {
+	s := sender{
+		UserID:    cmd.Sender.ID.String(),
+		ChannelID: cmd.ChannelID.String(),
+		RoleIDs:   make([]string, 0),
+	}
+
+	// Roles and permissions only exist in a Guild
+	if cmd.GuildID.IsValid() {
+		if member, err := state.Member(cmd.GuildID, cmd.Sender.ID); err == nil {
+			for _, roleID := range member.RoleIDs {
+				s.RoleIDs = append(s.RoleIDs, roleID.String())
+			}
+		}
+
+		perms, err := state.Permissions(cmd.ChannelID, cmd.Sender.ID)
+		if err != nil {
+			p.log.Warnf("Unable to get the permissions of '%s': %s\n", cmd.Sender.Username, err)
+		}
+		s.Permissions = perms
+	}
+
+	return s
+}
+
+// checkRule checks if a sender is allowed to use a command by its permission
+// rule. If the command has no rule, the sender must have the default
+// permission of the command.
+func (p *Parser) checkRule(rule *config.PermissionConfig, defaultPerm discord.Permissions, s sender) (bool, string) {
+	// Fall back to the default permission of the command
+	if rule == nil {
+		if defaultPerm == 0 || s.Permissions.Has(defaultPerm) {
+			return true, ""
+		}
+		return false, "missing the default permission"
+	}
+
+	// Check where the command is being used
+	if len(rule.Channels) > 0 && !containsID(rule.Channels, s.ChannelID) {
+		return false, "not allowed in this channel"
+	}
+
+	// Anyone can use the command if the rule doesn't limit who
+	if len(rule.Users) == 0 && len(rule.Roles) == 0 && rule.Permission == "" {
+		return true, ""
+	}
+
+	if containsID(rule.Users, s.UserID) {
+		return true, ""
+	}
+
+	for _, roleID := range s.RoleIDs {
+		if containsID(rule.Roles, roleID) {
+			return true, ""
+		}
+	}
+
+	if rule.Permission != "" {
+		perm, ok := ParsePermission(rule.Permission)
+		if !ok {
+			p.log.Warnf("Unknown permission '%s' in the permission rule for '%s'\n", rule.Permission, rule.Command)
+		} else if s.Permissions.Has(perm) {
+			return true, ""
+		}
+	}
+
+	return false, "not allowed by the permission rule"
+}
+
+// containsID checks if an ID is in a list of IDs.
+func containsID(ids []string, id string) bool {
+	for _, i := range ids {
+		if strings.TrimSpace(i) == id {
+			return true
+		}
+	}
+	return false
+}
+
+// SendAuditEmbed sends an embed to the configured audit channel. Nothing is
+// sent if there is no audit channel.
+func (p *Parser) SendAuditEmbed(state *state.State, embed discord.Embed) {
+	if p.config().Discord.Commands.AuditChannelID == "" {
+		return
+	}
+
+	snowflake, err := discord.ParseSnowflake(p.config().Discord.Commands.AuditChannelID)
+	if err != nil {
+		p.log.Warnf("Invalid audit channel ID: %s\n", err)
+		return
+	}
+
+	if _, err := state.Client.SendEmbed(discord.ChannelID(snowflake), embed); err != nil {
+		p.log.Errorf("Error sending to the audit channel: %s\n", err)
+	}
+}
+
+// auditDenied logs a command that a user wasn't allowed to use.
+func (p *Parser) auditDenied(state *state.State, cmd DiscordCommand, reason string) {
+	p.log.Warnf("User '%s' was denied the '%s' command: %s\n", cmd.Sender.Username, cmd.Command, reason)
+
+	text := fmt.Sprintf(":no_entry: %s tried to use `%s` in <#%s>", cmd.Sender.Mention(), commandText(cmd), cmd.ChannelID)
+	embed := CreateEmbed(ErrorColor, "Command Denied", text, fmt.Sprintf("Reason: %s", reason))
+	p.SendAuditEmbed(state, embed)
+}
+
+// commandText recreates what the user typed for a command.
+func commandText(cmd DiscordCommand) string {
+	text := commandPrefix(cmd) + cmd.Command
+	if len(cmd.Args) > 0 {
+		text += " " + strings.Join(cmd.Args, " ")
+	}
+	return text
+}
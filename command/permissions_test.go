@@ -0,0 +1,151 @@
+package command
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/DataDrake/waterlog"
+
+	"github.com/diamondburned/arikawa/discord"
+	"gitlab.com/EbonJaeger/dolphin/config"
+)
+
+// testParser creates a command parser with the given config that logs
+// nowhere.
+func testParser(conf *config.RootConfig) *Parser {
+	return &Parser{log: waterlog.New(ioutil.Discard, "", 0), store: newConfigHolder(conf)}
+}
+
+func TestFindPermissionRuleSubcommand(t *testing.T) {
+	// given
+	p := testParser(&config.RootConfig{})
+	p.config().Discord.Commands.Permissions = &[]config.PermissionConfig{
+		{Command: "config", Roles: []string{"1"}},
+		{Command: "Config Set", Roles: []string{"2"}},
+	}
+
+	// when
+	rule := p.findPermissionRule("config set")
+
+	// then
+	if rule == nil || rule.Command != "Config Set" {
+		t.Errorf("Expected the subcommand rule, got: %+v", rule)
+	}
+}
+
+func TestFindPermissionRuleParent(t *testing.T) {
+	// given
+	p := testParser(&config.RootConfig{})
+	p.config().Discord.Commands.Permissions = &[]config.PermissionConfig{
+		{Command: "config", Roles: []string{"1"}},
+	}
+
+	// when
+	rule := p.findPermissionRule("config set")
+
+	// then
+	if rule == nil || rule.Command != "config" {
+		t.Errorf("Expected the parent command rule, got: %+v", rule)
+	}
+	if rule := p.findPermissionRule("list"); rule != nil {
+		t.Errorf("Expected no rule for an unconfigured command, got: %+v", rule)
+	}
+}
+
+func TestCheckRuleDefaultPermission(t *testing.T) {
+	// given
+	p := testParser(&config.RootConfig{})
+	member := sender{UserID: "1", ChannelID: "10"}
+	admin := sender{UserID: "2", ChannelID: "10", Permissions: discord.PermissionAll}
+
+	// then
+	if ok, _ := p.checkRule(nil, 0, member); !ok {
+		t.Errorf("Commands without a default permission should be usable by anyone")
+	}
+	if ok, _ := p.checkRule(nil, discord.PermissionAdministrator, member); ok {
+		t.Errorf("Members without the default permission should be denied")
+	}
+	if ok, _ := p.checkRule(nil, discord.PermissionAdministrator, admin); !ok {
+		t.Errorf("Members with the default permission should be allowed")
+	}
+}
+
+func TestSubcommandsInheritDefaultPermission(t *testing.T) {
+	// given
+	_, db, cleanup := openTestDB(t)
+	defer cleanup()
+	p := NewParser(&config.RootConfig{}, waterlog.New(ioutil.Discard, "", 0), db)
+	member := sender{UserID: "1", ChannelID: "10", Permissions: discord.PermissionSendMessages}
+
+	// when
+	set := p.findSubcommand("config", "set")
+	ok, _ := p.checkRule(p.findPermissionRule("config set"), set.DefaultPermission, member)
+
+	// then
+	if set.DefaultPermission != discord.PermissionAdministrator {
+		t.Errorf("Subcommand should inherit the permission of its command, got: %d", set.DefaultPermission)
+	}
+	if ok {
+		t.Errorf("Members without Administrator should be denied 'config set'")
+	}
+	for _, name := range []string{"get", "list"} {
+		if sub := p.findSubcommand("config", name); sub.DefaultPermission != discord.PermissionAdministrator {
+			t.Errorf("'config %s' should need Administrator, got: %d", name, sub.DefaultPermission)
+		}
+	}
+	if check := p.findSubcommand("whitelist", "check"); check.DefaultPermission != 0 {
+		t.Errorf("Subcommands of commands anyone can use should stay open, got: %d", check.DefaultPermission)
+	}
+}
+
+func TestCheckRuleRoles(t *testing.T) {
+	// given
+	p := testParser(&config.RootConfig{})
+	rule := &config.PermissionConfig{Command: "config", Roles: []string{"100", "200"}}
+	moderator := sender{UserID: "1", ChannelID: "10", RoleIDs: []string{"50", "200"}}
+	member := sender{UserID: "2", ChannelID: "10", RoleIDs: []string{"50"}}
+
+	// then
+	if ok, _ := p.checkRule(rule, discord.PermissionAdministrator, moderator); !ok {
+		t.Errorf("Members with an allowed role should be allowed")
+	}
+	if ok, _ := p.checkRule(rule, discord.PermissionAdministrator, member); ok {
+		t.Errorf("Members without an allowed role should be denied")
+	}
+}
+
+func TestCheckRuleUsersAndPermission(t *testing.T) {
+	// given
+	p := testParser(&config.RootConfig{})
+	rule := &config.PermissionConfig{Command: "config", Users: []string{"1"}, Permission: "ManageServer"}
+	user := sender{UserID: "1", ChannelID: "10"}
+	manager := sender{UserID: "2", ChannelID: "10", Permissions: discord.PermissionManageGuild}
+	member := sender{UserID: "3", ChannelID: "10", Permissions: discord.PermissionSendMessages}
+
+	// then
+	if ok, _ := p.checkRule(rule, 0, user); !ok {
+		t.Errorf("Allowed users should be allowed")
+	}
+	if ok, _ := p.checkRule(rule, 0, manager); !ok {
+		t.Errorf("Members with the permission should be allowed")
+	}
+	if ok, _ := p.checkRule(rule, 0, member); ok {
+		t.Errorf("Members without the permission should be denied")
+	}
+}
+
+func TestCheckRuleChannels(t *testing.T) {
+	// given
+	p := testParser(&config.RootConfig{})
+	rule := &config.PermissionConfig{Command: "list", Channels: []string{"10"}}
+	inChannel := sender{UserID: "1", ChannelID: "10"}
+	elsewhere := sender{UserID: "1", ChannelID: "20", Permissions: discord.PermissionAll}
+
+	// then
+	if ok, _ := p.checkRule(rule, 0, inChannel); !ok {
+		t.Errorf("Anyone should be allowed in an allowed channel")
+	}
+	if ok, reason := p.checkRule(rule, 0, elsewhere); ok || reason != "not allowed in this channel" {
+		t.Errorf("Commands should be denied outside of the allowed channels, got: %t %s", ok, reason)
+	}
+}
@@ -0,0 +1,333 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/diamondburned/arikawa/discord"
+	"github.com/diamondburned/arikawa/state"
+	"gitlab.com/EbonJaeger/dolphin/config"
+	"gitlab.com/EbonJaeger/dolphin/rcon"
+)
+
+// Limits for showing the output of a server command.
+const (
+	rconPageSize = 1900
+	rconMaxPages = 10
+)
+
+// auditLogMutex keeps lines written to the audit log from being mixed up.
+var auditLogMutex sync.Mutex
+
+// RunRconCommand runs a command on the Minecraft server and shows the output
+// to the user.
+func (p *Parser) RunRconCommand(state *state.State, cmd DiscordCommand) error {
+	command := strings.TrimPrefix(strings.TrimSpace(cmd.StringArg("command")), "/")
+	if command == "" {
+		embed := CreateEmbed(WarnColor, "Missing Command", ":warning: Please give a server command to run.", "")
+		return SendCommandEmbed(state, cmd, embed)
+	}
+
+	// Server commands are a single line, so line breaks can't sneak another
+	// command past the rules
+	if strings.IndexFunc(command, unicode.IsControl) != -1 {
+		embed := CreateEmbed(WarnColor, "Invalid Command", ":warning: Server commands can't contain line breaks or other control characters.", "")
+		return SendCommandEmbed(state, cmd, embed)
+	}
+
+	// Check if the sender may run this server command
+	allowed, reason := checkRconRules(p.rconRules(), p.getSender(state, cmd), command)
+	p.recordRconCommand(state, cmd, command, allowed, reason)
+	if !allowed {
+		embed := CreateEmbed(ErrorColor, "Command Not Allowed", fmt.Sprintf(":no_entry: You aren't allowed to run `%s` on the server.", command), "Please contact a server administrator for help.")
+		return SendCommandEmbed(state, cmd, embed)
+	}
+
+	// Create RCON connection
+	conn, err := p.connect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// Send the command to Minecraft
+	resp, err := conn.SendCommand(command)
+	if err != nil {
+		return err
+	}
+
+	return sendRconOutput(state, cmd, command, rcon.StripFormatting(resp))
+}
+
+// rconRules gets the configured rules for which server commands can be run.
+func (p *Parser) rconRules() []config.RconRuleConfig {
+	if p.config().Discord.Commands.Rcon.Rules == nil {
+		return []config.RconRuleConfig{}
+	}
+	return *p.config().Discord.Commands.Rcon.Rules
+}
+
+// checkRconRules checks if a sender may run a server command. A command is
+// denied if it matches a deny pattern of any rule that applies to the sender.
+// Otherwise, it must match an allow pattern, unless there are no rules at all.
+// Administrators may run any command that isn't denied to them. Commands run
+// by an execute command are checked too, so "execute run op Steve" is denied
+// just like "op Steve".
+func checkRconRules(rules []config.RconRuleConfig, s sender, command string) (bool, string) {
+	if len(rules) == 0 {
+		return true, ""
+	}
+
+	commands := commandChain(command)
+	admin := s.Permissions.Has(discord.PermissionAdministrator)
+	allowed := make([]bool, len(commands))
+	for _, rule := range rules {
+		if !rconRuleApplies(rule, s) {
+			continue
+		}
+
+		for i, c := range commands {
+			for _, pattern := range rule.Deny {
+				if matchCommand(pattern, c) {
+					return false, fmt.Sprintf("denied by pattern '%s'", pattern)
+				}
+			}
+
+			for _, pattern := range rule.Allow {
+				if matchCommand(pattern, c) {
+					allowed[i] = true
+				}
+			}
+		}
+	}
+
+	// Every command in the chain must be allowed
+	for _, ok := range allowed {
+		if !ok && !admin {
+			return false, "not allowed by any pattern"
+		}
+	}
+	return true, ""
+}
+
+// commandChain gets a server command and every command it runs through
+// execute ... run, without namespaces such as minecraft:.
+func commandChain(command string) []string {
+	commands := make([]string, 0, 1)
+	for {
+		fields := strings.Fields(command)
+		if len(fields) == 0 {
+			return commands
+		}
+		fields[0] = stripNamespace(strings.TrimPrefix(fields[0], "/"))
+		commands = append(commands, strings.Join(fields, " "))
+
+		// Find the command run by an execute command, if any
+		if !strings.EqualFold(fields[0], "execute") {
+			return commands
+		}
+		run := -1
+		for i, field := range fields {
+			if strings.EqualFold(field, "run") {
+				run = i
+				break
+			}
+		}
+		if run == -1 {
+			return commands
+		}
+		command = strings.Join(fields[run+1:], " ")
+	}
+}
+
+// stripNamespace removes the namespace from a command name, so
+// "minecraft:op" becomes "op".
+func stripNamespace(name string) string {
+	if i := strings.LastIndex(name, ":"); i != -1 {
+		return name[i+1:]
+	}
+	return name
+}
+
+// rconRuleApplies checks if a rule is for the given sender. Rules without any
+// users or roles apply to everyone.
+func rconRuleApplies(rule config.RconRuleConfig, s sender) bool {
+	if len(rule.Users) == 0 && len(rule.Roles) == 0 {
+		return true
+	}
+
+	if containsID(rule.Users, s.UserID) {
+		return true
+	}
+
+	for _, roleID := range s.RoleIDs {
+		if containsID(rule.Roles, roleID) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchCommand checks if a server command matches a pattern. A * in the
+// pattern matches anything, and a pattern without arguments matches the
+// command with any arguments, so "op" matches "op Steve". Matching is
+// case-insensitive.
+func matchCommand(pattern string, command string) bool {
+	pattern = strings.TrimPrefix(strings.TrimSpace(pattern), "/")
+	if pattern == "" {
+		return false
+	}
+
+	// Patterns are matched against commands without a namespace
+	if fields := strings.SplitN(pattern, " ", 2); len(fields) > 0 {
+		fields[0] = stripNamespace(fields[0])
+		pattern = strings.Join(fields, " ")
+	}
+
+	parts := strings.Split(pattern, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+
+	regex, err := regexp.Compile("(?is)^" + strings.Join(parts, ".*") + "( .*)?$")
+	if err != nil {
+		return false
+	}
+
+	return regex.MatchString(strings.TrimSpace(command))
+}
+
+// recordRconCommand records a server command run from Discord in the audit
+// log file and the audit channel.
+func (p *Parser) recordRconCommand(state *state.State, cmd DiscordCommand, command string, allowed bool, reason string) {
+	result := "allowed"
+	if !allowed {
+		result = "denied: " + reason
+	}
+
+	p.log.Infof("User '%s' ran the server command '%s' (%s)\n", cmd.Sender.Username, command, result)
+
+	if path := p.config().Discord.Commands.Rcon.AuditLog; path != "" {
+		line := auditLogLine(time.Now(), cmd.Sender, result, command)
+		if err := appendAuditLog(path, line); err != nil {
+			p.log.Errorf("Error writing to the RCON audit log: %s\n", err)
+		}
+	}
+
+	color := discord.Color(InfoColor)
+	if !allowed {
+		color = ErrorColor
+	}
+	text := fmt.Sprintf("%s ran `%s` in <#%s>", cmd.Sender.Mention(), command, cmd.ChannelID)
+	p.SendAuditEmbed(state, CreateEmbed(color, "Server Command", text, strings.ToUpper(result[:1])+result[1:]))
+}
+
+// auditLogLine formats a line of the audit log. The command is quoted, so
+// control characters in it can't add lines or fields to the log.
+func auditLogLine(t time.Time, user discord.User, result string, command string) string {
+	return fmt.Sprintf("%s\t%s#%s (%s)\t%s\t%s\n", t.Format(time.RFC3339), user.Username, user.Discriminator, user.ID, result, strconv.Quote(command))
+}
+
+// appendAuditLog adds a line to the end of the audit log file, creating it
+// if it doesn't exist.
+func appendAuditLog(path string, line string) error {
+	auditLogMutex.Lock()
+	defer auditLogMutex.Unlock()
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.WriteString(line)
+	return err
+}
+
+// sendRconOutput sends the output of a server command in code blocks, split
+// over as many pages as needed.
+func sendRconOutput(state *state.State, cmd DiscordCommand, command string, output string) error {
+	if strings.TrimSpace(output) == "" {
+		output = "(no output)"
+	}
+
+	pages := paginate(output, rconPageSize)
+	truncated := len(pages) > rconMaxPages
+	if truncated {
+		pages = pages[:rconMaxPages]
+	}
+
+	for i, page := range pages {
+		footer := ""
+		if len(pages) > 1 {
+			footer = fmt.Sprintf("Page %d/%d", i+1, len(pages))
+		}
+		if truncated && i == len(pages)-1 {
+			footer += " (output truncated)"
+		}
+
+		embed := CreateEmbed(InfoColor, "", fmt.Sprintf("```\n%s\n```", EscapeCodeBlock(page)), strings.TrimSpace(footer))
+		if i == 0 {
+			embed.Title = fmt.Sprintf("Output of %s", command)
+			// Discord counts the title length in characters
+			if title := []rune(embed.Title); len(title) > 256 {
+				embed.Title = string(title[:253]) + "..."
+			}
+		}
+
+		if err := sendRconPage(state, cmd, i, embed); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sendRconPage sends a page of server command output. Output isn't removed
+// after a while like other command replies, so it can be read.
+func sendRconPage(state *state.State, cmd DiscordCommand, page int, embed discord.Embed) error {
+	if cmd.Interaction != nil {
+		if page == 0 {
+			return SendInteractionEmbed(state, cmd.Interaction, embed)
+		}
+		return SendInteractionFollowup(state, cmd.Interaction, embed)
+	}
+
+	_, err := state.Client.SendEmbed(cmd.ChannelID, embed)
+	return err
+}
+
+// paginate splits text into pages no longer than size bytes, splitting at
+// the end of a line where possible.
+func paginate(text string, size int) []string {
+	pages := make([]string, 0)
+
+	for len(text) > size {
+		// Find a good place to split the text
+		split := strings.LastIndex(text[:size], "\n")
+		if split <= 0 {
+			split = size
+			// Don't split in the middle of a character
+			for split > 0 && !utf8.RuneStart(text[split]) {
+				split--
+			}
+		}
+
+		pages = append(pages, text[:split])
+		text = strings.TrimPrefix(text[split:], "\n")
+	}
+
+	if text != "" || len(pages) == 0 {
+		pages = append(pages, text)
+	}
+
+	return pages
+}
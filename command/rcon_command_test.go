@@ -0,0 +1,128 @@
+package command
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/diamondburned/arikawa/discord"
+	"gitlab.com/EbonJaeger/dolphin/config"
+)
+
+func TestMatchCommand(t *testing.T) {
+	// given
+	tests := []struct {
+		pattern  string
+		command  string
+		expected bool
+	}{
+		{"kick *", "kick Steve griefing", true},
+		{"kick *", "kick", false},
+		{"op", "op Steve", true},
+		{"op", "op", true},
+		{"op", "operator", false},
+		{"/tp *", "TP Steve Alex", true},
+		{"gamemode * Steve", "gamemode creative Steve", true},
+		{"say", "stop", false},
+		{"minecraft:op", "op Steve", true},
+	}
+
+	for _, test := range tests {
+		// when
+		actual := matchCommand(test.pattern, test.command)
+
+		// then
+		if actual != test.expected {
+			t.Errorf("Matching '%s' against '%s' is incorrect, got: %t, expected: %t", test.command, test.pattern, actual, test.expected)
+		}
+	}
+}
+
+func TestCheckRconRules(t *testing.T) {
+	// given
+	rules := []config.RconRuleConfig{
+		{Roles: []string{"100"}, Allow: []string{"kick *", "tp *"}, Deny: []string{"op", "stop"}},
+		{Deny: []string{"stop"}},
+	}
+	moderator := sender{UserID: "1", RoleIDs: []string{"100"}}
+	member := sender{UserID: "2", RoleIDs: []string{"200"}}
+	admin := sender{UserID: "3", Permissions: discord.PermissionAll}
+
+	// then
+	if ok, _ := checkRconRules(rules, moderator, "kick Steve"); !ok {
+		t.Errorf("Moderators should be able to kick players")
+	}
+	if ok, _ := checkRconRules(rules, moderator, "op Steve"); ok {
+		t.Errorf("Moderators should not be able to op players")
+	}
+	if ok, _ := checkRconRules(rules, member, "kick Steve"); ok {
+		t.Errorf("Members should not be able to run commands without an allow pattern")
+	}
+	if ok, _ := checkRconRules(rules, admin, "op Steve"); !ok {
+		t.Errorf("Administrators should be able to run commands that aren't denied to them")
+	}
+	if ok, _ := checkRconRules(rules, admin, "stop"); ok {
+		t.Errorf("Administrators should not be able to run commands denied to everyone")
+	}
+	if ok, _ := checkRconRules(rules, moderator, "minecraft:op Steve"); ok {
+		t.Errorf("Moderators should not be able to op players with a namespaced command")
+	}
+	if ok, _ := checkRconRules(rules, admin, "execute as @a run minecraft:stop"); ok {
+		t.Errorf("Administrators should not be able to stop the server with an execute command")
+	}
+	if ok, _ := checkRconRules(rules, moderator, "execute as @a run kick Steve"); ok {
+		t.Errorf("Moderators should not be able to use execute without an allow pattern for it")
+	}
+	if ok, _ := checkRconRules([]config.RconRuleConfig{}, member, "stop"); !ok {
+		t.Errorf("Any command should be allowed if there are no rules")
+	}
+}
+
+func TestCommandChain(t *testing.T) {
+	// when
+	actual := commandChain("/execute as @a at @s run execute if entity @s run minecraft:op  Steve")
+
+	// then
+	expected := []string{"execute as @a at @s run execute if entity @s run minecraft:op Steve", "execute if entity @s run minecraft:op Steve", "op Steve"}
+	if strings.Join(actual, "|") != strings.Join(expected, "|") {
+		t.Errorf("Command chain is incorrect, got: %q, expected: %q", actual, expected)
+	}
+}
+
+func TestAuditLogLine(t *testing.T) {
+	// given
+	user := discord.User{ID: 1234, Username: "Steve", Discriminator: "0001"}
+	at := time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	// when
+	line := auditLogLine(at, user, "allowed", "say hi\n2020-05-01T12:00:00Z\tAdmin#0001 (1)\tallowed\top Steve")
+
+	// then
+	expected := "2020-05-01T12:00:00Z\tSteve#0001 (1234)\tallowed\t\"say hi\\n2020-05-01T12:00:00Z\\tAdmin#0001 (1)\\tallowed\\top Steve\"\n"
+	if line != expected {
+		t.Errorf("Audit log line is incorrect, got: %q, expected: %q", line, expected)
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	// given
+	text := strings.Repeat("a", 8) + "\n" + strings.Repeat("b", 8) + "\n" + strings.Repeat("c", 25)
+
+	// when
+	pages := paginate(text, 20)
+
+	// then
+	expected := []string{
+		strings.Repeat("a", 8) + "\n" + strings.Repeat("b", 8),
+		strings.Repeat("c", 20),
+		strings.Repeat("c", 5),
+	}
+	if len(pages) != len(expected) {
+		t.Fatalf("Incorrect number of pages, got: %d, expected: %d", len(pages), len(expected))
+	}
+	for i := range pages {
+		if pages[i] != expected[i] {
+			t.Errorf("Page %d is incorrect, got: '%s', expected: '%s'", i, pages[i], expected[i])
+		}
+	}
+}
@@ -0,0 +1,361 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"gitlab.com/EbonJaeger/dolphin/storage"
+)
+
+// sessionStaleAfter is how long an open session can go without being seen
+// before we assume the player left while we weren't watching, such as when
+// Dolphin wasn't running.
+const sessionStaleAfter = 10 * time.Minute
+
+// PlayerStats are the play sessions and statistics of a player.
+type PlayerStats struct {
+	Name      string        `json:"name"`
+	FirstSeen time.Time     `json:"first_seen"`
+	LastSeen  time.Time     `json:"last_seen"`
+	Playtime  time.Duration `json:"playtime"`
+	Sessions  int           `json:"sessions"`
+	// SessionStart is when the player's current session started, or zero if
+	// they aren't online.
+	SessionStart time.Time `json:"session_start,omitempty"`
+}
+
+// Online checks if the player has an open session.
+func (p PlayerStats) Online() bool {
+	return !p.SessionStart.IsZero()
+}
+
+// TotalPlaytime gets the playtime of the player, including their current
+// session if they're online.
+func (p PlayerStats) TotalPlaytime(now time.Time) time.Duration {
+	if p.Online() && now.After(p.SessionStart) {
+		return p.Playtime + now.Sub(p.SessionStart)
+	}
+	return p.Playtime
+}
+
+// open starts a new session for the player.
+func (p *PlayerStats) open(at time.Time) {
+	if p.FirstSeen.IsZero() {
+		p.FirstSeen = at
+	}
+	p.LastSeen = at
+	p.Sessions++
+	p.SessionStart = at
+}
+
+// close ends the player's current session and adds it to their playtime.
+func (p *PlayerStats) close(at time.Time) {
+	if !p.Online() {
+		return
+	}
+	if at.After(p.SessionStart) {
+		p.Playtime += at.Sub(p.SessionStart)
+	}
+	p.LastSeen = at
+	p.SessionStart = time.Time{}
+}
+
+// Session is a finished play session of a player.
+type Session struct {
+	Player string    `json:"player"`
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+}
+
+// SessionTracker keeps track of when players join and leave the server.
+// Sessions are kept in the database, so they can be closed correctly after
+// Dolphin restarts.
+type SessionTracker struct {
+	mu   sync.Mutex
+	repo storage.Repository
+}
+
+// NewSessionTracker creates a session tracker stored in a repository.
+func NewSessionTracker(repo storage.Repository) *SessionTracker {
+	return &SessionTracker{repo: repo}
+}
+
+// Join starts a session for a player. If they already have one, such as
+// when we missed them leaving, it is closed first.
+func (t *SessionTracker) Join(player string, at time.Time) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stats, _, err := t.get(player)
+	if err != nil {
+		return err
+	}
+
+	stats.Name = player
+	if err := t.end(&stats, stats.LastSeen); err != nil {
+		return err
+	}
+	stats.open(at)
+	return t.save(stats)
+}
+
+// Leave ends the session of a player.
+func (t *SessionTracker) Leave(player string, at time.Time) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stats, ok, err := t.get(player)
+	if err != nil || !ok {
+		return err
+	}
+
+	if err := t.end(&stats, at); err != nil {
+		return err
+	}
+	return t.save(stats)
+}
+
+// CloseAll ends every open session, such as when the server stops.
+func (t *SessionTracker) CloseAll(at time.Time) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	all, err := t.all()
+	if err != nil {
+		return err
+	}
+
+	for _, stats := range all {
+		if stats.Online() {
+			if err := t.end(&stats, at); err != nil {
+				return err
+			}
+			if err := t.save(stats); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// CloseOpen ends every session that was left open, such as when Dolphin
+// didn't stop cleanly. Each session ends when the player was last seen,
+// because we can't know how long they stayed on after that.
+func (t *SessionTracker) CloseOpen() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	all, err := t.all()
+	if err != nil {
+		return err
+	}
+
+	for _, stats := range all {
+		if stats.Online() {
+			if err := t.end(&stats, stats.LastSeen); err != nil {
+				return err
+			}
+			if err := t.save(stats); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Reconcile updates the open sessions to match the players that are online
+// right now, such as from the list command. Players that are no longer
+// online have their session closed when they were last seen, and players
+// that don't have a session get one. Sessions that haven't been seen in a
+// while are restarted, because we can't know how long the player was on.
+func (t *SessionTracker) Reconcile(online []string, at time.Time) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	all, err := t.all()
+	if err != nil {
+		return err
+	}
+
+	// Find who is online
+	isOnline := make(map[string]string, len(online))
+	for _, player := range online {
+		isOnline[strings.ToLower(player)] = player
+	}
+
+	seen := make(map[string]bool, len(all))
+	for _, stats := range all {
+		key := strings.ToLower(stats.Name)
+		seen[key] = true
+
+		_, on := isOnline[key]
+		if !on && !stats.Online() {
+			continue
+		}
+
+		if stats.Online() && (!on || at.Sub(stats.LastSeen) > sessionStaleAfter) {
+			if err := t.end(&stats, stats.LastSeen); err != nil {
+				return err
+			}
+		}
+		if on {
+			if stats.Online() {
+				stats.LastSeen = at
+			} else {
+				stats.open(at)
+			}
+		}
+
+		if err := t.save(stats); err != nil {
+			return err
+		}
+	}
+
+	// Start sessions for players we've never seen before
+	for key, player := range isOnline {
+		if seen[key] {
+			continue
+		}
+
+		stats := PlayerStats{Name: player}
+		stats.open(at)
+		if err := t.save(stats); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Get gets the statistics of a player, case-insensitive.
+func (t *SessionTracker) Get(player string) (PlayerStats, bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.get(player)
+}
+
+// All gets the statistics of every player.
+func (t *SessionTracker) All() ([]PlayerStats, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.all()
+}
+
+// get gets the statistics of a player. The mutex must be held.
+func (t *SessionTracker) get(player string) (PlayerStats, bool, error) {
+	var stats PlayerStats
+	ok, err := t.repo.Get(storage.PlayersBucket, strings.ToLower(player), &stats)
+	return stats, ok, err
+}
+
+// all gets the statistics of every player. The mutex must be held.
+func (t *SessionTracker) all() ([]PlayerStats, error) {
+	all := make([]PlayerStats, 0)
+	err := t.repo.ForEach(storage.PlayersBucket, func(key string, data []byte) error {
+		var stats PlayerStats
+		if err := json.Unmarshal(data, &stats); err != nil {
+			return fmt.Errorf("invalid player stats '%s': %s", key, err)
+		}
+
+		all = append(all, stats)
+		return nil
+	})
+
+	return all, err
+}
+
+// end closes the current session of a player, if they have one, and adds it
+// to the session history. The mutex must be held.
+func (t *SessionTracker) end(stats *PlayerStats, at time.Time) error {
+	if !stats.Online() {
+		return nil
+	}
+
+	session := Session{Player: stats.Name, Start: stats.SessionStart, End: at}
+	stats.close(at)
+	return t.repo.Put(storage.SessionsBucket, eventKey(at, stats.Name), session)
+}
+
+// Sessions gets the finished sessions that ended in a time range.
+func (t *SessionTracker) Sessions(from time.Time, to time.Time) ([]Session, error) {
+	list := make([]Session, 0)
+	err := forEachEvent(t.repo, storage.SessionsBucket, from, to, func(data []byte) error {
+		var session Session
+		if err := json.Unmarshal(data, &session); err != nil {
+			return err
+		}
+		list = append(list, session)
+		return nil
+	})
+	return list, err
+}
+
+// save saves the statistics of a player. The mutex must be held.
+func (t *SessionTracker) save(stats PlayerStats) error {
+	return t.repo.Put(storage.PlayersBucket, strings.ToLower(stats.Name), stats)
+}
+
+// PlayerJoined starts a play session for a player that joined the server.
+func (p *Parser) PlayerJoined(player string, at time.Time) {
+	if p.sessions == nil {
+		return
+	}
+
+	if err := p.sessions.Join(player, at); err != nil {
+		p.log.Errorf("Error starting the session of '%s': %s\n", player, err)
+	}
+}
+
+// PlayerLeft ends the play session of a player that left the server.
+func (p *Parser) PlayerLeft(player string, at time.Time) {
+	if p.sessions == nil {
+		return
+	}
+
+	if err := p.sessions.Leave(player, at); err != nil {
+		p.log.Errorf("Error ending the session of '%s': %s\n", player, err)
+	}
+}
+
+// CloseSessions ends every open play session, such as when the server or
+// Dolphin stops.
+func (p *Parser) CloseSessions(at time.Time) {
+	if p.sessions == nil {
+		return
+	}
+
+	if err := p.sessions.CloseAll(at); err != nil {
+		p.log.Errorf("Error ending play sessions: %s\n", err)
+	}
+}
+
+// CloseOpenSessions ends the play sessions left open the last time Dolphin
+// ran, so players that are still online don't get counted twice.
+func (p *Parser) CloseOpenSessions() {
+	if p.sessions == nil {
+		return
+	}
+
+	if err := p.sessions.CloseOpen(); err != nil {
+		p.log.Errorf("Error ending old play sessions: %s\n", err)
+	}
+}
+
+// ReconcileSessions updates the open play sessions to match the players
+// that are online right now.
+func (p *Parser) ReconcileSessions(online []string, at time.Time) {
+	if p.sessions == nil {
+		return
+	}
+
+	if err := p.sessions.Reconcile(online, at); err != nil {
+		p.log.Errorf("Error updating play sessions: %s\n", err)
+	}
+}
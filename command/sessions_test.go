@@ -0,0 +1,143 @@
+package command
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSessionPlaytime(t *testing.T) {
+	// given
+	_, db, cleanup := openTestDB(t)
+	defer cleanup()
+	tracker := NewSessionTracker(db)
+	start := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	// when
+	tracker.Join("Steve", start)
+	tracker.Leave("Steve", start.Add(time.Hour))
+	tracker.Join("Steve", start.Add(2*time.Hour))
+	tracker.Leave("steve", start.Add(150*time.Minute))
+
+	// then
+	stats, ok, err := tracker.Get("STEVE")
+	if err != nil || !ok {
+		t.Fatalf("Failed to get player stats: %t %v", ok, err)
+	}
+	if stats.Playtime != 90*time.Minute || stats.Sessions != 2 {
+		t.Errorf("Playtime is incorrect: %+v", stats)
+	}
+	if !stats.FirstSeen.Equal(start) || !stats.LastSeen.Equal(start.Add(150*time.Minute)) || stats.Online() {
+		t.Errorf("Session times are incorrect: %+v", stats)
+	}
+}
+
+func TestServerStopClosesSessions(t *testing.T) {
+	// given
+	_, db, cleanup := openTestDB(t)
+	defer cleanup()
+	tracker := NewSessionTracker(db)
+	start := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+	tracker.Join("Steve", start)
+	tracker.Join("Alex", start.Add(30*time.Minute))
+
+	// when
+	tracker.CloseAll(start.Add(time.Hour))
+
+	// then
+	all, _ := tracker.All()
+	for _, stats := range all {
+		if stats.Online() {
+			t.Errorf("Session of %s should be closed", stats.Name)
+		}
+	}
+	alex, _, _ := tracker.Get("Alex")
+	if alex.Playtime != 30*time.Minute {
+		t.Errorf("Playtime is incorrect: %s", alex.Playtime)
+	}
+}
+
+func TestCloseOpenSessionsAtLastSeen(t *testing.T) {
+	// given
+	_, db, cleanup := openTestDB(t)
+	defer cleanup()
+	tracker := NewSessionTracker(db)
+	start := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+	tracker.Join("Steve", start)
+	tracker.Reconcile([]string{"Steve"}, start.Add(5*time.Minute))
+
+	// when
+	tracker.CloseOpen()
+	tracker.Reconcile([]string{"Steve"}, start.Add(20*time.Minute))
+	tracker.CloseOpen()
+
+	// then
+	steve, _, _ := tracker.Get("Steve")
+	if steve.Online() || steve.Sessions != 2 || steve.Playtime != 5*time.Minute {
+		t.Errorf("Open sessions should end when the player was last seen: %+v", steve)
+	}
+}
+
+func TestReconcileSessions(t *testing.T) {
+	// given
+	_, db, cleanup := openTestDB(t)
+	defer cleanup()
+	tracker := NewSessionTracker(db)
+	start := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+	tracker.Join("Steve", start)
+	tracker.Join("Alex", start)
+
+	// when
+	tracker.Reconcile([]string{"Steve", "Notch"}, start.Add(5*time.Minute))
+
+	// then
+	steve, _, _ := tracker.Get("Steve")
+	if !steve.Online() || steve.Sessions != 1 {
+		t.Errorf("Online player should keep their session: %+v", steve)
+	}
+	alex, _, _ := tracker.Get("Alex")
+	if alex.Online() || alex.Playtime != 0 {
+		t.Errorf("Offline player should have their session closed when last seen: %+v", alex)
+	}
+	notch, ok, _ := tracker.Get("Notch")
+	if !ok || !notch.Online() || !notch.SessionStart.Equal(start.Add(5*time.Minute)) {
+		t.Errorf("Missed player should get a session: %+v", notch)
+	}
+}
+
+func TestReconcileRestartsStaleSessions(t *testing.T) {
+	// given
+	_, db, cleanup := openTestDB(t)
+	defer cleanup()
+	tracker := NewSessionTracker(db)
+	start := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+	tracker.Join("Steve", start)
+
+	// when
+	later := start.Add(24 * time.Hour)
+	tracker.Reconcile([]string{"Steve"}, later)
+
+	// then
+	steve, _, _ := tracker.Get("Steve")
+	if steve.Sessions != 2 || !steve.SessionStart.Equal(later) || steve.Playtime != 0 {
+		t.Errorf("Stale session should be restarted: %+v", steve)
+	}
+}
+
+func TestPlaytimeLeaderboard(t *testing.T) {
+	// given
+	now := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+	all := []PlayerStats{
+		{Name: "Steve", Playtime: time.Hour},
+		{Name: "Alex", Playtime: 30 * time.Minute, SessionStart: now.Add(-time.Hour)},
+		{Name: "Notch", Playtime: 2 * time.Minute},
+	}
+	expected := "**1.** Alex — 1h 30m\n**2.** Steve — 1h\n**3.** Notch — 2m\n"
+
+	// when
+	actual := playtimeLeaderboard(all, now)
+
+	// then
+	if actual != expected {
+		t.Errorf("Leaderboard is incorrect, expected: %q, got: %q", expected, actual)
+	}
+}
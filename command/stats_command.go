@@ -0,0 +1,279 @@
+package command
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/diamondburned/arikawa/state"
+)
+
+// leaderboardSize is how many players are shown on a leaderboard.
+const leaderboardSize = 10
+
+// statsPlayerParam is the optional player parameter of the stats commands.
+var statsPlayerParam = Param{Name: "player", Desc: "The Minecraft name of the player", Type: StringParam, Optional: true}
+
+// SeenPlayer shows when a player was last online.
+func (p *Parser) SeenPlayer(state *state.State, cmd DiscordCommand) error {
+	player := cmd.StringArg("player")
+	if !playerNameRegex.MatchString(player) {
+		return sendInvalidName(state, cmd, player)
+	}
+
+	stats, ok, err := p.sessions.Get(player)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return sendNeverSeen(state, cmd, player)
+	}
+
+	embed := CreateEmbed(InfoColor, stats.Name, seenText(stats, time.Now()), "")
+	return SendCommandEmbed(state, cmd, embed)
+}
+
+// ShowPlaytime shows how long a player has played on the server. If no
+// player is given, the sender's linked player is used.
+func (p *Parser) ShowPlaytime(state *state.State, cmd DiscordCommand) error {
+	player, ok := p.statsPlayer(cmd)
+	if !ok {
+		embed := CreateEmbed(WarnColor, "No Player Given", fmt.Sprintf(":warning: Give a player name, or link your account with `%slink`.", commandPrefix(cmd)), "")
+		return SendCommandEmbed(state, cmd, embed)
+	}
+	if !playerNameRegex.MatchString(player) {
+		return sendInvalidName(state, cmd, player)
+	}
+
+	stats, ok, err := p.sessions.Get(player)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return sendNeverSeen(state, cmd, player)
+	}
+
+	embed := CreateEmbed(InfoColor, fmt.Sprintf("Playtime of %s", stats.Name), playtimeText(stats, time.Now()), "")
+	return SendCommandEmbed(state, cmd, embed)
+}
+
+// TopPlaytime shows the players that have played the longest.
+func (p *Parser) TopPlaytime(state *state.State, cmd DiscordCommand) error {
+	all, err := p.sessions.All()
+	if err != nil {
+		return err
+	}
+
+	embed := CreateEmbed(InfoColor, "Top Playtime", playtimeLeaderboard(all, time.Now()), "")
+	return SendCommandEmbed(state, cmd, embed)
+}
+
+// ShowDeaths shows how many times a player has died, and how. If no player
+// is given, the sender's linked player is used, or else the whole server.
+func (p *Parser) ShowDeaths(state *state.State, cmd DiscordCommand) error {
+	player, ok := p.statsPlayer(cmd)
+	if ok && !playerNameRegex.MatchString(player) {
+		return sendInvalidName(state, cmd, player)
+	}
+
+	deaths, err := p.events.Deaths(time.Time{}, time.Now().Add(time.Second))
+	if err != nil {
+		return err
+	}
+
+	title := "Server Deaths"
+	if ok {
+		deaths = playerDeaths(deaths, player)
+		title = fmt.Sprintf("Deaths of %s", player)
+	}
+
+	embed := CreateEmbed(InfoColor, title, deathsText(deaths), "")
+	return SendCommandEmbed(state, cmd, embed)
+}
+
+// TopDeaths shows the players that have died the most.
+func (p *Parser) TopDeaths(state *state.State, cmd DiscordCommand) error {
+	deaths, err := p.events.Deaths(time.Time{}, time.Now().Add(time.Second))
+	if err != nil {
+		return err
+	}
+
+	embed := CreateEmbed(InfoColor, "Top Deaths", deathsLeaderboard(deaths), "")
+	return SendCommandEmbed(state, cmd, embed)
+}
+
+// ShowAdvancements shows the advancements a player has made, newest first.
+func (p *Parser) ShowAdvancements(state *state.State, cmd DiscordCommand) error {
+	player := cmd.StringArg("player")
+	if !playerNameRegex.MatchString(player) {
+		return sendInvalidName(state, cmd, player)
+	}
+
+	advs, err := p.events.Advancements(player, time.Time{}, time.Now().Add(time.Second))
+	if err != nil {
+		return err
+	}
+
+	footer := fmt.Sprintf("%d advancements", len(advs))
+	embed := CreateEmbed(InfoColor, fmt.Sprintf("Advancements of %s", player), advancementsText(advs), footer)
+	return SendCommandEmbed(state, cmd, embed)
+}
+
+// statsPlayer gets the player a stats command is for: the player argument
+// if there is one, or else the sender's linked player.
+func (p *Parser) statsPlayer(cmd DiscordCommand) (string, bool) {
+	if cmd.HasArg("player") {
+		return cmd.StringArg("player"), true
+	}
+	return p.LinkedPlayer(cmd.Sender.ID)
+}
+
+// seenText describes when a player was last online.
+func seenText(stats PlayerStats, now time.Time) string {
+	b := strings.Builder{}
+	if stats.Online() {
+		b.WriteString(fmt.Sprintf(":green_circle: **%s** is online now, and has been for %s.", stats.Name, formatDuration(now.Sub(stats.SessionStart))))
+	} else {
+		b.WriteString(fmt.Sprintf(":red_circle: **%s** was last seen %s ago.", stats.Name, formatDuration(now.Sub(stats.LastSeen))))
+	}
+	b.WriteString(fmt.Sprintf("\nFirst seen %s.", stats.FirstSeen.UTC().Format("2006-01-02")))
+	return b.String()
+}
+
+// playtimeText describes how long a player has played.
+func playtimeText(stats PlayerStats, now time.Time) string {
+	total := stats.TotalPlaytime(now)
+	text := fmt.Sprintf("**%s** has played for **%s** over %d sessions.", stats.Name, formatDuration(total), stats.Sessions)
+	if stats.Sessions > 0 {
+		text += fmt.Sprintf("\nThe average session is %s.", formatDuration(total/time.Duration(stats.Sessions)))
+	}
+	return text
+}
+
+// playtimeLeaderboard lists the players with the most playtime, most first.
+func playtimeLeaderboard(all []PlayerStats, now time.Time) string {
+	if len(all) == 0 {
+		return "Nobody has played yet."
+	}
+
+	sort.SliceStable(all, func(i, j int) bool {
+		return all[i].TotalPlaytime(now) > all[j].TotalPlaytime(now)
+	})
+	if len(all) > leaderboardSize {
+		all = all[:leaderboardSize]
+	}
+
+	b := strings.Builder{}
+	for i, stats := range all {
+		b.WriteString(fmt.Sprintf("**%d.** %s — %s\n", i+1, stats.Name, formatDuration(stats.TotalPlaytime(now))))
+	}
+	return b.String()
+}
+
+// sendNeverSeen tells the user that a player has never been seen on the
+// server.
+func sendNeverSeen(state *state.State, cmd DiscordCommand, player string) error {
+	embed := CreateEmbed(WarnColor, "Never Seen", fmt.Sprintf(":warning: **%s** has never played on this server.", player), "")
+	return SendCommandEmbed(state, cmd, embed)
+}
+
+// playerDeaths gets the deaths of one player.
+func playerDeaths(deaths []DeathEvent, player string) []DeathEvent {
+	list := make([]DeathEvent, 0)
+	for _, death := range deaths {
+		if strings.EqualFold(death.Player, player) {
+			list = append(list, death)
+		}
+	}
+	return list
+}
+
+// deathsText describes a list of deaths, with the most common causes and
+// killers.
+func deathsText(deaths []DeathEvent) string {
+	if len(deaths) == 0 {
+		return "No deaths yet!"
+	}
+
+	causes := make(map[string]int)
+	killers := make(map[string]int)
+	for _, death := range deaths {
+		causes[death.Cause]++
+		if death.Killer != "" {
+			killers[death.Killer]++
+		}
+	}
+
+	b := strings.Builder{}
+	b.WriteString(fmt.Sprintf(":skull: **%d** deaths\n", len(deaths)))
+	b.WriteString("\n**Most common causes**\n")
+	b.WriteString(rankCounts(causes, 3))
+	if len(killers) > 0 {
+		b.WriteString("\n**Deadliest killers**\n")
+		b.WriteString(rankCounts(killers, 3))
+	}
+	return b.String()
+}
+
+// deathsLeaderboard lists the players with the most deaths, most first.
+func deathsLeaderboard(deaths []DeathEvent) string {
+	if len(deaths) == 0 {
+		return "Nobody has died yet."
+	}
+
+	counts := make(map[string]int)
+	names := make(map[string]string)
+	for _, death := range deaths {
+		key := strings.ToLower(death.Player)
+		counts[key]++
+		names[key] = death.Player
+	}
+
+	byName := make(map[string]int, len(counts))
+	for key, count := range counts {
+		byName[names[key]] = count
+	}
+	return rankCounts(byName, leaderboardSize)
+}
+
+// advancementsText lists advancements, newest first. Only the newest 20 are
+// shown.
+func advancementsText(advs []AdvancementEvent) string {
+	if len(advs) == 0 {
+		return "No advancements yet."
+	}
+
+	b := strings.Builder{}
+	for i := len(advs) - 1; i >= 0 && i >= len(advs)-20; i-- {
+		b.WriteString(fmt.Sprintf("**%s** — %s\n", advs[i].Title, advs[i].At.UTC().Format("2006-01-02")))
+	}
+	if len(advs) > 20 {
+		b.WriteString(fmt.Sprintf("*and %d more*", len(advs)-20))
+	}
+	return b.String()
+}
+
+// rankCounts lists the names with the highest counts, highest first. Names
+// with the same count are sorted alphabetically.
+func rankCounts(counts map[string]int, max int) string {
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if counts[names[i]] != counts[names[j]] {
+			return counts[names[i]] > counts[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	if len(names) > max {
+		names = names[:max]
+	}
+
+	b := strings.Builder{}
+	for i, name := range names {
+		b.WriteString(fmt.Sprintf("**%d.** %s — %d\n", i+1, name, counts[name]))
+	}
+	return b.String()
+}
@@ -0,0 +1,55 @@
+package command
+
+import (
+	"sync"
+
+	"gitlab.com/EbonJaeger/dolphin/config"
+)
+
+// ConfigStore holds the live config used by commands. The config is never
+// changed in place; changes are made to a copy that is swapped in, so
+// anything reading the config always sees a whole one.
+type ConfigStore interface {
+	// Config gets the current config. It must not be changed.
+	Config() *config.RootConfig
+	// UpdateConfig calls change with a copy of the current config, and swaps
+	// the copy in if change doesn't return an error. Updates are made one at
+	// a time, so none of them are lost.
+	UpdateConfig(change func(c *config.RootConfig) error) error
+}
+
+// configHolder is a ConfigStore that keeps the config in memory.
+type configHolder struct {
+	conf *config.RootConfig
+	mu   sync.RWMutex
+	// updateMu makes updates one at a time
+	updateMu sync.Mutex
+}
+
+// newConfigHolder creates a new configHolder with a config.
+func newConfigHolder(conf *config.RootConfig) *configHolder {
+	return &configHolder{conf: conf}
+}
+
+// Config gets the current config.
+func (h *configHolder) Config() *config.RootConfig {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.conf
+}
+
+// UpdateConfig changes a copy of the config and swaps it in.
+func (h *configHolder) UpdateConfig(change func(c *config.RootConfig) error) error {
+	h.updateMu.Lock()
+	defer h.updateMu.Unlock()
+
+	updated := *h.Config()
+	if err := change(&updated); err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	h.conf = &updated
+	h.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,54 @@
+package command
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"gitlab.com/EbonJaeger/dolphin/config"
+)
+
+func TestConfigHolderUpdatesOneAtATime(t *testing.T) {
+	// given
+	old := &config.RootConfig{}
+	holder := newConfigHolder(old)
+
+	// when
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			holder.UpdateConfig(func(c *config.RootConfig) error {
+				c.Discord.Status.PollInterval++
+				return nil
+			})
+		}()
+	}
+	wg.Wait()
+
+	// then
+	if actual := holder.Config().Discord.Status.PollInterval; actual != 50 {
+		t.Errorf("Updates were lost, got: %d, expected: 50", actual)
+	}
+	if old.Discord.Status.PollInterval != 0 {
+		t.Errorf("The old config should not be changed in place")
+	}
+}
+
+func TestConfigHolderKeepsConfigOnError(t *testing.T) {
+	// given
+	old := &config.RootConfig{}
+	holder := newConfigHolder(old)
+
+	// when
+	err := holder.UpdateConfig(func(c *config.RootConfig) error {
+		c.Discord.Status.PollInterval = 10
+		return errors.New("invalid")
+	})
+
+	// then
+	if err == nil || holder.Config() != old {
+		t.Errorf("A failed update should keep the old config, got: %+v", holder.Config())
+	}
+}
@@ -0,0 +1,154 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/diamondburned/arikawa/state"
+	"gitlab.com/EbonJaeger/dolphin/storage"
+)
+
+// banCheckInterval is how often expired temporary bans are checked for.
+const banCheckInterval = 30 * time.Second
+
+// TempBan is a temporary ban of a player.
+type TempBan struct {
+	Player    string    `json:"player"`
+	Reason    string    `json:"reason"`
+	BannedBy  string    `json:"banned_by"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// TempBanList keeps track of temporary bans. Bans are kept in the database
+// so they survive restarts.
+type TempBanList struct {
+	repo storage.Repository
+}
+
+// NewTempBanList creates a list of temporary bans stored in a repository.
+func NewTempBanList(repo storage.Repository) *TempBanList {
+	return &TempBanList{repo: repo}
+}
+
+// Add adds or replaces the temporary ban of a player.
+func (l *TempBanList) Add(ban TempBan) error {
+	return l.repo.Put(storage.TempBansBucket, strings.ToLower(ban.Player), ban)
+}
+
+// Remove removes the temporary ban of a player, if they have one.
+func (l *TempBanList) Remove(player string) error {
+	return l.repo.Delete(storage.TempBansBucket, strings.ToLower(player))
+}
+
+// Get gets the temporary ban of a player, if they have one.
+func (l *TempBanList) Get(player string) (TempBan, bool, error) {
+	var ban TempBan
+	ok, err := l.repo.Get(storage.TempBansBucket, strings.ToLower(player), &ban)
+	if err != nil {
+		return TempBan{}, false, err
+	}
+	return ban, ok, nil
+}
+
+// Expired gets all of the bans that have expired by the given time.
+func (l *TempBanList) Expired(now time.Time) ([]TempBan, error) {
+	expired := make([]TempBan, 0)
+	err := l.repo.ForEach(storage.TempBansBucket, func(key string, data []byte) error {
+		var ban TempBan
+		if err := json.Unmarshal(data, &ban); err != nil {
+			return fmt.Errorf("invalid temporary ban '%s': %s", key, err)
+		}
+
+		if !ban.ExpiresAt.After(now) {
+			expired = append(expired, ban)
+		}
+		return nil
+	})
+
+	return expired, err
+}
+
+// BanScheduler lifts temporary bans when they expire.
+type BanScheduler struct {
+	done   chan struct{}
+	parser *Parser
+	state  *state.State
+}
+
+// NewBanScheduler creates a new scheduler to lift the temporary bans kept by
+// a command parser.
+func NewBanScheduler(parser *Parser, state *state.State) *BanScheduler {
+	return &BanScheduler{
+		done:   make(chan struct{}),
+		parser: parser,
+		state:  state,
+	}
+}
+
+// Run checks for expired bans until the scheduler is closed.
+func (s *BanScheduler) Run() {
+	ticker := time.NewTicker(banCheckInterval)
+	defer ticker.Stop()
+
+	s.liftExpired()
+	for {
+		select {
+		case <-ticker.C:
+			s.liftExpired()
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// Close stops checking for expired bans.
+func (s *BanScheduler) Close() {
+	close(s.done)
+}
+
+// liftExpired pardons every player whose temporary ban has expired. If the
+// server can't be reached, they are tried again later.
+func (s *BanScheduler) liftExpired() {
+	p := s.parser
+	if p.tempBans == nil {
+		return
+	}
+
+	expired, err := p.tempBans.Expired(time.Now())
+	if err != nil {
+		p.log.Errorf("Error reading temporary bans: %s\n", err)
+		return
+	}
+	if len(expired) == 0 {
+		return
+	}
+
+	// Create RCON connection
+	conn, err := p.connect()
+	if err != nil {
+		p.log.Warnf("Unable to lift expired bans: %s\n", err)
+		return
+	}
+	defer conn.Close()
+
+	for _, ban := range expired {
+		if _, err := conn.SendCommand("pardon " + ban.Player); err != nil {
+			p.log.Errorf("Error lifting the temporary ban of '%s': %s\n", ban.Player, err)
+			continue
+		}
+
+		p.log.Infof("Temporary ban of '%s' has expired\n", ban.Player)
+		if err := p.tempBans.Remove(ban.Player); err != nil {
+			p.log.Errorf("Error saving temporary bans: %s\n", err)
+		}
+
+		p.sendModerationLog(s.state, moderationAction{
+			Title:  "Temporary Ban Expired",
+			Color:  SuccessColor,
+			Player: ban.Player,
+			Reason: ban.Reason,
+		})
+	}
+}
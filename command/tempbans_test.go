@@ -0,0 +1,88 @@
+package command
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gitlab.com/EbonJaeger/dolphin/storage"
+)
+
+// openTestDB opens a database in a new temporary directory. The returned
+// function closes the database and removes the directory.
+func openTestDB(t *testing.T) (string, *storage.DB, func()) {
+	dir, err := ioutil.TempDir("", "dolphin")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %s", err)
+	}
+
+	path := filepath.Join(dir, "dolphin.db")
+	db, err := storage.Open(path)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("Failed to open database: %s", err)
+	}
+
+	return path, db, func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestTempBanListPersists(t *testing.T) {
+	// given
+	path, db, cleanup := openTestDB(t)
+	defer cleanup()
+
+	list := NewTempBanList(db)
+	expires := time.Now().Add(time.Hour).Round(time.Second)
+
+	// when
+	if err := list.Add(TempBan{Player: "Steve", Reason: "griefing", BannedBy: "mod", ExpiresAt: expires}); err != nil {
+		t.Fatalf("Failed to add temporary ban: %s", err)
+	}
+	db.Close()
+	reopened, err := storage.Open(path)
+
+	// then
+	if err != nil {
+		t.Fatalf("Failed to reopen database: %s", err)
+	}
+	defer reopened.Close()
+	ban, ok, _ := NewTempBanList(reopened).Get("steve")
+	if !ok {
+		t.Fatalf("Temporary ban was not saved")
+	}
+	if ban.Reason != "griefing" || !ban.ExpiresAt.Equal(expires) {
+		t.Errorf("Loaded temporary ban is incorrect: %+v", ban)
+	}
+}
+
+func TestTempBanListExpired(t *testing.T) {
+	// given
+	_, db, cleanup := openTestDB(t)
+	defer cleanup()
+
+	list := NewTempBanList(db)
+	now := time.Now()
+	list.Add(TempBan{Player: "Steve", ExpiresAt: now.Add(-time.Minute)})
+	list.Add(TempBan{Player: "Alex", ExpiresAt: now.Add(time.Minute)})
+
+	// when
+	expired, err := list.Expired(now)
+
+	// then
+	if err != nil {
+		t.Fatalf("Failed to get expired bans: %s", err)
+	}
+	if len(expired) != 1 || expired[0].Player != "Steve" {
+		t.Errorf("Expired bans are incorrect: %+v", expired)
+	}
+
+	list.Remove("Steve")
+	if _, ok, _ := list.Get("Steve"); ok {
+		t.Errorf("Removed ban should be gone")
+	}
+}
@@ -1,8 +1,11 @@
 package command
 
 import (
+	"github.com/DataDrake/waterlog"
 	"github.com/diamondburned/arikawa/discord"
 	"github.com/diamondburned/arikawa/state"
+	"gitlab.com/EbonJaeger/dolphin/config"
+	"gitlab.com/EbonJaeger/dolphin/rcon"
 )
 
 // Color code for embed colors.
@@ -13,11 +16,47 @@ const (
 	WarnColor    = 0xff9800
 )
 
-// Handler is the interface the each Discord command handler implements.
+// Handler is a command that can be used in Discord. The parameters,
+// subcommands, and aliases are used to parse and validate the arguments
+// given to the command, and to generate help pages and slash commands.
 type Handler struct {
-	Name string
-	Desc string
-	Run  func(state *state.State, cmd DiscordCommand) error
+	Name        string
+	Desc        string
+	Aliases     []string
+	Params      []Param
+	Subcommands []Handler
+	// DefaultPermission is the Discord permission needed to use the command
+	// if there is no permission rule for it in the config. Anyone can use
+	// the command if it is zero.
+	DefaultPermission discord.Permissions
+	// Run runs the command. It may be nil if the command can only be used
+	// with one of its subcommands.
+	Run func(state *state.State, cmd DiscordCommand) error
+}
+
+// ParamType is the type of value a command parameter accepts.
+type ParamType int
+
+// The types of command parameters.
+const (
+	StringParam ParamType = iota
+	IntParam
+	BoolParam
+	UserParam
+	ChannelParam
+	RoleParam
+	DurationParam
+	// RestParam takes the rest of the command text, including spaces. It
+	// must be the last parameter.
+	RestParam
+)
+
+// Param is a parameter that a command takes.
+type Param struct {
+	Name     string
+	Desc     string
+	Type     ParamType
+	Optional bool
 }
 
 // Cmd is the type that all command handlers are.
@@ -27,13 +66,36 @@ type Cmd struct {
 
 // DiscordCommand is a command sent by a user in Discord to be parsed and handled.
 type DiscordCommand struct {
-	Sender    discord.User
-	Command   string
-	Args      []string
+	Sender discord.User
+	// Command is the full name of the command, including any subcommands,
+	// e.g. "config set".
+	Command string
+	// Prefix is the prefix of text commands when the command was used.
+	Prefix string
+	// Args are the raw arguments given to the command.
+	Args []string
+	// Values are the parsed arguments, by parameter name.
+	Values    map[string]interface{}
 	GuildID   discord.GuildID
 	ChannelID discord.ChannelID
 	MessageID discord.MessageID
+	// Interaction is set if the command was used as a slash command.
+	Interaction *Interaction
 }
 
-// Parser is a command parser that handles sending commands to the appropriate handler.
-type Parser struct{}
+// Parser is a command parser that handles sending commands to the appropriate
+// handler. It holds everything the commands use, so several parsers can be
+// used at once.
+type Parser struct {
+	componentHandlers map[string]componentHandler
+	dial              rcon.Dialer
+	events            *EventLog
+	file              *config.File
+	handlers          []Handler
+	hook              func(cmd DiscordCommand, err error)
+	links             *LinkList
+	log               *waterlog.WaterLog
+	sessions          *SessionTracker
+	store             ConfigStore
+	tempBans          *TempBanList
+}
@@ -0,0 +1,294 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	"github.com/diamondburned/arikawa/discord"
+	"github.com/diamondburned/arikawa/state"
+	"gitlab.com/EbonJaeger/dolphin/rcon"
+)
+
+// whitelistPageSize is how many players are shown on each page of the
+// whitelist.
+const whitelistPageSize = 20
+
+// playerNameRegex matches valid Minecraft player names.
+var playerNameRegex = regexp.MustCompile("^[A-Za-z0-9_]{3,16}$")
+
+// whitelistNameParam is the player name parameter of the whitelist commands.
+var whitelistNameParam = Param{Name: "name", Desc: "The Minecraft name of the player", Type: StringParam}
+
+// WhitelistEntry is a player in the server's whitelist.json file.
+type WhitelistEntry struct {
+	UUID string `json:"uuid"`
+	Name string `json:"name"`
+}
+
+// AddToWhitelist adds a player to the server whitelist.
+func (p *Parser) AddToWhitelist(state *state.State, cmd DiscordCommand) error {
+	return p.runWhitelistCommand(state, cmd, "add")
+}
+
+// RemoveFromWhitelist removes a player from the server whitelist.
+func (p *Parser) RemoveFromWhitelist(state *state.State, cmd DiscordCommand) error {
+	return p.runWhitelistCommand(state, cmd, "remove")
+}
+
+// runWhitelistCommand runs a whitelist server command for a player, and
+// shows the response from the server.
+func (p *Parser) runWhitelistCommand(state *state.State, cmd DiscordCommand, action string) error {
+	name := cmd.StringArg("name")
+	if !playerNameRegex.MatchString(name) {
+		return sendInvalidName(state, cmd, name)
+	}
+
+	resp, err := p.sendWhitelistCommand(action, name)
+	if err != nil {
+		return err
+	}
+
+	text := fmt.Sprintf("%s ran `whitelist %s %s`: %s", cmd.Sender.Mention(), action, name, resp)
+	p.SendAuditEmbed(state, CreateEmbed(InfoColor, "Whitelist Changed", text, ""))
+
+	embed := CreateEmbed(SuccessColor, "Whitelist", fmt.Sprintf(":white_check_mark: %s", resp), "")
+	return SendCommandEmbed(state, cmd, embed)
+}
+
+// sendWhitelistCommand sends a whitelist command for a player to the server,
+// and returns the response.
+func (p *Parser) sendWhitelistCommand(action string, name string) (string, error) {
+	// Create RCON connection
+	conn, err := p.connect()
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	// Send the command to Minecraft
+	resp, err := conn.SendCommand(fmt.Sprintf("whitelist %s %s", action, name))
+	if err != nil {
+		return "", err
+	}
+
+	return rcon.StripFormatting(resp), nil
+}
+
+// ListWhitelist shows a page of the players in the server's whitelist.json
+// file, with their UUIDs.
+func (p *Parser) ListWhitelist(state *state.State, cmd DiscordCommand) error {
+	entries, err := readWhitelist(p.config().Discord.Whitelist.FilePath)
+	if err != nil {
+		return err
+	}
+
+	page := 1
+	if cmd.HasArg("page") {
+		page = cmd.IntArg("page")
+	}
+
+	embed := createWhitelistEmbed(entries, page, commandPrefix(cmd))
+	return SendCommandEmbed(state, cmd, embed)
+}
+
+// CheckWhitelist checks if a player is on the server whitelist.
+func (p *Parser) CheckWhitelist(state *state.State, cmd DiscordCommand) error {
+	name := cmd.StringArg("name")
+	if !playerNameRegex.MatchString(name) {
+		return sendInvalidName(state, cmd, name)
+	}
+
+	// Create RCON connection
+	conn, err := p.connect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// Get the whitelist from Minecraft
+	names, err := conn.Whitelist()
+	if err != nil {
+		return err
+	}
+
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			embed := CreateEmbed(SuccessColor, "Whitelist", fmt.Sprintf(":white_check_mark: **%s** is whitelisted.", n), "")
+			return SendCommandEmbed(state, cmd, embed)
+		}
+	}
+
+	embed := CreateEmbed(WarnColor, "Whitelist", fmt.Sprintf(":x: **%s** is not whitelisted.", name), "")
+	return SendCommandEmbed(state, cmd, embed)
+}
+
+// RequestWhitelist posts a request to be whitelisted for moderators to
+// approve or deny.
+func (p *Parser) RequestWhitelist(state *state.State, cmd DiscordCommand) error {
+	if !p.config().Discord.Whitelist.AllowRequests {
+		embed := CreateEmbed(WarnColor, "Requests Disabled", ":warning: Whitelist requests aren't enabled on this server.", "")
+		return SendCommandEmbed(state, cmd, embed)
+	}
+
+	name := cmd.StringArg("name")
+	if !playerNameRegex.MatchString(name) {
+		return sendInvalidName(state, cmd, name)
+	}
+
+	// Find where to post the request
+	channelID := p.config().Discord.Whitelist.RequestChannelID
+	if channelID == "" {
+		channelID = p.config().Discord.Commands.AuditChannelID
+	}
+	snowflake, err := discord.ParseSnowflake(channelID)
+	if err != nil {
+		return fmt.Errorf("no whitelist request channel configured")
+	}
+
+	text := fmt.Sprintf("%s would like **%s** to be whitelisted.", cmd.Sender.Mention(), name)
+	embed := CreateEmbed(InfoColor, "Whitelist Request", text, "")
+	id := fmt.Sprintf("%s:%s", name, cmd.Sender.ID)
+	components := []Component{
+		{
+			Type: ComponentTypeActionRow,
+			Components: []Component{
+				{Type: ComponentTypeButton, Style: ButtonStyleSuccess, Label: "Approve", CustomID: "whitelist:approve:" + id},
+				{Type: ComponentTypeButton, Style: ButtonStyleDanger, Label: "Deny", CustomID: "whitelist:deny:" + id},
+			},
+		},
+	}
+	if err := SendComponentEmbed(state, discord.ChannelID(snowflake), embed, components); err != nil {
+		return err
+	}
+
+	embed = CreateEmbed(SuccessColor, "Whitelist Request", fmt.Sprintf(":white_check_mark: Your request to whitelist **%s** was sent to the moderators.", name), "")
+	return SendCommandEmbed(state, cmd, embed)
+}
+
+// handleWhitelistButton handles the approve and deny buttons of a whitelist
+// request. Only users that can use the whitelist add command can use them.
+// The args are the action, player name, and ID of the user that made the
+// request.
+func (p *Parser) handleWhitelistButton(state *state.State, e *InteractionCreateEvent, args []string) error {
+	if len(args) != 3 || !playerNameRegex.MatchString(args[1]) {
+		return fmt.Errorf("invalid whitelist button: %s", strings.Join(args, ":"))
+	}
+	action, name := args[0], args[1]
+
+	// Check that the moderator may change the whitelist
+	cmd := DiscordCommand{
+		Sender:    interactionSender(e),
+		Command:   "whitelist add",
+		Prefix:    p.config().Discord.Commands.Prefix,
+		GuildID:   e.GuildID,
+		ChannelID: e.ChannelID,
+	}
+	handler := p.findSubcommand("whitelist", "add")
+	if handler == nil {
+		return fmt.Errorf("whitelist add command not found")
+	}
+	if ok, reason := p.canUseCommand(state, *handler, cmd); !ok {
+		p.auditDenied(state, cmd, reason)
+		embed := CreateEmbed(ErrorColor, "Insufficient Permissions", ":no_entry: You don't have permission to answer whitelist requests!", "")
+		return respondInteractionEmbed(state, e, embed)
+	}
+
+	var result discord.Embed
+	var notice string
+	switch action {
+	case "approve":
+		resp, err := p.sendWhitelistCommand("add", name)
+		if err != nil {
+			return err
+		}
+		result = CreateEmbed(SuccessColor, "Whitelist Request Approved", fmt.Sprintf("**%s** was approved by %s.\n%s", name, cmd.Sender.Mention(), resp), "")
+		notice = fmt.Sprintf(":white_check_mark: Your request to whitelist **%s** was approved!", name)
+	case "deny":
+		result = CreateEmbed(ErrorColor, "Whitelist Request Denied", fmt.Sprintf("**%s** was denied by %s.", name, cmd.Sender.Mention()), "")
+		notice = fmt.Sprintf(":x: Your request to whitelist **%s** was denied.", name)
+	default:
+		return fmt.Errorf("unknown whitelist button action: %s", action)
+	}
+
+	p.log.Infof("User '%s' answered the whitelist request for '%s': %s\n", cmd.Sender.Username, name, action)
+
+	// Replace the request with the result, and remove the buttons
+	err := respondInteraction(state, e, interactionResponse{
+		Type: responseTypeUpdateMessage,
+		Data: &interactionResponseData{Embeds: []discord.Embed{result}, Components: &[]Component{}},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Let the user that made the request know
+	if snowflake, err := discord.ParseSnowflake(args[2]); err == nil {
+		if dm, err := state.Client.CreatePrivateChannel(discord.UserID(snowflake)); err == nil {
+			if _, err := state.Client.SendEmbed(dm.ID, CreateEmbed(InfoColor, "Whitelist Request", notice, "")); err != nil {
+				p.log.Warnf("Unable to tell the user about their whitelist request: %s\n", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// readWhitelist reads the players in a whitelist.json file.
+func readWhitelist(path string) ([]WhitelistEntry, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]WhitelistEntry, 0)
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("invalid whitelist file: %s", err)
+	}
+
+	return entries, nil
+}
+
+// createWhitelistEmbed creates an embed showing a page of whitelisted
+// players.
+func createWhitelistEmbed(entries []WhitelistEntry, page int, prefix string) discord.Embed {
+	pages := (len(entries) + whitelistPageSize - 1) / whitelistPageSize
+	if pages == 0 {
+		pages = 1
+	}
+	if page < 1 {
+		page = 1
+	}
+	if page > pages {
+		page = pages
+	}
+
+	b := strings.Builder{}
+	if len(entries) == 0 {
+		b.WriteString("There are no whitelisted players.")
+	}
+
+	start := (page - 1) * whitelistPageSize
+	end := start + whitelistPageSize
+	if end > len(entries) {
+		end = len(entries)
+	}
+	for _, entry := range entries[start:end] {
+		b.WriteString(fmt.Sprintf("**%s** — `%s`\n", entry.Name, entry.UUID))
+	}
+
+	footer := fmt.Sprintf("%d players | Page %d/%d", len(entries), page, pages)
+	if page < pages {
+		footer += fmt.Sprintf(" | Use %swhitelist list %d for the next page", prefix, page+1)
+	}
+
+	return CreateEmbed(InfoColor, "Whitelisted Players", b.String(), footer)
+}
+
+// sendInvalidName tells the user that a player name isn't valid.
+func sendInvalidName(state *state.State, cmd DiscordCommand, name string) error {
+	embed := CreateEmbed(WarnColor, "Invalid Name", fmt.Sprintf(":warning: `%s` is not a valid Minecraft name.", name), "")
+	return SendCommandEmbed(state, cmd, embed)
+}
@@ -0,0 +1,77 @@
+package command
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestReadWhitelist(t *testing.T) {
+	// given
+	dir, err := ioutil.TempDir("", "dolphin")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "whitelist.json")
+	data := `[{"uuid": "069a79f4-44e9-4726-a5be-fca90e38aaf5", "name": "Notch"}, {"uuid": "853c80ef-3c37-49fd-aa49-938b674adae6", "name": "jeb_"}]`
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("Failed to write whitelist: %s", err)
+	}
+	expected := []WhitelistEntry{
+		{UUID: "069a79f4-44e9-4726-a5be-fca90e38aaf5", Name: "Notch"},
+		{UUID: "853c80ef-3c37-49fd-aa49-938b674adae6", Name: "jeb_"},
+	}
+
+	// when
+	actual, err := readWhitelist(path)
+
+	// then
+	if err != nil {
+		t.Fatalf("Failed to read whitelist: %s", err)
+	}
+	if !cmp.Equal(actual, expected) {
+		t.Errorf("Read whitelist is incorrect: Diff: %s", cmp.Diff(actual, expected))
+	}
+}
+
+func TestCreateWhitelistEmbedPages(t *testing.T) {
+	// given
+	entries := make([]WhitelistEntry, 0)
+	for i := 0; i < whitelistPageSize+5; i++ {
+		entries = append(entries, WhitelistEntry{UUID: "uuid", Name: "Player"})
+	}
+
+	// when
+	first := createWhitelistEmbed(entries, 1, "!")
+	last := createWhitelistEmbed(entries, 9, "!")
+
+	// then
+	if !strings.Contains(first.Footer.Text, "Page 1/2") || !strings.Contains(first.Footer.Text, "!whitelist list 2") {
+		t.Errorf("First page footer is incorrect: %s", first.Footer.Text)
+	}
+	if strings.Count(first.Description, "\n") != whitelistPageSize {
+		t.Errorf("First page should show %d players: %s", whitelistPageSize, first.Description)
+	}
+	if !strings.Contains(last.Footer.Text, "Page 2/2") || strings.Count(last.Description, "\n") != 5 {
+		t.Errorf("Pages past the end should show the last page, got: %s", last.Footer.Text)
+	}
+}
+
+func TestPlayerNameRegex(t *testing.T) {
+	for _, name := range []string{"Steve", "jeb_", "abc"} {
+		if !playerNameRegex.MatchString(name) {
+			t.Errorf("'%s' should be a valid player name", name)
+		}
+	}
+	for _, name := range []string{"ab", "Steve op", "Steve\nop", "ThisNameIsTooLong"} {
+		if playerNameRegex.MatchString(name) {
+			t.Errorf("'%s' should not be a valid player name", name)
+		}
+	}
+}
@@ -1,33 +1,56 @@
 package config
 
 import (
-	"bufio"
 	"bytes"
 	"errors"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
 
 	log "github.com/DataDrake/waterlog"
 	"github.com/pelletier/go-toml"
 )
 
-var configPath string
+// File is a config file, and the settings that were overridden when it was
+// last loaded. Each bridge has its own, so several bridges can use different
+// config files in the same process.
+type File struct {
+	path string
+	// saveMu makes sure only one change is made to the file at a time
+	saveMu sync.Mutex
 
-// CreateConfigFile attempts to create the given config dir+file
-// if it doesn't yet exist.
-func CreateConfigFile(path string) error {
-	var dir string
-	var file string
+	mu sync.Mutex
+	// overrides are the settings that were overridden when the config was
+	// last loaded
+	overrides []Override
+	// fileConfig is the config as it is in the file, without any overrides
+	fileConfig RootConfig
+	// loadedConfig is the config with the overrides applied
+	loadedConfig RootConfig
+	// serverProps are the server.properties settings read when the config
+	// was last loaded, or nil if no server directory is set
+	serverProps *ServerProperties
+}
 
-	// See if we're given a specific file to use
-	if filepath.Ext(path) != "" {
-		dir, file = filepath.Split(path)
-	} else {
-		dir = filepath.Clean(path)
-		file = "dolphin.conf"
+// NewFile gets the config file at a path, creating it if it doesn't yet
+// exist. The path can be the file or its directory.
+func NewFile(path string) (*File, error) {
+	dir, name := splitConfigPath(path)
+	path = filepath.Join(dir, name)
+	if err := CreateConfigFile(path); err != nil {
+		return nil, err
 	}
+	return &File{path: path}, nil
+}
 
-	configPath = filepath.Join(dir, file)
+// CreateConfigFile attempts to create the given config dir+file
+// if it doesn't yet exist.
+func CreateConfigFile(path string) error {
+	dir, file := splitConfigPath(path)
+	configPath := filepath.Join(dir, file)
 
 	// Check if the path exists
 	if _, err := os.Stat(configPath); err != nil {
@@ -50,63 +73,221 @@ func CreateConfigFile(path string) error {
 	return nil
 }
 
-// Load loads the configuration from disk.
-func Load() (RootConfig, error) {
-	var conf = RootConfig{}
-	log.Infof("Loading configuration from '%s'\n", configPath)
+// splitConfigPath gets the directory and file name of a config file from a
+// path to either the file or its directory.
+func splitConfigPath(path string) (string, string) {
+	// See if we're given a specific file to use
+	if filepath.Ext(path) != "" {
+		return filepath.Split(path)
+	}
+	return filepath.Clean(path), "dolphin.conf"
+}
+
+// CheckFile loads a config file without changing the loaded config, and
+// checks it for problems. The path can be the file or its directory; if it
+// is empty, the default location is used. The path of the file that was
+// checked is returned.
+func CheckFile(path string) (string, error) {
+	if path == "" {
+		var err error
+		if path, err = GetDefaultConfDir(); err != nil {
+			return path, err
+		}
+	}
+	dir, file := splitConfigPath(path)
+	path = filepath.Join(dir, file)
 
-	// Open the config file
-	file, err := os.Open(filepath.Clean(configPath))
+	data, err := ioutil.ReadFile(filepath.Clean(path))
 	if err != nil {
-		return conf, err
+		return path, err
 	}
-	defer file.Close()
 
-	// Unmarshal the file into our struct
-	decoder := toml.NewDecoder(file)
-	if err := decoder.Decode(&conf); err != nil {
+	// Check the file as it would be loaded, without changing it
+	conf, _, tree, _, err := parse(data)
+	if err != nil {
+		return path, err
+	}
+	applied, err := applyOverrides(&conf, os.LookupEnv)
+	if err != nil {
+		return path, err
+	}
+	if _, _, err := applyServerProperties(&conf, isSet(tree, applied)); err != nil {
+		return path, err
+	}
+
+	return path, Validate(conf)
+}
+
+// Load loads the configuration from disk. Older config files are migrated
+// to the current version, and settings that are missing from the file are
+// added with their default values. If anything changed, the file is saved,
+// keeping its comments and ordering. Finally, settings are overridden by
+// any environment variables that are set for them, and settings that are
+// left out of the file are read from the server's server.properties.
+func (f *File) Load() (RootConfig, error) {
+	log.Infof("Loading configuration from '%s'\n", f.path)
+
+	f.saveMu.Lock()
+	defer f.saveMu.Unlock()
+
+	// Read the config file
+	data, err := ioutil.ReadFile(filepath.Clean(f.path))
+	if err != nil {
+		return RootConfig{}, err
+	}
+
+	conf, doc, tree, ran, err := parse(data)
+	if err != nil {
 		return conf, err
 	}
+	for _, desc := range ran {
+		log.Infof("Migrated config: %s\n", desc)
+	}
 
-	return conf, nil
+	// Save the migrations and new settings
+	if !bytes.Equal(doc.Bytes(), data) {
+		if err := writeFile(f.path, doc.Bytes()); err != nil {
+			return conf, err
+		}
+	}
+
+	// Settings from the environment are applied last, so they are never saved
+	return conf, f.setOverrides(&conf, tree)
 }
 
-// SaveConfig saves the current configuration to disk.
-func SaveConfig(data interface{}) error {
-	var (
-		buf     bytes.Buffer
-		saveErr error
-	)
+// parse parses the contents of a config file. The file is migrated and has
+// missing settings added, and the changed document and its tree are
+// returned along with the descriptions of the migrations that were run.
+// Settings that are read from server.properties aren't added if the file
+// has a server directory.
+func parse(data []byte) (RootConfig, *Document, *toml.Tree, []string, error) {
+	var conf RootConfig
+
+	// Make sure the file is valid before changing it
+	if _, err := toml.LoadBytes(data); err != nil {
+		return conf, nil, nil, nil, err
+	}
+
+	doc := ParseDocument(data)
+	ran, err := migrate(doc, migrations)
+	if err != nil {
+		return conf, nil, nil, nil, err
+	}
 
-	// Create our buffer and encoder
-	file, err := os.Create(configPath)
+	tree, err := toml.LoadBytes(doc.Bytes())
 	if err != nil {
+		return conf, nil, nil, nil, err
+	}
+	skip := make(map[string]bool)
+	if dir, _ := tree.Get("Minecraft.ServerDir").(string); dir != "" {
+		for _, key := range serverPropertyKeys {
+			skip[key] = true
+		}
+	}
+	defaults := MergeDefaults(RootConfig{})
+	if err := addDefaults(doc, tree, reflect.ValueOf(defaults), "", skip); err != nil {
+		return conf, nil, nil, nil, err
+	}
+
+	// Unmarshal the file into our struct
+	if tree, err = toml.LoadBytes(doc.Bytes()); err != nil {
+		return conf, nil, nil, nil, err
+	}
+	if err := tree.Unmarshal(&conf); err != nil {
+		return conf, nil, nil, nil, err
+	}
+
+	// Fill in anything that couldn't be added to the file
+	mergeFieldDefaults(reflect.ValueOf(&conf).Elem(), reflect.ValueOf(defaults), tree)
+
+	return conf, doc, tree, ran, nil
+}
+
+// Save saves a configuration to the file. Only the settings
+// that changed are written, so the comments and ordering of the file are
+// kept, and the old file is kept as a backup. Settings that are overridden
+// by environment variables are saved with their value from the config file
+// instead. If a server directory is set, the settings read from its
+// server.properties are left out of the file when they are empty or 0.
+func (f *File) Save(data interface{}) error {
+	f.saveMu.Lock()
+	defer f.saveMu.Unlock()
+
+	var conf RootConfig
+	switch c := data.(type) {
+	case RootConfig:
+		conf = f.withoutOverrides(c)
+	case *RootConfig:
+		conf = f.withoutOverrides(*c)
+	default:
+		// Encode anything else as a whole
+		encoded, err := toml.Marshal(data)
+		if err != nil {
+			return err
+		}
+		return writeFile(f.path, encoded)
+	}
+
+	// Read the file as it is now
+	current, err := ioutil.ReadFile(filepath.Clean(f.path))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
 		return err
 	}
-	defer file.Close()
 
-	writer := bufio.NewWriter(file)
-	encoder := toml.NewEncoder(writer).Order(toml.OrderPreserve)
+	doc := ParseDocument(current)
+	if err := updateDocument(doc, conf); err != nil {
+		if _, parseErr := toml.LoadBytes(current); parseErr == nil {
+			return err
+		}
 
-	// Encode the struct as TOML
-	if saveErr = encoder.Encode(data); saveErr != nil {
-		return saveErr
+		// Start over if the file is broken; the old one is kept as a backup
+		log.Warnf("Config file is invalid, replacing it: %s\n", err)
+		doc = ParseDocument([]byte{})
+		if err := updateDocument(doc, conf); err != nil {
+			return err
+		}
 	}
 
-	// Write to the file
-	if _, saveErr = writer.Write(buf.Bytes()); saveErr != nil {
-		return saveErr
+	if conf.Minecraft.ServerDir != "" {
+		for _, key := range serverPropertyKeys {
+			if fieldByKey(reflect.ValueOf(conf), key).IsZero() {
+				dot := strings.LastIndex(key, ".")
+				doc.Delete(key[:dot], key[dot+1:])
+			}
+		}
 	}
 
-	return writer.Flush()
+	return writeFile(f.path, doc.Bytes())
+}
+
+// Path gets the path to the config file.
+func (f *File) Path() string {
+	return f.path
+}
+
+// DataPath gets the path to a data file that is kept next to the config
+// file, such as the database.
+func (f *File) DataPath(name string) string {
+	return filepath.Join(filepath.Dir(f.path), name)
 }
 
-// MergeDefaults sets sane config defaults and returns the resulting config.
+// DatabasePath gets the path to the database file. Unless another path is
+// configured, it is kept next to the config file.
+func (f *File) DatabasePath(conf RootConfig) string {
+	if conf.Storage.Path != "" {
+		return conf.Storage.Path
+	}
+	return f.DataPath("dolphin.db")
+}
+
+// MergeDefaults sets sane config defaults for every section that is empty,
+// and returns the resulting config. Load sets defaults for each missing
+// setting instead.
 func MergeDefaults(config RootConfig) RootConfig {
 	// Check if the given config is an empty struct
-	if config == (RootConfig{}) {
+	if reflect.ValueOf(config).IsZero() {
 		return RootConfig{
-			DiscordConfig{
+			Discord: DiscordConfig{
 				BotToken:       "",
 				ChannelID:      "",
 				AllowMentions:  true,
@@ -116,13 +297,25 @@ func MergeDefaults(config RootConfig) RootConfig {
 					ShowDeaths:       true,
 					ShowJoinsLeaves:  true,
 				},
+				Mentions: MentionConfig{
+					Policy:       MentionPolicyUsers,
+					AllowedRoles: &[]string{},
+				},
+				Embeds:     defaultEmbedConfig(),
+				Status:     defaultStatusConfig(),
+				Console:    defaultConsoleConfig(),
+				Whitelist:  defaultWhitelistConfig(),
+				Moderation: defaultModerationConfig(),
+				Linking:    defaultLinkingConfig(),
+				Digest:     defaultDigestConfig(),
+				Commands:   defaultCommandConfig(),
 				Webhook: WebhookConfig{
 					Enabled: false,
 					URL:     "",
 				},
 			},
 
-			MinecraftConfig{
+			Minecraft: MinecraftConfig{
 				RconIP:              "localhost",
 				RconPort:            25575,
 				RconPassword:        "",
@@ -131,6 +324,10 @@ func MergeDefaults(config RootConfig) RootConfig {
 				UseLogFile:          true,
 				LogFilePath:         "/home/minecraft/server/logs/latest.log",
 			},
+
+			IRC:     defaultIRCConfig(),
+			Routes:  defaultRouteConfig(),
+			Storage: StorageConfig{},
 		}
 	}
 
@@ -145,6 +342,18 @@ func MergeDefaults(config RootConfig) RootConfig {
 				ShowDeaths:       true,
 				ShowJoinsLeaves:  true,
 			},
+			Mentions: MentionConfig{
+				Policy:       MentionPolicyUsers,
+				AllowedRoles: &[]string{},
+			},
+			Embeds:     defaultEmbedConfig(),
+			Status:     defaultStatusConfig(),
+			Console:    defaultConsoleConfig(),
+			Whitelist:  defaultWhitelistConfig(),
+			Moderation: defaultModerationConfig(),
+			Linking:    defaultLinkingConfig(),
+			Digest:     defaultDigestConfig(),
+			Commands:   defaultCommandConfig(),
 			Webhook: WebhookConfig{
 				Enabled: false,
 				URL:     "",
@@ -160,6 +369,52 @@ func MergeDefaults(config RootConfig) RootConfig {
 		}
 	}
 
+	if config.Discord.Mentions == (MentionConfig{}) {
+		config.Discord.Mentions = MentionConfig{
+			Policy:       MentionPolicyUsers,
+			AllowedRoles: &[]string{},
+		}
+	}
+
+	if config.Discord.Embeds == (EmbedConfig{}) {
+		config.Discord.Embeds = defaultEmbedConfig()
+	}
+
+	if config.Discord.Status == (StatusConfig{}) {
+		config.Discord.Status = defaultStatusConfig()
+	}
+
+	if config.Discord.Commands == (CommandConfig{}) {
+		config.Discord.Commands = defaultCommandConfig()
+	}
+
+	if config.Discord.Commands.Rcon == (RconCommandConfig{}) {
+		config.Discord.Commands.Rcon = RconCommandConfig{
+			Rules:    &[]RconRuleConfig{},
+			AuditLog: "",
+		}
+	}
+
+	if config.Discord.Console == (ConsoleConfig{}) {
+		config.Discord.Console = defaultConsoleConfig()
+	}
+
+	if config.Discord.Whitelist == (WhitelistConfig{}) {
+		config.Discord.Whitelist = defaultWhitelistConfig()
+	}
+
+	if config.Discord.Moderation == (ModerationConfig{}) {
+		config.Discord.Moderation = defaultModerationConfig()
+	}
+
+	if config.Discord.Linking == (LinkingConfig{}) {
+		config.Discord.Linking = defaultLinkingConfig()
+	}
+
+	if config.Discord.Digest == (DigestConfig{}) {
+		config.Discord.Digest = defaultDigestConfig()
+	}
+
 	if config.Discord.Webhook == (WebhookConfig{}) {
 		config.Discord.Webhook = WebhookConfig{
 			Enabled: false,
@@ -179,5 +434,154 @@ func MergeDefaults(config RootConfig) RootConfig {
 		}
 	}
 
+	if config.IRC == (IRCConfig{}) {
+		config.IRC = defaultIRCConfig()
+	}
+
+	if config.Routes == nil {
+		config.Routes = defaultRouteConfig()
+	}
+
 	return config
 }
+
+// FillDefaults sets the settings of a config that can't have been set on
+// purpose, such as lists that are nil, to their default values. Unlike
+// MergeDefaults, settings that are false or zero are kept.
+func FillDefaults(conf RootConfig) RootConfig {
+	defaults := reflect.ValueOf(MergeDefaults(RootConfig{}))
+	walkFields(reflect.ValueOf(&conf).Elem(), "", func(key string, _ reflect.StructField, field reflect.Value) {
+		if (field.Kind() == reflect.Ptr || field.Kind() == reflect.Map) && field.IsNil() {
+			field.Set(fieldByKey(defaults, key))
+		}
+	})
+	return conf
+}
+
+// defaultEmbedConfig returns the default embed styles. Embeds are disabled
+// for every type of message by default.
+func defaultEmbedConfig() EmbedConfig {
+	return EmbedConfig{
+		Advancements: EmbedStyleConfig{
+			Enabled:   false,
+			Color:     "#ffc107",
+			Thumbnail: true,
+			Timestamp: true,
+		},
+		Deaths: EmbedStyleConfig{
+			Enabled:   false,
+			Color:     "#cc0000",
+			Thumbnail: true,
+			Timestamp: true,
+		},
+		JoinsLeaves: EmbedStyleConfig{
+			Enabled:   false,
+			Color:     "#0099ff",
+			Thumbnail: true,
+			Timestamp: true,
+		},
+		ServerStatus: EmbedStyleConfig{
+			Enabled:   false,
+			Color:     "#4caf50",
+			Thumbnail: false,
+			Timestamp: true,
+		},
+	}
+}
+
+// defaultStatusConfig returns the default server status settings.
+func defaultStatusConfig() StatusConfig {
+	return StatusConfig{
+		ServerName:     "Minecraft",
+		UpdatePresence: true,
+		UpdateTopic:    false,
+		TopicTemplate:  "%online%/%max% players online | Uptime: %uptime% | TPS: %tps%",
+		PollInterval:   60,
+	}
+}
+
+// defaultCommandConfig returns the default bot command settings.
+func defaultCommandConfig() CommandConfig {
+	return CommandConfig{
+		Prefix:        "!",
+		EnablePrefix:  true,
+		SlashCommands: true,
+		Permissions:   &[]PermissionConfig{},
+		Rcon: RconCommandConfig{
+			Rules:    &[]RconRuleConfig{},
+			AuditLog: "",
+		},
+	}
+}
+
+// defaultConsoleConfig returns the default console channel settings.
+func defaultConsoleConfig() ConsoleConfig {
+	return ConsoleConfig{
+		Enabled:       false,
+		ChannelID:     "",
+		Levels:        &[]string{},
+		Loggers:       &[]string{},
+		Include:       "",
+		Exclude:       "",
+		BatchInterval: 2,
+		RunCommands:   true,
+	}
+}
+
+// defaultWhitelistConfig returns the default whitelist command settings.
+func defaultWhitelistConfig() WhitelistConfig {
+	return WhitelistConfig{
+		FilePath:         "/home/minecraft/server/whitelist.json",
+		AllowRequests:    false,
+		RequestChannelID: "",
+	}
+}
+
+// defaultModerationConfig returns the default moderation command settings.
+func defaultModerationConfig() ModerationConfig {
+	return ModerationConfig{
+		LogChannelID: "",
+		BanListPath:  "/home/minecraft/server/banned-players.json",
+	}
+}
+
+// defaultLinkingConfig returns the default account linking settings.
+func defaultLinkingConfig() LinkingConfig {
+	return LinkingConfig{
+		Enabled:        true,
+		UseLinkedNames: true,
+		RoleSync:       &[]RoleSyncConfig{},
+	}
+}
+
+// defaultDigestConfig returns the default weekly digest settings.
+func defaultDigestConfig() DigestConfig {
+	return DigestConfig{
+		Enabled: false,
+		Day:     "sunday",
+		Hour:    18,
+	}
+}
+
+// defaultIRCConfig returns the default IRC settings. IRC is disabled by
+// default.
+func defaultIRCConfig() IRCConfig {
+	return IRCConfig{
+		Enabled:  false,
+		Server:   "irc.libera.chat:6697",
+		TLS:      true,
+		Nick:     "dolphin",
+		Password: "",
+		Channel:  "",
+	}
+}
+
+// defaultRouteConfig returns the default routes. Minecraft and Discord are
+// bridged both ways, and IRC is bridged with both of them.
+func defaultRouteConfig() RouteConfig {
+	return RouteConfig{
+		"minecraft": {"discord", "irc"},
+		"discord":   {"minecraft", "irc"},
+		"irc":       {"minecraft", "discord"},
+	}
+}
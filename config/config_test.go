@@ -50,45 +50,30 @@ func TestCreateConfigFileGivenAll(t *testing.T) {
 
 func TestSaveAndLoadConfig(t *testing.T) {
 	// given
-	data := RootConfig{
-		DiscordConfig{
-			BotToken:       "bot-token",
-			ChannelID:      "2576235623",
-			AllowMentions:  true,
-			UseMemberNicks: false,
-			Webhook: WebhookConfig{
-				Enabled: false,
-				URL:     "",
-			},
-		},
-
-		MinecraftConfig{
-			RconIP:              "localhost",
-			RconPort:            25575,
-			RconPassword:        "igb348grt348fg",
-			TellrawTemplate:     `[{"color": "white", "text": "<%username%> %message%"}]`,
-			CustomDeathKeywords: &[]string{},
-			UseLogFile:          true,
-			LogFilePath:         "/home/minecraft/server/logs/latest.log",
-		},
-	}
+	data := MergeDefaults(RootConfig{})
+	data.ConfigVersion = CurrentVersion
+	data.Discord.BotToken = "bot-token"
+	data.Discord.ChannelID = "2576235623"
+	data.Discord.AllowMentions = false
+	data.Minecraft.RconPassword = "igb348grt348fg"
 
 	// Create a temp config file
 	dir := filepath.Join(os.TempDir(), "dolphin-testing")
 	path := filepath.Join(dir, "dolphin_save_load_test.conf")
-	if err := CreateConfigFile(path); err != nil {
+	file, err := NewFile(path)
+	if err != nil {
 		t.Errorf("Error creating testing directory: %s\n", err)
 	}
 	defer os.RemoveAll(dir)
 
 	// when
-	err := SaveConfig(data)
+	err = file.Save(data)
 	if err != nil {
 		t.Errorf("Failed to save config: %s\n", err)
 	}
 
 	// then
-	loaded, err := Load()
+	loaded, err := file.Load()
 	if err != nil {
 		t.Errorf("Failed to load config: %s\n", err)
 	}
@@ -103,7 +88,7 @@ func TestSaveAndLoadConfig(t *testing.T) {
 func TestMergeEmptyConfig(t *testing.T) {
 	// given
 	expected := RootConfig{
-		DiscordConfig{
+		Discord: DiscordConfig{
 			BotToken:       "",
 			ChannelID:      "",
 			AllowMentions:  true,
@@ -113,13 +98,33 @@ func TestMergeEmptyConfig(t *testing.T) {
 				ShowDeaths:       true,
 				ShowJoinsLeaves:  true,
 			},
+			Mentions: MentionConfig{
+				Policy:       MentionPolicyUsers,
+				AllowedRoles: &[]string{},
+			},
+			Embeds:     defaultEmbedConfig(),
+			Status:     defaultStatusConfig(),
+			Console:    defaultConsoleConfig(),
+			Whitelist:  defaultWhitelistConfig(),
+			Moderation: defaultModerationConfig(),
+			Linking:    defaultLinkingConfig(),
+			Digest:     defaultDigestConfig(),
+			Commands: CommandConfig{
+				Prefix:        "!",
+				EnablePrefix:  true,
+				SlashCommands: true,
+				Permissions:   &[]PermissionConfig{},
+				Rcon: RconCommandConfig{
+					Rules: &[]RconRuleConfig{},
+				},
+			},
 			Webhook: WebhookConfig{
 				Enabled: false,
 				URL:     "",
 			},
 		},
 
-		MinecraftConfig{
+		Minecraft: MinecraftConfig{
 			RconIP:              "localhost",
 			RconPort:            25575,
 			RconPassword:        "",
@@ -128,6 +133,10 @@ func TestMergeEmptyConfig(t *testing.T) {
 			UseLogFile:          true,
 			LogFilePath:         "/home/minecraft/server/logs/latest.log",
 		},
+
+		IRC:     defaultIRCConfig(),
+		Routes:  defaultRouteConfig(),
+		Storage: StorageConfig{},
 	}
 
 	emptyConfig := RootConfig{}
@@ -145,7 +154,7 @@ func TestMergeEmptyConfig(t *testing.T) {
 func TestMigrateNoMessageConfig(t *testing.T) {
 	// given
 	expected := RootConfig{
-		DiscordConfig{
+		Discord: DiscordConfig{
 			BotToken:       "",
 			ChannelID:      "",
 			AllowMentions:  true,
@@ -155,13 +164,33 @@ func TestMigrateNoMessageConfig(t *testing.T) {
 				ShowDeaths:       true,
 				ShowJoinsLeaves:  true,
 			},
+			Mentions: MentionConfig{
+				Policy:       MentionPolicyUsers,
+				AllowedRoles: &[]string{},
+			},
+			Embeds:     defaultEmbedConfig(),
+			Status:     defaultStatusConfig(),
+			Console:    defaultConsoleConfig(),
+			Whitelist:  defaultWhitelistConfig(),
+			Moderation: defaultModerationConfig(),
+			Linking:    defaultLinkingConfig(),
+			Digest:     defaultDigestConfig(),
+			Commands: CommandConfig{
+				Prefix:        "!",
+				EnablePrefix:  true,
+				SlashCommands: true,
+				Permissions:   &[]PermissionConfig{},
+				Rcon: RconCommandConfig{
+					Rules: &[]RconRuleConfig{},
+				},
+			},
 			Webhook: WebhookConfig{
 				Enabled: false,
 				URL:     "",
 			},
 		},
 
-		MinecraftConfig{
+		Minecraft: MinecraftConfig{
 			RconIP:              "localhost",
 			RconPort:            25575,
 			RconPassword:        "",
@@ -170,10 +199,14 @@ func TestMigrateNoMessageConfig(t *testing.T) {
 			UseLogFile:          true,
 			LogFilePath:         "/home/minecraft/server/logs/latest.log",
 		},
+
+		IRC:     defaultIRCConfig(),
+		Routes:  defaultRouteConfig(),
+		Storage: StorageConfig{},
 	}
 
 	givenConfig := RootConfig{
-		DiscordConfig{
+		Discord: DiscordConfig{
 			BotToken:       "",
 			ChannelID:      "",
 			AllowMentions:  true,
@@ -185,7 +218,7 @@ func TestMigrateNoMessageConfig(t *testing.T) {
 			},
 		},
 
-		MinecraftConfig{
+		Minecraft: MinecraftConfig{
 			RconIP:              "localhost",
 			RconPort:            25575,
 			RconPassword:        "",
@@ -194,6 +227,8 @@ func TestMigrateNoMessageConfig(t *testing.T) {
 			UseLogFile:          true,
 			LogFilePath:         "/home/minecraft/server/logs/latest.log",
 		},
+
+		Storage: StorageConfig{},
 	}
 
 	// when
@@ -209,7 +244,7 @@ func TestMigrateNoMessageConfig(t *testing.T) {
 func TestMigrateNoWebhookConfig(t *testing.T) {
 	// given
 	expected := RootConfig{
-		DiscordConfig{
+		Discord: DiscordConfig{
 			BotToken:       "",
 			ChannelID:      "",
 			AllowMentions:  true,
@@ -219,13 +254,33 @@ func TestMigrateNoWebhookConfig(t *testing.T) {
 				ShowDeaths:       true,
 				ShowJoinsLeaves:  true,
 			},
+			Mentions: MentionConfig{
+				Policy:       MentionPolicyUsers,
+				AllowedRoles: &[]string{},
+			},
+			Embeds:     defaultEmbedConfig(),
+			Status:     defaultStatusConfig(),
+			Console:    defaultConsoleConfig(),
+			Whitelist:  defaultWhitelistConfig(),
+			Moderation: defaultModerationConfig(),
+			Linking:    defaultLinkingConfig(),
+			Digest:     defaultDigestConfig(),
+			Commands: CommandConfig{
+				Prefix:        "!",
+				EnablePrefix:  true,
+				SlashCommands: true,
+				Permissions:   &[]PermissionConfig{},
+				Rcon: RconCommandConfig{
+					Rules: &[]RconRuleConfig{},
+				},
+			},
 			Webhook: WebhookConfig{
 				Enabled: false,
 				URL:     "",
 			},
 		},
 
-		MinecraftConfig{
+		Minecraft: MinecraftConfig{
 			RconIP:              "localhost",
 			RconPort:            25575,
 			RconPassword:        "",
@@ -234,10 +289,14 @@ func TestMigrateNoWebhookConfig(t *testing.T) {
 			UseLogFile:          true,
 			LogFilePath:         "/home/minecraft/server/logs/latest.log",
 		},
+
+		IRC:     defaultIRCConfig(),
+		Routes:  defaultRouteConfig(),
+		Storage: StorageConfig{},
 	}
 
 	givenConfig := RootConfig{
-		DiscordConfig{
+		Discord: DiscordConfig{
 			BotToken:       "",
 			ChannelID:      "",
 			AllowMentions:  true,
@@ -247,10 +306,30 @@ func TestMigrateNoWebhookConfig(t *testing.T) {
 				ShowDeaths:       true,
 				ShowJoinsLeaves:  true,
 			},
+			Mentions: MentionConfig{
+				Policy:       MentionPolicyUsers,
+				AllowedRoles: &[]string{},
+			},
+			Embeds:     defaultEmbedConfig(),
+			Status:     defaultStatusConfig(),
+			Console:    defaultConsoleConfig(),
+			Whitelist:  defaultWhitelistConfig(),
+			Moderation: defaultModerationConfig(),
+			Linking:    defaultLinkingConfig(),
+			Digest:     defaultDigestConfig(),
+			Commands: CommandConfig{
+				Prefix:        "!",
+				EnablePrefix:  true,
+				SlashCommands: true,
+				Permissions:   &[]PermissionConfig{},
+				Rcon: RconCommandConfig{
+					Rules: &[]RconRuleConfig{},
+				},
+			},
 			Webhook: WebhookConfig{},
 		},
 
-		MinecraftConfig{
+		Minecraft: MinecraftConfig{
 			RconIP:              "localhost",
 			RconPort:            25575,
 			RconPassword:        "",
@@ -259,6 +338,8 @@ func TestMigrateNoWebhookConfig(t *testing.T) {
 			UseLogFile:          true,
 			LogFilePath:         "/home/minecraft/server/logs/latest.log",
 		},
+
+		Storage: StorageConfig{},
 	}
 
 	// when
@@ -274,7 +355,7 @@ func TestMigrateNoWebhookConfig(t *testing.T) {
 func TestMigrateNoDiscordConfig(t *testing.T) {
 	// given
 	expected := RootConfig{
-		DiscordConfig{
+		Discord: DiscordConfig{
 			BotToken:       "",
 			ChannelID:      "",
 			AllowMentions:  true,
@@ -284,13 +365,33 @@ func TestMigrateNoDiscordConfig(t *testing.T) {
 				ShowDeaths:       true,
 				ShowJoinsLeaves:  true,
 			},
+			Mentions: MentionConfig{
+				Policy:       MentionPolicyUsers,
+				AllowedRoles: &[]string{},
+			},
+			Embeds:     defaultEmbedConfig(),
+			Status:     defaultStatusConfig(),
+			Console:    defaultConsoleConfig(),
+			Whitelist:  defaultWhitelistConfig(),
+			Moderation: defaultModerationConfig(),
+			Linking:    defaultLinkingConfig(),
+			Digest:     defaultDigestConfig(),
+			Commands: CommandConfig{
+				Prefix:        "!",
+				EnablePrefix:  true,
+				SlashCommands: true,
+				Permissions:   &[]PermissionConfig{},
+				Rcon: RconCommandConfig{
+					Rules: &[]RconRuleConfig{},
+				},
+			},
 			Webhook: WebhookConfig{
 				Enabled: false,
 				URL:     "",
 			},
 		},
 
-		MinecraftConfig{
+		Minecraft: MinecraftConfig{
 			RconIP:              "localhost",
 			RconPort:            25575,
 			RconPassword:        "",
@@ -299,12 +400,16 @@ func TestMigrateNoDiscordConfig(t *testing.T) {
 			UseLogFile:          true,
 			LogFilePath:         "/home/minecraft/server/logs/latest.log",
 		},
+
+		IRC:     defaultIRCConfig(),
+		Routes:  defaultRouteConfig(),
+		Storage: StorageConfig{},
 	}
 
 	givenConfig := RootConfig{
-		DiscordConfig{},
+		Discord: DiscordConfig{},
 
-		MinecraftConfig{
+		Minecraft: MinecraftConfig{
 			RconIP:              "localhost",
 			RconPort:            25575,
 			RconPassword:        "",
@@ -313,6 +418,8 @@ func TestMigrateNoDiscordConfig(t *testing.T) {
 			UseLogFile:          true,
 			LogFilePath:         "/home/minecraft/server/logs/latest.log",
 		},
+
+		Storage: StorageConfig{},
 	}
 
 	// when
@@ -328,7 +435,7 @@ func TestMigrateNoDiscordConfig(t *testing.T) {
 func TestMigrateNoMinecraftConfig(t *testing.T) {
 	// given
 	expected := RootConfig{
-		DiscordConfig{
+		Discord: DiscordConfig{
 			BotToken:       "",
 			ChannelID:      "",
 			AllowMentions:  true,
@@ -338,13 +445,33 @@ func TestMigrateNoMinecraftConfig(t *testing.T) {
 				ShowDeaths:       true,
 				ShowJoinsLeaves:  true,
 			},
+			Mentions: MentionConfig{
+				Policy:       MentionPolicyUsers,
+				AllowedRoles: &[]string{},
+			},
+			Embeds:     defaultEmbedConfig(),
+			Status:     defaultStatusConfig(),
+			Console:    defaultConsoleConfig(),
+			Whitelist:  defaultWhitelistConfig(),
+			Moderation: defaultModerationConfig(),
+			Linking:    defaultLinkingConfig(),
+			Digest:     defaultDigestConfig(),
+			Commands: CommandConfig{
+				Prefix:        "!",
+				EnablePrefix:  true,
+				SlashCommands: true,
+				Permissions:   &[]PermissionConfig{},
+				Rcon: RconCommandConfig{
+					Rules: &[]RconRuleConfig{},
+				},
+			},
 			Webhook: WebhookConfig{
 				Enabled: false,
 				URL:     "",
 			},
 		},
 
-		MinecraftConfig{
+		Minecraft: MinecraftConfig{
 			RconIP:              "localhost",
 			RconPort:            25575,
 			RconPassword:        "",
@@ -353,10 +480,14 @@ func TestMigrateNoMinecraftConfig(t *testing.T) {
 			UseLogFile:          true,
 			LogFilePath:         "/home/minecraft/server/logs/latest.log",
 		},
+
+		IRC:     defaultIRCConfig(),
+		Routes:  defaultRouteConfig(),
+		Storage: StorageConfig{},
 	}
 
 	givenConfig := RootConfig{
-		DiscordConfig{
+		Discord: DiscordConfig{
 			BotToken:       "",
 			ChannelID:      "",
 			AllowMentions:  true,
@@ -366,12 +497,34 @@ func TestMigrateNoMinecraftConfig(t *testing.T) {
 				ShowDeaths:       true,
 				ShowJoinsLeaves:  true,
 			},
+			Mentions: MentionConfig{
+				Policy:       MentionPolicyUsers,
+				AllowedRoles: &[]string{},
+			},
+			Embeds:     defaultEmbedConfig(),
+			Status:     defaultStatusConfig(),
+			Console:    defaultConsoleConfig(),
+			Whitelist:  defaultWhitelistConfig(),
+			Moderation: defaultModerationConfig(),
+			Linking:    defaultLinkingConfig(),
+			Digest:     defaultDigestConfig(),
+			Commands: CommandConfig{
+				Prefix:        "!",
+				EnablePrefix:  true,
+				SlashCommands: true,
+				Permissions:   &[]PermissionConfig{},
+				Rcon: RconCommandConfig{
+					Rules: &[]RconRuleConfig{},
+				},
+			},
 			Webhook: WebhookConfig{
 				Enabled: false,
 				URL:     "",
 			}},
 
-		MinecraftConfig{},
+		Minecraft: MinecraftConfig{},
+
+		Storage: StorageConfig{},
 	}
 
 	// when
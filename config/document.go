@@ -0,0 +1,554 @@
+package config
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/pelletier/go-toml"
+)
+
+// ErrInlineTable is returned when a key can't be set because its table is
+// written inline, or is an array of tables.
+var ErrInlineTable = errors.New("table is written inline or is an array of tables")
+
+var bareKeyRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Document is a TOML file that can be edited without losing the comments,
+// ordering, or formatting of the parts that aren't changed. Only keys in
+// standard tables can be edited; arrays of tables and inline tables are
+// kept as they are.
+type Document struct {
+	lines []string
+}
+
+// docTable is a table header in a document.
+type docTable struct {
+	name  string
+	array bool
+	line  int
+}
+
+// docEntry is a key and its value in a document.
+type docEntry struct {
+	table string
+	key   string
+	array bool
+	start int
+	end   int
+	// valueCol is where the value starts on the first line
+	valueCol int
+	// comment is where the trailing comment starts on the last line, or -1
+	comment int
+}
+
+// ParseDocument creates a document from the contents of a TOML file.
+func ParseDocument(data []byte) *Document {
+	return &Document{lines: strings.Split(string(data), "\n")}
+}
+
+// Bytes gets the contents of the document.
+func (d *Document) Bytes() []byte {
+	return []byte(d.String())
+}
+
+// String gets the contents of the document.
+func (d *Document) String() string {
+	return strings.Join(d.lines, "\n")
+}
+
+// Get gets the value of a key in a table. Use an empty table for keys at the
+// top of the file.
+func (d *Document) Get(table string, key string) (interface{}, bool) {
+	entry, ok := d.find(table, key)
+	if !ok {
+		return nil, false
+	}
+
+	tree, err := toml.Load("value = " + d.raw(entry))
+	if err != nil {
+		return nil, false
+	}
+	return tree.Get("value"), true
+}
+
+// Set sets the value of a key in a table. If the key exists, only its value
+// is replaced; otherwise it is added to the end of the table, and the table
+// is added if it doesn't exist.
+func (d *Document) Set(table string, key string, value interface{}) error {
+	raw, err := encodeValue(value)
+	if err != nil {
+		return err
+	}
+	return d.set(table, key, raw, "")
+}
+
+// Delete removes a key and its value from a table. It returns false if the
+// key doesn't exist.
+func (d *Document) Delete(table string, key string) bool {
+	entry, ok := d.find(table, key)
+	if !ok {
+		return false
+	}
+
+	d.lines = append(d.lines[:entry.start], d.lines[entry.end+1:]...)
+	return true
+}
+
+// Rename changes the name of a key in a table, keeping its value and
+// comments. It returns false if the key doesn't exist.
+func (d *Document) Rename(table string, key string, newKey string) bool {
+	entry, ok := d.find(table, key)
+	if !ok {
+		return false
+	}
+
+	line := d.lines[entry.start]
+	keyStart := len(line) - len(strings.TrimLeft(line, " \t"))
+	eq := keyStart + indexOutsideQuotes(line[keyStart:], '=')
+	d.lines[entry.start] = line[:keyStart] + formatKey(newKey) + " " + line[eq:]
+	return true
+}
+
+// HasTable checks if a table has a header in the document.
+func (d *Document) HasTable(table string) bool {
+	tables, _ := d.scan()
+	for _, t := range tables {
+		if t.name == table && !t.array {
+			return true
+		}
+	}
+	return false
+}
+
+// AddTable adds an empty table after its parent table, or to the end of the
+// document, with an optional comment above it. Nothing is done if the table
+// already exists.
+func (d *Document) AddTable(table string, comment string) error {
+	if d.HasTable(table) {
+		return nil
+	}
+	if d.inline(table) {
+		return ErrInlineTable
+	}
+
+	indent := strings.Repeat("  ", strings.Count(table, "."))
+	lines := commentLines(comment, indent)
+	lines = append(lines, indent+"["+formatTable(table)+"]")
+
+	// Keep a blank line between tables
+	at := d.tablePosition(table)
+	if at > 0 && strings.TrimSpace(d.lines[at-1]) != "" {
+		lines = append([]string{""}, lines...)
+	}
+	d.insert(at, lines)
+	return nil
+}
+
+// tablePosition gets the index to add a new table at. Sub-tables are kept
+// next to their parent, if it exists; otherwise tables are added to the end.
+func (d *Document) tablePosition(table string) int {
+	at := d.end()
+	if dot := strings.LastIndex(table, "."); dot >= 0 {
+		parent := table[:dot]
+		tables, _ := d.scan()
+		for i, t := range tables {
+			if t.name != parent && !strings.HasPrefix(t.name, parent+".") {
+				continue
+			}
+
+			next := d.end()
+			if i+1 < len(tables) {
+				next = tables[i+1].line
+			}
+			at = d.contentEnd(t.line, next)
+		}
+	}
+	return at
+}
+
+// setArray replaces every table in an array of tables with new ones. Each
+// table is given as its lines of keys and raw values. Comments inside the
+// old tables are lost, but the rest of the document is kept.
+func (d *Document) setArray(name string, tables [][]string) error {
+	// Check if the array is written inline
+	if dot := strings.LastIndex(name, "."); dot >= 0 {
+		if _, ok := d.find(name[:dot], name[dot+1:]); ok {
+			return ErrInlineTable
+		}
+	} else if _, ok := d.find("", name); ok {
+		return ErrInlineTable
+	}
+
+	// Remove the old tables, starting from the end
+	at := -1
+	headers, _ := d.scan()
+	for i := len(headers) - 1; i >= 0; i-- {
+		if headers[i].name != name || !headers[i].array {
+			continue
+		}
+
+		next := d.end()
+		if i+1 < len(headers) {
+			next = headers[i+1].line
+		}
+		end := d.contentEnd(headers[i].line, next)
+		if i+1 < len(headers) && headers[i+1].name == name && headers[i+1].array {
+			// The next table is removed too
+			end = next
+		}
+		d.lines = append(d.lines[:headers[i].line], d.lines[end:]...)
+		at = headers[i].line
+	}
+	if at < 0 {
+		at = d.tablePosition(name)
+	}
+
+	indent := strings.Repeat("  ", strings.Count(name, "."))
+	lines := make([]string, 0)
+	for _, table := range tables {
+		if len(lines) > 0 || (at > 0 && strings.TrimSpace(d.lines[at-1]) != "") {
+			lines = append(lines, "")
+		}
+		lines = append(lines, indent+"[["+formatTable(name)+"]]")
+		for _, line := range table {
+			lines = append(lines, indent+"  "+line)
+		}
+	}
+
+	d.insert(at, lines)
+	return nil
+}
+
+// contentEnd gets the index after the last line between two lines that
+// isn't blank or a comment, so comments above the next table stay with it.
+func (d *Document) contentEnd(from int, to int) int {
+	for i := to - 1; i > from; i-- {
+		trimmed := strings.TrimSpace(d.lines[i])
+		if trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+			return i + 1
+		}
+	}
+	return from + 1
+}
+
+// set sets the raw value of a key in a table, adding the comment above it if
+// the key is new.
+func (d *Document) set(table string, key string, raw string, comment string) error {
+	if entry, ok := d.find(table, key); ok {
+		line := d.lines[entry.start][:entry.valueCol] + raw
+		if entry.comment >= 0 {
+			line += " " + d.lines[entry.end][entry.comment:]
+		}
+		d.lines = append(d.lines[:entry.start], append(strings.Split(line, "\n"), d.lines[entry.end+1:]...)...)
+		return nil
+	}
+
+	if table != "" {
+		if err := d.AddTable(table, ""); err != nil {
+			return err
+		}
+	}
+
+	// Find where the table's keys end
+	tables, entries := d.scan()
+	at, indent := 0, ""
+	if table != "" {
+		for _, t := range tables {
+			if t.name == table && !t.array {
+				at = t.line + 1
+				line := d.lines[t.line]
+				indent = line[:len(line)-len(strings.TrimLeft(line, " \t"))] + "  "
+			}
+		}
+	}
+	for _, entry := range entries {
+		if entry.table == table && !entry.array {
+			at = entry.end + 1
+			line := d.lines[entry.start]
+			indent = line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		}
+	}
+
+	lines := commentLines(comment, indent)
+	lines = append(lines, indent+formatKey(key)+" = "+raw)
+
+	// Keep a blank line before the next table
+	if at < len(d.lines) && strings.HasPrefix(strings.TrimSpace(d.lines[at]), "[") {
+		lines = append(lines, "")
+	}
+	d.insert(at, lines)
+	return nil
+}
+
+// find finds a key in a table. Keys in arrays of tables aren't found.
+func (d *Document) find(table string, key string) (docEntry, bool) {
+	_, entries := d.scan()
+	for _, entry := range entries {
+		if entry.table == table && entry.key == key && !entry.array {
+			return entry, true
+		}
+	}
+	return docEntry{}, false
+}
+
+// inline checks if a table, or one of its parents, is written as a value,
+// such as an inline table, or is an array of tables.
+func (d *Document) inline(table string) bool {
+	tables, _ := d.scan()
+	for _, t := range tables {
+		if t.array && (t.name == table || strings.HasPrefix(table, t.name+".")) {
+			return true
+		}
+	}
+
+	parts := splitKey(table)
+	for i := range parts {
+		if _, ok := d.find(strings.Join(parts[:i], "."), parts[i]); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// raw gets the raw text of the value of an entry, without its comment.
+func (d *Document) raw(entry docEntry) string {
+	lines := make([]string, 0, entry.end-entry.start+1)
+	for i := entry.start; i <= entry.end; i++ {
+		line := d.lines[i]
+		if i == entry.end && entry.comment >= 0 {
+			line = line[:entry.comment]
+		}
+		if i == entry.start {
+			line = line[entry.valueCol:]
+		}
+		lines = append(lines, line)
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// end gets the index to add lines at the end of the document, before the
+// final newline.
+func (d *Document) end() int {
+	if n := len(d.lines); n > 0 && d.lines[n-1] == "" {
+		return n - 1
+	}
+	return len(d.lines)
+}
+
+// insert inserts lines at an index.
+func (d *Document) insert(at int, lines []string) {
+	d.lines = append(d.lines[:at], append(lines, d.lines[at:]...)...)
+}
+
+// scan finds every table header and key in the document.
+func (d *Document) scan() ([]docTable, []docEntry) {
+	var (
+		tables  []docTable
+		entries []docEntry
+		current docTable
+	)
+
+	for i := 0; i < len(d.lines); i++ {
+		line := d.lines[i]
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		// Check if this is a table header
+		if strings.HasPrefix(trimmed, "[") {
+			current = docTable{line: i}
+			name := strings.TrimPrefix(trimmed, "[")
+			if strings.HasPrefix(name, "[") {
+				current.array = true
+				name = strings.TrimPrefix(name, "[")
+			}
+			if end := indexOutsideQuotes(name, ']'); end >= 0 {
+				name = name[:end]
+			}
+			current.name = strings.Join(splitKey(name), ".")
+			tables = append(tables, current)
+			continue
+		}
+
+		eq := indexOutsideQuotes(line, '=')
+		if eq < 0 {
+			continue
+		}
+
+		entry := docEntry{
+			table:    current.name,
+			key:      strings.Join(splitKey(line[:eq]), "."),
+			array:    current.array,
+			start:    i,
+			valueCol: eq + 1,
+		}
+		for entry.valueCol < len(line) && (line[entry.valueCol] == ' ' || line[entry.valueCol] == '\t') {
+			entry.valueCol++
+		}
+		entry.end, entry.comment = d.scanValue(i, entry.valueCol)
+		entries = append(entries, entry)
+		i = entry.end
+	}
+
+	return tables, entries
+}
+
+// scanValue finds the last line of a value that starts at a line and column,
+// and where its trailing comment starts, if it has one.
+func (d *Document) scanValue(line int, col int) (int, int) {
+	depth := 0
+	quote := ""
+
+	for i := line; i < len(d.lines); i++ {
+		s := d.lines[i]
+		j := 0
+		if i == line {
+			j = col
+		}
+
+		for j < len(s) {
+			switch {
+			case quote == `"""` || quote == `'''`:
+				if strings.HasPrefix(s[j:], quote) {
+					quote = ""
+					j += 3
+					continue
+				}
+				if quote == `"""` && s[j] == '\\' {
+					j++
+				}
+			case quote == `"`:
+				if s[j] == '\\' {
+					j++
+				} else if s[j] == '"' {
+					quote = ""
+				}
+			case quote == `'`:
+				if s[j] == '\'' {
+					quote = ""
+				}
+			case strings.HasPrefix(s[j:], `"""`) || strings.HasPrefix(s[j:], `'''`):
+				quote = s[j : j+3]
+				j += 3
+				continue
+			case s[j] == '"' || s[j] == '\'':
+				quote = string(s[j])
+			case s[j] == '[' || s[j] == '{':
+				depth++
+			case s[j] == ']' || s[j] == '}':
+				depth--
+			case s[j] == '#':
+				if depth <= 0 {
+					return i, j
+				}
+				// Comments inside of arrays end with the line
+				j = len(s)
+				continue
+			}
+			j++
+		}
+
+		// Basic and literal strings can't span lines
+		if quote == `"` || quote == `'` {
+			quote = ""
+		}
+		if depth <= 0 && quote == "" {
+			return i, -1
+		}
+	}
+
+	return len(d.lines) - 1, -1
+}
+
+// encodeValue encodes a value as TOML.
+func encodeValue(value interface{}) (string, error) {
+	tree, err := toml.TreeFromMap(map[string]interface{}{"value": value})
+	if err != nil {
+		return "", err
+	}
+
+	s, err := tree.ToTomlString()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(strings.TrimPrefix(s, "value = ")), nil
+}
+
+// commentLines formats a comment as TOML comment lines.
+func commentLines(comment string, indent string) []string {
+	if comment == "" {
+		return []string{}
+	}
+
+	lines := strings.Split(comment, "\n")
+	for i, line := range lines {
+		lines[i] = indent + "# " + line
+	}
+	return lines
+}
+
+// indexOutsideQuotes finds the first index of a character that isn't in a
+// quoted string, or -1.
+func indexOutsideQuotes(s string, c byte) int {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		switch {
+		case quote != 0:
+			if s[i] == '\\' && quote == '"' {
+				i++
+			} else if s[i] == quote {
+				quote = 0
+			}
+		case s[i] == '"' || s[i] == '\'':
+			quote = s[i]
+		case s[i] == c:
+			return i
+		}
+	}
+	return -1
+}
+
+// splitKey splits a dotted key into its parts, removing whitespace and
+// quotes.
+func splitKey(key string) []string {
+	parts := make([]string, 0)
+	for {
+		dot := indexOutsideQuotes(key, '.')
+		part := key
+		if dot >= 0 {
+			part = key[:dot]
+		}
+
+		part = strings.TrimSpace(part)
+		if len(part) >= 2 && (part[0] == '"' || part[0] == '\'') && part[len(part)-1] == part[0] {
+			part = part[1 : len(part)-1]
+		}
+		if part != "" {
+			parts = append(parts, part)
+		}
+
+		if dot < 0 {
+			return parts
+		}
+		key = key[dot+1:]
+	}
+}
+
+// formatKey formats a single key, quoting it if needed.
+func formatKey(key string) string {
+	if bareKeyRegex.MatchString(key) {
+		return key
+	}
+	return `"` + strings.ReplaceAll(key, `"`, `\"`) + `"`
+}
+
+// formatTable formats a dotted table name.
+func formatTable(table string) string {
+	parts := strings.Split(table, ".")
+	for i, part := range parts {
+		parts[i] = formatKey(part)
+	}
+	return strings.Join(parts, ".")
+}
@@ -0,0 +1,141 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const testDocument = `# Bot settings
+[Discord]
+  # Keep this secret
+  BotToken = "abc" # from the developer portal
+  AllowMentions = false
+
+  [Discord.console]
+    levels = [
+      "INFO", # normal lines
+      "WARN",
+    ]
+
+[[Discord.commands.permissions]]
+  command = "rcon"
+
+[Minecraft]
+  RconPort = 25575
+`
+
+func TestDocumentSetKeepsComments(t *testing.T) {
+	// given
+	doc := ParseDocument([]byte(testDocument))
+	expected := `# Bot settings
+[Discord]
+  # Keep this secret
+  BotToken = "xyz" # from the developer portal
+  AllowMentions = false
+  ChannelID = "1234"
+
+  [Discord.console]
+    levels = ["ERROR"]
+
+[[Discord.commands.permissions]]
+  command = "rcon"
+
+[Minecraft]
+  RconPort = 25565
+`
+
+	// when
+	doc.Set("Discord", "BotToken", "xyz")
+	doc.Set("Discord", "ChannelID", "1234")
+	doc.Set("Discord.console", "levels", []string{"ERROR"})
+	doc.Set("Minecraft", "RconPort", 25565)
+
+	// then
+	if actual := doc.String(); actual != expected {
+		t.Errorf("Document is incorrect: Diff: %s", cmp.Diff(actual, expected))
+	}
+}
+
+func TestDocumentAddTable(t *testing.T) {
+	// given
+	doc := ParseDocument([]byte(testDocument))
+	expected := `# Bot settings
+[Discord]
+  # Keep this secret
+  BotToken = "abc" # from the developer portal
+  AllowMentions = false
+
+  [Discord.console]
+    levels = [
+      "INFO", # normal lines
+      "WARN",
+    ]
+
+[[Discord.commands.permissions]]
+  command = "rcon"
+
+  # Post a weekly summary
+  [Discord.digest]
+    enabled = true
+
+[Minecraft]
+  RconPort = 25575
+
+[Storage]
+  path = "/tmp/dolphin.db"
+`
+
+	// when
+	doc.AddTable("Discord.digest", "Post a weekly summary")
+	doc.Set("Discord.digest", "enabled", true)
+	doc.Set("Storage", "path", "/tmp/dolphin.db")
+
+	// then
+	if actual := doc.String(); actual != expected {
+		t.Errorf("Document is incorrect: Diff: %s", cmp.Diff(actual, expected))
+	}
+}
+
+func TestDocumentGetDeleteRename(t *testing.T) {
+	// given
+	doc := ParseDocument([]byte(testDocument))
+
+	// when
+	levels, ok := doc.Get("Discord.console", "levels")
+	deleted := doc.Delete("Discord.console", "levels")
+	renamed := doc.Rename("Discord", "AllowMentions", "allow_mentions")
+
+	// then
+	if !ok || !cmp.Equal(levels, []interface{}{"INFO", "WARN"}) {
+		t.Errorf("Multi-line value was not read, got: %v", levels)
+	}
+	if !deleted || !renamed {
+		t.Fatalf("Keys were not changed, got: %t %t", deleted, renamed)
+	}
+	if _, ok := doc.Get("Discord.console", "levels"); ok {
+		t.Errorf("Deleted key should be gone")
+	}
+	if value, ok := doc.Get("Discord", "allow_mentions"); !ok || value != false {
+		t.Errorf("Renamed key should keep its value, got: %v", value)
+	}
+	if _, ok := doc.Get("Discord.commands.permissions", "command"); ok {
+		t.Errorf("Keys in arrays of tables should not be found")
+	}
+	if err := doc.Set("Discord.commands.permissions", "command", "list"); err != ErrInlineTable {
+		t.Errorf("Setting keys in arrays of tables should fail, got: %v", err)
+	}
+}
+
+func TestDocumentInlineTable(t *testing.T) {
+	// given
+	doc := ParseDocument([]byte("[Discord]\n  webhook = { enabled = true }\n"))
+
+	// when
+	err := doc.Set("Discord.webhook", "url", "https://discord.com")
+
+	// then
+	if err != ErrInlineTable {
+		t.Errorf("Setting keys in inline tables should fail, got: %v", err)
+	}
+}
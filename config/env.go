@@ -0,0 +1,241 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	log "github.com/DataDrake/waterlog"
+	"github.com/pelletier/go-toml"
+)
+
+// envPrefix is the start of the name of every environment variable that
+// overrides a setting.
+const envPrefix = "DOLPHIN_"
+
+// envFileSuffix is added to the name of an environment variable to read the
+// value from a file instead, such as a container secret.
+const envFileSuffix = "_FILE"
+
+// Override is a setting that is set by an environment variable or the
+// server.properties file instead of the config file.
+type Override struct {
+	// Key is the path of the setting in the config file, such as
+	// Discord.BotToken
+	Key string
+	// Env is the name of the environment variable the value came from
+	Env string
+	// File is the path of the file the value came from if it didn't come
+	// from an environment variable, such as server.properties
+	File string
+}
+
+// Source describes where the value of an overridden setting came from.
+func (o Override) Source() string {
+	if o.Env != "" {
+		return fmt.Sprintf("the `%s` environment variable", o.Env)
+	}
+	return fmt.Sprintf("`%s`", o.File)
+}
+
+// EnvName gets the name of the environment variable that overrides a
+// setting, such as DOLPHIN_DISCORD_BOTTOKEN for Discord.BotToken.
+func EnvName(key string) string {
+	name := strings.NewReplacer(".", "_", "-", "_").Replace(key)
+	return envPrefix + strings.ToUpper(name)
+}
+
+// Overridden checks if a setting was set by an environment variable or the
+// server.properties file when the config was last loaded.
+func (f *File) Overridden(key string) (Override, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, o := range f.overrides {
+		if o.Key == key {
+			return o, true
+		}
+	}
+	return Override{}, false
+}
+
+// applyOverrides sets every setting that has an environment variable to the
+// value of the variable, or the contents of the file it points to if the
+// variable ends with _FILE. Strings are used as they are; every other type
+// is parsed as a TOML value, such as true, 25575, or ["INFO", "WARN"].
+func applyOverrides(conf *RootConfig, lookup func(string) (string, bool)) ([]Override, error) {
+	applied := make([]Override, 0)
+	var err error
+
+	walkFields(reflect.ValueOf(conf).Elem(), "", func(key string, _ reflect.StructField, field reflect.Value) {
+		if err != nil {
+			return
+		}
+
+		env := EnvName(key)
+		value, ok := lookup(env)
+		if path, isFile := lookup(env + envFileSuffix); isFile {
+			if ok {
+				err = fmt.Errorf("only one of %s and %s%s can be set", env, env, envFileSuffix)
+				return
+			}
+
+			// Read the value from the secret file
+			var data []byte
+			if data, err = ioutil.ReadFile(filepath.Clean(path)); err != nil {
+				err = fmt.Errorf("unable to read %s%s: %s", env, envFileSuffix, err)
+				return
+			}
+			value, ok = strings.TrimRight(string(data), "\r\n"), true
+			env += envFileSuffix
+		}
+		if !ok {
+			return
+		}
+
+		if err = setFromString(field, value); err != nil {
+			err = fmt.Errorf("invalid value for %s: %s", env, err)
+			return
+		}
+		applied = append(applied, Override{Key: key, Env: env})
+	})
+
+	return applied, err
+}
+
+// setOverrides applies the environment overrides and the server.properties
+// file to a config loaded from the config file, given as its tree, and
+// remembers them so they are never saved.
+func (f *File) setOverrides(conf *RootConfig, tree *toml.Tree) error {
+	file := *conf
+	applied, err := applyOverrides(conf, os.LookupEnv)
+	if err != nil {
+		return err
+	}
+
+	props, fromProps, err := applyServerProperties(conf, isSet(tree, applied))
+	if err != nil {
+		return err
+	}
+	applied = append(applied, fromProps...)
+
+	for _, o := range applied {
+		if o.Env != "" {
+			log.Infof("Using %s from %s\n", o.Key, o.Env)
+		} else {
+			log.Debugf("Using %s from %s\n", o.Key, o.File)
+		}
+	}
+	if props != nil && !props.EnableRcon {
+		log.Warnln("RCON is disabled in server.properties, so Dolphin can't send anything to Minecraft. Set enable-rcon=true and restart the server.")
+		if props.EnableQuery {
+			log.Infof("Polling the server status with the query protocol on port %d instead\n", props.QueryPort)
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.overrides = applied
+	f.fileConfig = file
+	f.loadedConfig = *conf
+	f.serverProps = props
+	return nil
+}
+
+// isSet gets a function that checks if a setting is in the config file,
+// given as its tree, or was set by an environment variable.
+func isSet(tree *toml.Tree, applied []Override) func(key string) bool {
+	return func(key string) bool {
+		for _, o := range applied {
+			if o.Key == key {
+				return true
+			}
+		}
+		return treeHasKey(tree, key)
+	}
+}
+
+// withoutOverrides gets a copy of a config with every overridden setting
+// set back to its value in the config file, or cleared if it was read from
+// server.properties, so it's left out of the file. Settings that were
+// changed since they were overridden, such as with the config command, are
+// kept.
+func (f *File) withoutOverrides(conf RootConfig) RootConfig {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(f.overrides) == 0 {
+		return conf
+	}
+
+	file := reflect.ValueOf(f.fileConfig)
+	loaded := reflect.ValueOf(f.loadedConfig)
+	walkFields(reflect.ValueOf(&conf).Elem(), "", func(key string, _ reflect.StructField, field reflect.Value) {
+		for _, o := range f.overrides {
+			if o.Key != key || !reflect.DeepEqual(field.Interface(), fieldByKey(loaded, key).Interface()) {
+				continue
+			}
+			if o.Env != "" {
+				field.Set(fieldByKey(file, key))
+			} else {
+				field.Set(reflect.Zero(field.Type()))
+			}
+		}
+	})
+	return conf
+}
+
+// setFromString sets a field from a string. Strings are used as they are,
+// and every other type is parsed as a TOML value.
+func setFromString(field reflect.Value, value string) error {
+	if field.Kind() == reflect.String {
+		field.SetString(value)
+		return nil
+	}
+
+	// Decode the value as if it were in a config file
+	wrapper := reflect.New(reflect.StructOf([]reflect.StructField{
+		{Name: "Value", Type: field.Type(), Tag: `toml:"value"`},
+	}))
+	if err := toml.Unmarshal([]byte("value = "+value), wrapper.Interface()); err != nil {
+		return err
+	}
+
+	field.Set(wrapper.Elem().Field(0))
+	return nil
+}
+
+// walkFields calls a function with the key, struct field, and value of every
+// setting in a config struct, such as Discord.message_options.show_deaths.
+func walkFields(v reflect.Value, prefix string, fn func(key string, field reflect.StructField, value reflect.Value)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		key := tomlKey(t.Field(i))
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+
+		if t.Field(i).Type.Kind() == reflect.Struct {
+			walkFields(v.Field(i), key, fn)
+			continue
+		}
+		fn(key, t.Field(i), v.Field(i))
+	}
+}
+
+// fieldByKey gets the value of a setting from a config struct by its key.
+func fieldByKey(v reflect.Value, key string) reflect.Value {
+	for _, part := range strings.Split(key, ".") {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			if tomlKey(t.Field(i)) == part {
+				v = v.Field(i)
+				break
+			}
+		}
+	}
+	return v
+}
@@ -0,0 +1,138 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestEnvName(t *testing.T) {
+	// then
+	if name := EnvName("Discord.BotToken"); name != "DOLPHIN_DISCORD_BOTTOKEN" {
+		t.Errorf("Env name is incorrect, got: %s", name)
+	}
+	if name := EnvName("Discord.message_options.show_deaths"); name != "DOLPHIN_DISCORD_MESSAGE_OPTIONS_SHOW_DEATHS" {
+		t.Errorf("Env name is incorrect, got: %s", name)
+	}
+}
+
+func TestApplyOverrides(t *testing.T) {
+	// given
+	dir, err := ioutil.TempDir("", "dolphin-testing")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	secret := filepath.Join(dir, "rcon_password")
+	if err := ioutil.WriteFile(secret, []byte("hunter2\n"), 0600); err != nil {
+		t.Fatalf("Failed to write secret: %s", err)
+	}
+
+	env := map[string]string{
+		"DOLPHIN_DISCORD_BOTTOKEN":            "bot-token",
+		"DOLPHIN_DISCORD_ALLOWMENTIONS":       "false",
+		"DOLPHIN_DISCORD_CONSOLE_LEVELS":      `["INFO", "WARN"]`,
+		"DOLPHIN_MINECRAFT_RCONPORT":          "25576",
+		"DOLPHIN_MINECRAFT_RCONPASSWORD_FILE": secret,
+		"DOLPHIN_MINECRAFT_UNKNOWN":           "ignored",
+	}
+	conf := MergeDefaults(RootConfig{})
+
+	// when
+	applied, err := applyOverrides(&conf, func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	})
+
+	// then
+	if err != nil {
+		t.Fatalf("Failed to apply overrides: %s", err)
+	}
+	if conf.Discord.BotToken != "bot-token" || conf.Discord.AllowMentions || conf.Minecraft.RconPort != 25576 {
+		t.Errorf("Overrides were not applied: %+v", conf)
+	}
+	if conf.Minecraft.RconPassword != "hunter2" {
+		t.Errorf("Secret file was not read, got: %q", conf.Minecraft.RconPassword)
+	}
+	if !cmp.Equal(*conf.Discord.Console.Levels, []string{"INFO", "WARN"}) {
+		t.Errorf("List override is incorrect, got: %v", *conf.Discord.Console.Levels)
+	}
+	if len(applied) != 5 {
+		t.Errorf("Expected 5 overrides, got: %+v", applied)
+	}
+}
+
+func TestApplyOverridesInvalid(t *testing.T) {
+	// given
+	tests := map[string]map[string]string{
+		"invalid value": {"DOLPHIN_MINECRAFT_RCONPORT": "lots"},
+		"both set":      {"DOLPHIN_DISCORD_BOTTOKEN": "a", "DOLPHIN_DISCORD_BOTTOKEN_FILE": "/tmp/token"},
+		"missing file":  {"DOLPHIN_DISCORD_BOTTOKEN_FILE": "/nonexistent/token"},
+	}
+
+	for name, env := range tests {
+		conf := MergeDefaults(RootConfig{})
+
+		// when
+		_, err := applyOverrides(&conf, func(name string) (string, bool) {
+			value, ok := env[name]
+			return value, ok
+		})
+
+		// then
+		if err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestSaveConfigSkipsOverrides(t *testing.T) {
+	// given
+	dir, err := ioutil.TempDir("", "dolphin-testing")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "dolphin.conf")
+	file, err := NewFile(path)
+	if err != nil {
+		t.Fatalf("Failed to create config file: %s", err)
+	}
+
+	os.Setenv("DOLPHIN_DISCORD_BOTTOKEN", "secret-token")
+	defer os.Unsetenv("DOLPHIN_DISCORD_BOTTOKEN")
+
+	conf, err := file.Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %s", err)
+	}
+	conf.Discord.ChannelID = "1234"
+
+	// when
+	err = file.Save(&conf)
+
+	// then
+	if err != nil {
+		t.Fatalf("Failed to save config: %s", err)
+	}
+	if conf.Discord.BotToken != "secret-token" {
+		t.Errorf("Overridden setting should be used, got: %s", conf.Discord.BotToken)
+	}
+	if o, ok := file.Overridden("Discord.BotToken"); !ok || o.Env != "DOLPHIN_DISCORD_BOTTOKEN" {
+		t.Errorf("Override was not recorded, got: %+v", o)
+	}
+
+	saved, _ := ioutil.ReadFile(path)
+	if strings.Contains(string(saved), "secret-token") {
+		t.Errorf("Overridden setting was saved:\n%s", saved)
+	}
+	if !strings.Contains(string(saved), `"1234"`) {
+		t.Errorf("Changed setting was not saved:\n%s", saved)
+	}
+}
@@ -0,0 +1,219 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/pelletier/go-toml"
+)
+
+// CurrentVersion is the version of the config file format used by this
+// version of Dolphin. Older config files are migrated to it when they are
+// loaded.
+const CurrentVersion = 1
+
+// versionKey is the key of the config file format version.
+const versionKey = "config_version"
+
+// migration changes a config file from the previous version to the next.
+type migration struct {
+	Version int
+	Desc    string
+	Migrate func(doc *Document) error
+}
+
+// migrations are the changes to the config file format, in order.
+var migrations = []migration{
+	{
+		Version: 1,
+		Desc:    "use discord.com in webhook URLs",
+		Migrate: func(doc *Document) error {
+			url, ok := doc.Get("Discord.Webhook", "URL")
+			if s, isString := url.(string); ok && isString && strings.Contains(s, "discordapp.com/") {
+				return doc.Set("Discord.Webhook", "URL", strings.Replace(s, "discordapp.com/", "discord.com/", 1))
+			}
+			return nil
+		},
+	},
+}
+
+// migrate runs the migrations that haven't been run on a config file, in
+// order, and returns the descriptions of the ones that were run.
+func migrate(doc *Document, list []migration) ([]string, error) {
+	ran := make([]string, 0)
+
+	// Files from before versions were added don't have one
+	version := 0
+	if value, ok := doc.Get("", versionKey); ok {
+		v, isInt := value.(int64)
+		if !isInt {
+			return ran, fmt.Errorf("%s must be a number", versionKey)
+		}
+		version = int(v)
+	}
+
+	if version > CurrentVersion {
+		return ran, fmt.Errorf("config version %d is newer than this version of Dolphin supports (%d)", version, CurrentVersion)
+	}
+
+	for _, m := range list {
+		if m.Version <= version {
+			continue
+		}
+
+		if err := m.Migrate(doc); err != nil {
+			return ran, fmt.Errorf("config migration %d (%s) failed: %s", m.Version, m.Desc, err)
+		}
+		if err := doc.set("", versionKey, fmt.Sprint(m.Version), "The version of this config file. Don't change this!"); err != nil {
+			return ran, err
+		}
+		ran = append(ran, m.Desc)
+	}
+
+	return ran, nil
+}
+
+// addDefaults adds the settings that are missing from a config file, with
+// their default values, so new settings show up in the file. Settings in
+// tables that can't be edited, such as inline tables, are skipped, as are
+// the settings in skip, by their full key.
+func addDefaults(doc *Document, tree *toml.Tree, defaults reflect.Value, table string, skip map[string]bool) error {
+	t := defaults.Type()
+
+	// Add the keys of this table before any of its sub-tables
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key := tomlKey(field)
+		if field.Type.Kind() == reflect.Struct || field.Type.Kind() == reflect.Map || hasKey(tree, key) || skip[joinKey(table, key)] {
+			continue
+		}
+
+		value := defaults.Field(i)
+		if value.Kind() == reflect.Ptr {
+			if value.IsNil() {
+				continue
+			}
+			value = value.Elem()
+		}
+
+		// Arrays of tables are left out until something is added to them
+		if value.Kind() == reflect.Slice && value.Type().Elem().Kind() == reflect.Struct {
+			continue
+		}
+
+		raw, err := encodeValue(value.Interface())
+		if err != nil {
+			return err
+		}
+		if err := doc.set(table, key, raw, field.Tag.Get("comment")); err != nil && err != ErrInlineTable {
+			return err
+		}
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key := tomlKey(field)
+		name := joinKey(table, key)
+
+		// Maps are tables with keys that aren't known ahead of time, so
+		// they are only added as a whole
+		if field.Type.Kind() == reflect.Map {
+			if hasKey(tree, key) || skip[name] || defaults.Field(i).IsNil() {
+				continue
+			}
+			if err := setTable(doc, name, defaults.Field(i), field.Tag.Get("comment")); err != nil && err != ErrInlineTable {
+				return err
+			}
+			continue
+		}
+		if field.Type.Kind() != reflect.Struct {
+			continue
+		}
+
+		sub := subTree(tree, key)
+		if sub == nil {
+			if err := doc.AddTable(name, field.Tag.Get("comment")); err == ErrInlineTable {
+				continue
+			} else if err != nil {
+				return err
+			}
+		}
+		if err := addDefaults(doc, sub, defaults.Field(i), name, skip); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// joinKey gets the full key of a setting in a table.
+func joinKey(table string, key string) string {
+	if table == "" {
+		return key
+	}
+	return table + "." + key
+}
+
+// mergeFieldDefaults sets every field of a config that isn't in the config
+// file to its default value. Unlike MergeDefaults, settings that are in the
+// file are always kept, even if they are false or zero.
+func mergeFieldDefaults(conf reflect.Value, defaults reflect.Value, tree *toml.Tree) {
+	t := conf.Type()
+	for i := 0; i < t.NumField(); i++ {
+		key := tomlKey(t.Field(i))
+		if t.Field(i).Type.Kind() == reflect.Struct {
+			mergeFieldDefaults(conf.Field(i), defaults.Field(i), subTree(tree, key))
+			continue
+		}
+
+		if !hasKey(tree, key) {
+			conf.Field(i).Set(defaults.Field(i))
+		}
+	}
+}
+
+// tomlKey gets the key of a struct field in a config file.
+func tomlKey(field reflect.StructField) string {
+	if name := strings.Split(field.Tag.Get("toml"), ",")[0]; name != "" {
+		return name
+	}
+	return field.Name
+}
+
+// hasKey checks if a tree has a key. Case is ignored, because the decoder
+// accepts keys in a few different cases.
+func hasKey(tree *toml.Tree, key string) bool {
+	if tree == nil {
+		return false
+	}
+	if tree.Has(key) {
+		return true
+	}
+
+	for _, k := range tree.Keys() {
+		if strings.EqualFold(k, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// subTree gets the table with a key from a tree, or nil.
+func subTree(tree *toml.Tree, key string) *toml.Tree {
+	if tree == nil {
+		return nil
+	}
+
+	if sub, ok := tree.GetPath([]string{key}).(*toml.Tree); ok {
+		return sub
+	}
+
+	for _, k := range tree.Keys() {
+		if strings.EqualFold(k, key) {
+			sub, _ := tree.GetPath([]string{k}).(*toml.Tree)
+			return sub
+		}
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package config
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMigrateRunsInOrder(t *testing.T) {
+	// given
+	doc := ParseDocument([]byte("config_version = 1\n[Discord]\n  BotToken = \"abc\"\n"))
+	ran := make([]int, 0)
+	list := []migration{
+		{Version: 1, Desc: "one", Migrate: func(doc *Document) error { ran = append(ran, 1); return nil }},
+		{Version: 2, Desc: "two", Migrate: func(doc *Document) error {
+			ran = append(ran, 2)
+			doc.Rename("Discord", "BotToken", "bot_token")
+			return nil
+		}},
+		{Version: 3, Desc: "three", Migrate: func(doc *Document) error { ran = append(ran, 3); return nil }},
+	}
+
+	// when
+	descs, err := migrate(doc, list)
+
+	// then
+	if err != nil {
+		t.Fatalf("Failed to migrate: %s", err)
+	}
+	if !cmp.Equal(ran, []int{2, 3}) || !cmp.Equal(descs, []string{"two", "three"}) {
+		t.Errorf("Wrong migrations were run, got: %v %v", ran, descs)
+	}
+	if version, _ := doc.Get("", versionKey); version != int64(3) {
+		t.Errorf("Config version was not updated, got: %v", version)
+	}
+	if token, ok := doc.Get("Discord", "bot_token"); !ok || token != "abc" {
+		t.Errorf("Migration was not applied, got: %v", token)
+	}
+}
+
+func TestMigrateFailureKeepsVersion(t *testing.T) {
+	// given
+	doc := ParseDocument([]byte(""))
+	list := []migration{
+		{Version: 1, Desc: "one", Migrate: func(doc *Document) error { return nil }},
+		{Version: 2, Desc: "two", Migrate: func(doc *Document) error { return errors.New("broken") }},
+	}
+
+	// when
+	_, err := migrate(doc, list)
+
+	// then
+	if err == nil {
+		t.Fatalf("Failed migrations should return an error")
+	}
+	if version, _ := doc.Get("", versionKey); version != int64(1) {
+		t.Errorf("Config version should be the last good migration, got: %v", version)
+	}
+}
+
+func TestMigrateNewerVersion(t *testing.T) {
+	// given
+	doc := ParseDocument([]byte("config_version = 99\n"))
+
+	// when
+	_, err := migrate(doc, migrations)
+
+	// then
+	if err == nil {
+		t.Errorf("Config files from newer versions should not be loaded")
+	}
+}
+
+func TestLoadFieldDefaults(t *testing.T) {
+	// given
+	dir, err := ioutil.TempDir("", "dolphin-testing")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "dolphin.conf")
+	old := `# My server
+[Discord]
+  AllowMentions = false # no pings
+
+  [Discord.Webhook]
+    Enabled = true
+    URL = "https://discordapp.com/api/webhooks/1/abc"
+
+[Minecraft]
+  RconIP = "mc.example.com"
+`
+	if err := ioutil.WriteFile(path, []byte(old), 0600); err != nil {
+		t.Fatalf("Failed to write config: %s", err)
+	}
+	file, err := NewFile(path)
+	if err != nil {
+		t.Fatalf("Failed to set config path: %s", err)
+	}
+
+	// when
+	conf, err := file.Load()
+
+	// then
+	if err != nil {
+		t.Fatalf("Failed to load config: %s", err)
+	}
+	if conf.Discord.AllowMentions {
+		t.Errorf("Settings that are false should not be replaced by defaults")
+	}
+	if conf.Minecraft.RconPort != 25575 || conf.Minecraft.RconIP != "mc.example.com" {
+		t.Errorf("Missing settings should get defaults, got: %s:%d", conf.Minecraft.RconIP, conf.Minecraft.RconPort)
+	}
+	if conf.Discord.Webhook.URL != "https://discord.com/api/webhooks/1/abc" {
+		t.Errorf("Webhook URL was not migrated, got: %s", conf.Discord.Webhook.URL)
+	}
+	if conf.ConfigVersion != CurrentVersion {
+		t.Errorf("Config version is incorrect, got: %d", conf.ConfigVersion)
+	}
+
+	saved, _ := ioutil.ReadFile(path)
+	for _, s := range []string{"# My server", "AllowMentions = false # no pings", "RconPort = 25575", "config_version = 1"} {
+		if !strings.Contains(string(saved), s) {
+			t.Errorf("Saved config is missing '%s':\n%s", s, saved)
+		}
+	}
+
+	// Loading again should not change the file
+	if _, err := file.Load(); err != nil {
+		t.Fatalf("Failed to load config again: %s", err)
+	}
+	if again, _ := ioutil.ReadFile(path); string(again) != string(saved) {
+		t.Errorf("Config should not change when loaded again: Diff: %s", cmp.Diff(string(again), string(saved)))
+	}
+}
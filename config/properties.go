@@ -0,0 +1,200 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ServerProperties are the settings Dolphin uses from a Minecraft server's
+// server.properties file.
+type ServerProperties struct {
+	EnableRcon   bool
+	RconPort     int
+	RconPassword string
+	// EnableQuery and QueryPort let the server status be polled without
+	// RCON
+	EnableQuery bool
+	QueryPort   int
+}
+
+// serverPropertyKeys are the settings that are read from server.properties
+// when they aren't in the config file.
+var serverPropertyKeys = []string{
+	"Minecraft.RconPort",
+	"Minecraft.RconPassword",
+	"Minecraft.LogFilePath",
+}
+
+// ServerPropertiesPath gets the path of the server.properties file in the
+// server directory of a config, or an empty string if no server directory is
+// set.
+func ServerPropertiesPath(conf RootConfig) string {
+	if conf.Minecraft.ServerDir == "" {
+		return ""
+	}
+	return filepath.Join(conf.Minecraft.ServerDir, "server.properties")
+}
+
+// ServerProperties gets the server.properties settings that were read when
+// the config was last loaded. If no server directory is set, false is
+// returned.
+func (f *File) ServerProperties() (ServerProperties, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.serverProps == nil {
+		return ServerProperties{}, false
+	}
+	return *f.serverProps, true
+}
+
+// applyServerProperties sets the settings that aren't set, according to
+// isSet, to their values from the server.properties file in the server
+// directory, so settings in the config file or the environment always win.
+// The settings that were set are returned along with the server.properties
+// settings, which are nil if no server directory is set.
+func applyServerProperties(conf *RootConfig, isSet func(key string) bool) (*ServerProperties, []Override, error) {
+	path := ServerPropertiesPath(*conf)
+	if path == "" {
+		return nil, nil, nil
+	}
+
+	props, err := ReadServerProperties(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to read the server.properties of Minecraft.ServerDir: %s", err)
+	}
+
+	applied := make([]Override, 0)
+	m := &conf.Minecraft
+	if !isSet("Minecraft.RconPort") {
+		m.RconPort = props.RconPort
+		applied = append(applied, Override{Key: "Minecraft.RconPort", File: path})
+	}
+	if !isSet("Minecraft.RconPassword") {
+		m.RconPassword = props.RconPassword
+		applied = append(applied, Override{Key: "Minecraft.RconPassword", File: path})
+	}
+	if !isSet("Minecraft.LogFilePath") {
+		m.LogFilePath = filepath.Join(m.ServerDir, "logs", "latest.log")
+		applied = append(applied, Override{Key: "Minecraft.LogFilePath", File: m.ServerDir})
+	}
+
+	return &props, applied, nil
+}
+
+// ReadServerProperties reads the settings Dolphin uses from a
+// server.properties file. Settings that aren't in the file get the same
+// defaults the server uses.
+func ReadServerProperties(path string) (ServerProperties, error) {
+	props := ServerProperties{
+		RconPort:  25575,
+		QueryPort: 25565,
+	}
+
+	file, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return props, err
+	}
+	defer file.Close()
+
+	values, err := parseProperties(file)
+	if err != nil {
+		return props, err
+	}
+
+	if value, ok := values["enable-rcon"]; ok {
+		props.EnableRcon, _ = strconv.ParseBool(value)
+	}
+	if value, ok := values["rcon.port"]; ok {
+		if port, err := strconv.Atoi(value); err == nil {
+			props.RconPort = port
+		}
+	}
+	props.RconPassword = values["rcon.password"]
+	if value, ok := values["enable-query"]; ok {
+		props.EnableQuery, _ = strconv.ParseBool(value)
+	}
+	if value, ok := values["query.port"]; ok {
+		if port, err := strconv.Atoi(value); err == nil {
+			props.QueryPort = port
+		}
+	}
+
+	return props, nil
+}
+
+// parseProperties parses a Java properties file, such as server.properties.
+func parseProperties(file *os.File) (map[string]string, error) {
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimLeft(scanner.Text(), " \t\f")
+
+		// Skip blank lines and comments
+		if line == "" || line[0] == '#' || line[0] == '!' {
+			continue
+		}
+
+		// Find the end of the key, skipping escaped characters
+		end := len(line)
+		for i := 0; i < len(line); i++ {
+			if line[i] == '\\' {
+				i++
+			} else if line[i] == '=' || line[i] == ':' {
+				end = i
+				break
+			}
+		}
+
+		key := unescapeProperty(strings.TrimSpace(line[:end]))
+		value := ""
+		if end < len(line) {
+			value = unescapeProperty(strings.TrimLeft(line[end+1:], " \t\f"))
+		}
+		values[key] = value
+	}
+
+	return values, scanner.Err()
+}
+
+// unescapeProperty removes the escapes from a key or value in a properties
+// file, such as \: and \u00e9.
+func unescapeProperty(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+
+	b := strings.Builder{}
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		i++
+		switch s[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 'r':
+			b.WriteByte('\r')
+		case 'u':
+			if i+4 < len(s) {
+				if r, err := strconv.ParseUint(s[i+1:i+5], 16, 32); err == nil {
+					b.WriteRune(rune(r))
+					i += 4
+					continue
+				}
+			}
+			b.WriteByte('u')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
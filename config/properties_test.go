@@ -0,0 +1,189 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestReadServerProperties(t *testing.T) {
+	// given
+	dir, err := ioutil.TempDir("", "dolphin-testing")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "server.properties")
+	contents := `#Minecraft server properties
+#Sat Oct 17 12:00:00 UTC 2020
+enable-rcon=true
+rcon.port=25580
+rcon.password=p\=ss\:word
+motd=A Minecraft Server é
+enable-query = true
+query.port : 25566
+`
+	if err := ioutil.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatalf("Failed to write server.properties: %s", err)
+	}
+	expected := ServerProperties{
+		EnableRcon:   true,
+		RconPort:     25580,
+		RconPassword: "p=ss:word",
+		EnableQuery:  true,
+		QueryPort:    25566,
+	}
+
+	// when
+	actual, err := ReadServerProperties(path)
+
+	// then
+	if err != nil {
+		t.Fatalf("Failed to read server.properties: %s", err)
+	}
+	if !cmp.Equal(actual, expected) {
+		t.Errorf("Server properties are incorrect: Diff: %s", cmp.Diff(actual, expected))
+	}
+}
+
+func TestLoadServerProperties(t *testing.T) {
+	// given
+	dir, err := ioutil.TempDir("", "dolphin-testing")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	props := "enable-rcon=false\nrcon.port=25580\nrcon.password=hunter2\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "server.properties"), []byte(props), 0600); err != nil {
+		t.Fatalf("Failed to write server.properties: %s", err)
+	}
+	path := filepath.Join(dir, "dolphin.conf")
+	file, err := NewFile(path)
+	if err != nil {
+		t.Fatalf("Failed to create config file: %s", err)
+	}
+	contents := "[Minecraft]\nRconIP = \"localhost\"\nRconPassword = \"explicit\"\nServerDir = " + fmt.Sprintf("%q", dir) + "\n"
+	if err := ioutil.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatalf("Failed to write config file: %s", err)
+	}
+
+	// when
+	conf, err := file.Load()
+
+	// then
+	if err != nil {
+		t.Fatalf("Failed to load config: %s", err)
+	}
+	if conf.Minecraft.RconPort != 25580 {
+		t.Errorf("RCON port should be read from server.properties, got: %d", conf.Minecraft.RconPort)
+	}
+	if conf.Minecraft.RconPassword != "explicit" {
+		t.Errorf("RCON password in the config should win, got: %s", conf.Minecraft.RconPassword)
+	}
+	if conf.Minecraft.LogFilePath != filepath.Join(dir, "logs", "latest.log") {
+		t.Errorf("Log file path should be in the server directory, got: %s", conf.Minecraft.LogFilePath)
+	}
+	if props, ok := file.ServerProperties(); !ok || props.EnableRcon {
+		t.Errorf("Server properties were not kept, got: %+v", props)
+	}
+	if o, ok := file.Overridden("Minecraft.RconPort"); !ok || o.File != filepath.Join(dir, "server.properties") {
+		t.Errorf("Override was not recorded, got: %+v", o)
+	}
+	if _, ok := file.Overridden("Minecraft.RconPassword"); ok {
+		t.Errorf("RCON password should not be overridden")
+	}
+}
+
+func TestLoadServerPropertiesWithDefaults(t *testing.T) {
+	// given
+	dir, err := ioutil.TempDir("", "dolphin-testing")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "server.properties"), []byte("enable-rcon=true\nrcon.port=30000\n"), 0600); err != nil {
+		t.Fatalf("Failed to write server.properties: %s", err)
+	}
+	path := filepath.Join(dir, "dolphin.conf")
+	file, err := NewFile(path)
+	if err != nil {
+		t.Fatalf("Failed to create config file: %s", err)
+	}
+	if err := ioutil.WriteFile(path, []byte("[Minecraft]\nServerDir = "+fmt.Sprintf("%q", dir)+"\n"), 0600); err != nil {
+		t.Fatalf("Failed to write config file: %s", err)
+	}
+
+	// when
+	conf, err := file.Load()
+
+	// then
+	if err != nil {
+		t.Fatalf("Failed to load config: %s", err)
+	}
+	if conf.Minecraft.RconPort != 30000 {
+		t.Errorf("RCON port should be read from server.properties, got: %d", conf.Minecraft.RconPort)
+	}
+	if conf.Minecraft.LogFilePath != filepath.Join(dir, "logs", "latest.log") {
+		t.Errorf("Log file path should be in the server directory, got: %s", conf.Minecraft.LogFilePath)
+	}
+	saved, _ := ioutil.ReadFile(path)
+	if strings.Contains(string(saved), "RconPort") || strings.Contains(string(saved), "LogFilePath") {
+		t.Errorf("Defaults were added for settings read from server.properties:\n%s", saved)
+	}
+	if !strings.Contains(string(saved), "RconIP") {
+		t.Errorf("Other defaults should still be added:\n%s", saved)
+	}
+}
+
+func TestSaveConfigSkipsServerProperties(t *testing.T) {
+	// given
+	dir, err := ioutil.TempDir("", "dolphin-testing")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "server.properties"), []byte("rcon.port=25580\nrcon.password=hunter2\n"), 0600); err != nil {
+		t.Fatalf("Failed to write server.properties: %s", err)
+	}
+	path := filepath.Join(dir, "dolphin.conf")
+	file, err := NewFile(path)
+	if err != nil {
+		t.Fatalf("Failed to create config file: %s", err)
+	}
+	contents := "[Minecraft]\nLogFilePath = \"/var/log/latest.log\"\nServerDir = " + fmt.Sprintf("%q", dir) + "\n"
+	if err := ioutil.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatalf("Failed to write config file: %s", err)
+	}
+	conf, err := file.Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %s", err)
+	}
+	conf.Minecraft.RconPort = 25590
+
+	// when
+	err = file.Save(&conf)
+
+	// then
+	if err != nil {
+		t.Fatalf("Failed to save config: %s", err)
+	}
+	saved, _ := ioutil.ReadFile(path)
+	if strings.Contains(string(saved), "hunter2") {
+		t.Errorf("Password from server.properties was saved:\n%s", saved)
+	}
+	if !strings.Contains(string(saved), "RconPort = 25590") {
+		t.Errorf("Changed setting was not saved:\n%s", saved)
+	}
+	if !strings.Contains(string(saved), `LogFilePath = "/var/log/latest.log"`) {
+		t.Errorf("Log file path in the config should be kept:\n%s", saved)
+	}
+}
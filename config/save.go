@@ -0,0 +1,215 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pelletier/go-toml"
+)
+
+// backupSuffix is added to the name of the config file to get the name of the
+// backup of the previous version of the file.
+const backupSuffix = ".bak"
+
+// updateDocument changes a config document to match a config. Only the
+// settings that are different are changed, so comments and ordering are
+// kept everywhere else.
+func updateDocument(doc *Document, conf RootConfig) error {
+	tree, err := toml.LoadBytes(doc.Bytes())
+	if err != nil {
+		return err
+	}
+	var current RootConfig
+	if err := tree.Unmarshal(&current); err != nil {
+		return err
+	}
+
+	var updateErr error
+	walkFields(reflect.ValueOf(conf), "", func(key string, field reflect.StructField, value reflect.Value) {
+		if updateErr != nil {
+			return
+		}
+
+		// Skip settings that haven't changed
+		if treeHasKey(tree, key) && reflect.DeepEqual(fieldByKey(reflect.ValueOf(current), key).Interface(), value.Interface()) {
+			return
+		}
+
+		if value.Kind() == reflect.Ptr {
+			if value.IsNil() {
+				return
+			}
+			value = value.Elem()
+		}
+
+		table, name := "", key
+		if dot := strings.LastIndex(key, "."); dot >= 0 {
+			table, name = key[:dot], key[dot+1:]
+		}
+
+		var err error
+		if value.Kind() == reflect.Map {
+			err = setTable(doc, key, value, field.Tag.Get("comment"))
+			if current := fieldByKey(reflect.ValueOf(current), key); err == nil && current.Kind() == reflect.Map {
+				// Remove the keys that were taken out of the map
+				for _, k := range current.MapKeys() {
+					if !value.MapIndex(k).IsValid() {
+						doc.Delete(key, k.String())
+					}
+				}
+			}
+		} else if value.Kind() == reflect.Slice && value.Type().Elem().Kind() == reflect.Struct {
+			var tables [][]string
+			if tables, err = encodeTables(value); err == nil {
+				err = doc.setArray(key, tables)
+			}
+		} else {
+			var raw string
+			if raw, err = encodeValue(value.Interface()); err == nil {
+				err = doc.set(table, name, raw, field.Tag.Get("comment"))
+			}
+		}
+		if err != nil {
+			updateErr = fmt.Errorf("unable to save %s: %s", key, err)
+		}
+	})
+
+	return updateErr
+}
+
+// setTable writes the keys and values of a map as a table, adding the table
+// with its comment if it doesn't exist. Keys are written in order, so new
+// ones are always added in the same place.
+func setTable(doc *Document, table string, m reflect.Value, comment string) error {
+	if err := doc.AddTable(table, comment); err != nil {
+		return err
+	}
+
+	keys := m.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].String() < keys[j].String()
+	})
+	for _, k := range keys {
+		raw, err := encodeValue(m.MapIndex(k).Interface())
+		if err != nil {
+			return err
+		}
+		if err := doc.set(table, k.String(), raw, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeTables encodes a slice of structs as the lines of keys and values of
+// each table in an array of tables.
+func encodeTables(slice reflect.Value) ([][]string, error) {
+	tables := make([][]string, 0, slice.Len())
+	for i := 0; i < slice.Len(); i++ {
+		lines := make([]string, 0)
+		var err error
+		walkFields(slice.Index(i), "", func(key string, _ reflect.StructField, value reflect.Value) {
+			if value.Kind() == reflect.Ptr {
+				if value.IsNil() {
+					return
+				}
+				value = value.Elem()
+			}
+
+			var raw string
+			if raw, err = encodeValue(value.Interface()); err == nil {
+				lines = append(lines, formatTable(key)+" = "+raw)
+			}
+		})
+		if err != nil {
+			return tables, err
+		}
+
+		tables = append(tables, lines)
+	}
+	return tables, nil
+}
+
+// treeHasKey checks if a tree has a setting, given as its dotted key.
+func treeHasKey(tree *toml.Tree, key string) bool {
+	parts := strings.Split(key, ".")
+	for _, part := range parts[:len(parts)-1] {
+		if tree = subTree(tree, part); tree == nil {
+			return false
+		}
+	}
+	return hasKey(tree, parts[len(parts)-1])
+}
+
+// writeFile replaces the contents of a file without leaving it half written
+// if Dolphin crashes, by writing to a temporary file and renaming it over the
+// old one. The old contents are kept in a backup file.
+func writeFile(path string, data []byte) error {
+	mode := os.FileMode(0640)
+	old, err := ioutil.ReadFile(filepath.Clean(path))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	if err == nil {
+		if bytes.Equal(old, data) {
+			return nil
+		}
+		if info, err := os.Stat(path); err == nil {
+			mode = info.Mode().Perm()
+		}
+
+		// Keep the previous version of the file
+		if len(old) > 0 {
+			if err := replaceFile(path+backupSuffix, old, mode); err != nil {
+				return fmt.Errorf("unable to back up the config file: %s", err)
+			}
+		}
+	}
+
+	return replaceFile(path, data, mode)
+}
+
+// replaceFile atomically replaces a file with new contents.
+func replaceFile(path string, data []byte, mode os.FileMode) error {
+	dir := filepath.Dir(path)
+	tmp, err := ioutil.TempFile(dir, "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	// Clean up if something goes wrong before the rename
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	// Make sure the rename is written to disk. This isn't supported on every
+	// platform, so errors are ignored.
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
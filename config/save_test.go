@@ -0,0 +1,207 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pelletier/go-toml"
+)
+
+// setupSave creates a config file with some contents in a temporary
+// directory, and loads it. The returned function removes the directory.
+func setupSave(t *testing.T, contents string) (*File, RootConfig, string, func()) {
+	dir, err := ioutil.TempDir("", "dolphin-testing")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %s", err)
+	}
+
+	path := filepath.Join(dir, "dolphin.conf")
+	if err := ioutil.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatalf("Failed to write config: %s", err)
+	}
+	file, err := NewFile(path)
+	if err != nil {
+		t.Fatalf("Failed to set config path: %s", err)
+	}
+
+	conf, err := file.Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %s", err)
+	}
+
+	return file, conf, path, func() { os.RemoveAll(dir) }
+}
+
+func TestSaveConfigKeepsComments(t *testing.T) {
+	// given
+	file, conf, path, cleanup := setupSave(t, `# My server's bridge
+[Discord]
+  ChannelID = "1234" # #minecraft
+  # Ping people from Minecraft
+  AllowMentions = true
+`)
+	defer cleanup()
+	before, _ := ioutil.ReadFile(path)
+
+	// when
+	conf.Discord.AllowMentions = false
+	conf.Minecraft.RconPort = 25576
+	err := file.Save(&conf)
+
+	// then
+	if err != nil {
+		t.Fatalf("Failed to save config: %s", err)
+	}
+
+	saved, _ := ioutil.ReadFile(path)
+	expected := strings.Replace(string(before), "AllowMentions = true", "AllowMentions = false", 1)
+	expected = strings.Replace(expected, "RconPort = 25575", "RconPort = 25576", 1)
+	if string(saved) != expected {
+		t.Errorf("Only the changed settings should be saved: Diff: %s", cmp.Diff(string(saved), expected))
+	}
+
+	backup, _ := ioutil.ReadFile(path + backupSuffix)
+	if string(backup) != string(before) {
+		t.Errorf("Backup should have the previous contents: Diff: %s", cmp.Diff(string(backup), string(before)))
+	}
+
+	files, _ := ioutil.ReadDir(filepath.Dir(path))
+	if len(files) != 2 {
+		t.Errorf("Temporary files were left behind: %d files", len(files))
+	}
+}
+
+func TestSaveConfigArrayOfTables(t *testing.T) {
+	// given
+	file, conf, path, cleanup := setupSave(t, `[Discord]
+  [Discord.commands]
+    prefix = "?"
+
+    [[Discord.commands.permissions]]
+      command = "rcon"
+      roles = ["1"]
+
+    [[Discord.commands.permissions]]
+      command = "config"
+      roles = ["2"]
+
+[Minecraft]
+  # Where the server is
+  RconIP = "mc.example.com"
+`)
+	defer cleanup()
+	permissions := []PermissionConfig{{Command: "list", Roles: []string{"3"}, Users: []string{}, Channels: []string{}, Permission: "ManageGuild"}}
+
+	// when
+	conf.Discord.Commands.Permissions = &permissions
+	err := file.Save(conf)
+
+	// then
+	if err != nil {
+		t.Fatalf("Failed to save config: %s", err)
+	}
+
+	loaded, err := file.Load()
+	if err != nil {
+		t.Fatalf("Failed to load saved config: %s", err)
+	}
+	if !cmp.Equal(*loaded.Discord.Commands.Permissions, permissions) {
+		t.Errorf("Permissions were not saved: Diff: %s", cmp.Diff(*loaded.Discord.Commands.Permissions, permissions))
+	}
+	if loaded.Discord.Commands.Prefix != "?" || loaded.Minecraft.RconIP != "mc.example.com" {
+		t.Errorf("Other settings should be kept: %+v", loaded)
+	}
+
+	saved, _ := ioutil.ReadFile(path)
+	if !strings.Contains(string(saved), "# Where the server is") {
+		t.Errorf("Comments should be kept:\n%s", saved)
+	}
+}
+
+func TestSaveConfigRoutes(t *testing.T) {
+	// given
+	file, conf, path, cleanup := setupSave(t, `[Routes]
+  # Keep IRC quiet
+  minecraft = ["discord"]
+  irc = ["discord"]
+`)
+	defer cleanup()
+	routes := RouteConfig{"minecraft": {"discord", "slack"}, "slack": {"minecraft"}}
+
+	// when
+	conf.Routes = routes
+	err := file.Save(conf)
+
+	// then
+	if err != nil {
+		t.Fatalf("Failed to save config: %s", err)
+	}
+
+	loaded, err := file.Load()
+	if err != nil {
+		t.Fatalf("Failed to load saved config: %s", err)
+	}
+	if !cmp.Equal(loaded.Routes, routes) {
+		t.Errorf("Routes were not saved: Diff: %s", cmp.Diff(loaded.Routes, routes))
+	}
+
+	saved, _ := ioutil.ReadFile(path)
+	if !strings.Contains(string(saved), "# Keep IRC quiet") {
+		t.Errorf("Comments should be kept:\n%s", saved)
+	}
+}
+
+func TestLoadAddsDefaultRoutes(t *testing.T) {
+	// given
+	_, conf, path, cleanup := setupSave(t, "[Discord]\n  ChannelID = \"1234\"\n")
+	defer cleanup()
+
+	// then
+	if !cmp.Equal(conf.Routes, defaultRouteConfig()) {
+		t.Errorf("Default routes were not used: Diff: %s", cmp.Diff(conf.Routes, defaultRouteConfig()))
+	}
+	saved, _ := ioutil.ReadFile(path)
+	tree, err := toml.LoadBytes(saved)
+	if err != nil {
+		t.Fatalf("Saved config is invalid: %s\n%s", err, saved)
+	}
+	if !tree.Has("Routes.irc") {
+		t.Errorf("Default routes were not added to the file:\n%s", saved)
+	}
+}
+
+func TestSaveConfigConcurrently(t *testing.T) {
+	// given
+	file, conf, path, cleanup := setupSave(t, "")
+	defer cleanup()
+
+	// when
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			c := conf
+			c.Discord.ChannelID = fmt.Sprint(i)
+			if err := file.Save(c); err != nil {
+				t.Errorf("Failed to save config: %s", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// then
+	saved, _ := ioutil.ReadFile(path)
+	if _, err := toml.LoadBytes(saved); err != nil {
+		t.Errorf("Config file is corrupt: %s\n%s", err, saved)
+	}
+	if strings.Count(string(saved), "ChannelID =") != 1 {
+		t.Errorf("Config file should have one channel:\n%s", saved)
+	}
+}
@@ -0,0 +1,151 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ErrSecretSetting is returned when trying to change a secret setting from
+// Discord.
+var ErrSecretSetting = errors.New("secret settings can only be changed in the config file")
+
+// ErrReadOnlySetting is returned when trying to change a read-only setting
+// from Discord.
+var ErrReadOnlySetting = errors.New("read-only settings can only be changed in the config file")
+
+// redacted is shown instead of the value of a secret setting.
+const redacted = "[redacted]"
+
+// Setting is a setting that can be shown and changed with the config
+// command. Settings are described by the struct tags of the config types:
+// `secret:"true"` settings, such as tokens and passwords, are never shown or
+// changed, `option` gives a setting a short name, `discord:"channel"` marks
+// the ID of a Discord channel, and `discord:"readonly"` settings, such as
+// hosts and file paths, are shown but never changed.
+type Setting struct {
+	// Key is the path of the setting in the config file
+	Key string
+	// Name is the name of the setting in the config command
+	Name string
+	// Alias is a short name for the setting, or empty
+	Alias string
+	// Desc is the comment of the setting in the config file
+	Desc string
+	// Secret settings are always redacted
+	Secret bool
+	// Channel settings hold the ID of a Discord channel
+	Channel bool
+	// ReadOnly settings can only be changed in the config file
+	ReadOnly bool
+	// Type is the type of the value of the setting
+	Type reflect.Type
+}
+
+// Settings gets every setting in the config, in the order they are in the
+// config file. Arrays of tables, such as the command permissions, and the
+// routes can only be changed in the file, and are left out.
+func Settings() []Setting {
+	settings := make([]Setting, 0)
+	walkFields(reflect.ValueOf(RootConfig{}), "", func(key string, field reflect.StructField, _ reflect.Value) {
+		t := field.Type
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if key == versionKey || t.Kind() == reflect.Map || (t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Struct) {
+			return
+		}
+
+		settings = append(settings, Setting{
+			Key:      key,
+			Name:     strings.ToLower(strings.TrimPrefix(key, "Discord.")),
+			Alias:    field.Tag.Get("option"),
+			Desc:     strings.ReplaceAll(field.Tag.Get("comment"), "\n", " "),
+			Secret:   field.Tag.Get("secret") == "true",
+			Channel:  field.Tag.Get("discord") == "channel",
+			ReadOnly: field.Tag.Get("discord") == "readonly",
+			Type:     t,
+		})
+	})
+	return settings
+}
+
+// FindSetting finds a setting by its name or alias, ignoring case.
+func FindSetting(name string) (Setting, bool) {
+	for _, setting := range Settings() {
+		if strings.EqualFold(setting.Name, name) || (setting.Alias != "" && strings.EqualFold(setting.Alias, name)) {
+			return setting, true
+		}
+	}
+	return Setting{}, false
+}
+
+// Value gets the value of the setting in a config.
+func (s Setting) Value(conf RootConfig) interface{} {
+	value := fieldByKey(reflect.ValueOf(conf), s.Key)
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return reflect.Zero(s.Type).Interface()
+		}
+		value = value.Elem()
+	}
+	return value.Interface()
+}
+
+// Display formats the value of the setting in a config to be shown to
+// users. Secret settings are always redacted.
+func (s Setting) Display(conf RootConfig) string {
+	value := s.Value(conf)
+	if s.Secret {
+		if reflect.ValueOf(value).IsZero() {
+			return "(not set)"
+		}
+		return redacted
+	}
+
+	switch v := value.(type) {
+	case string:
+		if v == "" {
+			return "(empty)"
+		}
+		return v
+	case []string:
+		if len(v) == 0 {
+			return "(none)"
+		}
+		return strings.Join(v, ", ")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// Set changes the setting in a config. The value must be the same kind of
+// value as the setting, such as a string or bool. Secret and read-only
+// settings can't be changed.
+func (s Setting) Set(conf *RootConfig, value interface{}) error {
+	if s.Secret {
+		return ErrSecretSetting
+	}
+	if s.ReadOnly {
+		return ErrReadOnlySetting
+	}
+
+	v := reflect.ValueOf(value)
+	if !v.IsValid() || v.Kind() != s.Type.Kind() || !v.Type().ConvertibleTo(s.Type) {
+		return fmt.Errorf("%s can't be set to a %T", s.Name, value)
+	}
+
+	field := fieldByKey(reflect.ValueOf(conf).Elem(), s.Key)
+	if field.Kind() == reflect.Ptr {
+		// Replace pointers instead of changing what they point to, because
+		// other copies of the config may share them
+		ptr := reflect.New(s.Type)
+		ptr.Elem().Set(v.Convert(s.Type))
+		field.Set(ptr)
+		return nil
+	}
+
+	field.Set(v.Convert(s.Type))
+	return nil
+}
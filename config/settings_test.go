@@ -0,0 +1,134 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSettings(t *testing.T) {
+	// when
+	settings := Settings()
+
+	// then
+	names := make(map[string]Setting, len(settings))
+	for _, setting := range settings {
+		names[setting.Name] = setting
+	}
+	if _, ok := names[versionKey]; ok {
+		t.Errorf("Config version should not be a setting")
+	}
+	if _, ok := names["commands.permissions"]; ok {
+		t.Errorf("Arrays of tables should not be settings")
+	}
+	for _, secret := range []string{"bottoken", "webhook.url", "minecraft.rconpassword"} {
+		if !names[secret].Secret {
+			t.Errorf("'%s' should be secret", secret)
+		}
+	}
+	if !names["console.channel_id"].Channel {
+		t.Errorf("Console channel should be a channel setting")
+	}
+}
+
+func TestFindSettingByAlias(t *testing.T) {
+	// when
+	setting, ok := FindSetting("ShowDeaths")
+
+	// then
+	if !ok || setting.Key != "Discord.message_options.show_deaths" {
+		t.Errorf("Setting was not found by its alias, got: %+v", setting)
+	}
+}
+
+func TestSetSetting(t *testing.T) {
+	// given
+	conf := MergeDefaults(RootConfig{})
+	original := conf
+	levels, _ := FindSetting("console.levels")
+	port, _ := FindSetting("minecraft.rconport")
+	token, _ := FindSetting("bottoken")
+
+	// when
+	levelsErr := levels.Set(&conf, []string{"WARN"})
+	portErr := port.Set(&conf, 25576)
+	tokenErr := token.Set(&conf, "new-token")
+
+	// then
+	if levelsErr != nil || portErr != nil {
+		t.Fatalf("Failed to set settings: %v %v", levelsErr, portErr)
+	}
+	if !cmp.Equal(*conf.Discord.Console.Levels, []string{"WARN"}) || conf.Minecraft.RconPort != 25576 {
+		t.Errorf("Settings were not changed: %+v", conf)
+	}
+	if len(*original.Discord.Console.Levels) != 0 {
+		t.Errorf("Copies of the config should not be changed")
+	}
+	if tokenErr != ErrSecretSetting || conf.Discord.BotToken != "" {
+		t.Errorf("Secret settings should not be changed, got: %v", tokenErr)
+	}
+	if err := port.Set(&conf, "25577"); err == nil {
+		t.Errorf("Settings should only be set to the right type")
+	}
+}
+
+func TestDisplaySetting(t *testing.T) {
+	// given
+	conf := MergeDefaults(RootConfig{})
+	conf.Discord.Webhook.URL = "https://discord.com/api/webhooks/1234/token"
+	url, _ := FindSetting("webhook.url")
+	token, _ := FindSetting("bottoken")
+	levels, _ := FindSetting("console.levels")
+
+	// then
+	if display := url.Display(conf); display != redacted {
+		t.Errorf("Webhook URL should be redacted, got: %s", display)
+	}
+	if display := token.Display(conf); display != "(not set)" {
+		t.Errorf("Empty secrets should be shown as not set, got: %s", display)
+	}
+	if display := levels.Display(conf); display != "(none)" {
+		t.Errorf("Empty lists should be shown as none, got: %s", display)
+	}
+}
+
+func TestReadOnlySettings(t *testing.T) {
+	// given
+	conf := MergeDefaults(RootConfig{})
+	conf.Minecraft.RconIP = "localhost"
+	conf.Discord.Commands.Rcon.AuditLog = "rcon.log"
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"minecraft.rconip", "attacker.example.com"},
+		{"irc.server", "attacker.example.com:6667"},
+		{"commands.rcon.audit_log", "/home/minecraft/.ssh/authorized_keys"},
+		{"whitelist.file_path", "/etc/passwd"},
+		{"moderation.ban_list_path", "/etc/passwd"},
+		{"minecraft.logfilepath", "/etc/passwd"},
+		{"minecraft.serverdir", "/"},
+		{"storage.path", "/tmp/dolphin.db"},
+	}
+
+	for _, test := range tests {
+		setting, ok := FindSetting(test.name)
+		if !ok {
+			t.Fatalf("Setting '%s' was not found", test.name)
+		}
+
+		// when
+		err := setting.Set(&conf, test.value)
+
+		// then
+		if !setting.ReadOnly {
+			t.Errorf("'%s' should be read-only", test.name)
+		}
+		if err != ErrReadOnlySetting {
+			t.Errorf("'%s' should not be changed, got: %v", test.name, err)
+		}
+	}
+	if conf.Minecraft.RconIP != "localhost" || conf.Discord.Commands.Rcon.AuditLog != "rcon.log" {
+		t.Errorf("Read-only settings were changed: %+v", conf.Minecraft)
+	}
+}
@@ -2,40 +2,210 @@ package config
 
 // RootConfig is our root config struct.
 type RootConfig struct {
-	Discord   DiscordConfig
-	Minecraft MinecraftConfig
+	ConfigVersion int `toml:"config_version" comment:"The version of this config file. Don't change this!"`
+	Discord       DiscordConfig
+	Minecraft     MinecraftConfig
+	IRC           IRCConfig   `comment:"Bridge an IRC channel too"`
+	Routes        RouteConfig `comment:"Where messages from each source are sent, by the name of the source.\nThe built-in sources and sinks are minecraft, discord, and irc."`
+	Storage       StorageConfig
 }
 
 // DiscordConfig holds all settings for the Discord side of the application.
 type DiscordConfig struct {
-	BotToken       string
-	ChannelID      string
+	BotToken       string `secret:"true"`
+	ChannelID      string `option:"channel" discord:"channel"`
 	AllowMentions  bool
 	UseMemberNicks bool
-	MessageOptions MessageConfig `toml:"message_options" comment:"Toggle whether certain messages are sent to the Discord channel"`
+	MessageOptions MessageConfig    `toml:"message_options" comment:"Toggle whether certain messages are sent to the Discord channel"`
+	Mentions       MentionConfig    `toml:"mentions" comment:"Control which Discord mentions players in Minecraft are able to use"`
+	Embeds         EmbedConfig      `toml:"embeds" comment:"Send Minecraft events to Discord as rich embeds instead of plain text.\nChat messages are always sent as plain text."`
+	Status         StatusConfig     `toml:"status" comment:"Show the live status of the Minecraft server in Discord"`
+	Commands       CommandConfig    `toml:"commands" comment:"Control how bot commands are used in Discord"`
+	Console        ConsoleConfig    `toml:"console" comment:"Mirror the server log to a Discord channel, and run commands typed there"`
+	Whitelist      WhitelistConfig  `toml:"whitelist" comment:"Manage the server whitelist from Discord"`
+	Moderation     ModerationConfig `toml:"moderation" comment:"Kick and ban players from Discord"`
+	Linking        LinkingConfig    `toml:"linking" comment:"Let players link their Minecraft and Discord accounts"`
+	Digest         DigestConfig     `toml:"digest" comment:"Post a weekly summary of player statistics to the Discord channel"`
 	Webhook        WebhookConfig
 }
 
+// Mention policies that control which mentions in messages from Minecraft
+// will actually notify someone in Discord. Everyone and here mentions are
+// never allowed.
+const (
+	MentionPolicyNone  = "none"
+	MentionPolicyUsers = "users"
+	MentionPolicyRoles = "roles"
+)
+
+// MentionConfig holds settings for mentions in messages sent to Discord from Minecraft.
+type MentionConfig struct {
+	Policy       string    `toml:"policy" comment:"Which mentions are allowed: none, users, or roles (users and the allowed roles)"`
+	AllowedRoles *[]string `toml:"allowed_roles" comment:"IDs of the roles that can be mentioned when the policy is roles"`
+}
+
 // MessageConfig holds settings for the messages that should be sent to Discord from Minecraft
 type MessageConfig struct {
-	ShowAdvancements bool `toml:"show_advancements"`
-	ShowDeaths       bool `toml:"show_deaths"`
-	ShowJoinsLeaves  bool `toml:"show_joins_and_leaves"`
+	ShowAdvancements bool `toml:"show_advancements" option:"showadvancements"`
+	ShowDeaths       bool `toml:"show_deaths" option:"showdeaths"`
+	ShowJoinsLeaves  bool `toml:"show_joins_and_leaves" option:"showjoinleave"`
+}
+
+// EmbedConfig holds the embed style for each type of Minecraft event.
+type EmbedConfig struct {
+	Advancements EmbedStyleConfig `toml:"advancements"`
+	Deaths       EmbedStyleConfig `toml:"deaths"`
+	JoinsLeaves  EmbedStyleConfig `toml:"joins_and_leaves"`
+	ServerStatus EmbedStyleConfig `toml:"server_status"`
+}
+
+// EmbedStyleConfig holds how a type of Minecraft event looks as a Discord embed.
+type EmbedStyleConfig struct {
+	Enabled   bool   `toml:"enabled"`
+	Color     string `toml:"color" comment:"Hex color code of the embed, e.g. #4caf50"`
+	Thumbnail bool   `toml:"thumbnail" comment:"Show the player's head as the embed thumbnail"`
+	Timestamp bool   `toml:"timestamp"`
+}
+
+// StatusConfig holds settings for showing the live server status in the bot's
+// presence and the channel topic.
+type StatusConfig struct {
+	ServerName     string `toml:"server_name" comment:"Name of the server shown in the bot's presence"`
+	UpdatePresence bool   `toml:"update_presence" comment:"Show the number of online players in the bot's presence"`
+	UpdateTopic    bool   `toml:"update_topic" comment:"Keep the channel topic updated with the server status. Requires the Manage Channels permission."`
+	TopicTemplate  string `toml:"topic_template" comment:"Placeholders: %online%, %max%, %uptime%, %tps%"`
+	PollInterval   int    `toml:"poll_interval" comment:"How often to poll the server over RCON, in seconds"`
+}
+
+// ConsoleConfig holds settings for the console channel, which shows the
+// server log and runs server commands typed in it.
+type ConsoleConfig struct {
+	Enabled       bool      `toml:"enabled"`
+	ChannelID     string    `toml:"channel_id" discord:"channel" comment:"ID of the channel to use as the console. This should not be the chat channel."`
+	Levels        *[]string `toml:"levels" comment:"Only show log lines with these levels, such as INFO, WARN, or ERROR. Leave empty for all levels."`
+	Loggers       *[]string `toml:"loggers" comment:"Only show log lines from these loggers or threads, such as \"Server thread\". Leave empty for all."`
+	Include       string    `toml:"include" comment:"Only show log lines matching this regular expression. Leave empty to disable."`
+	Exclude       string    `toml:"exclude" comment:"Don't show log lines matching this regular expression. Leave empty to disable."`
+	BatchInterval int       `toml:"batch_interval" comment:"How often to send log lines to Discord, in seconds. Lines are dropped if the log is too busy to keep up."`
+	RunCommands   bool      `toml:"run_commands" comment:"Run messages typed in the console channel as server commands.\nThis uses the same permissions and rules as the rcon command."`
+}
+
+// WhitelistConfig holds settings for the whitelist command.
+type WhitelistConfig struct {
+	FilePath         string `toml:"file_path" discord:"readonly" comment:"Path to the server's whitelist.json file"`
+	AllowRequests    bool   `toml:"allow_requests" comment:"Let anyone request to be whitelisted with the whitelist request command"`
+	RequestChannelID string `toml:"request_channel_id" discord:"channel" comment:"ID of the channel to post whitelist requests in for moderators to approve.\nLeave empty to use the audit channel."`
+}
+
+// ModerationConfig holds settings for the moderation commands.
+type ModerationConfig struct {
+	LogChannelID string `toml:"log_channel_id" discord:"channel" comment:"ID of the channel to post kicks and bans in. Leave empty to use the audit channel."`
+	BanListPath  string `toml:"ban_list_path" discord:"readonly" comment:"Path to the server's banned-players.json file"`
+}
+
+// LinkingConfig holds settings for linking Minecraft and Discord accounts.
+type LinkingConfig struct {
+	Enabled        bool              `toml:"enabled"`
+	UseLinkedNames bool              `toml:"use_linked_names" comment:"Show the Minecraft name of linked users in game, and let their mentions work both ways"`
+	RoleSync       *[]RoleSyncConfig `toml:"role_sync" comment:"Server commands to run when a linked player gains or loses a Discord role.\nThis needs the Server Members intent to be enabled for the bot."`
+}
+
+// RoleSyncConfig holds the server commands to run for linked players with or
+// without a Discord role. %player% is replaced with the player's name.
+type RoleSyncConfig struct {
+	RoleID        string `toml:"role_id" comment:"ID of the Discord role"`
+	AddCommand    string `toml:"add_command" comment:"Command to run if the player has the role, such as \"team join mods %player%\""`
+	RemoveCommand string `toml:"remove_command" comment:"Command to run if the player doesn't have the role, such as \"team leave %player%\""`
+}
+
+// DigestConfig holds settings for the weekly statistics digest.
+type DigestConfig struct {
+	Enabled bool   `toml:"enabled"`
+	Day     string `toml:"day" comment:"The day of the week to post the digest on, such as sunday"`
+	Hour    int    `toml:"hour" comment:"The hour of the day to post the digest at, from 0 to 23, in the bot's local time"`
+}
+
+// CommandConfig holds settings for how bot commands are used in Discord.
+type CommandConfig struct {
+	Prefix         string              `toml:"prefix" comment:"Prefix for text commands, e.g. !list"`
+	EnablePrefix   bool                `toml:"enable_prefix" comment:"Allow text commands using the prefix alongside slash commands"`
+	SlashCommands  bool                `toml:"slash_commands" comment:"Register slash commands. The bot must be invited with the applications.commands scope."`
+	AuditChannelID string              `toml:"audit_channel_id" discord:"channel" comment:"ID of the channel to log denied and sensitive commands to. Leave empty to disable."`
+	Permissions    *[]PermissionConfig `toml:"permissions" comment:"Who can use each command. Commands without a rule use their default permissions."`
+	Rcon           RconCommandConfig   `toml:"rcon"`
+}
+
+// PermissionConfig holds the rule for who may use a command. A user may use
+// the command if they are in Users, have one of the Roles, or have the
+// Discord Permission. If Channels is set, the command may only be used in
+// those channels.
+type PermissionConfig struct {
+	Command    string   `toml:"command" comment:"Name of the command, or a command and subcommand such as \"config set\""`
+	Roles      []string `toml:"roles" comment:"IDs of the roles allowed to use the command"`
+	Users      []string `toml:"users" comment:"IDs of the users allowed to use the command"`
+	Channels   []string `toml:"channels" comment:"IDs of the channels the command can be used in"`
+	Permission string   `toml:"permission" comment:"A Discord permission that allows using the command, such as ManageGuild"`
+}
+
+// RconCommandConfig holds settings for running server commands from Discord
+// with the rcon command.
+type RconCommandConfig struct {
+	Rules    *[]RconRuleConfig `toml:"rules" comment:"Which server commands each role can run. If there are no rules, anyone allowed to use the rcon command can run any server command."`
+	AuditLog string            `toml:"audit_log" discord:"readonly" comment:"Path to a file to record every server command run from Discord in. Leave empty to disable."`
+}
+
+// RconRuleConfig holds the server commands that users or roles may or may
+// not run. A rule without any users or roles applies to everyone.
+type RconRuleConfig struct {
+	Roles []string `toml:"roles" comment:"IDs of the roles this rule applies to"`
+	Users []string `toml:"users" comment:"IDs of the users this rule applies to"`
+	Allow []string `toml:"allow" comment:"Server commands that can be run, such as \"kick *\". * matches anything."`
+	Deny  []string `toml:"deny" comment:"Server commands that can't be run, such as \"op\". Deny rules win over allow rules."`
 }
 
 // WebhookConfig holds settings for using Discord webhooks to send messages.
 type WebhookConfig struct {
 	Enabled bool
-	URL     string
+	URL     string `secret:"true"`
 }
 
 // MinecraftConfig holds all settings for the Minecraft server side of the application.
 type MinecraftConfig struct {
-	RconIP              string
+	RconIP              string `discord:"readonly"`
 	RconPort            int
-	RconPassword        string
+	RconPassword        string `secret:"true"`
 	TellrawTemplate     string
 	CustomDeathKeywords *[]string
 	UseLogFile          bool
-	LogFilePath         string
+	LogFilePath         string `discord:"readonly"`
+	ServerDir           string `discord:"readonly" comment:"Path to the Minecraft server directory. RconPort, RconPassword, and LogFilePath are read from\nits server.properties when they aren't in this file, and kept up to date when the file changes."`
+}
+
+// IRCConfig holds settings for bridging an IRC channel.
+type IRCConfig struct {
+	Enabled  bool   `toml:"enabled"`
+	Server   string `toml:"server" discord:"readonly" comment:"Address and port of the IRC server, such as irc.libera.chat:6697"`
+	TLS      bool   `toml:"tls" comment:"Connect to the server using TLS"`
+	Nick     string `toml:"nick" comment:"Nickname of the bot on IRC"`
+	Password string `toml:"password" secret:"true" comment:"Password of the IRC server. Leave empty if it doesn't need one."`
+	Channel  string `toml:"channel" comment:"The channel to bridge, such as #minecraft"`
+}
+
+// RouteConfig holds the names of the sinks that messages from each source
+// are sent to, by the name of the source. Sources and sinks that aren't
+// enabled are skipped.
+type RouteConfig map[string][]string
+
+// Targets gets the names of the sinks that messages from a source are sent
+// to.
+func (r RouteConfig) Targets(source string) []string {
+	if targets, ok := r[source]; ok {
+		return targets
+	}
+	return []string{}
+}
+
+// StorageConfig holds settings for where Dolphin keeps its data.
+type StorageConfig struct {
+	Path string `toml:"path" discord:"readonly" comment:"Path to the database file. If empty, it is kept next to the config file."`
 }
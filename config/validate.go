@@ -0,0 +1,251 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// webhookURLRegex matches Discord webhook URLs.
+var webhookURLRegex = regexp.MustCompile(`^https://(?:(?:ptb|canary)\.)?discord(?:app)?\.com/api/webhooks/\d+/[\w-]+$`)
+
+// colorRegex matches hex color codes such as #4caf50.
+var colorRegex = regexp.MustCompile(`^#?[0-9A-Fa-f]{6}$`)
+
+// weekdayNames are the valid days of the week for the weekly digest.
+var weekdayNames = []string{"sunday", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday"}
+
+// Problem is something wrong with a config value.
+type Problem struct {
+	// Field is the path to the value in the config file, such as
+	// "Discord.ChannelID".
+	Field   string
+	Message string
+	// Hint says how to fix the problem, if we know.
+	Hint string
+}
+
+// String formats a problem for showing to the user.
+func (p Problem) String() string {
+	if p.Hint == "" {
+		return fmt.Sprintf("%s: %s", p.Field, p.Message)
+	}
+	return fmt.Sprintf("%s: %s (%s)", p.Field, p.Message, p.Hint)
+}
+
+// ValidationError is returned when a config has problems.
+type ValidationError struct {
+	Problems []Problem
+}
+
+// Error lists all of the problems with the config.
+func (e *ValidationError) Error() string {
+	lines := make([]string, 0, len(e.Problems))
+	for _, p := range e.Problems {
+		lines = append(lines, p.String())
+	}
+	return fmt.Sprintf("config has %d problem(s):\n  %s", len(e.Problems), strings.Join(lines, "\n  "))
+}
+
+// validator collects the problems found in a config.
+type validator struct {
+	problems []Problem
+}
+
+// add adds a problem.
+func (v *validator) add(field string, message string, hint string) {
+	v.problems = append(v.problems, Problem{Field: field, Message: message, Hint: hint})
+}
+
+// snowflake checks that a value is a Discord ID. If optional is true, an
+// empty value is allowed.
+func (v *validator) snowflake(field string, value string, optional bool) {
+	if value == "" {
+		if !optional {
+			v.add(field, "is not set", "enable Developer Mode in Discord, then right-click and Copy ID")
+		}
+		return
+	}
+
+	if id, err := strconv.ParseUint(value, 10, 64); err != nil || id == 0 {
+		v.add(field, fmt.Sprintf("'%s' is not a valid Discord ID", value), "Discord IDs are long numbers; enable Developer Mode in Discord, then right-click and Copy ID")
+	}
+}
+
+// pattern checks that a value is a valid regular expression, if set.
+func (v *validator) pattern(field string, value string) {
+	if value == "" {
+		return
+	}
+
+	if _, err := regexp.Compile(value); err != nil {
+		v.add(field, fmt.Sprintf("is not a valid regular expression: %s", err), "")
+	}
+}
+
+// readable checks that a file exists and can be read.
+func (v *validator) readable(field string, path string) {
+	file, err := os.Open(path)
+	if err != nil {
+		v.add(field, fmt.Sprintf("can't be read: %s", err), "check the path, and that Dolphin's user can read it")
+		return
+	}
+	file.Close()
+}
+
+// Validate checks a config for problems, such as IDs that aren't Discord IDs
+// or files that can't be read. All of the problems are returned together
+// in a ValidationError. The bot token isn't checked, because a missing token
+// is handled on its own.
+func Validate(conf RootConfig) error {
+	v := &validator{}
+	d := conf.Discord
+	m := conf.Minecraft
+
+	// Discord
+	v.snowflake("Discord.ChannelID", d.ChannelID, false)
+
+	switch d.Mentions.Policy {
+	case MentionPolicyNone, MentionPolicyUsers, MentionPolicyRoles:
+	default:
+		v.add("Discord.mentions.policy", fmt.Sprintf("'%s' is not a mention policy", d.Mentions.Policy), "use none, users, or roles")
+	}
+	if d.Mentions.AllowedRoles != nil {
+		for i, role := range *d.Mentions.AllowedRoles {
+			v.snowflake(fmt.Sprintf("Discord.mentions.allowed_roles[%d]", i), role, false)
+		}
+	}
+
+	embeds := map[string]EmbedStyleConfig{
+		"advancements":     d.Embeds.Advancements,
+		"deaths":           d.Embeds.Deaths,
+		"joins_and_leaves": d.Embeds.JoinsLeaves,
+		"server_status":    d.Embeds.ServerStatus,
+	}
+	for _, name := range []string{"advancements", "deaths", "joins_and_leaves", "server_status"} {
+		if color := embeds[name].Color; color != "" && !colorRegex.MatchString(color) {
+			v.add(fmt.Sprintf("Discord.embeds.%s.color", name), fmt.Sprintf("'%s' is not a hex color code", color), "use a code like #4caf50")
+		}
+	}
+
+	if d.Status.PollInterval < 0 {
+		v.add("Discord.status.poll_interval", "can't be negative", "")
+	}
+
+	v.snowflake("Discord.commands.audit_channel_id", d.Commands.AuditChannelID, true)
+	if d.Commands.Permissions != nil {
+		for i, rule := range *d.Commands.Permissions {
+			field := fmt.Sprintf("Discord.commands.permissions[%d]", i)
+			if strings.TrimSpace(rule.Command) == "" {
+				v.add(field+".command", "is not set", "name the command the rule is for, such as \"whitelist add\"")
+			}
+			for j, id := range rule.Roles {
+				v.snowflake(fmt.Sprintf("%s.roles[%d]", field, j), id, false)
+			}
+			for j, id := range rule.Users {
+				v.snowflake(fmt.Sprintf("%s.users[%d]", field, j), id, false)
+			}
+			for j, id := range rule.Channels {
+				v.snowflake(fmt.Sprintf("%s.channels[%d]", field, j), id, false)
+			}
+		}
+	}
+
+	if d.Console.Enabled {
+		v.snowflake("Discord.console.channel_id", d.Console.ChannelID, false)
+		if d.Console.ChannelID != "" && d.Console.ChannelID == d.ChannelID {
+			v.add("Discord.console.channel_id", "is the same as the chat channel", "use a separate channel for the console")
+		}
+		if !m.UseLogFile {
+			v.add("Discord.console.enabled", "needs the server log", "enable Minecraft.UseLogFile")
+		}
+	}
+	v.pattern("Discord.console.include", d.Console.Include)
+	v.pattern("Discord.console.exclude", d.Console.Exclude)
+
+	v.snowflake("Discord.whitelist.request_channel_id", d.Whitelist.RequestChannelID, true)
+	v.snowflake("Discord.moderation.log_channel_id", d.Moderation.LogChannelID, true)
+
+	if d.Linking.RoleSync != nil {
+		for i, rule := range *d.Linking.RoleSync {
+			v.snowflake(fmt.Sprintf("Discord.linking.role_sync[%d].role_id", i), rule.RoleID, false)
+		}
+	}
+
+	if d.Digest.Enabled {
+		if !containsString(weekdayNames, strings.ToLower(strings.TrimSpace(d.Digest.Day))) {
+			v.add("Discord.digest.day", fmt.Sprintf("'%s' is not a day of the week", d.Digest.Day), "use a day such as sunday")
+		}
+		if d.Digest.Hour < 0 || d.Digest.Hour > 23 {
+			v.add("Discord.digest.hour", fmt.Sprintf("%d is not an hour of the day", d.Digest.Hour), "use an hour from 0 to 23")
+		}
+	}
+
+	if d.Webhook.Enabled && !webhookURLRegex.MatchString(d.Webhook.URL) {
+		v.add("Discord.Webhook.URL", "is not a Discord webhook URL", "copy the URL from the webhook's settings in Discord")
+	}
+
+	// Minecraft
+	if strings.TrimSpace(m.RconIP) == "" {
+		v.add("Minecraft.RconIP", "is not set", "use the address of the Minecraft server, such as localhost")
+	}
+	if m.RconPort < 1 || m.RconPort > 65535 {
+		v.add("Minecraft.RconPort", fmt.Sprintf("%d is not a valid port", m.RconPort), "use the rcon.port from server.properties, usually 25575, or set Minecraft.ServerDir to read it from there")
+	}
+	if !json.Valid([]byte(m.TellrawTemplate)) {
+		v.add("Minecraft.TellrawTemplate", "is not valid JSON", "check for missing quotes, commas, or brackets")
+	} else if !strings.Contains(m.TellrawTemplate, "%message%") {
+		v.add("Minecraft.TellrawTemplate", "doesn't contain %message%", "add %message% where the chat message should go")
+	}
+	if m.UseLogFile {
+		v.readable("Minecraft.LogFilePath", m.LogFilePath)
+	}
+
+	// IRC
+	if conf.IRC.Enabled {
+		if _, port, err := net.SplitHostPort(conf.IRC.Server); err != nil || port == "" {
+			v.add("IRC.server", fmt.Sprintf("'%s' is not an address and port", conf.IRC.Server), "use the server's address and port, such as irc.libera.chat:6697")
+		}
+		if strings.TrimSpace(conf.IRC.Nick) == "" || strings.ContainsAny(conf.IRC.Nick, " ,*?!@#") {
+			v.add("IRC.nick", fmt.Sprintf("'%s' is not a valid nickname", conf.IRC.Nick), "use a nickname without spaces or special characters")
+		}
+		if !strings.HasPrefix(conf.IRC.Channel, "#") && !strings.HasPrefix(conf.IRC.Channel, "&") {
+			v.add("IRC.channel", fmt.Sprintf("'%s' is not an IRC channel", conf.IRC.Channel), "channel names start with #, such as #minecraft")
+		}
+	}
+
+	// Routes. Which sources and sinks there are depends on the bridge, so
+	// it checks the names
+	sources := make([]string, 0, len(conf.Routes))
+	for source := range conf.Routes {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+	for _, source := range sources {
+		for i, target := range conf.Routes[source] {
+			if target == source {
+				v.add(fmt.Sprintf("Routes.%s[%d]", source, i), "sends messages back where they came from", "remove it from the list")
+			}
+		}
+	}
+
+	if len(v.problems) == 0 {
+		return nil
+	}
+	return &ValidationError{Problems: v.problems}
+}
+
+// containsString checks if a list contains a string.
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
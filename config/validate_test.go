@@ -0,0 +1,151 @@
+package config
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// validConfig creates a config with no problems, using a log file in a
+// temporary directory. The returned function removes the directory.
+func validConfig(t *testing.T) (RootConfig, func()) {
+	dir, err := ioutil.TempDir("", "dolphin")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %s", err)
+	}
+	logPath := filepath.Join(dir, "latest.log")
+	if err := ioutil.WriteFile(logPath, []byte{}, 0600); err != nil {
+		t.Fatalf("Failed to create log file: %s", err)
+	}
+
+	conf := MergeDefaults(RootConfig{})
+	conf.Discord.ChannelID = "123456789012345678"
+	conf.Minecraft.LogFilePath = logPath
+
+	return conf, func() {
+		os.RemoveAll(dir)
+	}
+}
+
+// problemFields gets the fields that have problems from a validation error.
+func problemFields(t *testing.T, err error) []string {
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Expected a validation error, got: %v", err)
+	}
+
+	fields := make([]string, 0, len(validationErr.Problems))
+	for _, p := range validationErr.Problems {
+		fields = append(fields, p.Field)
+	}
+	return fields
+}
+
+func TestValidateDefaults(t *testing.T) {
+	// given
+	conf, cleanup := validConfig(t)
+	defer cleanup()
+
+	// when
+	err := Validate(conf)
+
+	// then
+	if err != nil {
+		t.Errorf("Default config should be valid, got: %s", err)
+	}
+}
+
+func TestValidateCollectsAllProblems(t *testing.T) {
+	// given
+	conf, cleanup := validConfig(t)
+	defer cleanup()
+	conf.Discord.ChannelID = "general"
+	conf.Discord.Webhook = WebhookConfig{Enabled: true, URL: "https://example.com/hook"}
+	conf.Discord.Console = ConsoleConfig{Enabled: true, ChannelID: "123456789012345678", Include: "("}
+	conf.Minecraft.RconPort = 0
+	conf.Minecraft.TellrawTemplate = `[{"text": "%message%"`
+	conf.Minecraft.LogFilePath = filepath.Join(os.TempDir(), "dolphin-missing", "latest.log")
+	expected := []string{
+		"Discord.ChannelID",
+		"Discord.console.include",
+		"Discord.Webhook.URL",
+		"Minecraft.RconPort",
+		"Minecraft.TellrawTemplate",
+		"Minecraft.LogFilePath",
+	}
+
+	// when
+	err := Validate(conf)
+
+	// then
+	actual := problemFields(t, err)
+	if !cmp.Equal(actual, expected) {
+		t.Errorf("Problems are incorrect: Diff: %s", cmp.Diff(actual, expected))
+	}
+}
+
+func TestValidateWebhookURLs(t *testing.T) {
+	// given
+	conf, cleanup := validConfig(t)
+	defer cleanup()
+	urls := []string{
+		"https://discordapp.com/api/webhooks/123456789012345678/abc-DEF_123",
+		"https://discord.com/api/webhooks/123456789012345678/abc-DEF_123",
+		"https://canary.discord.com/api/webhooks/123456789012345678/abc-DEF_123",
+	}
+
+	for _, url := range urls {
+		// when
+		conf.Discord.Webhook = WebhookConfig{Enabled: true, URL: url}
+		err := Validate(conf)
+
+		// then
+		if err != nil {
+			t.Errorf("Webhook URL '%s' should be valid, got: %s", url, err)
+		}
+	}
+}
+
+func TestValidateIRCAndRoutes(t *testing.T) {
+	// given
+	conf, cleanup := validConfig(t)
+	defer cleanup()
+	conf.IRC = IRCConfig{Enabled: true, Server: "irc.example.com", Nick: "dolphin", Channel: "minecraft"}
+	conf.Routes = RouteConfig{"discord": {"minecraft", "discord", "slack"}}
+
+	// when
+	err := Validate(conf)
+
+	// then
+	expected := []string{"IRC.server", "IRC.channel", "Routes.discord[1]"}
+	if actual := problemFields(t, err); !cmp.Equal(actual, expected) {
+		t.Errorf("Problems are incorrect: Diff: %s", cmp.Diff(actual, expected))
+	}
+}
+
+func TestCheckFile(t *testing.T) {
+	// given
+	dir, err := ioutil.TempDir("", "dolphin")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "dolphin.conf")
+	ioutil.WriteFile(path, []byte("[Discord]\nChannelID = \"abc\"\n"), 0600)
+
+	// when
+	checked, err := CheckFile(dir)
+
+	// then
+	if checked != path {
+		t.Errorf("Checked the wrong file, expected: %s, got: %s", path, checked)
+	}
+	fields := problemFields(t, err)
+	if len(fields) == 0 || fields[0] != "Discord.ChannelID" {
+		t.Errorf("Problems are incorrect: %v", fields)
+	}
+}
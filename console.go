@@ -0,0 +1,279 @@
+package dolphin
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/diamondburned/arikawa/api"
+	"github.com/diamondburned/arikawa/discord"
+	"gitlab.com/EbonJaeger/dolphin/command"
+	"gitlab.com/EbonJaeger/dolphin/config"
+)
+
+const (
+	// consoleBufferSize is how many log lines can wait to be sent before
+	// new lines are dropped.
+	consoleBufferSize = 1000
+	// consoleMaxMessages is the most messages sent to Discord for each batch
+	// of log lines, to stay within Discord's rate limits.
+	consoleMaxMessages = 2
+	// consoleMessageSize is the most text in a message, leaving room for the
+	// code block and skipped lines note. Discord messages can be up to 2000
+	// characters.
+	consoleMessageSize = 1950
+	// consoleMinInterval is the shortest time allowed between batches.
+	consoleMinInterval = 2 * time.Second
+)
+
+// logLineRegex matches the prefix of a server log line, such as
+// "[12:34:56] [Server thread/INFO]: " or "[12:34:56 INFO]: ".
+var logLineRegex = regexp.MustCompile(`^\[[^\]]*?(?: ([A-Z]+))?\](?: \[([^\]]+?)/([A-Z]+)\])?:? `)
+
+// Console mirrors the Minecraft server log to a Discord channel. Log lines
+// are buffered and sent in batches, so that a busy log doesn't go over
+// Discord's rate limits. Lines are dropped if they can't be sent fast enough.
+type Console struct {
+	bot      *DiscordBot
+	channel  discord.ChannelID
+	dropped  int64
+	filter   consoleFilter
+	interval time.Duration
+	lines    chan string
+	done     chan struct{}
+}
+
+// consoleFilter decides which log lines are shown in the console channel.
+type consoleFilter struct {
+	levels  []string
+	loggers []string
+	include *regexp.Regexp
+	exclude *regexp.Regexp
+}
+
+// NewConsole creates a new console for a Discord bot using the given console
+// channel settings.
+func NewConsole(bot *DiscordBot, conf config.ConsoleConfig) (*Console, error) {
+	snowflake, err := discord.ParseSnowflake(conf.ChannelID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid console channel ID: %s", err)
+	}
+
+	filter, err := newConsoleFilter(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	interval := time.Duration(conf.BatchInterval) * time.Second
+	if interval < consoleMinInterval {
+		interval = consoleMinInterval
+	}
+
+	return &Console{
+		bot:      bot,
+		channel:  discord.ChannelID(snowflake),
+		filter:   filter,
+		interval: interval,
+		lines:    make(chan string, consoleBufferSize),
+		done:     make(chan struct{}),
+	}, nil
+}
+
+// newConsoleFilter creates a log line filter from the console settings.
+func newConsoleFilter(conf config.ConsoleConfig) (consoleFilter, error) {
+	filter := consoleFilter{}
+	if conf.Levels != nil {
+		filter.levels = *conf.Levels
+	}
+	if conf.Loggers != nil {
+		filter.loggers = *conf.Loggers
+	}
+
+	var err error
+	if conf.Include != "" {
+		if filter.include, err = regexp.Compile(conf.Include); err != nil {
+			return filter, fmt.Errorf("invalid console include pattern: %s", err)
+		}
+	}
+	if conf.Exclude != "" {
+		if filter.exclude, err = regexp.Compile(conf.Exclude); err != nil {
+			return filter, fmt.Errorf("invalid console exclude pattern: %s", err)
+		}
+	}
+
+	return filter, nil
+}
+
+// Channel gets the ID of the console channel.
+func (c *Console) Channel() discord.ChannelID {
+	return c.channel
+}
+
+// HandleLine queues a server log line to be sent to the console channel if
+// it passes the filters. It never blocks; if too many lines are waiting, the
+// line is dropped.
+func (c *Console) HandleLine(line string) {
+	if !c.filter.Matches(line) {
+		return
+	}
+
+	select {
+	case c.lines <- line:
+	default:
+		atomic.AddInt64(&c.dropped, 1)
+	}
+}
+
+// Run sends queued log lines to Discord in batches until the console is
+// closed.
+func (c *Console) Run() {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.flush()
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// Close stops sending log lines to Discord.
+func (c *Console) Close() {
+	close(c.done)
+}
+
+// flush sends all of the queued log lines to Discord.
+func (c *Console) flush() {
+	lines := make([]string, 0)
+	for empty := false; !empty; {
+		select {
+		case line := <-c.lines:
+			lines = append(lines, line)
+		default:
+			empty = true
+		}
+	}
+
+	if len(lines) == 0 {
+		return
+	}
+
+	messages, skipped := batchLines(lines, consoleMessageSize, consoleMaxMessages)
+	skipped += int(atomic.SwapInt64(&c.dropped, 0))
+
+	for i, message := range messages {
+		content := fmt.Sprintf("```\n%s\n```", message)
+		if skipped > 0 && i == len(messages)-1 {
+			content += fmt.Sprintf("\n*%d more lines skipped*", skipped)
+		}
+
+		// Log lines should never notify anyone
+		data := api.SendMessageData{
+			Content:         content,
+			AllowedMentions: &api.AllowedMentions{Parse: []api.AllowedMentionType{}},
+		}
+		if _, err := c.bot.state.Client.SendMessageComplex(c.channel, data); err != nil {
+			c.bot.bridge.log.Errorf("Error sending log lines to the console channel: %s\n", err)
+			return
+		}
+	}
+}
+
+// Matches checks if a log line should be shown in the console channel.
+func (f consoleFilter) Matches(line string) bool {
+	level, logger := parseLogLine(line)
+
+	if len(f.levels) > 0 && !containsFold(f.levels, level) {
+		return false
+	}
+	if len(f.loggers) > 0 && !containsFold(f.loggers, logger) {
+		return false
+	}
+	if f.include != nil && !f.include.MatchString(line) {
+		return false
+	}
+	if f.exclude != nil && f.exclude.MatchString(line) {
+		return false
+	}
+
+	return true
+}
+
+// parseLogLine gets the level and the logger or thread name of a server log
+// line. Either may be empty if the line doesn't have them, such as the lines
+// of a stack trace.
+func parseLogLine(line string) (level string, logger string) {
+	match := logLineRegex.FindStringSubmatch(line)
+	if match == nil {
+		return "", ""
+	}
+
+	// Vanilla servers log the thread and level together, and Paper logs the
+	// level with the time
+	if match[3] != "" {
+		return match[3], match[2]
+	}
+	return match[1], ""
+}
+
+// batchLines joins log lines into as few messages as possible, each no longer
+// than size. At most max messages are made; the number of lines that didn't
+// fit is returned.
+func batchLines(lines []string, size int, max int) ([]string, int) {
+	messages := make([]string, 0)
+	b := strings.Builder{}
+
+	for i, line := range lines {
+		line = truncateLine(command.EscapeCodeBlock(line), size)
+
+		// Start a new message if this line doesn't fit
+		if b.Len() > 0 && b.Len()+1+len(line) > size {
+			messages = append(messages, b.String())
+			b.Reset()
+			if len(messages) == max {
+				return messages, len(lines) - i
+			}
+		}
+
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(line)
+	}
+
+	if b.Len() > 0 {
+		messages = append(messages, b.String())
+	}
+
+	return messages, 0
+}
+
+// truncateLine shortens a line to at most size bytes, without cutting a
+// character in half.
+func truncateLine(line string, size int) string {
+	if len(line) <= size {
+		return line
+	}
+
+	end := size - len("…")
+	for end > 0 && !utf8.RuneStart(line[end]) {
+		end--
+	}
+	return line[:end] + "…"
+}
+
+// containsFold checks if a list contains a string, ignoring case.
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(strings.TrimSpace(item), s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package dolphin
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestParseLogLineVanilla(t *testing.T) {
+	// When
+	level, logger := parseLogLine("[12:34:56] [Server thread/WARN]: Can't keep up!")
+	// Then
+	if level != "WARN" || logger != "Server thread" {
+		t.Errorf("Parsing log line got incorrect result, got: %s %s, expected: %s %s", level, logger, "WARN", "Server thread")
+	}
+}
+
+func TestParseLogLinePaper(t *testing.T) {
+	// When
+	level, logger := parseLogLine("[12:34:56 ERROR]: Could not pass event")
+	// Then
+	if level != "ERROR" || logger != "" {
+		t.Errorf("Parsing log line got incorrect result, got: %s %s, expected: %s %s", level, logger, "ERROR", "")
+	}
+}
+
+func TestParseLogLineStackTrace(t *testing.T) {
+	// When
+	level, logger := parseLogLine("\tat net.minecraft.server.MinecraftServer.run")
+	// Then
+	if level != "" || logger != "" {
+		t.Errorf("Parsing log line got incorrect result, got: %s %s, expected empty", level, logger)
+	}
+}
+
+func TestConsoleFilter(t *testing.T) {
+	// Given
+	filter := consoleFilter{
+		levels:  []string{"warn", "error"},
+		exclude: regexp.MustCompile("keep up"),
+	}
+	// Then
+	if filter.Matches("[12:34:56] [Server thread/INFO]: Steve joined the game") {
+		t.Errorf("Filter should not match lines with other levels")
+	}
+	if filter.Matches("[12:34:56] [Server thread/WARN]: Can't keep up!") {
+		t.Errorf("Filter should not match excluded lines")
+	}
+	if !filter.Matches("[12:34:56] [Server thread/ERROR]: Encountered an unexpected exception") {
+		t.Errorf("Filter should match lines with an included level")
+	}
+}
+
+func TestBatchLines(t *testing.T) {
+	// Given
+	lines := []string{strings.Repeat("a", 10), strings.Repeat("b", 10), strings.Repeat("c", 10), strings.Repeat("d", 10)}
+	// When
+	messages, skipped := batchLines(lines, 21, 1)
+	// Then
+	if len(messages) != 1 || messages[0] != lines[0]+"\n"+lines[1] {
+		t.Errorf("Batching lines got incorrect messages, got: %q", messages)
+	}
+	if skipped != 2 {
+		t.Errorf("Batching lines got incorrect skipped count, got: %d, expected: %d", skipped, 2)
+	}
+}
+
+func TestBatchLinesLongLine(t *testing.T) {
+	// When
+	messages, skipped := batchLines([]string{strings.Repeat("é", 20)}, 15, 2)
+	// Then
+	if len(messages) != 1 || len(messages[0]) > 15 || !strings.HasSuffix(messages[0], "…") {
+		t.Errorf("Batching a long line should truncate it, got: %q", messages)
+	}
+	if skipped != 0 {
+		t.Errorf("Batching lines got incorrect skipped count, got: %d, expected: %d", skipped, 0)
+	}
+}
@@ -5,34 +5,46 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/diamondburned/arikawa/api"
 	"github.com/diamondburned/arikawa/discord"
 	"github.com/diamondburned/arikawa/gateway"
 	"github.com/diamondburned/arikawa/state"
+	"github.com/diamondburned/arikawa/utils/handler"
 	"github.com/diamondburned/arikawa/webhook"
-	"gitlab.com/EbonJaeger/dolphin/rcon"
+	"gitlab.com/EbonJaeger/dolphin/command"
 )
 
-var webhookRegex = regexp.MustCompile("https://discordapp.com/api/webhooks/(.*)/(.*)")
+var webhookRegex = regexp.MustCompile(`https://(?:(?:ptb|canary)\.)?discord(?:app)?\.com/api/webhooks/(.*)/(.*)`)
 
-// NewDiscordBot creates a new DiscordBot with a MinecraftWatcher and
-// connects to discord.
-func NewDiscordBot() (*DiscordBot, error) {
-	bot := &DiscordBot{}
+// NewDiscordBot creates a new DiscordBot with a MinecraftWatcher for a
+// bridge and connects to discord.
+func NewDiscordBot(bridge *Bridge) (*DiscordBot, error) {
+	bot := &DiscordBot{bridge: bridge}
+	conf := bridge.config()
 	var discordErr error
 
 	// Create Discord session
-	s, discordErr := state.New("Bot " + Config.Discord.BotToken)
+	s, discordErr := state.New("Bot " + conf.Discord.BotToken)
 	if discordErr != nil {
 		return nil, discordErr
 	}
 	bot.state = s
+	bot.status = NewStatusTracker(bot)
+	bot.bans = command.NewBanScheduler(bridge.parser, s)
 
 	// Add our Discord handlers
 	bot.state.AddHandler(bot.onReady)
 	bot.state.AddHandler(bot.onGuildCreate)
 	bot.state.AddHandler(bot.onMessageCreate)
+	bot.state.AddHandler(bot.onInteractionCreate)
+
+	// Member updates are handled before the state is updated, so we can see
+	// the roles the member had before
+	bot.state.PreHandler = handler.New()
+	bot.state.PreHandler.Synchronous = true
+	bot.state.PreHandler.AddHandler(bot.onGuildMemberUpdate)
 
 	// Connect to Discord websocket
 	if discordErr = bot.state.Open(); discordErr != nil {
@@ -50,8 +62,8 @@ func NewDiscordBot() (*DiscordBot, error) {
 	bot.name = self.Username
 	bot.avatarURL = self.AvatarURL()
 
-	if Config.Discord.ChannelID != "" {
-		snowflake, discordErr := discord.ParseSnowflake(Config.Discord.ChannelID)
+	if conf.Discord.ChannelID != "" {
+		snowflake, discordErr := discord.ParseSnowflake(conf.Discord.ChannelID)
 		if discordErr != nil {
 			return nil, discordErr
 		}
@@ -60,7 +72,16 @@ func NewDiscordBot() (*DiscordBot, error) {
 		return nil, errors.New("no channel ID configured")
 	}
 
-	bot.watcher = NewWatcher(self.Username, *Config.Minecraft.CustomDeathKeywords)
+	bot.watcher = NewWatcher(self.Username, *conf.Minecraft.CustomDeathKeywords, bridge.log)
+	bot.digest = command.NewDigestScheduler(bridge.parser, s, bridge.db)
+
+	// Create the console channel mirror if configured
+	if conf.Discord.Console.Enabled {
+		if bot.console, discordErr = NewConsole(bot, conf.Discord.Console); discordErr != nil {
+			return nil, discordErr
+		}
+		bot.watcher.console = bot.console
+	}
 
 	return bot, discordErr
 }
@@ -69,6 +90,16 @@ func NewDiscordBot() (*DiscordBot, error) {
 func (bot *DiscordBot) Close() error {
 	var closeErr error
 
+	bot.status.Close()
+	bot.bans.Close()
+	bot.digest.Close()
+
+	// Players' sessions start again when we next see them online
+	bot.bridge.parser.CloseSessions(time.Now())
+	if console := bot.currentConsole(); console != nil {
+		console.Close()
+	}
+
 	if err := bot.watcher.Close(); err != nil {
 		closeErr = err
 	}
@@ -85,42 +116,89 @@ func (bot *DiscordBot) WaitForMessages() {
 	// Make our messages channel
 	mc := make(chan *MinecraftMessage)
 
-	// Start our Minecraft watcher
-	go bot.watcher.Watch(mc)
+	// Start our Minecraft watcher, server status polling, temporary ban
+	// scheduler, and weekly digest
+	if conf := bot.bridge.config(); conf.Minecraft.UseLogFile {
+		go bot.watcher.Watch(conf.Minecraft.LogFilePath, mc)
+	}
+	go bot.status.Run()
+	go bot.bans.Run()
+	go bot.digest.Run()
+	if console := bot.currentConsole(); console != nil {
+		go console.Run()
+	}
 	for {
 		// Read message from the channel
 		msg := <-mc
-		Log.Debugf("Received a line from Minecraft: Username='%s', Text='%s'\n", msg.Username, msg.Message)
-
-		// Don't send messages that are disabled
-		switch msg.Type {
-		case AdvancementMessage:
-			{
-				if !Config.Discord.MessageOptions.ShowAdvancements {
-					continue
-				}
-			}
-		case DeathMessage:
-			{
-				if !Config.Discord.MessageOptions.ShowDeaths {
-					continue
-				}
+		bot.bridge.log.Debugf("Received a line from Minecraft: Username='%s', Text='%s'\n", msg.Username, msg.Message)
+
+		// Keep track of players and the server state
+		bot.status.HandleMessage(msg)
+		bot.trackStats(msg)
+
+		// Link accounts with codes typed in chat, and don't show the codes
+		if msg.Type == ChatMessage {
+			if code, ok := bot.bridge.parser.ParseLinkCode(msg.Message); ok {
+				go bot.bridge.parser.CompleteLink(bot.state, bot.guildID, msg.Player, code)
+				continue
 			}
-		case JoinLeaveMessage:
-			{
-				if !Config.Discord.MessageOptions.ShowJoinsLeaves {
-					continue
-				}
+		}
+
+		// Sync the roles of linked players when they join
+		if msg.Type == JoinLeaveMessage && msg.Detail == "joined" {
+			if userID, ok := bot.bridge.parser.LinkedUser(msg.Player); ok {
+				go bot.bridge.parser.SyncRoles(bot.state, bot.guildID, userID)
 			}
 		}
 
-		// Send the message to the Discord channel
-		bot.sendToDiscord(msg)
+		// Let middleware change or drop the message, then let subscribers
+		// know what happened
+		if !bot.bridge.filterMinecraft(msg) {
+			continue
+		}
+		bot.bridge.publishMinecraft(minecraftName, msg)
+
+		// Send the message everywhere it's routed to
+		bot.bridge.route(minecraftName, msg)
+	}
+}
+
+// trackStats updates the play sessions and statistics of players from a
+// Minecraft message.
+func (bot *DiscordBot) trackStats(msg *MinecraftMessage) {
+	p := bot.bridge.parser
+
+	switch msg.Type {
+	case AdvancementMessage:
+		p.RecordAdvancement(command.AdvancementEvent{Player: msg.Player, Title: msg.Detail, At: msg.Timestamp})
+	case DeathMessage:
+		cause, killer := parseDeath(msg.Detail)
+		p.RecordDeath(command.DeathEvent{
+			Player:  msg.Player,
+			Cause:   cause,
+			Killer:  killer,
+			Message: msg.Detail,
+			At:      msg.Timestamp,
+		})
+	case JoinLeaveMessage:
+		if msg.Detail == "joined" {
+			p.PlayerJoined(msg.Player, msg.Timestamp)
+		} else {
+			p.PlayerLeft(msg.Player, msg.Timestamp)
+		}
+	case ServerStopMessage:
+		p.CloseSessions(msg.Timestamp)
 	}
 }
 
 // onReady sets the bot's Discord status.
 func (bot *DiscordBot) onReady(e *gateway.ReadyEvent) {
+	// Show the server status if configured
+	if bot.bridge.config().Discord.Status.UpdatePresence {
+		bot.status.resetPresence()
+		return
+	}
+
 	// Set the bot gaming status
 	err := bot.state.Gateway.UpdateStatus(gateway.UpdateStatusData{
 		Game: &discord.Activity{
@@ -129,7 +207,7 @@ func (bot *DiscordBot) onReady(e *gateway.ReadyEvent) {
 	})
 
 	if err != nil {
-		Log.Errorf("Unable to update Discord status: %s\n", err)
+		bot.bridge.log.Errorf("Unable to update Discord status: %s\n", err)
 	}
 }
 
@@ -137,75 +215,161 @@ func (bot *DiscordBot) onReady(e *gateway.ReadyEvent) {
 func (bot *DiscordBot) onGuildCreate(e *gateway.GuildCreateEvent) {
 	// Make sure the guild is available
 	if e.Unavailable {
-		Log.Warnf("Attempted to join Guild '%s', but it was unavailable\n", e.Guild.Name)
+		bot.bridge.log.Warnf("Attempted to join Guild '%s', but it was unavailable\n", e.Guild.Name)
 		return
 	}
 
 	if bot.guildID.String() != "" {
-		Log.Warnf("Received a Guild join event for '%s', but we've already joined one\n", e.Guild.Name)
+		bot.bridge.log.Warnf("Received a Guild join event for '%s', but we've already joined one\n", e.Guild.Name)
 		return
 	}
 
-	Log.Infof("Connected to guild named '%s'\n", e.Guild.Name)
+	bot.bridge.log.Infof("Connected to guild named '%s'\n", e.Guild.Name)
 	bot.guildID = e.Guild.ID
+
+	// Register our slash commands in the guild
+	if bot.bridge.config().Discord.Commands.SlashCommands {
+		if err := bot.bridge.parser.RegisterCommands(bot.state, discord.Snowflake(bot.id), bot.guildID); err != nil {
+			bot.bridge.log.Errorf("Error registering slash commands: %s\n", err)
+		}
+	}
+}
+
+// channelID gets the bridge channel. It can change when the config is
+// reloaded.
+func (bot *DiscordBot) channelID() discord.ChannelID {
+	bot.bridge.mu.RLock()
+	defer bot.bridge.mu.RUnlock()
+	return bot.channel
+}
+
+// currentConsole gets the console channel mirror, if there is one. It can
+// change when the config is reloaded.
+func (bot *DiscordBot) currentConsole() *Console {
+	bot.bridge.mu.RLock()
+	defer bot.bridge.mu.RUnlock()
+	return bot.console
+}
+
+// onGuildMemberUpdate syncs the roles of linked players when their roles
+// change in Discord. Other changes, such as nicknames, are ignored.
+func (bot *DiscordBot) onGuildMemberUpdate(e *gateway.GuildMemberUpdateEvent) {
+	if e.GuildID != bot.guildID {
+		return
+	}
+
+	// We can only tell if the roles changed if we know the member
+	if old, err := bot.state.Store.Member(e.GuildID, e.User.ID); err == nil && sameRoles(old.RoleIDs, e.RoleIDs) {
+		return
+	}
+
+	go bot.bridge.parser.SyncMemberRoles(e.User.ID, e.RoleIDs)
+}
+
+// sameRoles checks if two lists have the same roles, in any order.
+func sameRoles(a []discord.RoleID, b []discord.RoleID) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	seen := make(map[discord.RoleID]bool, len(a))
+	for _, id := range a {
+		seen[id] = true
+	}
+	for _, id := range b {
+		if !seen[id] {
+			return false
+		}
+	}
+	return true
+}
+
+// onInteractionCreate handles slash commands and buttons used in Discord.
+func (bot *DiscordBot) onInteractionCreate(e *command.InteractionCreateEvent) {
+	// Buttons in our messages still work if slash commands are disabled
+	if !bot.bridge.config().Discord.Commands.SlashCommands && e.Data != nil && e.Data.CustomID == "" {
+		return
+	}
+
+	bot.bridge.parser.ParseInteraction(e, bot.state)
 }
 
 // onMessageCreate handles messages that the bot receives, and sends them
 // to Minecraft via RCON.
 func (bot *DiscordBot) onMessageCreate(e *gateway.MessageCreateEvent) {
+	conf := bot.bridge.config()
+
 	// Ignore messages from ourselves
 	if e.Author.ID != bot.id && e.Message.WebhookID.String() == "" {
 		// Check if the message is a bot command
-		if strings.HasPrefix(e.Message.Content, "!") {
+		prefix := conf.Discord.Commands.Prefix
+		if conf.Discord.Commands.EnablePrefix && prefix != "" && strings.HasPrefix(e.Message.Content, prefix) {
 			c := make(chan bool)
-			go parser.Parse(e.Message, bot.state, c)
+			go bot.bridge.parser.Parse(e.Message, bot.state, c)
 			// Don't go any further if the command was found and ran
 			if <-c {
 				return
 			}
 		}
 
+		// Run messages in the console channel as server commands
+		if console := bot.currentConsole(); console != nil && e.ChannelID == console.Channel() {
+			if conf.Discord.Console.RunCommands {
+				go bot.bridge.parser.RunServerCommand(e.Message, bot.state)
+			}
+			return
+		}
+
 		// Not a command, so ignore messages from other channels
-		if e.ChannelID.String() == Config.Discord.ChannelID {
-			Log.Debugln("Received a message from Discord")
+		if e.ChannelID.String() == conf.Discord.ChannelID {
+			bot.bridge.log.Debugln("Received a message from Discord")
 
 			// Get the name to use
 			var name string
-			if Config.Discord.UseMemberNicks {
+			if conf.Discord.UseMemberNicks {
 				name = bot.getNickname(e.Author.ID)
 			} else {
 				name = e.Author.Username
 			}
 
-			// Print the URL if message contains an attachement but no message content
-			if len(e.Message.Attachments) > 0 {
-				if len(e.Content) == 0 {
-					e.Content = e.Message.Attachments[0].URL
-					if err := sendToMinecraft(e.Content, name); err != nil {
-						Log.Errorf("Error sending command to RCON: %s\n", err)
-					}
-					return
+			// Linked users use their Minecraft name
+			if conf.Discord.Linking.UseLinkedNames {
+				if player, ok := bot.bridge.parser.LinkedPlayer(e.Author.ID); ok {
+					name = player
 				}
 			}
 
-			content := formatMessage(bot.state, e.Message)
-			lines := strings.Split(content, "\n")
-
-			// Send a separate message for each line
-			for i := 0; i < len(lines); i++ {
-				line := lines[i]
-				// Split long lines into additional messages
-				if len(line) > 100 {
-					lines = append(lines, "")
-					copy(lines[i+2:], lines[i+1:])
-					lines[i+1] = line[100:]
-					line = line[:100]
-				}
+			// Print the URL if message contains an attachement but no message content
+			attachment := len(e.Message.Attachments) > 0 && len(e.Content) == 0
+			content := bot.formatMessage(e.Message)
+			if attachment {
+				content = e.Message.Attachments[0].URL
+			}
 
-				if err := sendToMinecraft(line, name); err != nil {
-					Log.Errorf("Error sending command to RCON: %s\n", err)
-				}
+			msg := &DiscordMessage{
+				Author:    name,
+				UserID:    e.Author.ID,
+				ChannelID: e.ChannelID,
+				Content:   content,
+				Timestamp: e.Timestamp.Time(),
+			}
+			// Let middleware change or drop it, then let subscribers know
+			if !bot.bridge.filterDiscord(msg) {
+				return
+			}
+			bot.bridge.publishDiscord(msg)
+
+			// Send the message everywhere it's routed to
+			routed := &MinecraftMessage{
+				Username:  msg.Author,
+				Message:   msg.Content,
+				Type:      ChatMessage,
+				Timestamp: msg.Timestamp,
+			}
+			if attachment {
+				routed.Detail = attachmentDetail
 			}
+			bot.bridge.route(discordName, routed)
 		}
 	}
 }
@@ -213,43 +377,64 @@ func (bot *DiscordBot) onMessageCreate(e *gateway.MessageCreateEvent) {
 // sendToDiscord sends a message from Minecraft to the configured
 // Discord channel.
 func (bot *DiscordBot) sendToDiscord(m *MinecraftMessage) {
-	// Insert Discord mentions if configured and present
-	if Config.Discord.AllowMentions {
-		// Insert Discord mentions
-		m.Message = bot.insertMentions(m.Message)
+	conf := bot.bridge.config()
+
+	// Insert Discord mentions if configured and present, and only allow
+	// the ones we inserted to ping anyone
+	var allowed *api.AllowedMentions
+	m.Message, allowed = bot.insertMentions(m.Message)
+
+	// Create an embed for the message if configured for this type
+	var embed *discord.Embed
+	if style, ok := embedStyle(bot.bridge.config(), m.Type); ok {
+		e := createEventEmbed(m, style)
+		embed = &e
 	}
 
 	// Send the message to Discord either via webhook or normal channel message
-	if Config.Discord.Webhook.Enabled {
+	if conf.Discord.Webhook.Enabled {
 		// Get the configured webhook
-		id, token := matchWebhookURL(Config.Discord.Webhook.URL)
+		id, token := matchWebhookURL(conf.Discord.Webhook.URL)
 		if id == "" || token == "" {
-			Log.Warnln("Invalid or undefined Discord webhook URL")
+			bot.bridge.log.Warnln("Invalid or undefined Discord webhook URL")
 			return
 		}
 
 		// Attempt to get the webhook
 		snowflake, err := discord.ParseSnowflake(id)
 		if err != nil {
-			Log.Errorf("Error parsing Webhook Snowflake: %s\n", err.Error())
+			bot.bridge.log.Errorf("Error parsing Webhook Snowflake: %s\n", err.Error())
 		}
 		webhookID := discord.WebhookID(snowflake)
 
 		// Form our webhook params
 		params := bot.setWebhookParams(m)
+		params.AllowedMentions = allowed
+		if embed != nil {
+			params.Content = ""
+			params.Embeds = []discord.Embed{*embed}
+		}
 
 		// Send to the webhook
-		Log.Debugf("Sending to webhook: id='%s', token='%s'\n", id, token)
+		bot.bridge.log.Debugf("Sending to webhook: id='%s', token='%s'\n", id, token)
 		if err := webhook.Execute(webhookID, token, params); err != nil {
-			Log.Errorf("Error sending data to Discord webhook: %s\n", err.Error())
+			bot.bridge.log.Errorf("Error sending data to Discord webhook: %s\n", err.Error())
 		}
 	} else {
 		// Format the message for Discord
 		formatted := fmt.Sprintf("**%s**: %s", m.Username, m.Message)
 
 		// Send to the configured Discord channel
-		if _, err := bot.state.Client.SendMessage(bot.channel, formatted, nil); err != nil {
-			Log.Errorf("Error sending a message to Discord: %s\n", err.Error())
+		data := api.SendMessageData{
+			Content:         formatted,
+			AllowedMentions: allowed,
+		}
+		if embed != nil {
+			data.Content = ""
+			data.Embed = embed
+		}
+		if _, err := bot.state.Client.SendMessageComplex(bot.channelID(), data); err != nil {
+			bot.bridge.log.Errorf("Error sending a message to Discord: %s\n", err.Error())
 		}
 	}
 }
@@ -273,45 +458,6 @@ func (bot *DiscordBot) getNickname(id discord.UserID) string {
 	return m.Nick
 }
 
-// getUserFromName gets the Discord user from a mention or username. The username
-// can be only a partial username.
-func (bot *DiscordBot) getUserFromName(text string) (target *discord.User) {
-	// Look through all guild members in the state
-	members, _ := bot.state.Members(bot.guildID)
-	for _, u := range members {
-		// Check if the name matches, case-insensitive
-		if strings.EqualFold(u.User.Username, text) {
-			target = &u.User
-			break
-		}
-	}
-
-	return target
-}
-
-// insertMentions looks for potential Discord mentions in a Minecraft chat
-// message. If there are any, we will attempt to get the user being mentioned
-// to get their mention string to put into the chat message.
-func (bot *DiscordBot) insertMentions(msg string) string {
-	// Split the message into words
-	words := strings.Split(msg, " ")
-
-	// Iterate over each word
-	for _, word := range words {
-		// Check if the word might be a mention
-		if strings.HasPrefix(word, "@") {
-			// Attempt to get the user
-			user := bot.getUserFromName(word[1:])
-			if user != nil {
-				// Replace the word with the mention
-				msg = strings.Replace(msg, word, user.Mention(), 1)
-			}
-		}
-	}
-
-	return msg
-}
-
 func matchWebhookURL(url string) (string, string) {
 	wm := webhookRegex.FindStringSubmatch(url)
 
@@ -334,7 +480,7 @@ func (bot *DiscordBot) setWebhookParams(m *MinecraftMessage) api.ExecuteWebhookD
 		avatarURL = bot.avatarURL
 	} else {
 		// Player's Minecraft head as the avatar
-		avatarURL = fmt.Sprintf("https://minotar.net/helm/%s/256.png", m.Username)
+		avatarURL = playerHeadURL(m.Username, 256)
 	}
 
 	return api.ExecuteWebhookData{
@@ -344,7 +490,7 @@ func (bot *DiscordBot) setWebhookParams(m *MinecraftMessage) api.ExecuteWebhookD
 	}
 }
 
-func formatMessage(state *state.State, message discord.Message) string {
+func (bot *DiscordBot) formatMessage(message discord.Message) string {
 	content := message.Content
 
 	// Replace mentions
@@ -355,9 +501,9 @@ func formatMessage(state *state.State, message discord.Message) string {
 			snowflake, _ := discord.ParseSnowflake(id)
 			channelID := discord.ChannelID(snowflake)
 
-			channel, err := state.Channel(channelID)
+			channel, err := bot.state.Channel(channelID)
 			if err != nil {
-				Log.Warnf("Error while getting channel from Discord: %s\n", err)
+				bot.bridge.log.Warnf("Error while getting channel from Discord: %s\n", err)
 				continue
 			}
 
@@ -366,37 +512,18 @@ func formatMessage(state *state.State, message discord.Message) string {
 	}
 
 	for _, member := range message.Mentions {
-		content = strings.Replace(content, fmt.Sprintf("<@!%s>", member.ID), fmt.Sprintf("@%s", member.Username), -1)
-	}
+		name := member.Username
 
-	// Escape quote characters
-	content = strings.Replace(content, "\"", "\\\"", -1)
-
-	return content
-}
-
-func sendToMinecraft(content, username string) error {
-	// Format command to send to the Minecraft server
-	command := fmt.Sprintf("tellraw @a %s", Config.Minecraft.TellrawTemplate)
-	command = strings.Replace(command, "%username%", username, -1)
-	command = strings.Replace(command, "%message%", content, -1)
-
-	// Create RCON connection
-	conn, err := rcon.Dial(Config.Minecraft.RconIP, Config.Minecraft.RconPort, Config.Minecraft.RconPassword)
-	if err != nil {
-		return err
-	}
-	defer conn.Close()
-
-	// Authenticate to RCON
-	if err = conn.Authenticate(); err != nil {
-		return err
-	}
+		// Mention linked users by their Minecraft name
+		if bot.bridge.config().Discord.Linking.UseLinkedNames {
+			if player, ok := bot.bridge.parser.LinkedPlayer(member.ID); ok {
+				name = player
+			}
+		}
 
-	// Send the command to Minecraft
-	if _, err := conn.SendCommand(command); err != nil {
-		return err
+		content = strings.Replace(content, fmt.Sprintf("<@!%s>", member.ID), fmt.Sprintf("@%s", name), -1)
+		content = strings.Replace(content, fmt.Sprintf("<@%s>", member.ID), fmt.Sprintf("@%s", name), -1)
 	}
 
-	return nil
+	return content
 }
@@ -0,0 +1,20 @@
+package dolphin
+
+import (
+	"testing"
+
+	"github.com/diamondburned/arikawa/discord"
+)
+
+func TestSameRoles(t *testing.T) {
+	// Given
+	roles := []discord.RoleID{1, 2, 3}
+
+	// Then
+	if !sameRoles(roles, []discord.RoleID{3, 1, 2}) {
+		t.Errorf("Roles in a different order should be the same")
+	}
+	if sameRoles(roles, []discord.RoleID{1, 2}) || sameRoles(roles, []discord.RoleID{1, 2, 4}) {
+		t.Errorf("Added or removed roles should not be the same")
+	}
+}
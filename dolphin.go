@@ -9,29 +9,19 @@ import (
 	"github.com/DataDrake/waterlog"
 	"github.com/DataDrake/waterlog/format"
 	"github.com/DataDrake/waterlog/level"
-	"gitlab.com/EbonJaeger/dolphin/command"
 	"gitlab.com/EbonJaeger/dolphin/config"
 )
 
-// Config is our struct that holds all configuration options.
-var Config *config.RootConfig
-
-// Log is our Waterlog instance.
-var Log *waterlog.WaterLog
-
-var discordBot *DiscordBot
-var parser *command.Parser
-
 // NewDolphin initializes all the things and connects to Discord.
 func NewDolphin(cliFlags Flags) {
 	// Initialize logging
-	Log = waterlog.New(os.Stdout, "", log.Ltime)
+	logger := waterlog.New(os.Stdout, "", log.Ltime)
 	if cliFlags.Debug {
-		Log.SetLevel(level.Debug)
+		logger.SetLevel(level.Debug)
 	} else {
-		Log.SetLevel(level.Info)
+		logger.SetLevel(level.Info)
 	}
-	Log.SetFormat(format.Partial)
+	logger.SetFormat(format.Partial)
 
 	// Get default config path if we weren't passed one from the CLI
 	configPath := cliFlags.Config
@@ -42,58 +32,50 @@ func NewDolphin(cliFlags Flags) {
 		var err error
 		configPath, err = config.GetDefaultConfDir()
 		if err != nil {
-			Log.Fatalf("Unable to get the default config location: %s\n", err)
+			logger.Fatalf("Unable to get the default config location: %s\n", err)
 		}
 	}
 
 	// Create the config file if it doesn't exist
-	if err := config.CreateConfigFile(configPath); err != nil {
-		Log.Fatalf("Error creating config file: %s\n", err)
+	file, err := config.NewFile(configPath)
+	if err != nil {
+		logger.Fatalf("Error creating config file: %s\n", err)
 	}
 
 	// Load our config
-	c, err := config.Load()
+	c, err := file.Load()
 	if err != nil {
-		Log.Fatalf("Error trying to load configuration: %s\n", err)
-	}
-
-	// Make sure we have good defaults
-	c = config.MergeDefaults(c)
-	if err := config.SaveConfig(c); err != nil {
-		Log.Fatalf("Error trying to save config: %s\n", err.Error())
+		logger.Fatalf("Error trying to load configuration: %s\n", err)
 	}
 
-	Config = &c
-
 	// Check if a bot token is configured
-	if Config.Discord.BotToken == "" {
-		Log.Errorln("+-------------------------------------------------------------------------------------+")
-		Log.Errorln("| No Discord bot token is configured!                                                 |")
-		Log.Errorln("|                                                                                     |")
-		Log.Errorln("| Create a Discord bot here:                                                          |")
-		Log.Errorln("| https://discordapp.com/developers/applications/me                                   |")
-		Log.Errorln("|                                                                                     |")
-		Log.Errorln("| Copy the token into your config file, and add the bot to your server with this URL: |")
-		Log.Errorln("| https://discordapp.com/oauth2/authorize?client_id=<BOT CLIENT ID>&scope=bot         |")
-		Log.Errorln("+-------------------------------------------------------------------------------------+")
+	if c.Discord.BotToken == "" {
+		logger.Errorln("+-------------------------------------------------------------------------------------+")
+		logger.Errorln("| No Discord bot token is configured!                                                 |")
+		logger.Errorln("|                                                                                     |")
+		logger.Errorln("| Create a Discord bot here:                                                          |")
+		logger.Errorln("| https://discordapp.com/developers/applications/me                                   |")
+		logger.Errorln("|                                                                                     |")
+		logger.Errorln("| Copy the token into your config file, and add the bot to your server with this URL: |")
+		logger.Errorln("| https://discordapp.com/oauth2/authorize?client_id=<BOT CLIENT ID>&scope=bot         |")
+		logger.Errorln("|                                                                                     |")
+		logger.Errorln("| Or run 'mcdolphin init' to set up Dolphin step by step.                             |")
+		logger.Errorln("+-------------------------------------------------------------------------------------+")
 		os.Exit(1)
 	}
 
-	// Create our Discord client and connect to Discord
-	Log.Infoln("Creating Discord session")
-	var discordErr error
-	discordBot, discordErr = NewDiscordBot()
-	if discordErr != nil {
-		Log.Fatalf("Error creating Discord bot: %s\n", discordErr.Error())
+	// Create our bridge, checking the rest of the config for problems
+	bridge, err := NewBridge(WithConfig(c), WithConfigFile(file), WithLogger(logger), WithConfigReloading())
+	if err != nil {
+		logger.Fatalf("Invalid configuration in '%s': %s\n", file.Path(), err)
 	}
 
-	// Create our command parser
-	parser = command.NewParser(Config, Log)
-
-	Log.Goodln("Connected to Discord! Press CTRL+C to exit")
+	// Connect to Discord and start bridging
+	if err := bridge.Start(); err != nil {
+		logger.Fatalf("Error creating Discord bot: %s\n", err.Error())
+	}
 
-	// Start watching Minecraft for messages
-	go discordBot.WaitForMessages()
+	logger.Goodln("Connected to Discord! Press CTRL+C to exit")
 
 	// Wait until told to close
 	sc := make(chan os.Signal, 1)
@@ -101,12 +83,12 @@ func NewDolphin(cliFlags Flags) {
 	<-sc
 
 	// Newline to keep things pretty
-	Log.Println("")
+	logger.Println("")
 
 	// Close everything on exit
-	if err := discordBot.Close(); err != nil {
-		Log.Fatalf("Error while closing: %s\n", err.Error())
+	if err := bridge.Close(); err != nil {
+		logger.Fatalf("Error while closing: %s\n", err.Error())
 	} else {
-		Log.Goodln("Dolphin shut down successfully!")
+		logger.Goodln("Dolphin shut down successfully!")
 	}
 }
@@ -0,0 +1,91 @@
+package dolphin
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/diamondburned/arikawa/discord"
+	"gitlab.com/EbonJaeger/dolphin/config"
+)
+
+// embedStyle gets the configured embed style for a type of message. The
+// returned bool is false if the message should be sent as plain text.
+func embedStyle(conf *config.RootConfig, t MessageType) (config.EmbedStyleConfig, bool) {
+	var style config.EmbedStyleConfig
+
+	switch t {
+	case AdvancementMessage:
+		style = conf.Discord.Embeds.Advancements
+	case DeathMessage:
+		style = conf.Discord.Embeds.Deaths
+	case JoinLeaveMessage:
+		style = conf.Discord.Embeds.JoinsLeaves
+	case ServerStartMessage, ServerStopMessage:
+		style = conf.Discord.Embeds.ServerStatus
+	default:
+		// Chat messages are always sent as plain text
+		return style, false
+	}
+
+	return style, style.Enabled
+}
+
+// createEventEmbed creates a Discord embed for a Minecraft event using the
+// given style.
+func createEventEmbed(m *MinecraftMessage, style config.EmbedStyleConfig) discord.Embed {
+	embed := discord.Embed{
+		Type:  discord.NormalEmbed,
+		Color: parseColor(style.Color),
+	}
+
+	switch m.Type {
+	case AdvancementMessage:
+		embed.Title = m.Detail
+		embed.Description = fmt.Sprintf("**%s** has made the advancement **%s**", m.Player, m.Detail)
+	case DeathMessage:
+		embed.Title = fmt.Sprintf("%s died", m.Player)
+		embed.Description = fmt.Sprintf("**%s** %s", m.Player, m.Detail)
+	case JoinLeaveMessage:
+		embed.Description = fmt.Sprintf("**%s** %s the game", m.Player, m.Detail)
+	case ServerStartMessage:
+		embed.Title = "Server has started"
+	case ServerStopMessage:
+		embed.Title = "Server is shutting down"
+	}
+
+	// Add the player's head if this message is about a player
+	if m.Player != "" {
+		embed.Author = &discord.EmbedAuthor{
+			Name: m.Player,
+			Icon: playerHeadURL(m.Player, 64),
+		}
+		if style.Thumbnail {
+			embed.Thumbnail = &discord.EmbedThumbnail{
+				URL: playerHeadURL(m.Player, 256),
+			}
+		}
+	}
+
+	if style.Timestamp && !m.Timestamp.IsZero() {
+		embed.Timestamp = discord.NewTimestamp(m.Timestamp)
+	}
+
+	return embed
+}
+
+// parseColor parses a hex color code such as #4caf50. If the code is
+// invalid, the default embed color is used.
+func parseColor(code string) discord.Color {
+	code = strings.TrimPrefix(strings.TrimSpace(code), "#")
+	value, err := strconv.ParseUint(code, 16, 32)
+	if err != nil || len(code) != 6 {
+		return discord.DefaultEmbedColor
+	}
+	return discord.Color(value)
+}
+
+// playerHeadURL gets the URL to a player's Minecraft head at the given size.
+func playerHeadURL(player string, size int) string {
+	return fmt.Sprintf("https://minotar.net/helm/%s/%d.png", player, size)
+}
@@ -0,0 +1,49 @@
+package dolphin
+
+import (
+	"testing"
+
+	"github.com/diamondburned/arikawa/discord"
+	"gitlab.com/EbonJaeger/dolphin/config"
+)
+
+func TestParseColor(t *testing.T) {
+	// When
+	actual := parseColor("#4caf50")
+	// Then
+	if actual != 0x4caf50 {
+		t.Errorf("Parsing color got incorrect value, got: %x, expected: %x", actual, 0x4caf50)
+	}
+}
+
+func TestParseInvalidColor(t *testing.T) {
+	// When
+	actual := parseColor("green")
+	// Then
+	if actual != discord.DefaultEmbedColor {
+		t.Errorf("Parsing invalid color should use the default, got: %x, expected: %x", actual, discord.DefaultEmbedColor)
+	}
+}
+
+func TestCreateDeathEmbed(t *testing.T) {
+	// Given
+	msg := &MinecraftMessage{
+		Type:   DeathMessage,
+		Player: "TestUser",
+		Detail: "was shot by Skeleton",
+	}
+	style := config.EmbedStyleConfig{
+		Enabled:   true,
+		Color:     "#cc0000",
+		Thumbnail: true,
+	}
+	// When
+	actual := createEventEmbed(msg, style)
+	// Then
+	if actual.Description != "**TestUser** was shot by Skeleton" {
+		t.Errorf("Creating death embed got incorrect description, got: %s", actual.Description)
+	}
+	if actual.Thumbnail == nil || actual.Thumbnail.URL != "https://minotar.net/helm/TestUser/256.png" {
+		t.Errorf("Creating death embed got incorrect thumbnail, got: %v", actual.Thumbnail)
+	}
+}
@@ -0,0 +1,296 @@
+package dolphin
+
+import (
+	"sync"
+	"time"
+
+	"github.com/DataDrake/waterlog"
+	"github.com/diamondburned/arikawa/discord"
+	"gitlab.com/EbonJaeger/dolphin/command"
+)
+
+// EventType is the kind of thing that happened in an Event.
+type EventType string
+
+// The types of events published by a bridge.
+const (
+	ChatEvent           EventType = "Chat"
+	JoinEvent           EventType = "Join"
+	LeaveEvent          EventType = "Leave"
+	DeathEvent          EventType = "Death"
+	AdvancementEvent    EventType = "Advancement"
+	ServerStartEvent    EventType = "ServerStart"
+	ServerStopEvent     EventType = "ServerStop"
+	DiscordMessageEvent EventType = "DiscordMessage"
+	CommandEvent        EventType = "Command"
+)
+
+// Event is something that happened in Minecraft or Discord. Only the field
+// for the kind of event is set.
+type Event struct {
+	Type      EventType
+	Timestamp time.Time
+	// Source is the name of where a message came from, such as minecraft,
+	// discord, or irc. It's empty for command events.
+	Source string
+
+	// Minecraft is the message from the server, for chat, join, leave,
+	// death, advancement, and server events. Chat from other sources, such
+	// as IRC, is also published as chat events.
+	Minecraft *MinecraftMessage
+	// Discord is the message from the bridge channel, for Discord message
+	// events.
+	Discord *DiscordMessage
+	// Command is the command that was run, for command events.
+	Command *command.DiscordCommand
+	// Err is the error the command returned, if any.
+	Err error
+}
+
+// DiscordMessage is a message from the bridge channel to be sent to
+// Minecraft.
+type DiscordMessage struct {
+	// Author is the name the message is shown with in Minecraft.
+	Author    string
+	UserID    discord.UserID
+	ChannelID discord.ChannelID
+	// Content is the text of the message, with mentions replaced by names.
+	Content   string
+	Timestamp time.Time
+}
+
+// MinecraftMiddleware can change a message from Minecraft, or another
+// source such as IRC, before it's sent on. The message is dropped if it
+// returns false.
+type MinecraftMiddleware func(m *MinecraftMessage) bool
+
+// DiscordMiddleware can change a message from Discord before it's sent to
+// Minecraft. The message is dropped if it returns false.
+type DiscordMiddleware func(m *DiscordMessage) bool
+
+// maxQueuedEvents is how many events can be waiting for a subscriber before
+// the oldest ones are dropped.
+const maxQueuedEvents = 1000
+
+// EventBus delivers the events of a bridge to everything subscribed to them.
+// Each subscriber gets its events on its own goroutine, so a slow subscriber
+// doesn't hold up the bridge or the other subscribers.
+type EventBus struct {
+	closed      bool
+	log         *waterlog.WaterLog
+	mu          sync.Mutex
+	nextID      int
+	subscribers []*subscriber
+}
+
+// subscriber is a function subscribed to some types of events, or every
+// type if none are given, and the events waiting to be delivered to it.
+type subscriber struct {
+	closeOnce sync.Once
+	done      chan struct{}
+	// dropping is true while events are being dropped because the queue
+	// is full, so the warning is only logged once
+	dropping bool
+	handler  func(Event)
+	id       int
+	mu       sync.Mutex
+	queue    []Event
+	types    []EventType
+	// wake is signalled when an event is queued
+	wake chan struct{}
+}
+
+// NewEventBus creates a new event bus with no subscribers. Subscribers that
+// panic are logged to log.
+func NewEventBus(log *waterlog.WaterLog) *EventBus {
+	return &EventBus{log: log}
+}
+
+// Subscribe calls a function for every event of the given types, or every
+// event if no types are given. The function is called on its own goroutine,
+// with one event at a time in the order they were published. If it panics,
+// the panic is logged and the next event is delivered. If it falls too far
+// behind, the oldest events are dropped. The returned function
+// unsubscribes; events that haven't been delivered yet are dropped.
+func (b *EventBus) Subscribe(handler func(Event), types ...EventType) func() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return func() {}
+	}
+
+	s := &subscriber{
+		done:    make(chan struct{}),
+		handler: handler,
+		id:      b.nextID,
+		types:   types,
+		wake:    make(chan struct{}, 1),
+	}
+	b.nextID++
+	b.subscribers = append(b.subscribers, s)
+	go b.deliver(s)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+
+			kept := make([]*subscriber, 0, len(b.subscribers))
+			for _, other := range b.subscribers {
+				if other.id != s.id {
+					kept = append(kept, other)
+				}
+			}
+			b.subscribers = kept
+			s.close()
+		})
+	}
+}
+
+// Close unsubscribes everything, and stops delivering events.
+func (b *EventBus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, s := range b.subscribers {
+		s.close()
+	}
+	b.subscribers = nil
+	b.closed = true
+}
+
+// Publish queues an event for everything subscribed to its type. It
+// doesn't wait for the event to be delivered.
+func (b *EventBus) Publish(e Event) {
+	if e.Timestamp.IsZero() {
+		e.Timestamp = time.Now()
+	}
+
+	// Queue the event for every subscriber at once, so they all get events
+	// in the same order when several are published at the same time
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, s := range b.subscribers {
+		if s.wants(e.Type) && s.push(e) && b.log != nil {
+			b.log.Warnf("An event subscriber is more than %d events behind, dropping its oldest events\n", maxQueuedEvents)
+		}
+	}
+}
+
+// deliver calls a subscriber's function with its events until it
+// unsubscribes.
+func (b *EventBus) deliver(s *subscriber) {
+	for {
+		select {
+		case <-s.wake:
+		case <-s.done:
+			return
+		}
+
+		for {
+			e, ok := s.pop()
+			if !ok {
+				break
+			}
+			select {
+			case <-s.done:
+				return
+			default:
+			}
+			b.handle(s, e)
+		}
+	}
+}
+
+// handle calls a subscriber's function with an event, recovering if it
+// panics.
+func (b *EventBus) handle(s *subscriber, e Event) {
+	defer func() {
+		if r := recover(); r != nil && b.log != nil {
+			b.log.Errorf("Event subscriber panicked handling a %s event: %v\n", e.Type, r)
+		}
+	}()
+	s.handler(e)
+}
+
+// close stops delivering events to a subscriber.
+func (s *subscriber) close() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+	})
+}
+
+// push queues an event for a subscriber. If the queue is full, the oldest
+// event is dropped, and true is returned if it's the first one dropped since
+// the subscriber last caught up.
+func (s *subscriber) push(e Event) bool {
+	s.mu.Lock()
+	warn := false
+	if len(s.queue) >= maxQueuedEvents {
+		s.queue[0] = Event{}
+		s.queue = s.queue[1:]
+		warn = !s.dropping
+		s.dropping = true
+	}
+	s.queue = append(s.queue, e)
+	s.mu.Unlock()
+
+	// Wake up the subscriber if it isn't already
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return warn
+}
+
+// pop takes the next event from a subscriber's queue.
+func (s *subscriber) pop() (Event, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.queue) == 0 {
+		s.dropping = false
+		return Event{}, false
+	}
+	e := s.queue[0]
+	s.queue[0] = Event{}
+	s.queue = s.queue[1:]
+	return e, true
+}
+
+// wants checks if a subscriber wants events of a type.
+func (s *subscriber) wants(t EventType) bool {
+	if len(s.types) == 0 {
+		return true
+	}
+	for _, want := range s.types {
+		if want == t {
+			return true
+		}
+	}
+	return false
+}
+
+// EventType gets the type of event a Minecraft message is.
+func (m *MinecraftMessage) EventType() EventType {
+	switch m.Type {
+	case AdvancementMessage:
+		return AdvancementEvent
+	case DeathMessage:
+		return DeathEvent
+	case JoinLeaveMessage:
+		if m.Detail == "left" {
+			return LeaveEvent
+		}
+		return JoinEvent
+	case ServerStartMessage:
+		return ServerStartEvent
+	case ServerStopMessage:
+		return ServerStopEvent
+	default:
+		return ChatEvent
+	}
+}
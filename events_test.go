@@ -0,0 +1,264 @@
+package dolphin
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DataDrake/waterlog"
+	"github.com/DataDrake/waterlog/level"
+	"github.com/google/go-cmp/cmp"
+)
+
+// receive waits for a number of events from a channel, failing the test if
+// they don't arrive in time.
+func receive(t *testing.T, events <-chan Event, n int) []EventType {
+	received := make([]EventType, 0, n)
+	for len(received) < n {
+		select {
+		case e := <-events:
+			received = append(received, e.Type)
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for events, got: %v", received)
+		}
+	}
+	return received
+}
+
+func TestEventBusDeliversSubscribedTypes(t *testing.T) {
+	// Given
+	bus := NewEventBus(nil)
+	joins := make(chan Event, 10)
+	all := make(chan Event, 10)
+	bus.Subscribe(func(e Event) { joins <- e }, JoinEvent, LeaveEvent)
+	bus.Subscribe(func(e Event) { all <- e })
+
+	// When
+	bus.Publish(Event{Type: JoinEvent})
+	bus.Publish(Event{Type: ChatEvent})
+	bus.Publish(Event{Type: LeaveEvent})
+
+	// Then
+	if actual, expected := receive(t, joins, 2), []EventType{JoinEvent, LeaveEvent}; !cmp.Equal(actual, expected) {
+		t.Errorf("Subscriber got the wrong events: Diff: %s", cmp.Diff(actual, expected))
+	}
+	if actual, expected := receive(t, all, 3), []EventType{JoinEvent, ChatEvent, LeaveEvent}; !cmp.Equal(actual, expected) {
+		t.Errorf("Subscriber to every event got the wrong events: Diff: %s", cmp.Diff(actual, expected))
+	}
+}
+
+func TestEventBusUnsubscribe(t *testing.T) {
+	// Given
+	bus := NewEventBus(nil)
+	events := make(chan Event, 10)
+	unsubscribe := bus.Subscribe(func(e Event) { events <- e })
+
+	// When
+	bus.Publish(Event{Type: ChatEvent})
+	receive(t, events, 1)
+	unsubscribe()
+	unsubscribe()
+	bus.Publish(Event{Type: ChatEvent})
+
+	// Then
+	select {
+	case e := <-events:
+		t.Errorf("Expected no events after unsubscribing, got: %s", e.Type)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestEventBusSlowSubscriber(t *testing.T) {
+	// Given
+	bus := NewEventBus(nil)
+	release := make(chan struct{})
+	defer close(release)
+	bus.Subscribe(func(e Event) { <-release })
+	events := make(chan Event, 10)
+	bus.Subscribe(func(e Event) { events <- e })
+
+	// When
+	published := make(chan struct{})
+	go func() {
+		bus.Publish(Event{Type: ChatEvent})
+		bus.Publish(Event{Type: JoinEvent})
+		close(published)
+	}()
+
+	// Then
+	select {
+	case <-published:
+	case <-time.After(time.Second):
+		t.Fatalf("Publishing should not wait for a slow subscriber")
+	}
+	if actual, expected := receive(t, events, 2), []EventType{ChatEvent, JoinEvent}; !cmp.Equal(actual, expected) {
+		t.Errorf("Other subscribers should still get events: Diff: %s", cmp.Diff(actual, expected))
+	}
+}
+
+func TestEventBusRecoversFromPanics(t *testing.T) {
+	// Given
+	bus := NewEventBus(waterlog.New(ioutil.Discard, "", 0))
+	events := make(chan Event, 10)
+	bus.Subscribe(func(e Event) {
+		if e.Type == ChatEvent {
+			panic("bad subscriber")
+		}
+		events <- e
+	})
+
+	// When
+	bus.Publish(Event{Type: ChatEvent})
+	bus.Publish(Event{Type: JoinEvent})
+
+	// Then
+	if actual, expected := receive(t, events, 1), []EventType{JoinEvent}; !cmp.Equal(actual, expected) {
+		t.Errorf("Subscriber should get events after panicking: Diff: %s", cmp.Diff(actual, expected))
+	}
+}
+
+func TestEventBusDropsOldestEvents(t *testing.T) {
+	// Given
+	var logged bytes.Buffer
+	logger := waterlog.New(&logged, "", 0)
+	logger.SetLevel(level.Warn)
+	bus := NewEventBus(logger)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	bus.Subscribe(func(e Event) {
+		if e.Source == "0" {
+			close(started)
+			<-release
+		}
+	})
+	bus.Publish(Event{Type: ChatEvent, Source: "0"})
+	<-started
+
+	// When
+	for i := 1; i <= maxQueuedEvents+2; i++ {
+		bus.Publish(Event{Type: ChatEvent, Source: fmt.Sprint(i)})
+	}
+
+	// Then
+	s := bus.subscribers[0]
+	s.mu.Lock()
+	queued, oldest := len(s.queue), s.queue[0].Source
+	s.mu.Unlock()
+	if queued != maxQueuedEvents || oldest != "3" {
+		t.Errorf("Queue should keep the newest %d events, got: %d starting at %s", maxQueuedEvents, queued, oldest)
+	}
+	if n := strings.Count(logged.String(), "dropping its oldest events"); n != 1 {
+		t.Errorf("Dropping events should be warned about once, got: %d", n)
+	}
+}
+
+func TestEventBusClose(t *testing.T) {
+	// Given
+	bus := NewEventBus(nil)
+	events := make(chan Event, 10)
+	unsubscribe := bus.Subscribe(func(e Event) { events <- e })
+
+	// When
+	bus.Close()
+	unsubscribe()
+	bus.Subscribe(func(e Event) { events <- e })
+	bus.Publish(Event{Type: ChatEvent})
+
+	// Then
+	select {
+	case e := <-events:
+		t.Errorf("Expected no events after closing, got: %s", e.Type)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestMinecraftMessageEventType(t *testing.T) {
+	// Given
+	tests := map[EventType]MinecraftMessage{
+		ChatEvent:        {Type: ChatMessage},
+		JoinEvent:        {Type: JoinLeaveMessage, Detail: "joined"},
+		LeaveEvent:       {Type: JoinLeaveMessage, Detail: "left"},
+		DeathEvent:       {Type: DeathMessage},
+		AdvancementEvent: {Type: AdvancementMessage},
+		ServerStartEvent: {Type: ServerStartMessage},
+		ServerStopEvent:  {Type: ServerStopMessage},
+	}
+
+	// Then
+	for expected, m := range tests {
+		if actual := m.EventType(); actual != expected {
+			t.Errorf("Event type is incorrect, expected: %s, got: %s", expected, actual)
+		}
+	}
+}
+
+func TestMinecraftMiddleware(t *testing.T) {
+	// Given
+	b := &Bridge{}
+	WithMinecraftMiddleware(
+		func(m *MinecraftMessage) bool {
+			m.Message = strings.Replace(m.Message, "darn", "d***", -1)
+			return true
+		},
+		func(m *MinecraftMessage) bool {
+			return m.Type != DeathMessage
+		},
+	)(b)
+	chat := &MinecraftMessage{Type: ChatMessage, Message: "darn creepers"}
+	death := &MinecraftMessage{Type: DeathMessage, Message: "Steve blew up"}
+
+	// When
+	sendChat := b.filterMinecraft(chat)
+	sendDeath := b.filterMinecraft(death)
+
+	// Then
+	if !sendChat || chat.Message != "d*** creepers" {
+		t.Errorf("Middleware did not change the message, got: %t %s", sendChat, chat.Message)
+	}
+	if sendDeath {
+		t.Errorf("Middleware did not drop the message")
+	}
+}
+
+func TestDiscordMiddleware(t *testing.T) {
+	// Given
+	b := &Bridge{}
+	WithDiscordMiddleware(func(m *DiscordMessage) bool {
+		m.Author = "[Discord] " + m.Author
+		return !strings.HasPrefix(m.Content, "//")
+	})(b)
+	msg := &DiscordMessage{Author: "Alex", Content: "hello"}
+	hidden := &DiscordMessage{Author: "Alex", Content: "// not for Minecraft"}
+
+	// Then
+	if !b.filterDiscord(msg) || msg.Author != "[Discord] Alex" {
+		t.Errorf("Middleware did not change the message, got: %s", msg.Author)
+	}
+	if b.filterDiscord(hidden) {
+		t.Errorf("Middleware did not drop the message")
+	}
+}
+
+func TestPublishedMessagesAreCopies(t *testing.T) {
+	// Given
+	b := &Bridge{events: NewEventBus(nil)}
+	events := make(chan Event, 1)
+	b.events.Subscribe(func(e Event) {
+		e.Minecraft.Message = "changed"
+		events <- e
+	})
+	msg := &MinecraftMessage{Type: ChatMessage, Message: "hello"}
+
+	// When
+	b.publishMinecraft(minecraftName, msg)
+	receive(t, events, 1)
+
+	// Then
+	if msg.Message != "hello" {
+		t.Errorf("Subscribers should not change the message being sent, got: %s", msg.Message)
+	}
+}
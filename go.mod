@@ -5,14 +5,15 @@ go 1.14
 require (
 	github.com/DataDrake/waterlog v1.0.5
 	github.com/diamondburned/arikawa v1.3.1
-	github.com/fsnotify/fsnotify v1.4.9 // indirect
+	github.com/fsnotify/fsnotify v1.4.9
 	github.com/google/go-cmp v0.5.2
 	github.com/gorilla/schema v1.2.0 // indirect
 	github.com/jessevdk/go-flags v1.4.0
 	github.com/kr/pretty v0.1.0 // indirect
 	github.com/nxadm/tail v1.4.4
 	github.com/pelletier/go-toml v1.8.0
-	golang.org/x/sys v0.0.0-20200824131525-c12d262b63d8 // indirect
+	go.etcd.io/bbolt v1.3.5
+	golang.org/x/term v0.0.0-20201126162022-7de9c90e9dd1
 	golang.org/x/time v0.0.0-20200630173020-3af7569d3a1e // indirect
 	golang.org/x/xerrors v0.0.0-20200804184101-5ec99f83aff1 // indirect
 	gopkg.in/check.v1 v1.0.0-20190902080502-41f04d3bba15 // indirect
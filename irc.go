@@ -0,0 +1,300 @@
+package dolphin
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/DataDrake/waterlog"
+	"gitlab.com/EbonJaeger/dolphin/config"
+)
+
+const (
+	// ircName is the name of the IRC source and sink in the config.
+	ircName = "irc"
+	// ircReconnectDelay is how long to wait before connecting to the IRC
+	// server again after losing the connection.
+	ircReconnectDelay = 30 * time.Second
+	// ircLineLength is the longest text we send in one message, leaving
+	// room for the command and channel in IRC's 512 byte line limit.
+	ircLineLength = 400
+)
+
+// errIRCNotConnected is returned when sending to IRC while we're not
+// connected to the server.
+var errIRCNotConnected = errors.New("not connected to IRC")
+
+// IRCClient bridges an IRC channel. It is both a Source of messages from
+// the channel and a Sink that sends messages to it.
+type IRCClient struct {
+	closeOnce sync.Once
+	conf      config.IRCConfig
+	conn      net.Conn
+	done      chan struct{}
+	log       *waterlog.WaterLog
+	mu        sync.Mutex
+	nick      string
+}
+
+// ircMessage is a line received from an IRC server.
+type ircMessage struct {
+	// Nick is the nickname of the user that sent the message, if any.
+	Nick    string
+	Command string
+	Params  []string
+}
+
+// NewIRCClient creates a new IRC client. It connects when Listen is called.
+func NewIRCClient(conf config.IRCConfig, log *waterlog.WaterLog) *IRCClient {
+	return &IRCClient{
+		conf: conf,
+		done: make(chan struct{}),
+		log:  log,
+		nick: conf.Nick,
+	}
+}
+
+// Name gets the name of the IRC client in the config.
+func (c *IRCClient) Name() string {
+	return ircName
+}
+
+// Listen connects to the IRC server and sends every message in the channel
+// to c until the client is closed. If the connection is lost, we connect
+// again after a while.
+func (c *IRCClient) Listen(messages chan<- *MinecraftMessage) {
+	for {
+		err := c.session(messages)
+
+		select {
+		case <-c.done:
+			return
+		default:
+		}
+
+		c.log.Warnf("Lost the connection to IRC, reconnecting in %s: %s\n", ircReconnectDelay, err)
+		select {
+		case <-time.After(ircReconnectDelay):
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// Close disconnects from the IRC server. Closing it again does nothing.
+func (c *IRCClient) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		close(c.done)
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.conn == nil {
+			return
+		}
+		fmt.Fprint(c.conn, "QUIT :Bye\r\n")
+		err = c.conn.Close()
+	})
+	return err
+}
+
+// Send sends a message to the IRC channel. Chat messages are shown with
+// the name of who sent them.
+func (c *IRCClient) Send(m *MinecraftMessage) error {
+	text := m.Message
+	if m.Type == ChatMessage {
+		text = fmt.Sprintf("<%s> %s", m.Username, m.Message)
+	}
+
+	for _, line := range splitIRCText(text) {
+		if err := c.write("PRIVMSG %s :%s", c.conf.Channel, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// session connects to the server, joins the channel, and handles lines
+// from the server until the connection is closed.
+func (c *IRCClient) session(messages chan<- *MinecraftMessage) error {
+	conn, err := c.dial()
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.conn = conn
+	c.nick = c.conf.Nick
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.conn = nil
+		c.mu.Unlock()
+		conn.Close()
+	}()
+
+	// Register with the server
+	if c.conf.Password != "" {
+		c.write("PASS %s", c.conf.Password)
+	}
+	c.write("NICK %s", c.conf.Nick)
+	c.write("USER %s 0 * :Dolphin", c.conf.Nick)
+
+	reader := bufio.NewReader(conn)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return err
+		}
+
+		msg, ok := parseIRCLine(line)
+		if !ok {
+			continue
+		}
+		if chat := c.handle(msg); chat != nil {
+			select {
+			case messages <- chat:
+			case <-c.done:
+				return nil
+			}
+		}
+	}
+}
+
+// handle responds to a line from the server, and returns the chat message
+// in it, if any.
+func (c *IRCClient) handle(msg ircMessage) *MinecraftMessage {
+	switch msg.Command {
+	case "PING":
+		c.write("PONG :%s", strings.Join(msg.Params, " "))
+	case "001":
+		// We're registered, so we can join the channel
+		c.log.Infof("Connected to IRC, joining %s\n", c.conf.Channel)
+		c.write("JOIN %s", c.conf.Channel)
+	case "433":
+		// Our nickname is taken, so try another
+		c.mu.Lock()
+		c.nick += "_"
+		nick := c.nick
+		c.mu.Unlock()
+		c.write("NICK %s", nick)
+	case "PRIVMSG":
+		if len(msg.Params) < 2 || !strings.EqualFold(msg.Params[0], c.conf.Channel) {
+			return nil
+		}
+
+		// Show actions such as /me waves, and ignore other CTCP messages
+		text := msg.Params[1]
+		if strings.HasPrefix(text, "\x01") {
+			if !strings.HasPrefix(text, "\x01ACTION ") {
+				return nil
+			}
+			text = fmt.Sprintf("* %s %s", msg.Nick, strings.Trim(strings.TrimPrefix(text, "\x01ACTION "), "\x01"))
+		}
+
+		return &MinecraftMessage{
+			Username:  msg.Nick,
+			Message:   text,
+			Type:      ChatMessage,
+			Timestamp: time.Now(),
+		}
+	}
+
+	return nil
+}
+
+// dial connects to the IRC server, using TLS if configured.
+func (c *IRCClient) dial() (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	if c.conf.TLS {
+		host, _, err := net.SplitHostPort(c.conf.Server)
+		if err != nil {
+			return nil, err
+		}
+		return tls.DialWithDialer(dialer, "tcp", c.conf.Server, &tls.Config{ServerName: host})
+	}
+	return dialer.Dial("tcp", c.conf.Server)
+}
+
+// write sends a line to the IRC server.
+func (c *IRCClient) write(format string, args ...interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		return errIRCNotConnected
+	}
+
+	// Line breaks would start a new command
+	line := strings.NewReplacer("\r", " ", "\n", " ").Replace(fmt.Sprintf(format, args...))
+	_, err := fmt.Fprintf(c.conn, "%s\r\n", line)
+	return err
+}
+
+// parseIRCLine parses a line from an IRC server, such as
+// ":nick!user@host PRIVMSG #channel :hello there".
+func parseIRCLine(line string) (ircMessage, bool) {
+	var msg ircMessage
+	line = strings.TrimRight(line, "\r\n")
+
+	// Get who sent it from the prefix, if any
+	if strings.HasPrefix(line, ":") {
+		parts := strings.SplitN(line[1:], " ", 2)
+		if len(parts) < 2 {
+			return msg, false
+		}
+		msg.Nick = strings.SplitN(parts[0], "!", 2)[0]
+		line = parts[1]
+	}
+
+	// The last parameter can contain spaces if it starts with a colon
+	var trailing *string
+	if i := strings.Index(line, " :"); i != -1 {
+		text := line[i+2:]
+		trailing = &text
+		line = line[:i]
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return msg, false
+	}
+	msg.Command = strings.ToUpper(fields[0])
+	msg.Params = fields[1:]
+	if trailing != nil {
+		msg.Params = append(msg.Params, *trailing)
+	}
+
+	return msg, true
+}
+
+// splitIRCText splits text into lines that fit in IRC messages. Lines are
+// only split between characters, so none are cut in half.
+func splitIRCText(text string) []string {
+	lines := make([]string, 0)
+	for _, line := range strings.Split(text, "\n") {
+		for len(line) > ircLineLength {
+			// Back up to the start of the character at the limit
+			end := ircLineLength
+			for end > 0 && !utf8.RuneStart(line[end]) {
+				end--
+			}
+			if end == 0 {
+				// Not UTF-8, so split it anywhere
+				end = ircLineLength
+			}
+			lines = append(lines, line[:end])
+			line = line[end:]
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
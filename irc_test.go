@@ -0,0 +1,244 @@
+package dolphin
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/DataDrake/waterlog"
+	"github.com/google/go-cmp/cmp"
+	"gitlab.com/EbonJaeger/dolphin/config"
+)
+
+// ircServer is a stand-in for an IRC server that accepts one client.
+type ircServer struct {
+	listener net.Listener
+	conn     net.Conn
+	lines    chan string
+}
+
+// startIRCServer starts listening for a client on a random local port.
+func startIRCServer(t *testing.T) *ircServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err)
+	}
+	return &ircServer{listener: listener, lines: make(chan string, 100)}
+}
+
+// accept waits for the client to connect, and starts reading its lines.
+func (s *ircServer) accept(t *testing.T) {
+	conn, err := s.listener.Accept()
+	if err != nil {
+		t.Fatalf("Failed to accept the client: %s", err)
+	}
+	s.conn = conn
+
+	go func() {
+		reader := bufio.NewReader(conn)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				close(s.lines)
+				return
+			}
+			s.lines <- strings.TrimRight(line, "\r\n")
+		}
+	}()
+}
+
+// expect waits for the client to send a line starting with prefix, skipping
+// any other lines.
+func (s *ircServer) expect(t *testing.T, prefix string) string {
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case line, ok := <-s.lines:
+			if !ok {
+				t.Fatalf("Client disconnected while waiting for %q", prefix)
+			}
+			if strings.HasPrefix(line, prefix) {
+				return line
+			}
+		case <-timeout:
+			t.Fatalf("Timed out waiting for %q", prefix)
+		}
+	}
+}
+
+// send sends a line to the client.
+func (s *ircServer) send(line string) {
+	fmt.Fprintf(s.conn, "%s\r\n", line)
+}
+
+// close disconnects the client and stops listening.
+func (s *ircServer) close() {
+	if s.conn != nil {
+		s.conn.Close()
+	}
+	s.listener.Close()
+}
+
+// connectIRC starts an IRC client connected to a stand-in server, and waits
+// for it to join the channel.
+func connectIRC(t *testing.T) (*IRCClient, *ircServer, chan *MinecraftMessage) {
+	server := startIRCServer(t)
+	conf := config.IRCConfig{
+		Enabled:  true,
+		Server:   server.listener.Addr().String(),
+		Nick:     "dolphin",
+		Password: "secret",
+		Channel:  "#minecraft",
+	}
+	client := NewIRCClient(conf, waterlog.New(ioutil.Discard, "", 0))
+	messages := make(chan *MinecraftMessage, 10)
+	go client.Listen(messages)
+
+	server.accept(t)
+	server.expect(t, "PASS secret")
+	server.expect(t, "NICK dolphin")
+	server.expect(t, "USER dolphin")
+	server.send(":irc.example.com 001 dolphin :Welcome")
+	server.expect(t, "JOIN #minecraft")
+
+	return client, server, messages
+}
+
+func TestIRCReceivesChannelMessages(t *testing.T) {
+	// Given
+	client, server, messages := connectIRC(t)
+	defer server.close()
+	defer client.Close()
+
+	// When
+	server.send(":alice!alice@example.com PRIVMSG #other :not bridged")
+	server.send(":alice!alice@example.com PRIVMSG #Minecraft :hello there")
+	server.send(":bob!bob@example.com PRIVMSG #minecraft :\x01ACTION waves\x01")
+
+	// Then
+	for _, expected := range []string{"alice: hello there", "bob: * bob waves"} {
+		select {
+		case msg := <-messages:
+			if actual := msg.Username + ": " + msg.Message; actual != expected || msg.Type != ChatMessage {
+				t.Errorf("Message from IRC is incorrect, expected: %s, got: %s", expected, actual)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for %q", expected)
+		}
+	}
+}
+
+func TestIRCAnswersPings(t *testing.T) {
+	// Given
+	client, server, _ := connectIRC(t)
+	defer server.close()
+	defer client.Close()
+
+	// When
+	server.send("PING :irc.example.com")
+
+	// Then
+	server.expect(t, "PONG :irc.example.com")
+}
+
+func TestIRCSendsMessages(t *testing.T) {
+	// Given
+	client, server, _ := connectIRC(t)
+	defer server.close()
+	defer client.Close()
+
+	// When
+	err := client.Send(&MinecraftMessage{Username: "Steve", Message: "hi\nthere", Type: ChatMessage})
+	client.Send(&MinecraftMessage{Username: "TestBot", Message: ":skull: Steve blew up", Type: DeathMessage})
+
+	// Then
+	if err != nil {
+		t.Fatalf("Failed to send to IRC: %s", err)
+	}
+	server.expect(t, "PRIVMSG #minecraft :<Steve> hi")
+	server.expect(t, "PRIVMSG #minecraft :there")
+	server.expect(t, "PRIVMSG #minecraft ::skull: Steve blew up")
+}
+
+func TestIRCSendWhileDisconnected(t *testing.T) {
+	// Given
+	client := NewIRCClient(config.IRCConfig{Channel: "#minecraft"}, waterlog.New(ioutil.Discard, "", 0))
+
+	// When
+	err := client.Send(&MinecraftMessage{Username: "Steve", Message: "hi", Type: ChatMessage})
+
+	// Then
+	if err != errIRCNotConnected {
+		t.Errorf("Expected an error when not connected, got: %v", err)
+	}
+}
+
+func TestParseIRCLine(t *testing.T) {
+	// Given
+	tests := map[string]ircMessage{
+		":alice!a@host PRIVMSG #minecraft :hello there":                  {Nick: "alice", Command: "PRIVMSG", Params: []string{"#minecraft", "hello there"}},
+		"PING :irc.example.com":                                          {Command: "PING", Params: []string{"irc.example.com"}},
+		":irc.example.com 433 * dolphin :Nickname is already in use\r\n": {Nick: "irc.example.com", Command: "433", Params: []string{"*", "dolphin", "Nickname is already in use"}},
+	}
+
+	for line, expected := range tests {
+		// When
+		actual, ok := parseIRCLine(line)
+
+		// Then
+		if !ok || !cmp.Equal(actual, expected) {
+			t.Errorf("Parsed line is incorrect: Diff: %s", cmp.Diff(actual, expected))
+		}
+	}
+}
+
+func TestSplitIRCText(t *testing.T) {
+	// Given
+	text := "first\n" + strings.Repeat("a", ircLineLength+10)
+
+	// When
+	lines := splitIRCText(text)
+
+	// Then
+	expected := []string{"first", strings.Repeat("a", ircLineLength), strings.Repeat("a", 10)}
+	if !cmp.Equal(lines, expected) {
+		t.Errorf("Split text is incorrect: Diff: %s", cmp.Diff(lines, expected))
+	}
+}
+
+func TestSplitIRCTextKeepsCharacters(t *testing.T) {
+	// Given
+	text := "a" + strings.Repeat("é", ircLineLength)
+
+	// When
+	lines := splitIRCText(text)
+
+	// Then
+	if strings.Join(lines, "") != text {
+		t.Errorf("Split text should add up to the original text")
+	}
+	for _, line := range lines {
+		if !utf8.ValidString(line) || len(line) > ircLineLength {
+			t.Errorf("Line is cut in the middle of a character or too long: %q", line)
+		}
+	}
+}
+
+func TestIRCCloseTwice(t *testing.T) {
+	// Given
+	client := NewIRCClient(config.IRCConfig{}, waterlog.New(ioutil.Discard, "", 0))
+
+	// When
+	first := client.Close()
+	second := client.Close()
+
+	// Then
+	if first != nil || second != nil {
+		t.Errorf("Closing twice should not fail, got: %v, %v", first, second)
+	}
+}
@@ -0,0 +1,201 @@
+package dolphin
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/diamondburned/arikawa/api"
+	"github.com/diamondburned/arikawa/discord"
+	"gitlab.com/EbonJaeger/dolphin/config"
+)
+
+// mentionTrimChars are characters that may trail a mention in a chat message,
+// but aren't part of the name being mentioned.
+const mentionTrimChars = ".,!?:;'\")"
+
+// mentionRegex matches words that start with an @.
+var mentionRegex = regexp.MustCompile(`(^|\s)@(\S+)`)
+
+// insertMentions looks for potential Discord mentions in a Minecraft chat
+// message. If there are any, we will attempt to get the user or role being
+// mentioned to get their mention string to put into the chat message.
+//
+// The returned AllowedMentions only contains the users and roles that we
+// inserted, so players can never ping everyone, here, or anyone that the
+// configured mention policy doesn't allow.
+func (bot *DiscordBot) insertMentions(msg string) (string, *api.AllowedMentions) {
+	// Start with nothing allowed at all
+	allowed := &api.AllowedMentions{
+		Parse: []api.AllowedMentionType{},
+	}
+
+	conf := bot.bridge.config()
+	policy := conf.Discord.Mentions.Policy
+	if !conf.Discord.AllowMentions || policy == config.MentionPolicyNone {
+		return msg, allowed
+	}
+
+	msg = replaceMentions(msg, func(name string) string {
+		// Attempt to get the user
+		if user := bot.getUserFromName(name); user != nil {
+			allowed.Users = appendUserID(allowed.Users, user.ID)
+			return user.Mention()
+		}
+
+		// Attempt to get an allowed role
+		if policy == config.MentionPolicyRoles {
+			if role := bot.getRoleFromName(name); role != nil {
+				allowed.Roles = appendRoleID(allowed.Roles, role.ID)
+				return role.Mention()
+			}
+		}
+
+		return ""
+	})
+
+	return msg, allowed
+}
+
+// replaceMentions replaces every word in a message that is an @ followed by
+// a name with the mention that lookup returns for that name. Only whole words
+// are replaced, so @bob doesn't change @bobby. Longer names are looked up
+// first. If lookup returns an empty string, the word is left alone.
+func replaceMentions(msg string, lookup func(name string) string) string {
+	matches := mentionRegex.FindAllStringSubmatchIndex(msg, -1)
+	if len(matches) == 0 {
+		return msg
+	}
+
+	// Get the names being mentioned, without any trailing punctuation
+	names := make([]string, 0, len(matches))
+	mentions := make(map[string]string)
+	for _, match := range matches {
+		name := strings.TrimRight(msg[match[4]:match[5]], mentionTrimChars)
+		if name == "" || strings.EqualFold(name, "everyone") || strings.EqualFold(name, "here") {
+			continue
+		}
+		if _, ok := mentions[name]; !ok {
+			mentions[name] = ""
+			names = append(names, name)
+		}
+	}
+
+	// Look up the longest names first
+	sort.SliceStable(names, func(i, j int) bool {
+		return len(names[i]) > len(names[j])
+	})
+	for _, name := range names {
+		mentions[name] = lookup(name)
+	}
+
+	// Put the mentions in place of the words
+	var b strings.Builder
+	last := 0
+	for _, match := range matches {
+		start, end := match[4], match[5]
+		name := strings.TrimRight(msg[start:end], mentionTrimChars)
+		mention := mentions[name]
+		if mention == "" {
+			continue
+		}
+
+		// Replace the @ and the name, and keep the punctuation after it
+		b.WriteString(msg[last : start-1])
+		b.WriteString(mention)
+		last = start + len(name)
+	}
+	b.WriteString(msg[last:])
+
+	return b.String()
+}
+
+// getUserFromName gets the Discord user from a username or nickname, or the
+// name of a linked Minecraft player. The name can be only a partial name.
+func (bot *DiscordBot) getUserFromName(text string) *discord.User {
+	// Players can mention linked users by their Minecraft name
+	if bot.bridge.config().Discord.Linking.UseLinkedNames {
+		if userID, ok := bot.bridge.parser.LinkedUser(text); ok {
+			return &discord.User{ID: userID}
+		}
+	}
+
+	// Look through all guild members in the state
+	members, _ := bot.state.Members(bot.guildID)
+	if member := findMember(members, text); member != nil {
+		return &member.User
+	}
+
+	return nil
+}
+
+// getRoleFromName gets a Discord role by its name, if that role is in the
+// configured list of roles that can be mentioned.
+func (bot *DiscordBot) getRoleFromName(text string) *discord.Role {
+	roles, _ := bot.state.Roles(bot.guildID)
+	return findRole(roles, text, *bot.bridge.config().Discord.Mentions.AllowedRoles)
+}
+
+// findMember looks for the member whose username or nickname matches the
+// given name, case-insensitive. Exact matches are preferred. If there are
+// none, the name is treated as the start of a name, but only if exactly one
+// member matches it.
+func findMember(members []discord.Member, name string) *discord.Member {
+	// Look for an exact match first
+	for i, m := range members {
+		if strings.EqualFold(m.User.Username, name) || (m.Nick != "" && strings.EqualFold(m.Nick, name)) {
+			return &members[i]
+		}
+	}
+
+	// Look for partial matches
+	lower := strings.ToLower(name)
+	var found *discord.Member
+	for i, m := range members {
+		if strings.HasPrefix(strings.ToLower(m.User.Username), lower) || (m.Nick != "" && strings.HasPrefix(strings.ToLower(m.Nick), lower)) {
+			// Don't guess if the name is ambiguous
+			if found != nil {
+				return nil
+			}
+			found = &members[i]
+		}
+	}
+
+	return found
+}
+
+// findRole looks for a role with the given name, case-insensitive, that is in
+// the list of allowed role IDs.
+func findRole(roles []discord.Role, name string, allowedIDs []string) *discord.Role {
+	for i, r := range roles {
+		if !strings.EqualFold(r.Name, name) {
+			continue
+		}
+
+		for _, id := range allowedIDs {
+			if r.ID.String() == id {
+				return &roles[i]
+			}
+		}
+	}
+
+	return nil
+}
+
+func appendUserID(ids []discord.UserID, id discord.UserID) []discord.UserID {
+	for _, existing := range ids {
+		if existing == id {
+			return ids
+		}
+	}
+	return append(ids, id)
+}
+
+func appendRoleID(ids []discord.RoleID, id discord.RoleID) []discord.RoleID {
+	for _, existing := range ids {
+		if existing == id {
+			return ids
+		}
+	}
+	return append(ids, id)
+}
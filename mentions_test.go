@@ -0,0 +1,89 @@
+package dolphin
+
+import (
+	"testing"
+
+	"github.com/diamondburned/arikawa/discord"
+)
+
+var testMembers = []discord.Member{
+	{User: discord.User{ID: 1, Username: "EbonJaeger"}, Nick: "Evan"},
+	{User: discord.User{ID: 2, Username: "Everest"}},
+	{User: discord.User{ID: 3, Username: "Steve"}, Nick: "Builder"},
+}
+
+func TestFindMemberByUsername(t *testing.T) {
+	// When
+	actual := findMember(testMembers, "ebonjaeger")
+	// Then
+	if actual == nil || actual.User.ID != 1 {
+		t.Errorf("Finding member by username got incorrect member, got: %v, expected ID: 1", actual)
+	}
+}
+
+func TestFindMemberByNickname(t *testing.T) {
+	// When
+	actual := findMember(testMembers, "builder")
+	// Then
+	if actual == nil || actual.User.ID != 3 {
+		t.Errorf("Finding member by nickname got incorrect member, got: %v, expected ID: 3", actual)
+	}
+}
+
+func TestFindMemberByPartialName(t *testing.T) {
+	// When
+	actual := findMember(testMembers, "Ste")
+	// Then
+	if actual == nil || actual.User.ID != 3 {
+		t.Errorf("Finding member by partial name got incorrect member, got: %v, expected ID: 3", actual)
+	}
+}
+
+func TestFindMemberAmbiguousPartialName(t *testing.T) {
+	// When
+	actual := findMember(testMembers, "Ev")
+	// Then
+	if actual != nil {
+		t.Errorf("Finding member by ambiguous name should find nothing, got: %v", actual)
+	}
+}
+
+func TestFindRoleNotAllowed(t *testing.T) {
+	// Given
+	roles := []discord.Role{
+		{ID: 10, Name: "Moderators"},
+		{ID: 11, Name: "Admins"},
+	}
+	// When
+	allowed := findRole(roles, "moderators", []string{"10"})
+	denied := findRole(roles, "admins", []string{"10"})
+	// Then
+	if allowed == nil || allowed.ID != 10 {
+		t.Errorf("Finding allowed role got incorrect role, got: %v, expected ID: 10", allowed)
+	}
+	if denied != nil {
+		t.Errorf("Finding a role that isn't allowed should find nothing, got: %v", denied)
+	}
+}
+
+func TestReplaceMentionsWholeWords(t *testing.T) {
+	// Given
+	msg := "@bobby and @bob, hi! me@bob.com @everyone"
+	mentions := map[string]string{"bob": "<@2>", "everyone": "<@3>"}
+	var lookups []string
+
+	// When
+	actual := replaceMentions(msg, func(name string) string {
+		lookups = append(lookups, name)
+		return mentions[name]
+	})
+
+	// Then
+	expected := "@bobby and <@2>, hi! me@bob.com @everyone"
+	if actual != expected {
+		t.Errorf("Replacing mentions got incorrect message, got: %s, expected: %s", actual, expected)
+	}
+	if len(lookups) != 2 || lookups[0] != "bobby" || lookups[1] != "bob" {
+		t.Errorf("Names should be looked up longest first, got: %v", lookups)
+	}
+}
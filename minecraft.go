@@ -4,73 +4,187 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"regexp"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/DataDrake/waterlog"
 	"github.com/nxadm/tail"
 )
 
 // MinecraftWatcher watches for log lines from a Minecraft server.
 type MinecraftWatcher struct {
 	botName       string
+	console       *Console
 	deathKeywords []string
-	tail          *tail.Tail
+	log           *waterlog.WaterLog
+	reload        chan watcherSettings
+	done          chan struct{}
+	closeOnce     sync.Once
+
+	// mu guards tail, which is replaced by the watching goroutine when the
+	// log file moves
+	mu   sync.Mutex
+	tail *tail.Tail
+}
+
+// watcherSettings are the settings of a watcher that can be changed while
+// it is watching.
+type watcherSettings struct {
+	console       *Console
+	deathKeywords []string
+	logFilePath   string
 }
 
 // NewWatcher creates a new watcher with all of the Minecraft death message keywords.
-func NewWatcher(botName string, customDeathKeywords []string) *MinecraftWatcher {
+func NewWatcher(botName string, customDeathKeywords []string, log *waterlog.WaterLog) *MinecraftWatcher {
+	return &MinecraftWatcher{
+		botName:       botName,
+		deathKeywords: deathKeywords(customDeathKeywords),
+		log:           log,
+		reload:        make(chan watcherSettings, 1),
+		done:          make(chan struct{}),
+	}
+}
+
+// deathKeywords gets all of the Minecraft death message keywords, and any
+// custom ones.
+func deathKeywords(customDeathKeywords []string) []string {
 	var deathKeywords = []string{" shot", " pricked", " walked into a cactus", " roasted", " drowned", " kinetic", " blew up", " blown up", " killed", " hit the ground", " fell", " doomed", " squashed", " magic", " flames", " burned", " walked into fire", " burnt", " bang", " tried to swim in lava", " lightning", "floor was lava", "danger zone", " slain", " fireballed", " stung", " starved", " suffocated", " squished", " poked", " imapled", "didn't want to live", " withered", " pummeled", " died", " slain"}
 	// Append any custom death keywords
 	if len(customDeathKeywords) > 0 {
 		deathKeywords = append(deathKeywords, customDeathKeywords...)
 	}
-	return &MinecraftWatcher{
-		botName:       botName,
-		deathKeywords: deathKeywords,
-	}
+	return deathKeywords
 }
 
-// Close stops the tail process and cleans up inotify file watches.
+// Close stops watching, and stops the tail process and cleans up inotify
+// file watches if a log file is being tailed.
 func (w *MinecraftWatcher) Close() error {
+	w.closeOnce.Do(func() {
+		close(w.done)
+	})
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.tail == nil {
+		return nil
+	}
 	err := w.tail.Stop()
 	w.tail.Cleanup()
+	w.tail = nil
 	return err
 }
 
 // Watch watches a log file for changes and sends Minecraft messages
 // to the given channel.
-func (w *MinecraftWatcher) Watch(c chan<- *MinecraftMessage) {
-	if Config.Minecraft.UseLogFile {
-		// Check that the log file exists
-		if _, err := os.Stat(Config.Minecraft.LogFilePath); err == nil {
-			Log.Infof("Using Minecraft log file at '%s'\n", Config.Minecraft.LogFilePath)
-			// Start tailing the file
-			var tailErr error
-			w.tail, tailErr = tail.TailFile(Config.Minecraft.LogFilePath, tail.Config{
-				Location: &tail.SeekInfo{
-					Whence: io.SeekEnd,
-				},
-				ReOpen: true,
-				Follow: true,
-			})
-			if tailErr != nil {
-				Log.Fatalf("Error trying to tail log file: %s\n", tailErr.Error())
+func (w *MinecraftWatcher) Watch(path string, c chan<- *MinecraftMessage) {
+	t, err := w.open(path)
+	if err != nil {
+		w.log.Fatalf("Error opening log file: %s\n", err.Error())
+	}
+	if !w.setTail(t) {
+		return
+	}
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case line, ok := <-t.Lines:
+			if !ok {
+				return
+			}
+			if line == nil {
+				continue
+			}
+
+			// Mirror every line to the console channel
+			if w.console != nil {
+				w.console.HandleLine(line.Text)
+			}
+
+			// Parse the line to see if it's a message we care about
+			if msg := w.ParseLine(w.botName, line.Text); msg != nil {
+				// Send the message through the channel
+				c <- msg
+			}
+		case settings := <-w.reload:
+			w.console = settings.console
+			w.deathKeywords = settings.deathKeywords
+
+			// Switch to the new log file if it moved
+			if settings.logFilePath == path {
+				continue
+			}
+			next, err := w.open(settings.logFilePath)
+			if err != nil {
+				w.log.Errorf("Error opening new log file, still using '%s': %s\n", path, err)
+				continue
 			}
-			for {
-				// Read line from the Tail channel
-				if line := <-w.tail.Lines; line != nil {
-					// Parse the line to see if it's a message we care about
-					if msg := w.ParseLine(w.botName, line.Text); msg != nil {
-						// Send the message through the channel
-						c <- msg
-					}
-				}
+			if !w.setTail(next) {
+				return
 			}
-		} else {
-			Log.Fatalf("Error opening log file: %s\n", err.Error())
+			t.Stop()
+			t.Cleanup()
+			t = next
+			path = settings.logFilePath
 		}
 	}
 }
 
+// Reload changes the settings of the watcher. The changes are made by the
+// watching goroutine, so they never happen while a line is being handled.
+func (w *MinecraftWatcher) Reload(settings watcherSettings) {
+	// Only the newest settings matter
+	select {
+	case <-w.reload:
+	default:
+	}
+	w.reload <- settings
+}
+
+// setTail makes a tail the one Close stops. If the watcher was already
+// closed, the tail is stopped instead, and false is returned.
+func (w *MinecraftWatcher) setTail(t *tail.Tail) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	select {
+	case <-w.done:
+		t.Stop()
+		t.Cleanup()
+		return false
+	default:
+	}
+
+	w.tail = t
+	return true
+}
+
+// open starts tailing a log file from its end.
+func (w *MinecraftWatcher) open(path string) (*tail.Tail, error) {
+	// Check that the log file exists
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+
+	w.log.Infof("Using Minecraft log file at '%s'\n", path)
+	t, err := tail.TailFile(path, tail.Config{
+		Location: &tail.SeekInfo{
+			Whence: io.SeekEnd,
+		},
+		ReOpen: true,
+		Follow: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
 // ParseLine parses a log line for various types of messages and
 // returns a MinecraftMessage struct if it is a message we care about.
 func (w *MinecraftWatcher) ParseLine(botName string, line string) *MinecraftMessage {
@@ -96,33 +210,53 @@ func (w *MinecraftWatcher) ParseLine(botName string, line string) *MinecraftMess
 		username = strings.TrimSuffix(username, ">")
 		message := parts[1]
 		return &MinecraftMessage{
-			Username: username,
-			Message:  message,
+			Username:  username,
+			Message:   message,
+			Type:      ChatMessage,
+			Player:    username,
+			Timestamp: time.Now(),
 		}
 	}
 
 	// Check for player join or leave
 	if strings.Contains(line, "joined the game") || strings.Contains(line, "left the game") {
+		detail := "joined"
+		if strings.Contains(line, "left the game") {
+			detail = "left"
+		}
 		return &MinecraftMessage{
-			Username: botName,
-			Message:  line,
+			Username:  botName,
+			Message:   line,
+			Type:      JoinLeaveMessage,
+			Player:    playerName(line),
+			Detail:    detail,
+			Timestamp: time.Now(),
 		}
 	}
 
 	// Check if the line is an advancement message
 	if isAdvancement(line) {
 		return &MinecraftMessage{
-			Username: botName,
-			Message:  fmt.Sprintf(":partying_face: %s", line),
+			Username:  botName,
+			Message:   fmt.Sprintf(":partying_face: %s", line),
+			Type:      AdvancementMessage,
+			Player:    playerName(line),
+			Detail:    advancementTitle(line),
+			Timestamp: time.Now(),
 		}
 	}
 
 	// Check if the line is a death message
 	for _, word := range w.deathKeywords {
 		if strings.Contains(line, word) && line != "Found that the dragon has been killed in this world already." {
+			player := playerName(line)
 			return &MinecraftMessage{
-				Username: botName,
-				Message:  fmt.Sprintf(":skull: %s", line),
+				Username:  botName,
+				Message:   fmt.Sprintf(":skull: %s", line),
+				Type:      DeathMessage,
+				Player:    player,
+				Detail:    strings.TrimSpace(strings.TrimPrefix(line, player)),
+				Timestamp: time.Now(),
 			}
 		}
 	}
@@ -130,16 +264,20 @@ func (w *MinecraftWatcher) ParseLine(botName string, line string) *MinecraftMess
 	// Check if the server just finished starting
 	if strings.HasPrefix(line, "Done (") {
 		return &MinecraftMessage{
-			Username: botName,
-			Message:  ":white_check_mark: Server has started",
+			Username:  botName,
+			Message:   ":white_check_mark: Server has started",
+			Type:      ServerStartMessage,
+			Timestamp: time.Now(),
 		}
 	}
 
 	// Check if the server is shutting down
 	if strings.HasPrefix(line, "Stopping the server") {
 		return &MinecraftMessage{
-			Username: botName,
-			Message:  ":x: Server is shutting down",
+			Username:  botName,
+			Message:   ":x: Server is shutting down",
+			Type:      ServerStopMessage,
+			Timestamp: time.Now(),
 		}
 	}
 
@@ -147,12 +285,97 @@ func (w *MinecraftWatcher) ParseLine(botName string, line string) *MinecraftMess
 	return nil
 }
 
+// deathCauses are the kinds of death, and words in death messages that mean
+// a death is that kind. The first match is used.
+var deathCauses = []struct {
+	Cause    string
+	Keywords []string
+}{
+	{"Shot", []string{" shot", "fireballed"}},
+	{"Explosion", []string{"blew up", "blown up", "bang"}},
+	{"Fall", []string{" fell", "hit the ground", "doomed", "kinetic"}},
+	{"Drowning", []string{"drowned"}},
+	{"Lava", []string{"lava", "danger zone"}},
+	{"Fire", []string{"flames", "burn", "fire", "roasted"}},
+	{"Lightning", []string{"lightning"}},
+	{"Starvation", []string{"starved"}},
+	{"Suffocation", []string{"suffocated", "squished", "squashed"}},
+	{"Cactus", []string{"cactus", "pricked", "poked"}},
+	{"Wither", []string{"withered"}},
+	{"Magic", []string{"magic"}},
+	{"Killed", []string{"slain", "killed", "stung", "pummeled", "impaled", "imapled"}},
+}
+
+// killerRegex matches the mob or player that killed a player in a death
+// message, such as "was shot by Skeleton using Bow".
+// The last one in the message is used, so that "by magic whilst trying to
+// escape Zombie" gives the zombie.
+var killerRegex = regexp.MustCompile(`.*(?: by | whilst fighting | to escape )(.+?)(?: using .+)?$`)
+
+// environmentKillers are things that kill players in death messages that
+// aren't mobs or players, so they aren't recorded as killers.
+var environmentKillers = []string{
+	"lightning",
+	"magic",
+	"even more magic",
+	"a falling anvil",
+	"a falling block",
+	"a falling stalactite",
+	"a sweet berry bush",
+	"[intentional game design]",
+}
+
+// parseDeath gets the kind of death and the killer, if any, from the part
+// of a death message after the player's name.
+func parseDeath(detail string) (cause string, killer string) {
+	cause = "Other"
+	for _, c := range deathCauses {
+		if containsAny(" "+detail, c.Keywords) {
+			cause = c.Cause
+			break
+		}
+	}
+
+	if match := killerRegex.FindStringSubmatch(detail); match != nil && !containsFold(environmentKillers, match[1]) {
+		killer = match[1]
+	}
+
+	return cause, killer
+}
+
+// containsAny checks if a string contains any of the given substrings.
+func containsAny(s string, substrs []string) bool {
+	for _, substr := range substrs {
+		if strings.Contains(s, substr) {
+			return true
+		}
+	}
+	return false
+}
+
 func isAdvancement(line string) bool {
 	return strings.Contains(line, "has made the advancement") ||
 		strings.Contains(line, "has completed the challenge") ||
 		strings.Contains(line, "has reached the goal")
 }
 
+// advancementTitle gets the name of the advancement from an advancement
+// message, without the surrounding brackets.
+func advancementTitle(line string) string {
+	start := strings.LastIndex(line, "[")
+	end := strings.LastIndex(line, "]")
+	if start == -1 || end < start {
+		return ""
+	}
+	return line[start+1 : end]
+}
+
+// playerName gets the name of the player at the start of a server message.
+// Player names can't contain spaces, so this is the first word of the line.
+func playerName(line string) string {
+	return strings.SplitN(line, " ", 2)[0]
+}
+
 // trimPrefix trims the timestamp and thread prefix from incoming messages
 // from the Minecraft server. We have to check for multiple prefixes because
 // different server softwares change logging output slightly. Returns an empty
@@ -1,10 +1,16 @@
 package dolphin
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
+
+	"github.com/DataDrake/waterlog"
 )
 
-var watcher = NewWatcher("TestBot", make([]string, 0))
+var watcher = NewWatcher("TestBot", make([]string, 0), waterlog.New(ioutil.Discard, "", 0))
 
 func TestParseVanillaChatLine(t *testing.T) {
 	// Given
@@ -162,3 +168,120 @@ func TestIgnoreVillagerDeath(t *testing.T) {
 		t.Errorf("Parsing line failed to ignore villager death message, got: %s", result)
 	}
 }
+
+func TestParseAdvancementDetails(t *testing.T) {
+	// Given
+	input := "[12:32:45] [Server thread/INFO]: TestUser has made the advancement [Monster Hunter]"
+	// When
+	actual := watcher.ParseLine("TestBot", input)
+	// Then
+	if actual.Type != AdvancementMessage {
+		t.Errorf("Parsing advancement line got incorrect type, got: %s, expected: %s", actual.Type, AdvancementMessage)
+	}
+	if actual.Player != "TestUser" {
+		t.Errorf("Parsing advancement line got incorrect player, got: %s, expected: %s", actual.Player, "TestUser")
+	}
+	if actual.Detail != "Monster Hunter" {
+		t.Errorf("Parsing advancement line got incorrect title, got: %s, expected: %s", actual.Detail, "Monster Hunter")
+	}
+}
+
+func TestParseDeathDetails(t *testing.T) {
+	// Given
+	input := "[12:32:45] [Server thread/INFO]: TestUser was shot by Skeleton"
+	// When
+	actual := watcher.ParseLine("TestBot", input)
+	// Then
+	if actual.Type != DeathMessage {
+		t.Errorf("Parsing death line got incorrect type, got: %s, expected: %s", actual.Type, DeathMessage)
+	}
+	if actual.Player != "TestUser" {
+		t.Errorf("Parsing death line got incorrect player, got: %s, expected: %s", actual.Player, "TestUser")
+	}
+	if actual.Detail != "was shot by Skeleton" {
+		t.Errorf("Parsing death line got incorrect cause, got: %s, expected: %s", actual.Detail, "was shot by Skeleton")
+	}
+}
+
+func TestParseDeathCause(t *testing.T) {
+	tests := []struct {
+		detail string
+		cause  string
+		killer string
+	}{
+		{"was shot by Skeleton using Bow", "Shot", "Skeleton"},
+		{"was slain by Zombie", "Killed", "Zombie"},
+		{"fell from a high place", "Fall", ""},
+		{"hit the ground too hard whilst trying to escape Creeper", "Fall", "Creeper"},
+		{"was blown up by Creeper", "Explosion", "Creeper"},
+		{"tried to swim in lava", "Lava", ""},
+		{"was killed by magic whilst trying to escape Witch", "Magic", "Witch"},
+		{"was killed by magic", "Magic", ""},
+		{"drowned", "Drowning", ""},
+		{"went off with a bang", "Explosion", ""},
+		{"died", "Other", ""},
+		{"experienced kinetic energy", "Fall", ""},
+		{"was struck by lightning", "Lightning", ""},
+		{"was squashed by a falling anvil", "Suffocation", ""},
+		{"was squashed by a falling anvil whilst fighting Zombie", "Suffocation", "Zombie"},
+	}
+
+	for _, test := range tests {
+		// Given
+		detail := test.detail
+
+		// When
+		cause, killer := parseDeath(detail)
+
+		// Then
+		if cause != test.cause || killer != test.killer {
+			t.Errorf("Parsing death '%s' is incorrect, got: %s/%s, expected: %s/%s", detail, cause, killer, test.cause, test.killer)
+		}
+	}
+}
+
+func TestCloseWatcherWithoutLogFile(t *testing.T) {
+	// Given
+	w := NewWatcher("TestBot", make([]string, 0), waterlog.New(ioutil.Discard, "", 0))
+	// When
+	first := w.Close()
+	second := w.Close()
+	// Then
+	if first != nil || second != nil {
+		t.Errorf("Closing a watcher that isn't watching should not fail, got: %v, %v", first, second)
+	}
+}
+
+func TestCloseWatcherWhileReloading(t *testing.T) {
+	// Given
+	dir, err := ioutil.TempDir("", "dolphin")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	first := filepath.Join(dir, "latest.log")
+	second := filepath.Join(dir, "moved.log")
+	for _, path := range []string{first, second} {
+		if err := ioutil.WriteFile(path, []byte{}, 0644); err != nil {
+			t.Fatalf("Failed to write log file: %s", err)
+		}
+	}
+	w := NewWatcher("TestBot", make([]string, 0), waterlog.New(ioutil.Discard, "", 0))
+	done := make(chan struct{})
+	go func() {
+		w.Watch(first, make(chan *MinecraftMessage))
+		close(done)
+	}()
+	// When
+	w.Reload(watcherSettings{logFilePath: second})
+	err = w.Close()
+	// Then
+	if err != nil {
+		t.Errorf("Failed to close watcher: %s", err)
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Watching did not stop after the watcher was closed")
+	}
+}
@@ -0,0 +1,135 @@
+package query
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+)
+
+// Packet types of the query protocol.
+const (
+	packetTypeStat      byte = 0
+	packetTypeHandshake byte = 9
+)
+
+// magic starts every request sent to the server.
+var magic = []byte{0xFE, 0xFD}
+
+// sessionID identifies our requests. Only the lower four bits of each byte
+// are used by the server.
+const sessionID int32 = 0x01020304
+
+// maxPacketSize is the largest response we read from the server.
+const maxPacketSize = 1500
+
+// ErrInvalidResponse is returned when the server sends a response that
+// isn't a valid query response.
+var ErrInvalidResponse = errors.New("invalid query response")
+
+// Status is the basic status of a Minecraft server.
+type Status struct {
+	MOTD    string
+	Map     string
+	Online  int
+	Max     int
+	Port    int
+	Address string
+}
+
+// Stat gets the basic status of a Minecraft server using the query protocol.
+// The server must have enable-query=true in its server.properties.
+func Stat(host string, port int, timeout time.Duration) (Status, error) {
+	conn, err := net.DialTimeout("udp", net.JoinHostPort(host, strconv.Itoa(port)), timeout)
+	if err != nil {
+		return Status{}, err
+	}
+	defer conn.Close()
+	if err = conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return Status{}, err
+	}
+
+	// Get a challenge token to send with the stat request
+	resp, err := request(conn, packetTypeHandshake, nil)
+	if err != nil {
+		return Status{}, err
+	}
+	token, err := strconv.ParseInt(string(bytes.TrimRight(resp, "\x00")), 10, 32)
+	if err != nil {
+		return Status{}, ErrInvalidResponse
+	}
+
+	payload := make([]byte, 4)
+	binary.BigEndian.PutUint32(payload, uint32(token))
+	resp, err = request(conn, packetTypeStat, payload)
+	if err != nil {
+		return Status{}, err
+	}
+
+	return parseStat(resp)
+}
+
+// request sends a request to the server, and returns the payload of its
+// response.
+func request(conn net.Conn, packetType byte, payload []byte) ([]byte, error) {
+	buf := bytes.Buffer{}
+	buf.Write(magic)
+	buf.WriteByte(packetType)
+	binary.Write(&buf, binary.BigEndian, sessionID)
+	buf.Write(payload)
+	if _, err := conn.Write(buf.Bytes()); err != nil {
+		return nil, err
+	}
+
+	resp := make([]byte, maxPacketSize)
+	n, err := conn.Read(resp)
+	if err != nil {
+		return nil, err
+	}
+	resp = resp[:n]
+
+	// Responses start with the packet type and session ID of the request
+	if len(resp) < 5 || resp[0] != packetType || int32(binary.BigEndian.Uint32(resp[1:5])) != sessionID {
+		return nil, ErrInvalidResponse
+	}
+	return resp[5:], nil
+}
+
+// parseStat parses the payload of a basic stat response. It has the MOTD,
+// game type, map, and player counts as null-terminated strings, then the
+// port as a little-endian short, and then the address.
+func parseStat(payload []byte) (Status, error) {
+	fields := make([]string, 0, 5)
+	for len(fields) < 5 {
+		end := bytes.IndexByte(payload, 0)
+		if end == -1 {
+			return Status{}, ErrInvalidResponse
+		}
+		fields = append(fields, string(payload[:end]))
+		payload = payload[end+1:]
+	}
+	if len(payload) < 2 {
+		return Status{}, ErrInvalidResponse
+	}
+
+	online, err := strconv.Atoi(fields[3])
+	if err != nil {
+		return Status{}, fmt.Errorf("%w: player count %q", ErrInvalidResponse, fields[3])
+	}
+	max, err := strconv.Atoi(fields[4])
+	if err != nil {
+		return Status{}, fmt.Errorf("%w: max players %q", ErrInvalidResponse, fields[4])
+	}
+
+	return Status{
+		MOTD:    fields[0],
+		Map:     fields[2],
+		Online:  online,
+		Max:     max,
+		Port:    int(binary.LittleEndian.Uint16(payload[:2])),
+		Address: string(bytes.TrimRight(payload[2:], "\x00")),
+	}, nil
+}
@@ -0,0 +1,89 @@
+package query
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// serve answers query requests like a Minecraft server would, until the
+// connection is closed.
+func serve(conn net.PacketConn, token int32, stat []byte) {
+	buf := make([]byte, maxPacketSize)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		req := buf[:n]
+		if n < 7 || !bytes.Equal(req[:2], magic) {
+			continue
+		}
+
+		resp := append([]byte{req[2]}, req[3:7]...)
+		switch req[2] {
+		case packetTypeHandshake:
+			resp = append(resp, []byte("9513307\x00")...)
+		case packetTypeStat:
+			if n != 11 || int32(binary.BigEndian.Uint32(req[7:11])) != token {
+				continue
+			}
+			resp = append(resp, stat...)
+		}
+		conn.WriteTo(resp, addr)
+	}
+}
+
+func TestStat(t *testing.T) {
+	// given
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err)
+	}
+	defer conn.Close()
+	stat := []byte("A Minecraft Server\x00SMP\x00world\x002\x0020\x00\xdd\x63127.0.0.1\x00")
+	go serve(conn, 9513307, stat)
+	port := conn.LocalAddr().(*net.UDPAddr).Port
+	expected := Status{
+		MOTD:    "A Minecraft Server",
+		Map:     "world",
+		Online:  2,
+		Max:     20,
+		Port:    25565,
+		Address: "127.0.0.1",
+	}
+
+	// when
+	actual, err := Stat("127.0.0.1", port, time.Second)
+
+	// then
+	if err != nil {
+		t.Fatalf("Failed to query the server: %s", err)
+	}
+	if !cmp.Equal(actual, expected) {
+		t.Errorf("Server status is incorrect: Diff: %s", cmp.Diff(actual, expected))
+	}
+}
+
+func TestParseInvalidStat(t *testing.T) {
+	// given
+	payloads := [][]byte{
+		[]byte("A Minecraft Server\x00SMP\x00world\x00"),
+		[]byte("A Minecraft Server\x00SMP\x00world\x00two\x0020\x00\xdd\x63127.0.0.1\x00"),
+		[]byte("A Minecraft Server\x00SMP\x00world\x002\x0020\x00"),
+	}
+
+	for _, payload := range payloads {
+		// when
+		_, err := parseStat(payload)
+
+		// then
+		if err == nil {
+			t.Errorf("Invalid stat response should not be parsed: %q", payload)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package rcon
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// formatCodeRegex matches Minecraft text formatting codes, such as §a.
+var formatCodeRegex = regexp.MustCompile("§.")
+
+// ErrUnsupported is returned when the server doesn't know a command.
+var ErrUnsupported = errors.New("command not supported by the server")
+
+// PlayerList is the result of the list command.
+type PlayerList struct {
+	Online  int
+	Max     int
+	Players []string
+}
+
+// Dialer connects and authenticates to a server's RCON, like Connect.
+type Dialer func(host string, port int, password string) (*Client, error)
+
+// Connect dials the given host and authenticates with the password.
+func Connect(host string, port int, password string) (*Client, error) {
+	conn, err := Dial(host, port, password)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := conn.Authenticate(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+// ListPlayers sends the list command to the server and parses the response.
+func (c *Client) ListPlayers() (PlayerList, error) {
+	resp, err := c.SendCommand("minecraft:list")
+	if err != nil {
+		return PlayerList{}, err
+	}
+
+	// Vanilla servers dont support the 'minecraft:' command prefix
+	if isUnknownCommand(resp) {
+		resp, err = c.SendCommand("list")
+		if err != nil {
+			return PlayerList{}, err
+		}
+	}
+
+	return ParsePlayerList(resp), nil
+}
+
+// TPS sends the tps command to the server and returns the average ticks per
+// second over the last minute. Only some server softwares, such as Spigot
+// and Paper, have this command. ErrUnsupported is returned if the server
+// doesn't have it.
+func (c *Client) TPS() (float64, error) {
+	resp, err := c.SendCommand("tps")
+	if err != nil {
+		return 0, err
+	}
+
+	if isUnknownCommand(resp) {
+		return 0, ErrUnsupported
+	}
+
+	return ParseTPS(resp)
+}
+
+// Whitelist sends the whitelist list command to the server and returns the
+// names of the whitelisted players.
+func (c *Client) Whitelist() ([]string, error) {
+	resp, err := c.SendCommand("whitelist list")
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseWhitelist(resp), nil
+}
+
+// ParsePlayerList parses the response of the list command, e.g.
+// "There are 2 of a max of 20 players online: Steve, Alex".
+func ParsePlayerList(resp string) PlayerList {
+	list := PlayerList{
+		Players: make([]string, 0),
+	}
+	parts := strings.SplitN(resp, ":", 2)
+
+	// Get the player counts
+	gotOnline := false
+	for _, part := range strings.Split(parts[0], " ") {
+		if num, err := strconv.Atoi(part); err == nil {
+			if gotOnline {
+				list.Max = num
+			} else {
+				list.Online = num
+				gotOnline = true
+			}
+		}
+	}
+
+	// Get the player names
+	if len(parts) > 1 {
+		for _, name := range strings.Split(parts[1], ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				list.Players = append(list.Players, name)
+			}
+		}
+	}
+
+	return list
+}
+
+// ParseWhitelist parses the response of the whitelist list command, e.g.
+// "There are 2 whitelisted players: Steve, Alex".
+func ParseWhitelist(resp string) []string {
+	names := make([]string, 0)
+
+	parts := strings.SplitN(StripFormatting(resp), ":", 2)
+	if len(parts) < 2 {
+		// There are no whitelisted players
+		return names
+	}
+
+	for _, name := range strings.Split(parts[1], ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// ParseTPS parses the response of the tps command, e.g.
+// "TPS from last 1m, 5m, 15m: 20.0, 19.98, 19.97", and returns the first value.
+func ParseTPS(resp string) (float64, error) {
+	resp = StripFormatting(resp)
+
+	parts := strings.SplitN(resp, ":", 2)
+	if len(parts) != 2 {
+		return 0, errors.New("unexpected tps response: " + resp)
+	}
+
+	// Paper marks values above 20 with an asterisk
+	first := strings.Split(parts[1], ",")[0]
+	first = strings.TrimPrefix(strings.TrimSpace(first), "*")
+
+	return strconv.ParseFloat(first, 64)
+}
+
+// isUnknownCommand checks if the response from the server says that the
+// command doesn't exist.
+func isUnknownCommand(resp string) bool {
+	return strings.HasPrefix(resp, "Unknown or incomplete command") || strings.HasPrefix(resp, "Unknown command")
+}
+
+// StripFormatting removes Minecraft text formatting codes, such as §a, from
+// the response to a command.
+func StripFormatting(resp string) string {
+	return formatCodeRegex.ReplaceAllString(resp, "")
+}
@@ -0,0 +1,78 @@
+package rcon
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParsePlayerList(t *testing.T) {
+	// given
+	resp := "There are 2 of a max of 20 players online: Steve, Alex"
+	expected := PlayerList{
+		Online:  2,
+		Max:     20,
+		Players: []string{"Steve", "Alex"},
+	}
+
+	// when
+	actual := ParsePlayerList(resp)
+
+	// then
+	if !cmp.Equal(actual, expected) {
+		t.Errorf("Parsed player list is incorrect: Diff: %s", cmp.Diff(actual, expected))
+	}
+}
+
+func TestParseEmptyPlayerList(t *testing.T) {
+	// given
+	resp := "There are 0 of a max of 20 players online: "
+
+	// when
+	actual := ParsePlayerList(resp)
+
+	// then
+	if actual.Online != 0 || actual.Max != 20 || len(actual.Players) != 0 {
+		t.Errorf("Parsed empty player list is incorrect: %+v", actual)
+	}
+}
+
+func TestParseWhitelist(t *testing.T) {
+	// given
+	resp := "There are 2 whitelisted players: Steve, Alex"
+	expected := []string{"Steve", "Alex"}
+
+	// when
+	actual := ParseWhitelist(resp)
+
+	// then
+	if !cmp.Equal(actual, expected) {
+		t.Errorf("Parsed whitelist is incorrect: Diff: %s", cmp.Diff(actual, expected))
+	}
+}
+
+func TestParseEmptyWhitelist(t *testing.T) {
+	// when
+	actual := ParseWhitelist("There are no whitelisted players")
+
+	// then
+	if len(actual) != 0 {
+		t.Errorf("Parsed empty whitelist is incorrect: %+v", actual)
+	}
+}
+
+func TestParseTPS(t *testing.T) {
+	// given
+	resp := "§6TPS from last 1m, 5m, 15m: §a*20.0, §a19.98, §a19.97"
+
+	// when
+	actual, err := ParseTPS(resp)
+
+	// then
+	if err != nil {
+		t.Errorf("Failed to parse TPS: %s", err)
+	}
+	if actual != 20.0 {
+		t.Errorf("Parsed TPS is incorrect, got: %f, expected: %f", actual, 20.0)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"strconv"
 	"time"
 )
 
@@ -36,7 +37,7 @@ type header struct {
 
 // Dial connects to the given host
 func Dial(host string, port int, password string) (*Client, error) {
-	addr := fmt.Sprintf("%s:%d", host, port)
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
 	// Establish a connection
 	conn, err := net.DialTimeout("tcp", addr, 10*time.Second)
 	if err != nil {
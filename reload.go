@@ -0,0 +1,235 @@
+package dolphin
+
+import (
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"time"
+
+	"github.com/diamondburned/arikawa/discord"
+	"github.com/fsnotify/fsnotify"
+	"gitlab.com/EbonJaeger/dolphin/config"
+)
+
+// configReloadDelay is how long to wait after the config file changes before
+// reloading it, so that editors that save a file in several steps only cause
+// one reload.
+const configReloadDelay = time.Second
+
+// ConfigReloader reloads the config when its file or the server's
+// server.properties changes, or when Dolphin gets a SIGHUP signal.
+type ConfigReloader struct {
+	bot  *DiscordBot
+	done chan struct{}
+	// properties is the path of the server.properties being watched, if any
+	properties string
+	signals    chan os.Signal
+	watcher    *fsnotify.Watcher
+}
+
+// NewConfigReloader creates a new config reloader for a Discord bot, and
+// starts watching the config file.
+func NewConfigReloader(bot *DiscordBot) (*ConfigReloader, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	// Watch the directory, because many editors replace the file when saving
+	if err := watcher.Add(filepath.Dir(bot.bridge.file.Path())); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	r := &ConfigReloader{
+		bot:     bot,
+		done:    make(chan struct{}),
+		signals: make(chan os.Signal, 1),
+		watcher: watcher,
+	}
+	signal.Notify(r.signals, syscall.SIGHUP)
+	r.watchProperties(config.ServerPropertiesPath(bot.bridge.Config()))
+
+	return r, nil
+}
+
+// Run reloads the config when it changes until the reloader is closed.
+func (r *ConfigReloader) Run() {
+	timer := time.NewTimer(configReloadDelay)
+	timer.Stop()
+	defer timer.Stop()
+
+	path := filepath.Clean(r.bot.bridge.file.Path())
+	for {
+		select {
+		case event, ok := <-r.watcher.Events:
+			if !ok {
+				return
+			}
+			name := filepath.Clean(event.Name)
+			if (name != path && name != r.properties) || event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) == 0 {
+				continue
+			}
+			r.bot.bridge.log.Debugf("Config file changed: %s\n", event)
+			timer.Reset(configReloadDelay)
+		case err, ok := <-r.watcher.Errors:
+			if !ok {
+				return
+			}
+			r.bot.bridge.log.Errorf("Error watching the config file: %s\n", err)
+		case <-timer.C:
+			r.Reload()
+		case <-r.signals:
+			r.bot.bridge.log.Infoln("Got SIGHUP, reloading config")
+			r.Reload()
+		case <-r.done:
+			return
+		}
+	}
+}
+
+// Close stops watching the config file.
+func (r *ConfigReloader) Close() {
+	signal.Stop(r.signals)
+	close(r.done)
+	r.watcher.Close()
+}
+
+// Reload loads the config file and applies any changes to the running bot.
+// If the new config can't be used, the old one is kept.
+func (r *ConfigReloader) Reload() {
+	// Don't reload while a command is changing the config
+	r.bot.bridge.configMu.Lock()
+	defer r.bot.bridge.configMu.Unlock()
+
+	c, err := r.bot.bridge.file.Load()
+	if err != nil {
+		r.bot.bridge.log.Errorf("Error reloading config, keeping the old one: %s\n", err)
+		return
+	}
+	if err := config.Validate(c); err != nil {
+		r.bot.bridge.log.Errorf("Error reloading config, keeping the old one: %s\n", err)
+		return
+	}
+
+	// The config command applies its changes as it saves them, so they
+	// aren't applied again here
+	old := *r.bot.bridge.config()
+	if reflect.DeepEqual(c, old) {
+		r.bot.bridge.log.Debugln("Config file reloaded with no changes")
+		return
+	}
+
+	if err := r.bot.applyConfig(old, c); err != nil {
+		r.bot.bridge.log.Errorf("Error applying the new config, keeping the old one: %s\n", err)
+		return
+	}
+
+	r.watchProperties(config.ServerPropertiesPath(c))
+	r.bot.bridge.log.Goodln("Config reloaded")
+}
+
+// watchProperties starts watching a server.properties file for changes, and
+// stops watching the old one. An empty path stops watching.
+func (r *ConfigReloader) watchProperties(path string) {
+	if path != "" {
+		path = filepath.Clean(path)
+	}
+	if path == r.properties || r.watcher == nil {
+		return
+	}
+
+	// The config file's directory is always watched
+	configDir := filepath.Dir(filepath.Clean(r.bot.bridge.file.Path()))
+	if r.properties != "" && filepath.Dir(r.properties) != configDir {
+		r.watcher.Remove(filepath.Dir(r.properties))
+	}
+
+	r.properties = path
+	if path != "" && filepath.Dir(path) != configDir {
+		// Watch the directory, because the server replaces the file when saving
+		if err := r.watcher.Add(filepath.Dir(path)); err != nil {
+			r.bot.bridge.log.Errorf("Unable to watch '%s' for changes: %s\n", path, err)
+		}
+	}
+}
+
+// applyConfig swaps in a new config and applies the changes from the old one
+// to the running bot. Nothing is changed if the new config can't be used.
+// The config, channel, and console are swapped together, so nothing sees a
+// mix of the old and new settings. The bridge's configMu must be held.
+func (bot *DiscordBot) applyConfig(old config.RootConfig, c config.RootConfig) error {
+	// Check everything that can fail before changing anything
+	if err := bot.bridge.checkRoutes(c.Routes); err != nil {
+		return err
+	}
+	channel := bot.channelID()
+	if c.Discord.ChannelID != old.Discord.ChannelID {
+		snowflake, err := discord.ParseSnowflake(c.Discord.ChannelID)
+		if err != nil {
+			return err
+		}
+		channel = discord.ChannelID(snowflake)
+	}
+
+	console := bot.currentConsole()
+	consoleChanged := !reflect.DeepEqual(c.Discord.Console, old.Discord.Console)
+	if consoleChanged {
+		console = nil
+		if c.Discord.Console.Enabled {
+			var err error
+			if console, err = NewConsole(bot, c.Discord.Console); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Swap in the new config as a whole
+	bot.bridge.mu.Lock()
+	bot.bridge.conf = &c
+	bot.channel = channel
+	oldConsole := bot.console
+	bot.console = console
+	bot.bridge.mu.Unlock()
+
+	if consoleChanged {
+		if oldConsole != nil {
+			oldConsole.Close()
+		}
+		if console != nil {
+			go console.Run()
+		}
+	}
+
+	// Most settings are read when they're used, but the watcher needs to be told
+	custom := []string{}
+	if c.Minecraft.CustomDeathKeywords != nil {
+		custom = *c.Minecraft.CustomDeathKeywords
+	}
+	bot.watcher.Reload(watcherSettings{
+		console:       console,
+		deathKeywords: deathKeywords(custom),
+		logFilePath:   c.Minecraft.LogFilePath,
+	})
+
+	// Some settings can only be changed by restarting
+	if c.Discord.BotToken != old.Discord.BotToken {
+		bot.bridge.log.Warnln("The bot token changed; restart Dolphin to use it")
+	}
+	if c.Minecraft.UseLogFile != old.Minecraft.UseLogFile {
+		bot.bridge.log.Warnln("UseLogFile changed; restart Dolphin to use it")
+	}
+	if c.IRC != old.IRC {
+		bot.bridge.log.Warnln("The IRC settings changed; restart Dolphin to use them")
+	}
+	if c.Storage != old.Storage {
+		bot.bridge.log.Warnln("The database path changed; restart Dolphin to use it")
+	}
+	if c.Discord.Commands.SlashCommands != old.Discord.Commands.SlashCommands {
+		bot.bridge.log.Warnln("Slash commands were turned on or off; restart Dolphin to register or remove them")
+	}
+
+	return nil
+}
@@ -0,0 +1,170 @@
+package dolphin
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/DataDrake/waterlog"
+	"github.com/diamondburned/arikawa/discord"
+	"gitlab.com/EbonJaeger/dolphin/command"
+	"gitlab.com/EbonJaeger/dolphin/config"
+)
+
+// setupReload creates a config file in a temporary directory and a bridge to
+// reload it into. The returned function removes the directory.
+func setupReload(t *testing.T, contents string) (string, *ConfigReloader, func()) {
+	dir, err := ioutil.TempDir("", "dolphin")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %s", err)
+	}
+	path := filepath.Join(dir, "dolphin.conf")
+	file, err := config.NewFile(path)
+	if err != nil {
+		t.Fatalf("Failed to create config file: %s", err)
+	}
+	if err := ioutil.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatalf("Failed to write config file: %s", err)
+	}
+
+	c, err := file.Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %s", err)
+	}
+
+	// The test config isn't valid, so skip NewBridge's checks
+	logger := waterlog.New(ioutil.Discard, "", 0)
+	bridge := &Bridge{conf: &c, file: file, log: logger}
+	bridge.parser = command.NewParser(&c, logger, nil, command.WithConfigStore(configStore{bridge: bridge}), command.WithConfigFile(file))
+
+	bot := &DiscordBot{bridge: bridge, channel: 1234, watcher: NewWatcher("TestBot", []string{}, logger)}
+	bridge.bot = bot
+	r := &ConfigReloader{bot: bot}
+
+	return path, r, func() {
+		os.RemoveAll(dir)
+	}
+}
+
+func TestReloadAppliesChanges(t *testing.T) {
+	// Given
+	path, r, cleanup := setupReload(t, "[Discord]\nChannelID = \"1234\"\n")
+	defer cleanup()
+	logPath := filepath.Join(filepath.Dir(path), "latest.log")
+	ioutil.WriteFile(logPath, []byte{}, 0600)
+	ioutil.WriteFile(path, []byte(fmt.Sprintf("[Discord]\nChannelID = \"5678\"\n[Minecraft]\nRconIP = \"localhost\"\nRconPort = 25575\nTellrawTemplate = '{\"text\": \"%%message%%\"}'\nUseLogFile = true\nLogFilePath = %q\n", logPath)), 0600)
+
+	// When
+	r.Reload()
+
+	// Then
+	if r.bot.channel != discord.ChannelID(5678) {
+		t.Errorf("Reloading did not change the channel, got: %s", r.bot.channel)
+	}
+	if r.bot.bridge.Config().Discord.ChannelID != "5678" {
+		t.Errorf("Reloading did not swap in the new config, got: %s", r.bot.bridge.Config().Discord.ChannelID)
+	}
+	select {
+	case settings := <-r.bot.watcher.reload:
+		if settings.logFilePath != logPath {
+			t.Errorf("Watcher got the wrong log file, got: %s", settings.logFilePath)
+		}
+	default:
+		t.Errorf("Watcher was not told about the new settings")
+	}
+}
+
+func TestUpdateConfigChangesChannel(t *testing.T) {
+	// Given
+	_, r, cleanup := setupReload(t, "[Discord]\nChannelID = \"1234\"\n")
+	defer cleanup()
+
+	// When
+	err := r.bot.bridge.updateConfig(func(c *config.RootConfig) error {
+		c.Discord.ChannelID = "5678"
+		return nil
+	})
+
+	// Then
+	if err != nil {
+		t.Fatalf("Failed to update config: %s", err)
+	}
+	if r.bot.channelID() != discord.ChannelID(5678) || r.bot.bridge.Config().Discord.ChannelID != "5678" {
+		t.Errorf("Updating the config did not change the channel, got: %s", r.bot.channelID())
+	}
+	select {
+	case <-r.bot.watcher.reload:
+	default:
+		t.Errorf("Watcher was not told about the new settings")
+	}
+}
+
+func TestReloadWhileInUse(t *testing.T) {
+	// Given
+	path, r, cleanup := setupReload(t, "[Discord]\nChannelID = \"1234\"\n")
+	defer cleanup()
+	logPath := filepath.Join(filepath.Dir(path), "latest.log")
+	ioutil.WriteFile(logPath, []byte{}, 0600)
+	ioutil.WriteFile(path, []byte(fmt.Sprintf("[Discord]\nChannelID = \"5678\"\n[Minecraft]\nRconIP = \"localhost\"\nRconPort = 25575\nTellrawTemplate = '{\"text\": \"%%message%%\"}'\nUseLogFile = true\nLogFilePath = %q\n", logPath)), 0600)
+
+	// When
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			r.bot.channelID()
+			r.bot.currentConsole()
+			r.bot.bridge.config()
+		}
+	}()
+	r.Reload()
+	<-done
+
+	// Then
+	if r.bot.channelID() != discord.ChannelID(5678) || r.bot.bridge.Config().Discord.ChannelID != "5678" {
+		t.Errorf("Reloading did not swap in the new config and channel, got: %s", r.bot.channelID())
+	}
+}
+
+func TestReloadKeepsOldConfigOnError(t *testing.T) {
+	// Given
+	path, r, cleanup := setupReload(t, "[Discord]\nChannelID = \"1234\"\n")
+	defer cleanup()
+	old := r.bot.bridge.config()
+	ioutil.WriteFile(path, []byte("[Discord]\nChannelID = \"not a channel\"\n"), 0600)
+
+	// When
+	r.Reload()
+
+	// Then
+	if r.bot.bridge.config() != old || r.bot.channel != discord.ChannelID(1234) {
+		t.Errorf("Invalid config should not be applied, got channel: %s", r.bot.channel)
+	}
+}
+
+func TestReloadReadsServerProperties(t *testing.T) {
+	// Given
+	dir, err := ioutil.TempDir("", "dolphin")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	props := filepath.Join(dir, "server.properties")
+	ioutil.WriteFile(props, []byte("enable-rcon=true\nrcon.port=25580\n"), 0600)
+	os.Mkdir(filepath.Join(dir, "logs"), 0750)
+	ioutil.WriteFile(filepath.Join(dir, "logs", "latest.log"), []byte{}, 0600)
+
+	_, r, cleanup := setupReload(t, fmt.Sprintf("[Discord]\nChannelID = \"1234\"\n[Minecraft]\nServerDir = %q\n", dir))
+	defer cleanup()
+	ioutil.WriteFile(props, []byte("enable-rcon=true\nrcon.port=25590\n"), 0600)
+
+	// When
+	r.Reload()
+
+	// Then
+	if r.bot.bridge.Config().Minecraft.RconPort != 25590 {
+		t.Errorf("Reloading did not read the new RCON port, got: %d", r.bot.bridge.Config().Minecraft.RconPort)
+	}
+}
@@ -0,0 +1,270 @@
+package dolphin
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gitlab.com/EbonJaeger/dolphin/config"
+)
+
+// Names of the built-in sources and sinks, as used in the config.
+const (
+	minecraftName = "minecraft"
+	discordName   = "discord"
+)
+
+// attachmentDetail is the Detail of a message from Discord that is only a
+// link to an attachment.
+const attachmentDetail = "attachment"
+
+// Source is somewhere messages come from, such as a chat platform. The
+// Minecraft server and the Discord channel are built in; other sources are
+// added to a bridge with WithSource.
+type Source interface {
+	// Name is the name of the source in the Routes section of the config.
+	Name() string
+	// Listen sends every message from the source to the channel until the
+	// source is closed. Messages from people should have the ChatMessage
+	// type, with their name as the Username.
+	Listen(c chan<- *MinecraftMessage)
+	// Close stops listening for messages.
+	Close() error
+}
+
+// Sink is somewhere messages can be sent, such as the Minecraft server or a
+// chat platform.
+type Sink interface {
+	// Name is the name of the sink in the Routes section of the config.
+	Name() string
+	// Send sends a message to the sink.
+	Send(m *MinecraftMessage) error
+}
+
+// builtinNames are the sources and sinks every bridge can route between,
+// even when they aren't enabled.
+var builtinNames = []string{minecraftName, discordName, ircName}
+
+// checkRoutes checks that every route is from a source of the bridge and to
+// its sinks. Sources and sinks added with WithSource and WithSink can be
+// routed like the built-in ones.
+func (b *Bridge) checkRoutes(routes config.RouteConfig) error {
+	sources := make(map[string]bool)
+	sinks := make(map[string]bool)
+	for _, name := range builtinNames {
+		sources[name] = true
+		sinks[name] = true
+	}
+	for _, source := range b.sources {
+		sources[source.Name()] = true
+	}
+	b.mu.RLock()
+	for name := range b.sinks {
+		sinks[name] = true
+	}
+	b.mu.RUnlock()
+
+	names := make([]string, 0, len(routes))
+	for name := range routes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	problems := make([]config.Problem, 0)
+	for _, source := range names {
+		if !sources[source] {
+			problems = append(problems, config.Problem{
+				Field:   "Routes." + source,
+				Message: fmt.Sprintf("'%s' is not a source of messages", source),
+				Hint:    "use one of " + joinNames(sources),
+			})
+		}
+		for i, target := range routes[source] {
+			if !sinks[target] {
+				problems = append(problems, config.Problem{
+					Field:   fmt.Sprintf("Routes.%s[%d]", source, i),
+					Message: fmt.Sprintf("'%s' is not a place messages can be sent", target),
+					Hint:    "use one of " + joinNames(sinks),
+				})
+			}
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	return &config.ValidationError{Problems: problems}
+}
+
+// joinNames lists a set of names in order, separated by commas.
+func joinNames(set map[string]bool) string {
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// route sends a message from a source to every sink the config routes it
+// to. Each sink gets its own copy of the message, so sinks can't change
+// what the others send.
+func (b *Bridge) route(source string, m *MinecraftMessage) {
+	for _, name := range b.config().Routes.Targets(source) {
+		b.mu.RLock()
+		sink, ok := b.sinks[name]
+		b.mu.RUnlock()
+
+		// Sinks that aren't enabled are skipped
+		if !ok {
+			continue
+		}
+
+		msg := *m
+		if err := sink.Send(&msg); err != nil {
+			b.log.Errorf("Error sending a message from %s to %s: %s\n", source, name, err)
+		}
+	}
+}
+
+// listen routes the messages from a source until it's closed. Like
+// messages from Minecraft, they go through the middleware and are published
+// on the event bus first.
+func (b *Bridge) listen(source Source) {
+	c := make(chan *MinecraftMessage)
+	go func() {
+		source.Listen(c)
+		close(c)
+	}()
+
+	for msg := range c {
+		b.log.Debugf("Received a message from %s: Username='%s', Text='%s'\n", source.Name(), msg.Username, msg.Message)
+		if !b.filterMinecraft(msg) {
+			continue
+		}
+		b.publishMinecraft(source.Name(), msg)
+		b.route(source.Name(), msg)
+	}
+}
+
+// minecraftSink sends messages to the Minecraft server's chat over RCON.
+type minecraftSink struct {
+	bridge *Bridge
+}
+
+// Name gets the name of the sink in the config.
+func (s *minecraftSink) Name() string {
+	return minecraftName
+}
+
+// Send sends a message to Minecraft using the tellraw template. Each line
+// is sent separately, and long lines are split into more messages. Links to
+// attachments are sent whole.
+func (s *minecraftSink) Send(m *MinecraftMessage) error {
+	if m.Detail == attachmentDetail {
+		return s.tellraw(m.Message, m.Username)
+	}
+
+	// Send a separate message for each line
+	for _, line := range strings.Split(m.Message, "\n") {
+		// Split long lines into additional messages
+		runes := []rune(line)
+		for len(runes) > 100 {
+			if err := s.tellraw(string(runes[:100]), m.Username); err != nil {
+				return err
+			}
+			runes = runes[100:]
+		}
+
+		if err := s.tellraw(string(runes), m.Username); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// tellraw sends a line of chat to Minecraft.
+func (s *minecraftSink) tellraw(content, username string) error {
+	command, err := tellrawCommand(s.bridge.config().Minecraft.TellrawTemplate, username, content)
+	if err != nil {
+		return err
+	}
+
+	// Create RCON connection
+	conn, err := s.bridge.connect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// Send the command to Minecraft
+	if _, err := conn.SendCommand(command); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// tellrawCommand formats a tellraw command from the template. The username
+// and message go inside JSON strings in the template, so they are encoded as
+// JSON, and can't change the rest of the text component.
+func tellrawCommand(template, username, content string) (string, error) {
+	name, err := jsonText(username)
+	if err != nil {
+		return "", err
+	}
+	message, err := jsonText(content)
+	if err != nil {
+		return "", err
+	}
+
+	command := fmt.Sprintf("tellraw @a %s", template)
+	command = strings.Replace(command, "%username%", name, -1)
+	command = strings.Replace(command, "%message%", message, -1)
+	return command, nil
+}
+
+// jsonText encodes text to go inside a JSON string, without the quotes
+// around it.
+func jsonText(text string) (string, error) {
+	encoded, err := json.Marshal(text)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded[1 : len(encoded)-1]), nil
+}
+
+// discordSink sends messages to the Discord channel.
+type discordSink struct {
+	bot *DiscordBot
+}
+
+// Name gets the name of the sink in the config.
+func (s *discordSink) Name() string {
+	return discordName
+}
+
+// Send sends a message to the Discord channel, unless that type of message
+// is turned off in the config.
+func (s *discordSink) Send(m *MinecraftMessage) error {
+	options := s.bot.bridge.config().Discord.MessageOptions
+	switch m.Type {
+	case AdvancementMessage:
+		if !options.ShowAdvancements {
+			return nil
+		}
+	case DeathMessage:
+		if !options.ShowDeaths {
+			return nil
+		}
+	case JoinLeaveMessage:
+		if !options.ShowJoinsLeaves {
+			return nil
+		}
+	}
+
+	s.bot.sendToDiscord(m)
+	return nil
+}
@@ -0,0 +1,222 @@
+package dolphin
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DataDrake/waterlog"
+	"github.com/google/go-cmp/cmp"
+	"gitlab.com/EbonJaeger/dolphin/config"
+)
+
+// fakeSink records the messages sent to it.
+type fakeSink struct {
+	name string
+	sent []string
+}
+
+func (s *fakeSink) Name() string {
+	return s.name
+}
+
+func (s *fakeSink) Send(m *MinecraftMessage) error {
+	s.sent = append(s.sent, m.Message)
+	m.Message = "changed by " + s.name
+	return nil
+}
+
+// fakeSource sends a list of messages, then stops.
+type fakeSource struct {
+	messages []*MinecraftMessage
+}
+
+func (s *fakeSource) Name() string {
+	return ircName
+}
+
+func (s *fakeSource) Listen(c chan<- *MinecraftMessage) {
+	for _, m := range s.messages {
+		c <- m
+	}
+}
+
+func (s *fakeSource) Close() error {
+	return nil
+}
+
+// routedBridge creates a bridge with the given routes and fake sinks for
+// Minecraft, Discord, and IRC.
+func routedBridge(routes config.RouteConfig) (*Bridge, map[string]*fakeSink) {
+	c := config.MergeDefaults(config.RootConfig{})
+	c.Routes = routes
+	b := &Bridge{conf: &c, events: NewEventBus(nil), log: waterlog.New(ioutil.Discard, "", 0), sinks: make(map[string]Sink)}
+
+	sinks := make(map[string]*fakeSink)
+	for _, name := range []string{minecraftName, discordName, ircName} {
+		sinks[name] = &fakeSink{name: name}
+		WithSink(sinks[name])(b)
+	}
+	return b, sinks
+}
+
+func TestRouteSendsToConfiguredSinks(t *testing.T) {
+	// Given
+	b, sinks := routedBridge(config.RouteConfig{
+		minecraftName: {discordName, ircName},
+		discordName:   {minecraftName},
+	})
+
+	// When
+	b.route(minecraftName, &MinecraftMessage{Message: "from minecraft"})
+	b.route(discordName, &MinecraftMessage{Message: "from discord"})
+	b.route(ircName, &MinecraftMessage{Message: "from irc"})
+
+	// Then
+	expected := map[string][]string{
+		minecraftName: {"from discord"},
+		discordName:   {"from minecraft"},
+		ircName:       {"from minecraft"},
+	}
+	for name, sink := range sinks {
+		if !cmp.Equal(sink.sent, expected[name]) {
+			t.Errorf("Messages sent to %s are incorrect: Diff: %s", name, cmp.Diff(sink.sent, expected[name]))
+		}
+	}
+}
+
+func TestRouteSkipsDisabledSinks(t *testing.T) {
+	// Given
+	b, sinks := routedBridge(config.RouteConfig{
+		minecraftName: {ircName, discordName},
+	})
+	delete(b.sinks, ircName)
+
+	// When
+	b.route(minecraftName, &MinecraftMessage{Message: "hello"})
+
+	// Then
+	if len(sinks[ircName].sent) != 0 || len(sinks[discordName].sent) != 1 {
+		t.Errorf("Only enabled sinks should get messages, got: irc=%v discord=%v", sinks[ircName].sent, sinks[discordName].sent)
+	}
+}
+
+func TestRouteToAddedSink(t *testing.T) {
+	// Given
+	b, sinks := routedBridge(config.RouteConfig{
+		minecraftName: {"slack"},
+	})
+	slack := &fakeSink{name: "slack"}
+	WithSink(slack)(b)
+
+	// When
+	err := b.checkRoutes(b.conf.Routes)
+	b.route(minecraftName, &MinecraftMessage{Message: "hello"})
+
+	// Then
+	if err != nil {
+		t.Errorf("Routes to added sinks should be allowed, got: %s", err)
+	}
+	if !cmp.Equal(slack.sent, []string{"hello"}) || len(sinks[discordName].sent) != 0 {
+		t.Errorf("Message was not sent to the added sink, got: %v", slack.sent)
+	}
+}
+
+func TestCheckRoutesRejectsUnknownNames(t *testing.T) {
+	// Given
+	b, _ := routedBridge(config.RouteConfig{})
+	routes := config.RouteConfig{
+		minecraftName: {discordName, "slack"},
+		"matrix":      {minecraftName},
+	}
+
+	// When
+	err := b.checkRoutes(routes)
+
+	// Then
+	verr, ok := err.(*config.ValidationError)
+	if !ok {
+		t.Fatalf("Unknown routes should be a validation error, got: %v", err)
+	}
+	actual := make([]string, 0, len(verr.Problems))
+	for _, p := range verr.Problems {
+		actual = append(actual, p.Field)
+	}
+	expected := []string{"Routes.matrix", "Routes.minecraft[1]"}
+	if !cmp.Equal(actual, expected) {
+		t.Errorf("Problems are incorrect: Diff: %s", cmp.Diff(actual, expected))
+	}
+}
+
+func TestListenRoutesSourceMessages(t *testing.T) {
+	// Given
+	b, sinks := routedBridge(config.RouteConfig{
+		ircName: {minecraftName},
+	})
+	source := &fakeSource{messages: []*MinecraftMessage{
+		{Username: "alice", Message: "hi", Type: ChatMessage},
+		{Username: "bob", Message: "hello", Type: ChatMessage},
+	}}
+
+	// When
+	b.listen(source)
+
+	// Then
+	expected := []string{"hi", "hello"}
+	if actual := sinks[minecraftName].sent; !cmp.Equal(actual, expected) {
+		t.Errorf("Messages from the source are incorrect: Diff: %s", cmp.Diff(actual, expected))
+	}
+}
+
+func TestListenRunsMiddlewareAndPublishes(t *testing.T) {
+	// Given
+	b, sinks := routedBridge(config.RouteConfig{
+		ircName: {minecraftName},
+	})
+	WithMinecraftMiddleware(func(m *MinecraftMessage) bool {
+		return m.Username != "spammer"
+	})(b)
+	events := make(chan Event, 10)
+	b.events.Subscribe(func(e Event) { events <- e })
+	source := &fakeSource{messages: []*MinecraftMessage{
+		{Username: "spammer", Message: "buy gold", Type: ChatMessage},
+		{Username: "alice", Message: "hi", Type: ChatMessage},
+	}}
+
+	// When
+	b.listen(source)
+
+	// Then
+	if actual, expected := sinks[minecraftName].sent, []string{"hi"}; !cmp.Equal(actual, expected) {
+		t.Errorf("Middleware should drop messages from the source: Diff: %s", cmp.Diff(actual, expected))
+	}
+	receive(t, events, 1)
+	select {
+	case e := <-events:
+		t.Errorf("Dropped messages should not be published, got: %+v", e.Minecraft)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestTellrawCommandEncodesText(t *testing.T) {
+	// Given
+	template := `[{"color": "white", "text": "<%username%> %message%"}]`
+
+	// When
+	command, err := tellrawCommand(template, `bob", "color": "red`, `say "hi" \ bye`)
+
+	// Then
+	if err != nil {
+		t.Fatalf("Failed to format the command: %s", err)
+	}
+	var component []map[string]string
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(command, "tellraw @a ")), &component); err != nil {
+		t.Fatalf("Command is not valid JSON: %s\n%s", err, command)
+	}
+	expected := []map[string]string{{"color": "white", "text": `<bob", "color": "red> say "hi" \ bye`}}
+	if !cmp.Equal(component, expected) {
+		t.Errorf("Text component is incorrect: Diff: %s", cmp.Diff(component, expected))
+	}
+}
@@ -0,0 +1,103 @@
+package setup
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/diamondburned/arikawa/api"
+	"github.com/diamondburned/arikawa/discord"
+	"github.com/diamondburned/arikawa/state"
+	"gitlab.com/EbonJaeger/dolphin/rcon"
+)
+
+// loginTimeout is how long to wait for Discord to accept a login.
+const loginTimeout = 30 * time.Second
+
+// Checks test settings against the services they are for. Each check
+// returns nil if the settings work.
+type Checks struct {
+	// Token logs in to Discord with a bot token
+	Token func(token string) error
+	// Channel checks that the bot can see a channel
+	Channel func(token string, channelID string) error
+	// Rcon connects and authenticates to a server's RCON
+	Rcon func(host string, port int, password string) error
+	// LogFile checks that a log file can be read
+	LogFile func(path string) error
+}
+
+// DefaultChecks are the checks that connect to the real services.
+func DefaultChecks() Checks {
+	return Checks{
+		Token:   checkToken,
+		Channel: checkChannel,
+		Rcon:    checkRcon,
+		LogFile: checkLogFile,
+	}
+}
+
+// checkToken logs in to the Discord gateway with a bot token.
+func checkToken(token string) error {
+	// Check the token with the API first, because it fails much faster
+	if _, err := api.NewClient("Bot " + token).Me(); err != nil {
+		return fmt.Errorf("Discord didn't accept the token: %s", err)
+	}
+
+	s, err := state.New("Bot " + token)
+	if err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- s.Open()
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("unable to log in to Discord: %s", err)
+		}
+		return s.Close()
+	case <-time.After(loginTimeout):
+		return errors.New("timed out logging in to Discord")
+	}
+}
+
+// checkChannel checks that the bot can see a channel.
+func checkChannel(token string, channelID string) error {
+	snowflake, err := discord.ParseSnowflake(channelID)
+	if err != nil {
+		return fmt.Errorf("'%s' is not a channel ID", channelID)
+	}
+
+	channel, err := api.NewClient("Bot " + token).Channel(discord.ChannelID(snowflake))
+	if err != nil {
+		return fmt.Errorf("the bot can't see channel %s. Make sure the bot is in your server and can read the channel: %s", channelID, err)
+	}
+	if channel.Type != discord.GuildText {
+		return fmt.Errorf("#%s is not a text channel", channel.Name)
+	}
+	return nil
+}
+
+// checkRcon connects and authenticates to a server's RCON.
+func checkRcon(host string, port int, password string) error {
+	client, err := rcon.Connect(host, port, password)
+	if err != nil {
+		return err
+	}
+	return client.Close()
+}
+
+// checkLogFile checks that a log file can be read.
+func checkLogFile(path string) error {
+	file, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return err
+	}
+	return file.Close()
+}
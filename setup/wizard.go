@@ -0,0 +1,335 @@
+package setup
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gitlab.com/EbonJaeger/dolphin/config"
+	"golang.org/x/term"
+)
+
+// ErrAborted is returned when input ends before the wizard is finished.
+var ErrAborted = errors.New("setup was aborted")
+
+// Wizard asks the user for the settings needed to run Dolphin, and checks
+// that each of them works.
+type Wizard struct {
+	in     *bufio.Scanner
+	out    io.Writer
+	checks Checks
+	// overridden checks if a setting is set by the environment
+	overridden func(key string) (config.Override, bool)
+	// readSecret reads a secret without showing it, or is nil if secrets
+	// are read like any other answer
+	readSecret func() (string, error)
+}
+
+// NewWizard creates a wizard for the config in file that reads answers from
+// in and writes prompts to out, checking the answers with checks. If in is a
+// terminal, secrets aren't shown as they're typed.
+func NewWizard(file *config.File, in io.Reader, out io.Writer, checks Checks) *Wizard {
+	w := &Wizard{
+		in:         bufio.NewScanner(in),
+		out:        out,
+		checks:     checks,
+		overridden: file.Overridden,
+	}
+
+	if f, ok := in.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		w.readSecret = func() (string, error) {
+			secret, err := term.ReadPassword(int(f.Fd()))
+			// The user's enter isn't echoed either
+			w.printf("\n")
+			return string(secret), err
+		}
+	}
+
+	return w
+}
+
+// Run asks for each setting, starting from the values in conf, and returns
+// the updated config once it is valid. If the server's server.properties is
+// found, settings that match it are left empty so they are read from it.
+func (w *Wizard) Run(conf config.RootConfig) (config.RootConfig, error) {
+	w.printf("This will set up Dolphin to connect your Discord server and Minecraft server.\n")
+	w.printf("Press enter to keep the value in brackets.\n\n")
+
+	// Discord settings
+	token := conf.Discord.BotToken
+	if !w.isOverridden("Discord bot token", "Discord.BotToken") {
+		answer, err := w.askChecked("Discord bot token", token, true, w.checks.Token)
+		if err != nil {
+			return conf, err
+		}
+		token = answer
+	}
+	conf.Discord.BotToken = token
+
+	if !w.isOverridden("Channel ID", "Discord.ChannelID") {
+		channel, err := w.askChecked("ID of the channel to bridge", conf.Discord.ChannelID, false, func(id string) error {
+			return w.checks.Channel(token, id)
+		})
+		if err != nil {
+			return conf, err
+		}
+		conf.Discord.ChannelID = channel
+	}
+
+	// Find the server's settings
+	w.printf("\n")
+	defaultDir := conf.Minecraft.ServerDir
+	if defaultDir == "" {
+		defaultDir = serverDir(conf.Minecraft.LogFilePath)
+	}
+	dir := defaultDir
+	if !w.isOverridden("Minecraft server directory", "Minecraft.ServerDir") {
+		answer, err := w.ask("Minecraft server directory", defaultDir)
+		if err != nil {
+			return conf, err
+		}
+		dir = answer
+	}
+
+	props, err := config.ReadServerProperties(filepath.Join(dir, "server.properties"))
+	foundProps := err == nil
+	if err != nil {
+		w.printf("Unable to read server.properties, so RCON settings can't be detected: %s\n", err)
+	} else {
+		w.printf("Found server.properties: RCON is %s on port %d\n", enabledText(props.EnableRcon), props.RconPort)
+		if !props.EnableRcon {
+			w.printf("Set enable-rcon=true in server.properties and restart the server, or Dolphin won't be able to send messages to Minecraft.\n")
+			if props.EnableQuery {
+				w.printf("Until then, the server status will be polled with the query protocol on port %d.\n", props.QueryPort)
+			}
+		}
+		conf.Minecraft.RconPort = props.RconPort
+		if props.RconPassword != "" {
+			conf.Minecraft.RconPassword = props.RconPassword
+		}
+	}
+
+	// RCON settings
+	for {
+		if !w.isOverridden("RCON host", "Minecraft.RconIP") {
+			host, err := w.ask("RCON host", conf.Minecraft.RconIP)
+			if err != nil {
+				return conf, err
+			}
+			conf.Minecraft.RconIP = host
+		}
+
+		if !w.isOverridden("RCON port", "Minecraft.RconPort") {
+			port, err := w.askPort("RCON port", conf.Minecraft.RconPort)
+			if err != nil {
+				return conf, err
+			}
+			conf.Minecraft.RconPort = port
+		}
+
+		if !w.isOverridden("RCON password", "Minecraft.RconPassword") {
+			password, err := w.askSecret("RCON password", conf.Minecraft.RconPassword)
+			if err != nil {
+				return conf, err
+			}
+			conf.Minecraft.RconPassword = password
+		}
+
+		host, port, password := conf.Minecraft.RconIP, conf.Minecraft.RconPort, conf.Minecraft.RconPassword
+
+		w.printf("Checking RCON... ")
+		checkErr := w.checks.Rcon(host, port, password)
+		if checkErr == nil {
+			w.printf("ok\n")
+			break
+		}
+		w.printf("failed: %s\n", checkErr)
+
+		retry, err := w.confirm("Try again?")
+		if err != nil {
+			return conf, err
+		}
+		if !retry {
+			break
+		}
+	}
+
+	// Log file
+	defaultLog := filepath.Join(dir, "logs", "latest.log")
+	if conf.Minecraft.LogFilePath != "" && serverDir(conf.Minecraft.LogFilePath) == dir {
+		defaultLog = conf.Minecraft.LogFilePath
+	}
+	if !w.isOverridden("Server log file", "Minecraft.LogFilePath") {
+		logPath, err := w.askChecked("Server log file", defaultLog, false, w.checks.LogFile)
+		if err != nil {
+			return conf, err
+		}
+		conf.Minecraft.LogFilePath = logPath
+	}
+	conf.Minecraft.UseLogFile = true
+
+	if err := config.Validate(conf); err != nil {
+		return conf, err
+	}
+
+	// Leave the settings that match server.properties empty, so they are
+	// read from it and follow any changes to the server
+	if foundProps {
+		conf.Minecraft.ServerDir = dir
+		if conf.Minecraft.RconPort == props.RconPort {
+			conf.Minecraft.RconPort = 0
+		}
+		if conf.Minecraft.RconPassword == props.RconPassword {
+			conf.Minecraft.RconPassword = ""
+		}
+		if conf.Minecraft.LogFilePath == filepath.Join(dir, "logs", "latest.log") {
+			conf.Minecraft.LogFilePath = ""
+		}
+	}
+	return conf, nil
+}
+
+// ask asks for a value, returning the default if nothing is entered.
+func (w *Wizard) ask(prompt string, def string) (string, error) {
+	if def != "" {
+		w.printf("%s [%s]: ", prompt, def)
+	} else {
+		w.printf("%s: ", prompt)
+	}
+
+	if !w.in.Scan() {
+		if err := w.in.Err(); err != nil {
+			return "", err
+		}
+		return "", ErrAborted
+	}
+
+	if answer := strings.TrimSpace(w.in.Text()); answer != "" {
+		return answer, nil
+	}
+	return def, nil
+}
+
+// askSecret asks for a value without showing the default, or the answer if
+// we're reading from a terminal.
+func (w *Wizard) askSecret(prompt string, def string) (string, error) {
+	if def != "" {
+		prompt += " (press enter to keep the current one)"
+	}
+
+	// Keep the default out of the prompt
+	var (
+		answer string
+		err    error
+	)
+	if w.readSecret != nil {
+		w.printf("%s: ", prompt)
+		answer, err = w.readSecret()
+		answer = strings.TrimSpace(answer)
+	} else {
+		answer, err = w.ask(prompt, "")
+	}
+	if err == nil && answer == "" {
+		answer = def
+	}
+	return answer, err
+}
+
+// isOverridden checks if a setting is set by an environment variable. The
+// answer to its question wouldn't be saved, so the user is told and the
+// question is skipped.
+func (w *Wizard) isOverridden(name string, key string) bool {
+	o, ok := w.overridden(key)
+	if !ok || o.Env == "" {
+		return false
+	}
+
+	w.printf("%s is set by the %s environment variable, so it won't be asked for.\n", name, o.Env)
+	return true
+}
+
+// askPort asks for a port number until a valid one is entered.
+func (w *Wizard) askPort(prompt string, def int) (int, error) {
+	for {
+		answer, err := w.ask(prompt, strconv.Itoa(def))
+		if err != nil {
+			return def, err
+		}
+
+		port, err := strconv.Atoi(answer)
+		if err == nil && port > 0 && port < 65536 {
+			return port, nil
+		}
+		w.printf("'%s' is not a port number between 1 and 65535.\n", answer)
+	}
+}
+
+// askChecked asks for a value until it passes a check, or the user chooses
+// to keep it anyway. Secret values aren't shown as the default.
+func (w *Wizard) askChecked(prompt string, def string, secret bool, check func(string) error) (string, error) {
+	for {
+		var (
+			answer string
+			err    error
+		)
+		if secret {
+			answer, err = w.askSecret(prompt, def)
+		} else {
+			answer, err = w.ask(prompt, def)
+		}
+		if err != nil {
+			return def, err
+		}
+		if answer == "" {
+			w.printf("A value is required.\n")
+			continue
+		}
+
+		w.printf("Checking... ")
+		checkErr := check(answer)
+		if checkErr == nil {
+			w.printf("ok\n")
+			return answer, nil
+		}
+		w.printf("failed: %s\n", checkErr)
+
+		retry, err := w.confirm("Try again?")
+		if err != nil {
+			return def, err
+		}
+		if !retry {
+			return answer, nil
+		}
+		def = answer
+	}
+}
+
+// confirm asks a yes or no question, defaulting to yes.
+func (w *Wizard) confirm(prompt string) (bool, error) {
+	answer, err := w.ask(prompt+" (Y/n)", "")
+	if err != nil {
+		return false, err
+	}
+	return !strings.HasPrefix(strings.ToLower(answer), "n"), nil
+}
+
+func (w *Wizard) printf(format string, args ...interface{}) {
+	fmt.Fprintf(w.out, format, args...)
+}
+
+// serverDir guesses the server directory from the path to its log file.
+func serverDir(logPath string) string {
+	return filepath.Dir(filepath.Dir(logPath))
+}
+
+func enabledText(enabled bool) string {
+	if enabled {
+		return "enabled"
+	}
+	return "disabled"
+}
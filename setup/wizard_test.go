@@ -0,0 +1,160 @@
+package setup
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gitlab.com/EbonJaeger/dolphin/config"
+)
+
+// setupServer creates a server directory with a server.properties and log
+// file. The returned function removes the directory.
+func setupServer(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "dolphin-testing")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %s", err)
+	}
+
+	props := "enable-rcon=true\nrcon.port=25580\nrcon.password=hunter2\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "server.properties"), []byte(props), 0600); err != nil {
+		t.Fatalf("Failed to write server.properties: %s", err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "logs"), 0750); err != nil {
+		t.Fatalf("Failed to create logs directory: %s", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "logs", "latest.log"), []byte{}, 0600); err != nil {
+		t.Fatalf("Failed to write log file: %s", err)
+	}
+
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+// newFile creates a config file in a directory.
+func newFile(t *testing.T, dir string) *config.File {
+	file, err := config.NewFile(filepath.Join(dir, "dolphin.conf"))
+	if err != nil {
+		t.Fatalf("Failed to create config file: %s", err)
+	}
+	return file
+}
+
+// fakeChecks accepts one bot token, and the RCON settings from setupServer.
+func fakeChecks() Checks {
+	return Checks{
+		Token: func(token string) error {
+			if token != "good-token" {
+				return errors.New("invalid token")
+			}
+			return nil
+		},
+		Channel: func(token string, channelID string) error {
+			return nil
+		},
+		Rcon: func(host string, port int, password string) error {
+			if host != "localhost" || port != 25580 || password != "hunter2" {
+				return errors.New("unable to authenticate")
+			}
+			return nil
+		},
+		LogFile: checkLogFile,
+	}
+}
+
+func TestWizard(t *testing.T) {
+	// given
+	dir, cleanup := setupServer(t)
+	defer cleanup()
+
+	input := strings.Join([]string{
+		"bad-token",
+		"y",
+		"good-token",
+		"1234567890",
+		dir,
+		"", // RCON host
+		"", // RCON port, from server.properties
+		"", // RCON password, from server.properties
+		"", // log file
+	}, "\n") + "\n"
+	var out bytes.Buffer
+
+	// when
+	conf, err := NewWizard(newFile(t, dir), strings.NewReader(input), &out, fakeChecks()).Run(config.MergeDefaults(config.RootConfig{}))
+
+	// then
+	if err != nil {
+		t.Fatalf("Wizard failed: %s\n%s", err, out.String())
+	}
+	if conf.Discord.BotToken != "good-token" || conf.Discord.ChannelID != "1234567890" {
+		t.Errorf("Discord settings are incorrect: %+v", conf.Discord)
+	}
+	if conf.Minecraft.ServerDir != dir {
+		t.Errorf("Server directory is incorrect, got: %s", conf.Minecraft.ServerDir)
+	}
+	if conf.Minecraft.RconPort != 0 || conf.Minecraft.RconPassword != "" || conf.Minecraft.LogFilePath != "" {
+		t.Errorf("Settings from server.properties should be left empty: %+v", conf.Minecraft)
+	}
+	if strings.Contains(out.String(), "hunter2") {
+		t.Errorf("Passwords should not be shown:\n%s", out.String())
+	}
+}
+
+func TestWizardAborted(t *testing.T) {
+	// given
+	dir, cleanup := setupServer(t)
+	defer cleanup()
+	var out bytes.Buffer
+
+	// when
+	_, err := NewWizard(newFile(t, dir), strings.NewReader("good-token\n"), &out, fakeChecks()).Run(config.MergeDefaults(config.RootConfig{}))
+
+	// then
+	if err != ErrAborted {
+		t.Errorf("Expected the wizard to be aborted, got: %v", err)
+	}
+}
+
+func TestWizardSkipsOverriddenSettings(t *testing.T) {
+	// given
+	dir, cleanup := setupServer(t)
+	defer cleanup()
+
+	input := strings.Join([]string{
+		"1234567890",
+		dir,
+		"", // RCON host
+		"", // RCON port, from server.properties
+		"", // RCON password, from server.properties
+		"", // log file
+	}, "\n") + "\n"
+	var out bytes.Buffer
+	start := config.MergeDefaults(config.RootConfig{})
+	start.Discord.BotToken = "good-token"
+
+	w := NewWizard(newFile(t, dir), strings.NewReader(input), &out, fakeChecks())
+	w.overridden = func(key string) (config.Override, bool) {
+		if key == "Discord.BotToken" {
+			return config.Override{Key: key, Env: "DOLPHIN_DISCORD_BOTTOKEN"}, true
+		}
+		return config.Override{}, false
+	}
+
+	// when
+	conf, err := w.Run(start)
+
+	// then
+	if err != nil {
+		t.Fatalf("Wizard failed: %s\n%s", err, out.String())
+	}
+	if conf.Discord.BotToken != "good-token" || conf.Discord.ChannelID != "1234567890" {
+		t.Errorf("Discord settings are incorrect: %+v", conf.Discord)
+	}
+	if !strings.Contains(out.String(), "DOLPHIN_DISCORD_BOTTOKEN") {
+		t.Errorf("Wizard should say the token is set by the environment:\n%s", out.String())
+	}
+}
@@ -0,0 +1,404 @@
+package dolphin
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/diamondburned/arikawa/api"
+	"github.com/diamondburned/arikawa/discord"
+	"github.com/diamondburned/arikawa/gateway"
+	"github.com/diamondburned/arikawa/utils/json/option"
+	"gitlab.com/EbonJaeger/dolphin/query"
+	"gitlab.com/EbonJaeger/dolphin/rcon"
+)
+
+const (
+	// presenceInterval is the minimum time between presence updates.
+	presenceInterval = 20 * time.Second
+	// topicInterval is the minimum time between channel topic updates.
+	// Discord only allows two topic changes per channel every 10 minutes.
+	topicInterval = 5 * time.Minute
+	// queryTimeout is how long to wait for the server to answer a query.
+	queryTimeout = 10 * time.Second
+)
+
+// ServerStatus is the live status of the Minecraft server.
+type ServerStatus struct {
+	Online     bool
+	Players    int
+	MaxPlayers int
+	StartedAt  time.Time
+	// TPS is the ticks per second of the server, or 0 if unknown.
+	TPS float64
+}
+
+// StatusTracker keeps track of the status of the Minecraft server using
+// messages from the log watcher and by polling the server over RCON, or with
+// the query protocol if only that is enabled. Any changes are shown in the
+// bot's presence and the channel topic.
+type StatusTracker struct {
+	bot          *DiscordBot
+	done         chan struct{}
+	lastPresence string
+	lastTopic    string
+	mu           sync.Mutex
+	// polled is true once we've polled the server, so we don't show a
+	// player count we haven't checked
+	polled   bool
+	presence *throttle
+	status   ServerStatus
+	topic    *throttle
+}
+
+// NewStatusTracker creates a new status tracker for a Discord bot.
+func NewStatusTracker(bot *DiscordBot) *StatusTracker {
+	t := &StatusTracker{
+		bot:  bot,
+		done: make(chan struct{}),
+	}
+	t.presence = newThrottle(presenceInterval, t.updatePresence)
+	t.topic = newThrottle(topicInterval, t.updateTopic)
+	return t
+}
+
+// Run polls the server for its status until the tracker is closed.
+func (t *StatusTracker) Run() {
+	interval := time.Duration(t.bot.bridge.config().Discord.Status.PollInterval) * time.Second
+	if interval <= 0 {
+		interval = time.Minute
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// Show the status once we know it
+	t.poll()
+	t.mu.Lock()
+	t.polled = true
+	t.mu.Unlock()
+	t.changed()
+
+	for {
+		select {
+		case <-ticker.C:
+			t.poll()
+		case <-t.done:
+			return
+		}
+	}
+}
+
+// Close stops polling the server, and cancels any scheduled updates.
+func (t *StatusTracker) Close() {
+	close(t.done)
+	t.presence.Stop()
+	t.topic.Stop()
+}
+
+// Status returns the current status of the server.
+func (t *StatusTracker) Status() ServerStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.status
+}
+
+// HandleMessage updates the server status from a Minecraft message.
+func (t *StatusTracker) HandleMessage(m *MinecraftMessage) {
+	t.mu.Lock()
+	switch m.Type {
+	case JoinLeaveMessage:
+		if m.Detail == "joined" {
+			t.status.Players++
+		} else if t.status.Players > 0 {
+			t.status.Players--
+		}
+	case ServerStartMessage:
+		t.status.Online = true
+		t.status.Players = 0
+		t.status.StartedAt = m.Timestamp
+	case ServerStopMessage:
+		t.status.Online = false
+		t.status.Players = 0
+		t.status.TPS = 0
+	default:
+		t.mu.Unlock()
+		return
+	}
+	t.mu.Unlock()
+
+	t.changed()
+}
+
+// poll gets the current status of the server over RCON. If we can't
+// connect, the server is considered to be offline.
+func (t *StatusTracker) poll() {
+	if props, ok := t.bot.bridge.serverProperties(); ok && !props.EnableRcon {
+		if props.EnableQuery {
+			t.pollQuery(props.QueryPort)
+			return
+		}
+		t.bot.bridge.log.Debugln("Not polling server status because RCON and query are disabled in server.properties")
+		return
+	}
+
+	conn, err := t.bot.bridge.connect()
+	if err != nil {
+		t.bot.bridge.log.Debugf("Unable to poll server status: %s\n", err)
+		t.setOffline()
+		return
+	}
+	defer conn.Close()
+
+	list, err := conn.ListPlayers()
+	if err != nil {
+		t.bot.bridge.log.Warnf("Error getting the player list over RCON: %s\n", err)
+		return
+	}
+
+	// Fix any sessions we missed the start or end of, if we know who is on
+	if len(list.Players) == list.Online {
+		t.bot.bridge.parser.ReconcileSessions(list.Players, time.Now())
+	}
+
+	tps, err := conn.TPS()
+	if err != nil && !errors.Is(err, rcon.ErrUnsupported) {
+		t.bot.bridge.log.Debugf("Error getting the server TPS over RCON: %s\n", err)
+	}
+
+	t.setOnline(list.Online, list.Max, tps)
+}
+
+// pollQuery gets the current status of the server with the query protocol,
+// which is used when RCON is disabled. The query protocol doesn't tell us
+// the TPS, or who is on.
+func (t *StatusTracker) pollQuery(port int) {
+	stat, err := query.Stat(t.bot.bridge.config().Minecraft.RconIP, port, queryTimeout)
+	if err != nil {
+		t.bot.bridge.log.Debugf("Unable to query server status: %s\n", err)
+		t.setOffline()
+		return
+	}
+
+	t.setOnline(stat.Online, stat.Max, 0)
+}
+
+// setOnline updates the status with what we got by polling the server.
+func (t *StatusTracker) setOnline(players int, max int, tps float64) {
+	t.mu.Lock()
+	// We don't know when the server started if we weren't running to see it
+	if !t.status.Online || t.status.StartedAt.IsZero() {
+		t.status.StartedAt = time.Now()
+	}
+	t.status.Online = true
+	t.status.Players = players
+	t.status.MaxPlayers = max
+	t.status.TPS = tps
+	t.mu.Unlock()
+
+	t.changed()
+}
+
+// setOffline marks the server as offline because it couldn't be polled.
+func (t *StatusTracker) setOffline() {
+	t.mu.Lock()
+	t.status = ServerStatus{}
+	t.mu.Unlock()
+	t.changed()
+}
+
+// resetPresence forgets the last presence we set and sets it again. This is
+// needed when we reconnect to Discord, because the presence is cleared.
+func (t *StatusTracker) resetPresence() {
+	t.mu.Lock()
+	t.lastPresence = ""
+	polled := t.polled
+	t.mu.Unlock()
+
+	// The first poll sets the presence if it hasn't happened yet
+	if polled {
+		t.presence.Trigger()
+	}
+}
+
+// changed requests updates to the presence and topic, if enabled. Nothing
+// is shown until the server has been polled, because we don't know how many
+// players are on before then.
+func (t *StatusTracker) changed() {
+	t.mu.Lock()
+	polled := t.polled
+	t.mu.Unlock()
+	if !polled {
+		return
+	}
+
+	conf := t.bot.bridge.config()
+	if conf.Discord.Status.UpdatePresence {
+		t.presence.Trigger()
+	}
+	if conf.Discord.Status.UpdateTopic {
+		t.topic.Trigger()
+	}
+}
+
+// updatePresence sets the bot's Discord presence to the server status.
+func (t *StatusTracker) updatePresence() {
+	status := t.Status()
+	text := presenceText(status, t.bot.bridge.config().Discord.Status.ServerName)
+
+	// Don't bother Discord if nothing changed
+	t.mu.Lock()
+	if text == t.lastPresence {
+		t.mu.Unlock()
+		return
+	}
+	t.lastPresence = text
+	t.mu.Unlock()
+
+	discordStatus := discord.OnlineStatus
+	if !status.Online {
+		discordStatus = discord.DoNotDisturbStatus
+	}
+
+	err := t.bot.state.Gateway.UpdateStatus(gateway.UpdateStatusData{
+		Game: &discord.Activity{
+			Name: text,
+		},
+		Status: discordStatus,
+	})
+	if err != nil {
+		t.bot.bridge.log.Errorf("Unable to update Discord status: %s\n", err)
+	}
+}
+
+// updateTopic sets the topic of the bridge channel to the server status.
+func (t *StatusTracker) updateTopic() {
+	status := t.Status()
+	text := topicText(status, t.bot.bridge.config().Discord.Status.TopicTemplate)
+
+	// Don't bother Discord if nothing changed
+	t.mu.Lock()
+	if text == t.lastTopic {
+		t.mu.Unlock()
+		return
+	}
+	t.lastTopic = text
+	t.mu.Unlock()
+
+	err := t.bot.state.Client.ModifyChannel(t.bot.channelID(), api.ModifyChannelData{
+		Topic: option.NewNullableString(text),
+	})
+	if err != nil {
+		t.bot.bridge.log.Errorf("Unable to update the channel topic: %s\n", err)
+	}
+}
+
+// presenceText formats the server status for the bot's presence.
+func presenceText(status ServerStatus, serverName string) string {
+	if !status.Online {
+		return "Server offline"
+	}
+	return fmt.Sprintf("%d/%d players on %s", status.Players, status.MaxPlayers, serverName)
+}
+
+// topicText formats the server status for the channel topic using the
+// configured template.
+func topicText(status ServerStatus, template string) string {
+	if !status.Online {
+		return "Server offline"
+	}
+
+	tps := "N/A"
+	if status.TPS > 0 {
+		tps = fmt.Sprintf("%.1f", status.TPS)
+	}
+
+	text := strings.Replace(template, "%online%", fmt.Sprint(status.Players), -1)
+	text = strings.Replace(text, "%max%", fmt.Sprint(status.MaxPlayers), -1)
+	text = strings.Replace(text, "%uptime%", formatUptime(time.Since(status.StartedAt)), -1)
+	text = strings.Replace(text, "%tps%", tps, -1)
+
+	return text
+}
+
+// formatUptime formats a duration as days, hours, and minutes.
+func formatUptime(d time.Duration) string {
+	d = d.Round(time.Minute)
+	days := d / (24 * time.Hour)
+	d -= days * 24 * time.Hour
+	hours := d / time.Hour
+	d -= hours * time.Hour
+	minutes := d / time.Minute
+
+	if days > 0 {
+		return fmt.Sprintf("%dd %dh %dm", days, hours, minutes)
+	}
+	if hours > 0 {
+		return fmt.Sprintf("%dh %dm", hours, minutes)
+	}
+	return fmt.Sprintf("%dm", minutes)
+}
+
+// throttle limits how often a function is run. If it's triggered again
+// too soon, a single run is scheduled for when the interval has passed.
+type throttle struct {
+	interval time.Duration
+	last     time.Time
+	mu       sync.Mutex
+	pending  bool
+	run      func()
+	stopped  bool
+	timer    *time.Timer
+}
+
+func newThrottle(interval time.Duration, run func()) *throttle {
+	return &throttle{
+		interval: interval,
+		run:      run,
+	}
+}
+
+// Trigger runs the function now if enough time has passed since the last
+// run, or schedules it for later if not.
+func (t *throttle) Trigger() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// A run is already scheduled, or we've been stopped
+	if t.pending || t.stopped {
+		return
+	}
+
+	wait := t.interval - time.Since(t.last)
+	if wait <= 0 {
+		t.last = time.Now()
+		go t.run()
+		return
+	}
+
+	t.pending = true
+	t.timer = time.AfterFunc(wait, func() {
+		t.mu.Lock()
+		if t.stopped {
+			t.mu.Unlock()
+			return
+		}
+		t.pending = false
+		t.last = time.Now()
+		t.mu.Unlock()
+		t.run()
+	})
+}
+
+// Stop cancels a scheduled run, and ignores any more triggers.
+func (t *throttle) Stop() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.stopped = true
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+}
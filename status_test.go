@@ -0,0 +1,80 @@
+package dolphin
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPresenceTextOnline(t *testing.T) {
+	// Given
+	status := ServerStatus{Online: true, Players: 12, MaxPlayers: 20}
+	// When
+	actual := presenceText(status, "survival")
+	// Then
+	if actual != "12/20 players on survival" {
+		t.Errorf("Formatting presence got incorrect text, got: %s, expected: %s", actual, "12/20 players on survival")
+	}
+}
+
+func TestPresenceTextOffline(t *testing.T) {
+	// When
+	actual := presenceText(ServerStatus{}, "survival")
+	// Then
+	if actual != "Server offline" {
+		t.Errorf("Formatting presence got incorrect text, got: %s, expected: %s", actual, "Server offline")
+	}
+}
+
+func TestTopicText(t *testing.T) {
+	// Given
+	status := ServerStatus{
+		Online:     true,
+		Players:    3,
+		MaxPlayers: 20,
+		StartedAt:  time.Now().Add(-90 * time.Minute),
+		TPS:        19.96,
+	}
+	expected := "3/20 players online | Uptime: 1h 30m | TPS: 20.0"
+	// When
+	actual := topicText(status, "%online%/%max% players online | Uptime: %uptime% | TPS: %tps%")
+	// Then
+	if actual != expected {
+		t.Errorf("Formatting topic got incorrect text, got: %s, expected: %s", actual, expected)
+	}
+}
+
+func TestThrottleCoalescesUpdates(t *testing.T) {
+	// Given
+	var runs int32
+	th := newThrottle(50*time.Millisecond, func() {
+		atomic.AddInt32(&runs, 1)
+	})
+	// When
+	for i := 0; i < 5; i++ {
+		th.Trigger()
+	}
+	time.Sleep(100 * time.Millisecond)
+	// Then
+	if actual := atomic.LoadInt32(&runs); actual != 2 {
+		t.Errorf("Throttle ran an incorrect number of times, got: %d, expected: %d", actual, 2)
+	}
+}
+
+func TestThrottleStopCancelsScheduledRun(t *testing.T) {
+	// Given
+	var runs int32
+	th := newThrottle(50*time.Millisecond, func() {
+		atomic.AddInt32(&runs, 1)
+	})
+	th.Trigger()
+	th.Trigger()
+	// When
+	th.Stop()
+	th.Trigger()
+	time.Sleep(100 * time.Millisecond)
+	// Then
+	if actual := atomic.LoadInt32(&runs); actual != 1 {
+		t.Errorf("Throttle ran an incorrect number of times, got: %d, expected: %d", actual, 1)
+	}
+}
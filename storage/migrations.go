@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"go.etcd.io/bbolt"
+)
+
+// metaBucket holds information about the database itself.
+const metaBucket = "meta"
+
+// versionKey is the key of the schema version in the meta bucket.
+const versionKey = "schema_version"
+
+// migration updates the database schema to a new version.
+type migration struct {
+	Version int
+	Desc    string
+	Migrate func(tx *bbolt.Tx) error
+}
+
+// migrations are all of the schema migrations, in order. Once released, a
+// migration must never be changed; add a new one instead.
+var migrations = []migration{
+	{
+		Version: 1,
+		Desc:    "create buckets for linked accounts and temporary bans",
+		Migrate: createBuckets(LinksBucket, TempBansBucket),
+	},
+	{
+		Version: 2,
+		Desc:    "create bucket for player sessions and playtime",
+		Migrate: createBuckets(PlayersBucket),
+	},
+	{
+		Version: 3,
+		Desc:    "create buckets for session history, deaths, advancements, and bot state",
+		Migrate: createBuckets(SessionsBucket, DeathsBucket, AdvancementsBucket, StateBucket),
+	},
+}
+
+// createBuckets creates a migration that creates buckets.
+func createBuckets(names ...string) func(tx *bbolt.Tx) error {
+	return func(tx *bbolt.Tx) error {
+		for _, name := range names {
+			if _, err := tx.CreateBucketIfNotExists([]byte(name)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// SchemaVersion gets the version of the database schema.
+func (db *DB) SchemaVersion() (int, error) {
+	version := 0
+	err := db.bolt.View(func(tx *bbolt.Tx) error {
+		version = schemaVersion(tx)
+		return nil
+	})
+	return version, err
+}
+
+// migrate runs every migration newer than the database's schema version.
+// Each migration runs in its own transaction, so a failed migration leaves
+// the database at the last good version.
+func (db *DB) migrate(list []migration) error {
+	for _, m := range list {
+		err := db.bolt.Update(func(tx *bbolt.Tx) error {
+			if schemaVersion(tx) >= m.Version {
+				return nil
+			}
+
+			if err := m.Migrate(tx); err != nil {
+				return err
+			}
+
+			return setSchemaVersion(tx, m.Version)
+		})
+		if err != nil {
+			return fmt.Errorf("database migration %d (%s) failed: %s", m.Version, m.Desc, err)
+		}
+	}
+
+	return nil
+}
+
+// schemaVersion reads the schema version in a transaction. A new database
+// has version 0.
+func schemaVersion(tx *bbolt.Tx) int {
+	b := tx.Bucket([]byte(metaBucket))
+	if b == nil {
+		return 0
+	}
+
+	data := b.Get([]byte(versionKey))
+	if len(data) != 8 {
+		return 0
+	}
+
+	return int(binary.BigEndian.Uint64(data))
+}
+
+// setSchemaVersion saves the schema version in a transaction.
+func setSchemaVersion(tx *bbolt.Tx, version int) error {
+	b, err := tx.CreateBucketIfNotExists([]byte(metaBucket))
+	if err != nil {
+		return err
+	}
+
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, uint64(version))
+	return b.Put([]byte(versionKey), data)
+}
@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+// Buckets that data is stored in.
+const (
+	AdvancementsBucket = "advancements"
+	DeathsBucket       = "deaths"
+	LinksBucket        = "links"
+	PlayersBucket      = "players"
+	SessionsBucket     = "sessions"
+	StateBucket        = "state"
+	TempBansBucket     = "tempbans"
+)
+
+// ErrNoBucket is returned when a bucket doesn't exist.
+var ErrNoBucket = errors.New("bucket does not exist")
+
+// Repository stores values as JSON by key in named buckets.
+type Repository interface {
+	// Get decodes the value with the given key into value. The returned
+	// bool is false if there is no such value.
+	Get(bucket string, key string, value interface{}) (bool, error)
+	// Put stores a value, replacing any value with the same key.
+	Put(bucket string, key string, value interface{}) error
+	// Delete removes the value with the given key, if there is one.
+	Delete(bucket string, key string) error
+	// ForEach calls fn with the key and JSON data of every value in a
+	// bucket, in key order. If fn returns an error, it is returned.
+	ForEach(bucket string, fn func(key string, data []byte) error) error
+}
+
+// DB is a Repository backed by an embedded bbolt database file.
+type DB struct {
+	bolt *bbolt.DB
+}
+
+// Open opens the database at the given path, creating it if it doesn't exist,
+// and brings its schema up to date.
+func Open(path string) (*DB, error) {
+	bolt, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("unable to open database: %s", err)
+	}
+
+	db := &DB{bolt: bolt}
+	if err := db.migrate(migrations); err != nil {
+		bolt.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// Close closes the database file.
+func (db *DB) Close() error {
+	return db.bolt.Close()
+}
+
+// Get decodes the value with the given key into value.
+func (db *DB) Get(bucket string, key string, value interface{}) (bool, error) {
+	found := false
+	err := db.bolt.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return ErrNoBucket
+		}
+
+		data := b.Get([]byte(key))
+		if data == nil {
+			return nil
+		}
+
+		found = true
+		return json.Unmarshal(data, value)
+	})
+
+	return found, err
+}
+
+// Put stores a value, replacing any value with the same key.
+func (db *DB) Put(bucket string, key string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	return db.bolt.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return ErrNoBucket
+		}
+		return b.Put([]byte(key), data)
+	})
+}
+
+// Delete removes the value with the given key, if there is one.
+func (db *DB) Delete(bucket string, key string) error {
+	return db.bolt.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return ErrNoBucket
+		}
+		return b.Delete([]byte(key))
+	})
+}
+
+// ForEach calls fn with the key and JSON data of every value in a bucket.
+// The data is only valid until fn returns.
+func (db *DB) ForEach(bucket string, fn func(key string, data []byte) error) error {
+	return db.bolt.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return ErrNoBucket
+		}
+		return b.ForEach(func(k, v []byte) error {
+			return fn(string(k), v)
+		})
+	})
+}
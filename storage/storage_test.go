@@ -0,0 +1,157 @@
+package storage
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"go.etcd.io/bbolt"
+)
+
+type testValue struct {
+	Name  string
+	Count int
+}
+
+// openTestDB opens a database in a new temporary directory. The returned
+// function closes the database and removes the directory.
+func openTestDB(t *testing.T) (string, *DB, func()) {
+	dir, err := ioutil.TempDir("", "dolphin")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %s", err)
+	}
+
+	path := filepath.Join(dir, "dolphin.db")
+	db, err := Open(path)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("Failed to open database: %s", err)
+	}
+
+	return path, db, func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestPutAndGet(t *testing.T) {
+	// given
+	_, db, cleanup := openTestDB(t)
+	defer cleanup()
+	expected := testValue{Name: "Steve", Count: 3}
+
+	// when
+	if err := db.Put(LinksBucket, "steve", expected); err != nil {
+		t.Fatalf("Failed to put value: %s", err)
+	}
+	var actual testValue
+	ok, err := db.Get(LinksBucket, "steve", &actual)
+
+	// then
+	if err != nil || !ok {
+		t.Fatalf("Failed to get value: %t %v", ok, err)
+	}
+	if !cmp.Equal(actual, expected) {
+		t.Errorf("Value is incorrect: Diff: %s", cmp.Diff(actual, expected))
+	}
+}
+
+func TestGetMissing(t *testing.T) {
+	// given
+	_, db, cleanup := openTestDB(t)
+	defer cleanup()
+
+	// when
+	var actual testValue
+	ok, err := db.Get(LinksBucket, "nobody", &actual)
+
+	// then
+	if err != nil || ok {
+		t.Errorf("Missing value should not be found, got: %t %v", ok, err)
+	}
+	if _, err := db.Get("nope", "nobody", &actual); !errors.Is(err, ErrNoBucket) {
+		t.Errorf("Missing bucket should be an error, got: %v", err)
+	}
+}
+
+func TestDeleteAndForEach(t *testing.T) {
+	// given
+	_, db, cleanup := openTestDB(t)
+	defer cleanup()
+	db.Put(TempBansBucket, "a", testValue{Name: "a"})
+	db.Put(TempBansBucket, "b", testValue{Name: "b"})
+	db.Put(TempBansBucket, "c", testValue{Name: "c"})
+
+	// when
+	if err := db.Delete(TempBansBucket, "b"); err != nil {
+		t.Fatalf("Failed to delete value: %s", err)
+	}
+	keys := make([]string, 0)
+	err := db.ForEach(TempBansBucket, func(key string, data []byte) error {
+		keys = append(keys, key)
+		return nil
+	})
+
+	// then
+	if err != nil {
+		t.Fatalf("Failed to list values: %s", err)
+	}
+	if !cmp.Equal(keys, []string{"a", "c"}) {
+		t.Errorf("Listed keys are incorrect: %v", keys)
+	}
+}
+
+func TestMigrationsRunOnce(t *testing.T) {
+	// given
+	path, db, cleanup := openTestDB(t)
+	defer cleanup()
+	db.Close()
+
+	// when
+	reopened, err := Open(path)
+
+	// then
+	if err != nil {
+		t.Fatalf("Failed to reopen database: %s", err)
+	}
+	defer reopened.Close()
+	version, err := reopened.SchemaVersion()
+	if err != nil {
+		t.Fatalf("Failed to get schema version: %s", err)
+	}
+	if version != migrations[len(migrations)-1].Version {
+		t.Errorf("Schema version is incorrect: %d", version)
+	}
+}
+
+func TestFailedMigrationKeepsVersion(t *testing.T) {
+	// given
+	_, db, cleanup := openTestDB(t)
+	defer cleanup()
+	start, _ := db.SchemaVersion()
+	list := []migration{
+		{Version: start + 1, Desc: "works", Migrate: createBuckets("first")},
+		{Version: start + 2, Desc: "fails", Migrate: func(tx *bbolt.Tx) error {
+			tx.CreateBucket([]byte("second"))
+			return errors.New("broken")
+		}},
+	}
+
+	// when
+	err := db.migrate(list)
+
+	// then
+	if err == nil {
+		t.Fatalf("Failed migration should be an error")
+	}
+	version, _ := db.SchemaVersion()
+	if version != start+1 {
+		t.Errorf("Schema version should stay at the last good migration, got: %d", version)
+	}
+	if err := db.Put("second", "key", "value"); !errors.Is(err, ErrNoBucket) {
+		t.Errorf("Failed migration should be rolled back, got: %v", err)
+	}
+}
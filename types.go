@@ -1,18 +1,26 @@
 package dolphin
 
 import (
+	"time"
+
 	"github.com/diamondburned/arikawa/discord"
 	"github.com/diamondburned/arikawa/state"
+	"gitlab.com/EbonJaeger/dolphin/command"
 )
 
 // DiscordBot holds our Discord session info and Minecraft log watcher.
 type DiscordBot struct {
 	avatarURL string
+	bans      *command.BanScheduler
+	bridge    *Bridge
 	channel   discord.ChannelID
+	console   *Console
+	digest    *command.DigestScheduler
 	guildID   discord.GuildID
 	id        discord.UserID
 	name      string
 	state     *state.State
+	status    *StatusTracker
 	watcher   *MinecraftWatcher
 }
 
@@ -32,6 +40,8 @@ const (
 	ChatMessage        MessageType = "Chat"
 	DeathMessage       MessageType = "Death"
 	JoinLeaveMessage   MessageType = "JoinLeave"
+	ServerStartMessage MessageType = "ServerStart"
+	ServerStopMessage  MessageType = "ServerStop"
 )
 
 // MinecraftMessage represents a message from Minecraft to be sent to Discord.
@@ -39,4 +49,11 @@ type MinecraftMessage struct {
 	Username string
 	Message  string
 	Type     MessageType
+
+	// Player is the name of the player the message is about, if any.
+	Player string
+	// Detail is extra information about the event, such as the title of an
+	// advancement, the cause of a death, or whether a player joined or left.
+	Detail    string
+	Timestamp time.Time
 }